@@ -0,0 +1,215 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: api/delegation/v1/delegation.proto
+
+package delegationv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DelegationService_ListDelegations_FullMethodName  = "/tezos.delegation.v1.DelegationService/ListDelegations"
+	DelegationService_GetDelegator_FullMethodName     = "/tezos.delegation.v1.DelegationService/GetDelegator"
+	DelegationService_WatchDelegations_FullMethodName = "/tezos.delegation.v1.DelegationService/WatchDelegations"
+)
+
+// DelegationServiceClient is the client API for DelegationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DelegationService exposes indexed Tezos delegations to internal services.
+type DelegationServiceClient interface {
+	// ListDelegations returns delegations newest first, one page at a time.
+	ListDelegations(ctx context.Context, in *ListDelegationsRequest, opts ...grpc.CallOption) (*ListDelegationsResponse, error)
+	// GetDelegator returns the current baker of an account. It fails with
+	// NOT_FOUND if the account was never seen.
+	GetDelegator(ctx context.Context, in *GetDelegatorRequest, opts ...grpc.CallOption) (*Delegator, error)
+	// WatchDelegations streams delegations matching the request as they are
+	// indexed. Streams that cannot keep up are ended with RESOURCE_EXHAUSTED.
+	WatchDelegations(ctx context.Context, in *WatchDelegationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Delegation], error)
+}
+
+type delegationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDelegationServiceClient(cc grpc.ClientConnInterface) DelegationServiceClient {
+	return &delegationServiceClient{cc}
+}
+
+func (c *delegationServiceClient) ListDelegations(ctx context.Context, in *ListDelegationsRequest, opts ...grpc.CallOption) (*ListDelegationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDelegationsResponse)
+	err := c.cc.Invoke(ctx, DelegationService_ListDelegations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *delegationServiceClient) GetDelegator(ctx context.Context, in *GetDelegatorRequest, opts ...grpc.CallOption) (*Delegator, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Delegator)
+	err := c.cc.Invoke(ctx, DelegationService_GetDelegator_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *delegationServiceClient) WatchDelegations(ctx context.Context, in *WatchDelegationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Delegation], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DelegationService_ServiceDesc.Streams[0], DelegationService_WatchDelegations_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchDelegationsRequest, Delegation]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DelegationService_WatchDelegationsClient = grpc.ServerStreamingClient[Delegation]
+
+// DelegationServiceServer is the server API for DelegationService service.
+// All implementations must embed UnimplementedDelegationServiceServer
+// for forward compatibility.
+//
+// DelegationService exposes indexed Tezos delegations to internal services.
+type DelegationServiceServer interface {
+	// ListDelegations returns delegations newest first, one page at a time.
+	ListDelegations(context.Context, *ListDelegationsRequest) (*ListDelegationsResponse, error)
+	// GetDelegator returns the current baker of an account. It fails with
+	// NOT_FOUND if the account was never seen.
+	GetDelegator(context.Context, *GetDelegatorRequest) (*Delegator, error)
+	// WatchDelegations streams delegations matching the request as they are
+	// indexed. Streams that cannot keep up are ended with RESOURCE_EXHAUSTED.
+	WatchDelegations(*WatchDelegationsRequest, grpc.ServerStreamingServer[Delegation]) error
+	mustEmbedUnimplementedDelegationServiceServer()
+}
+
+// UnimplementedDelegationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDelegationServiceServer struct{}
+
+func (UnimplementedDelegationServiceServer) ListDelegations(context.Context, *ListDelegationsRequest) (*ListDelegationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDelegations not implemented")
+}
+func (UnimplementedDelegationServiceServer) GetDelegator(context.Context, *GetDelegatorRequest) (*Delegator, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDelegator not implemented")
+}
+func (UnimplementedDelegationServiceServer) WatchDelegations(*WatchDelegationsRequest, grpc.ServerStreamingServer[Delegation]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchDelegations not implemented")
+}
+func (UnimplementedDelegationServiceServer) mustEmbedUnimplementedDelegationServiceServer() {}
+func (UnimplementedDelegationServiceServer) testEmbeddedByValue()                           {}
+
+// UnsafeDelegationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DelegationServiceServer will
+// result in compilation errors.
+type UnsafeDelegationServiceServer interface {
+	mustEmbedUnimplementedDelegationServiceServer()
+}
+
+func RegisterDelegationServiceServer(s grpc.ServiceRegistrar, srv DelegationServiceServer) {
+	// If the following call pancis, it indicates UnimplementedDelegationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DelegationService_ServiceDesc, srv)
+}
+
+func _DelegationService_ListDelegations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDelegationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DelegationServiceServer).ListDelegations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DelegationService_ListDelegations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DelegationServiceServer).ListDelegations(ctx, req.(*ListDelegationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DelegationService_GetDelegator_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDelegatorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DelegationServiceServer).GetDelegator(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DelegationService_GetDelegator_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DelegationServiceServer).GetDelegator(ctx, req.(*GetDelegatorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DelegationService_WatchDelegations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchDelegationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DelegationServiceServer).WatchDelegations(m, &grpc.GenericServerStream[WatchDelegationsRequest, Delegation]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DelegationService_WatchDelegationsServer = grpc.ServerStreamingServer[Delegation]
+
+// DelegationService_ServiceDesc is the grpc.ServiceDesc for DelegationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DelegationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tezos.delegation.v1.DelegationService",
+	HandlerType: (*DelegationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDelegations",
+			Handler:    _DelegationService_ListDelegations_Handler,
+		},
+		{
+			MethodName: "GetDelegator",
+			Handler:    _DelegationService_GetDelegator_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchDelegations",
+			Handler:       _DelegationService_WatchDelegations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/delegation/v1/delegation.proto",
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/application"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/postgres"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const apiKeyUsage = "usage: apikey create -name NAME -scopes SCOPE[,SCOPE] | apikey list | apikey revoke -id ID"
+
+// runAPIKey manages API keys from the command line, which is how the first
+// admin key is issued.
+func runAPIKey(args []string) error {
+	if len(args) == 0 {
+		return errors.New(apiKeyUsage)
+	}
+
+	flags := flag.NewFlagSet("apikey "+args[0], flag.ContinueOnError)
+	name := flags.String("name", "", "name of the key, to recognise it")
+	scopes := flags.String("scopes", "", "comma-separated scopes: read:delegations, read:stats, admin")
+	id := flags.String("id", "", "ID of the key to revoke")
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	log, err := logger.New(cfg.Logging.Level, cfg.Logging.Environment)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer log.Sync()
+
+	db, err := postgres.NewConnection(&cfg.Database, log)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if err := postgres.RunMigrations(db, log); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	service := application.NewService(postgres.NewRepository(db, log), nil, &cfg.TzktAPI, log)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	switch args[0] {
+	case "create":
+		var keyScopes []domain.Scope
+		for _, scope := range strings.Split(*scopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				if !domain.Scope(scope).Valid() {
+					return fmt.Errorf("invalid scope %q", scope)
+				}
+				keyScopes = append(keyScopes, domain.Scope(scope))
+			}
+		}
+		if *name == "" || len(keyScopes) == 0 {
+			return errors.New("-name and -scopes are required")
+		}
+
+		key, err := service.CreateAPIKey(ctx, *name, keyScopes)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created API key %s. Store it now, it cannot be shown again:\n%s\n", key.ID, key.Secret)
+
+	case "list":
+		keys, err := service.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tUSES\tREVOKED")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%d\t%t\n", key.ID, key.Name, key.Prefix, key.Scopes, key.UsageCount, key.RevokedAt != nil)
+		}
+		return w.Flush()
+
+	case "revoke":
+		if *id == "" {
+			return errors.New("-id is required")
+		}
+		if err := service.RevokeAPIKey(ctx, *id); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked API key %s\n", *id)
+
+	default:
+		return errors.New(apiKeyUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/backup"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/postgres"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+// runBackup writes the stored delegations to a new archive in the backup
+// store, or lists the archives there. It does not start the indexer.
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	target := flags.String("target", "", "directory or s3://bucket/prefix to write to, instead of BACKUP_TARGET")
+	list := flags.Bool("list", false, "list the archives in the store instead")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cfg, log, err := loadBackupConfig(*target)
+	if err != nil {
+		return err
+	}
+	defer log.Sync()
+
+	store, err := backup.NewStore(&cfg.Backup)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *list {
+		objects, err := store.List(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED")
+		for _, object := range objects {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", object.Name, object.Size, object.ModTime.UTC().Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	}
+
+	db, err := postgres.NewConnection(&cfg.Database, log)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	manager := backup.NewManager(postgres.NewRepository(db, log), store, cfg.Backup.Keep, log)
+	info, err := manager.Backup(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backed up %d delegations to %s%s (%d bytes, sha256 %s)\n",
+		info.Delegations, info.Location, info.Name, info.Size, info.SHA256)
+	return nil
+}
+
+// runRestore restores an archive from the backup store, or a snapshot from a
+// file or URL. Delegations already stored are left as they are, so a
+// snapshot may be restored into a database that is not empty.
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	target := flags.String("target", "", "directory or s3://bucket/prefix to read from, instead of BACKUP_TARGET")
+	name := flags.String("name", backup.Latest, "name of the archive in the store")
+	file := flags.String("file", "", "path or URL of an archive, or of an NDJSON or CSV export, to restore instead of an archive from the store")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cfg, log, err := loadBackupConfig(*target)
+	if err != nil {
+		return err
+	}
+	defer log.Sync()
+
+	store, err := backup.NewStore(&cfg.Backup)
+	if err != nil {
+		return err
+	}
+
+	db, err := postgres.NewConnection(&cfg.Database, log)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if err := postgres.RunMigrations(db, log); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	manager := backup.NewManager(postgres.NewRepository(db, log), store, cfg.Backup.Keep, log)
+	progress := func(delegations int64) {
+		if delegations%100_000 == 0 {
+			log.Infow("Restoring delegations", "read", delegations)
+		}
+	}
+
+	var result *backup.RestoreResult
+	if *file != "" {
+		open, cleanup, fetchErr := backup.FetchSnapshot(ctx, *file)
+		if fetchErr != nil {
+			return fetchErr
+		}
+		defer cleanup()
+		result, err = manager.RestoreFrom(ctx, open, progress)
+		if result != nil {
+			result.Name = *file
+		}
+	} else {
+		result, err = manager.Restore(ctx, *name, progress)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %d of the %d delegations in %s, the others were already stored\n",
+		result.Restored, result.Delegations, result.Name)
+	if result.Checkpoint != nil {
+		fmt.Printf("Indexing resumes after level %d (%s)\n",
+			result.Checkpoint.Level, result.Checkpoint.Timestamp.UTC().Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+func loadBackupConfig(target string) (*config.Config, *logger.Logger, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if target != "" {
+		cfg.Backup.Target = target
+	}
+
+	log, err := logger.New(cfg.Logging.Level, cfg.Logging.Environment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	return cfg, log, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/export"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/postgres"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+// runExport writes the stored delegations to partitioned Parquet files. It
+// only reads the database and does not start the indexer.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := flags.String("out", "exports", "directory to write the Parquet partitions to")
+	partition := flags.String("partition", string(export.PartitionByMonth), "partition layout: year or month")
+	year := flags.Int("year", 0, "only delegations made in this year")
+	cycle := flags.Int64("cycle", -1, "only delegations made in this cycle")
+	baker := flags.String("baker", "", "only delegations to this baker")
+	delegator := flags.String("delegator", "", "only delegations by this account")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	var filter domain.DelegationFilter
+	if *year != 0 {
+		if *year < 2018 || *year > 2100 {
+			return errors.New("year must be between 2018 and 2100")
+		}
+		filter.Year = year
+	}
+	if *cycle >= 0 {
+		filter.Cycle = cycle
+	}
+	filter.Baker = *baker
+	filter.Delegator = *delegator
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	log, err := logger.New(cfg.Logging.Level, cfg.Logging.Environment)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer log.Sync()
+
+	db, err := postgres.NewConnection(&cfg.Database, log)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	writer, err := export.NewParquetWriter(*out, export.Partitioning(*partition))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Infow("Exporting delegations", "out", *out, "partition", *partition)
+
+	repo := postgres.NewRepository(db, log)
+	if err := repo.ExportDelegations(ctx, filter, writer.Write); err != nil {
+		writer.Abort()
+		return fmt.Errorf("failed to export delegations: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	log.Infow("Export complete", "rows", writer.Rows(), "files", len(writer.Files()), "out", *out)
+	return nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,8 +12,13 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/application"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/backup"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/eventbus"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/oidc"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/postgres"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/ratelimit"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/tzkt"
+	grpcServer "github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/interfaces/grpc"
 	httpHandler "github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/interfaces/http"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
@@ -20,6 +26,35 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			fmt.Printf("Export failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		if err := runBackup(os.Args[2:]); err != nil {
+			fmt.Printf("Backup failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := runRestore(os.Args[2:]); err != nil {
+			fmt.Printf("Restore failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if err := runAPIKey(os.Args[2:]); err != nil {
+			fmt.Printf("API key command failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Printf("Failed to load configuration: %v\n", err)
@@ -47,6 +82,17 @@ func main() {
 
 	repo := postgres.NewRepository(db, log)
 
+	if cfg.Outbox.Enabled {
+		publisher, err := eventbus.New(&cfg.Outbox)
+		if err != nil {
+			log.Fatalw("Failed to create outbox publisher", "error", err)
+		}
+		repo.EnableOutbox()
+		relay := application.NewOutboxRelay(repo, publisher, &cfg.Outbox, log)
+		relay.Start()
+		defer relay.Stop()
+	}
+
 	tzktClient := tzkt.NewClient(
 		cfg.TzktAPI.BaseURL,
 		cfg.TzktAPI.RequestTimeout,
@@ -56,16 +102,73 @@ func main() {
 	)
 
 	service := application.NewService(repo, tzktClient, &cfg.TzktAPI, log)
+	service.SetLargeMovementThreshold(cfg.Analytics.LargeMovementThreshold)
+	service.SetHealthConfig(cfg.Health)
+	service.SetJobConfig(cfg.Jobs)
+
+	backupStore, err := backup.NewStore(&cfg.Backup)
+	if err != nil {
+		log.Fatalw("Failed to create backup store", "error", err)
+	}
+	service.SetBackupManager(backup.NewManager(repo, backupStore, cfg.Backup.Keep, log))
+	service.SetSnapshot(cfg.Snapshot)
+	service.SetWebhookConfig(cfg.Webhooks)
+	if cfg.Snapshot.Source != "" && !cfg.TzktAPI.HistoricalIndexing {
+		log.Warnw("SNAPSHOT_SOURCE is ignored: snapshots are imported by historical indexing, which is disabled")
+	}
 
 	// Initialize metrics with existing data
 	initializeMetrics(repo, log)
 
+	jobRunner, err := application.NewJobRunner(service, &cfg.Jobs, log)
+	if err != nil {
+		log.Fatalw("Failed to create job runner", "error", err)
+	}
+	jobRunner.Start()
+	defer jobRunner.Stop()
+
+	usageFlusher := application.NewAPIKeyUsageFlusher(service, cfg.Auth.APIKeyUsageFlush, log)
+	usageFlusher.Start()
+	defer usageFlusher.Stop()
+
 	if err := service.StartPolling(); err != nil {
 		log.Fatalw("Failed to start polling", "error", err)
 	}
 	defer service.StopPolling()
 
-	router := httpHandler.NewRouter(service, log)
+	if cfg.Webhooks.Enabled {
+		dispatcher := application.NewWebhookDispatcher(repo, &cfg.Webhooks, log)
+		dispatcher.Start()
+		defer dispatcher.Stop()
+	}
+
+	routerConfig := httpHandler.RouterConfig{
+		TrustedProxies: cfg.Server.TrustedProxies,
+		RateLimit:      cfg.RateLimit,
+		RequestTimeout: cfg.Server.RequestTimeout,
+		RouteTimeouts:  cfg.Server.RouteTimeouts,
+		Auth:           cfg.Auth,
+		CORS:           cfg.CORS,
+	}
+	if cfg.RateLimit.Enabled {
+		routerConfig.RateLimiter, err = ratelimit.New(&cfg.RateLimit, db, log)
+		if err != nil {
+			log.Fatalw("Failed to create rate limiter", "error", err)
+		}
+		log.Infow("Rate limiting enabled", "backend", cfg.RateLimit.Backend)
+	}
+	if cfg.Auth.JWT.Enabled() {
+		routerConfig.TokenAuthenticator, err = oidc.New(&cfg.Auth.JWT, log)
+		if err != nil {
+			log.Fatalw("Failed to create JWT verifier", "error", err)
+		}
+		log.Infow("JWT authentication enabled", "issuer", cfg.Auth.JWT.Issuer)
+	}
+
+	router, err := httpHandler.NewRouter(service, routerConfig, log)
+	if err != nil {
+		log.Fatalw("Failed to create router", "error", err)
+	}
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
@@ -90,6 +193,21 @@ func main() {
 		}()
 	}
 
+	var rpc *grpcServer.Server
+	if cfg.GRPC.Enabled {
+		lis, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+		if err != nil {
+			log.Fatalw("Failed to listen for gRPC", "error", err)
+		}
+		rpc = grpcServer.NewServer(service, cfg.Auth, log)
+		go func() {
+			log.Infow("Starting gRPC server", "port", cfg.GRPC.Port)
+			if err := rpc.Serve(lis); err != nil {
+				log.Fatalw("Failed to start gRPC server", "error", err)
+			}
+		}()
+	}
+
 	go func() {
 		log.Infow("Starting HTTP server", "port", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -110,25 +228,32 @@ func main() {
 		log.Errorw("Server forced to shutdown", "error", err)
 	}
 
+	if rpc != nil {
+		rpc.Stop(ctx)
+	}
+
 	log.Info("Server shutdown complete")
 }
 
 func initializeMetrics(repo *postgres.Repository, log *logger.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
 	// Get total count of delegations from database
-	delegations, err := repo.FindAll(nil)
+	count, err := repo.CountDelegations(ctx, nil)
 	if err != nil {
 		log.Errorw("Failed to get delegation count for metrics", "error", err)
 		return
 	}
 
 	// Initialize the counter with the existing count
-	if len(delegations) > 0 {
-		metrics.DelegationsStored.Add(float64(len(delegations)))
-		log.Infow("Initialized metrics", "existing_delegations", len(delegations))
+	if count > 0 {
+		metrics.DelegationsStored.Add(float64(count))
+		log.Infow("Initialized metrics", "existing_delegations", count)
 	}
 
 	// Get last indexed level
-	lastLevel, err := repo.GetLastIndexedLevel()
+	lastLevel, err := repo.GetLastIndexedLevel(ctx)
 	if err == nil {
 		metrics.UpdateLastIndexedLevel(lastLevel)
 	}
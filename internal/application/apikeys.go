@@ -0,0 +1,250 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const (
+	// APIKeyPrefix starts every API key, so that leaked keys are easy to
+	// scan for and to tell from other bearer tokens.
+	APIKeyPrefix = "tzd_"
+
+	apiKeySecretBytes = 32
+	// apiKeyShownPrefix is how much of a key is kept in clear to tell keys
+	// apart.
+	apiKeyShownPrefix = len(APIKeyPrefix) + 8
+)
+
+func (s *Service) apiKeyRepository() (domain.APIKeyRepository, error) {
+	repo, ok := s.repo.(domain.APIKeyRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support API keys")
+	}
+	return repo, nil
+}
+
+// newAPIKeySecret returns a random key and the hash it is stored by. Keys
+// carry 256 random bits, so an unsalted SHA-256 is enough to protect them.
+func newAPIKeySecret() (string, []byte, error) {
+	random := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return secret, hashAPIKey(secret), nil
+}
+
+func hashAPIKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// CreateAPIKey issues a key with the given scopes. Its secret is returned
+// only here.
+func (s *Service) CreateAPIKey(ctx context.Context, name string, scopes []domain.Scope) (*domain.APIKey, error) {
+	repo, err := s.apiKeyRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, hash, err := newAPIKeySecret()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &domain.APIKey{
+		Name:   name,
+		Prefix: secret[:apiKeyShownPrefix],
+		Scopes: scopes,
+	}
+	if err := repo.CreateAPIKey(ctx, key, hash); err != nil {
+		return nil, err
+	}
+	key.Secret = secret
+
+	s.logger.Infow("API key created", "api_key_id", key.ID, "name", name, "scopes", scopes)
+	return key, nil
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	repo, err := s.apiKeyRepository()
+	if err != nil {
+		return nil, err
+	}
+	return repo.ListAPIKeys(ctx)
+}
+
+// RotateAPIKey gives a key a new secret, returned only here. The previous
+// secret stops working at once.
+func (s *Service) RotateAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	repo, err := s.apiKeyRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, hash, err := newAPIKeySecret()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := repo.RotateAPIKey(ctx, id, secret[:apiKeyShownPrefix], hash)
+	if err != nil {
+		return nil, err
+	}
+	key.Secret = secret
+
+	s.logger.Infow("API key rotated", "api_key_id", id)
+	return key, nil
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, id string) error {
+	repo, err := s.apiKeyRepository()
+	if err != nil {
+		return err
+	}
+
+	if err := repo.RevokeAPIKey(ctx, id); err != nil {
+		return err
+	}
+
+	s.logger.Infow("API key revoked", "api_key_id", id)
+	return nil
+}
+
+// AuthenticateAPIKey returns the principal a key stands for and counts a
+// use of the key. Uses are kept in memory until FlushAPIKeyUsage.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, secret string) (*domain.Principal, error) {
+	repo, err := s.apiKeyRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := repo.FindAPIKey(ctx, hashAPIKey(secret))
+	if errors.Is(err, domain.ErrAPIKeyNotFound) {
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.apiKeyUsage.add(key.ID, domain.APIKeyUsage{Count: 1, LastUsedAt: time.Now()})
+	return &domain.Principal{Subject: "api_key:" + key.ID, Scopes: key.Scopes}, nil
+}
+
+// FlushAPIKeyUsage writes the uses of API keys counted since the last
+// flush. Uses that could not be written are kept for the next one.
+func (s *Service) FlushAPIKeyUsage(ctx context.Context) error {
+	usage := s.apiKeyUsage.take()
+	if len(usage) == 0 {
+		return nil
+	}
+
+	repo, err := s.apiKeyRepository()
+	if err != nil {
+		return err
+	}
+	if err := repo.RecordAPIKeyUsage(ctx, usage); err != nil {
+		for id, u := range usage {
+			s.apiKeyUsage.add(id, u)
+		}
+		return err
+	}
+	return nil
+}
+
+// apiKeyUsage counts the uses of API keys by key ID.
+type apiKeyUsage struct {
+	mu      sync.Mutex
+	pending map[string]domain.APIKeyUsage
+}
+
+func (u *apiKeyUsage) add(id string, usage domain.APIKeyUsage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.pending == nil {
+		u.pending = make(map[string]domain.APIKeyUsage)
+	}
+	total := u.pending[id]
+	total.Count += usage.Count
+	if usage.LastUsedAt.After(total.LastUsedAt) {
+		total.LastUsedAt = usage.LastUsedAt
+	}
+	u.pending[id] = total
+}
+
+func (u *apiKeyUsage) take() map[string]domain.APIKeyUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	pending := u.pending
+	u.pending = nil
+	return pending
+}
+
+// APIKeyUsageFlusher calls FlushAPIKeyUsage periodically, and a last time
+// when stopped.
+type APIKeyUsageFlusher struct {
+	service  *Service
+	interval time.Duration
+	logger   *logger.Logger
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func NewAPIKeyUsageFlusher(service *Service, interval time.Duration, logger *logger.Logger) *APIKeyUsageFlusher {
+	return &APIKeyUsageFlusher{
+		service:  service,
+		interval: interval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (f *APIKeyUsageFlusher) Start() {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				f.flush()
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for the flush under way, then flushes the remaining uses.
+func (f *APIKeyUsageFlusher) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stop)
+		f.wg.Wait()
+		f.flush()
+	})
+}
+
+func (f *APIKeyUsageFlusher) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := f.service.FlushAPIKeyUsage(ctx); err != nil {
+		f.logger.Errorw("Failed to record API key usage", "error", err)
+	}
+}
@@ -0,0 +1,145 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAPIKeyRepository struct {
+	MockRepository
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey, hash []byte) error {
+	args := m.Called(key, hash)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	args := m.Called()
+	return args.Get(0).([]domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RotateAPIKey(ctx context.Context, id, prefix string, hash []byte) (*domain.APIKey, error) {
+	args := m.Called(id, prefix, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) FindAPIKey(ctx context.Context, hash []byte) (*domain.APIKey, error) {
+	args := m.Called(hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RecordAPIKeyUsage(ctx context.Context, usage map[string]domain.APIKeyUsage) error {
+	args := m.Called(usage)
+	return args.Error(0)
+}
+
+func TestService_CreateAndAuthenticateAPIKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	var storedHash []byte
+	mockRepo.On("CreateAPIKey", mock.MatchedBy(func(k *domain.APIKey) bool {
+		return k.Name == "dashboard" && k.Secret == "" && strings.HasPrefix(k.Prefix, APIKeyPrefix)
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.APIKey).ID = "key-1"
+		storedHash = args.Get(1).([]byte)
+	}).Return(nil)
+
+	key, err := service.CreateAPIKey(context.Background(), "dashboard", []domain.Scope{domain.ScopeReadStats})
+	require.NoError(t, err)
+	assert.Equal(t, "key-1", key.ID)
+	assert.True(t, strings.HasPrefix(key.Secret, key.Prefix))
+	assert.NotContains(t, string(storedHash), key.Secret)
+
+	mockRepo.On("FindAPIKey", storedHash).Return(&domain.APIKey{ID: "key-1", Scopes: key.Scopes}, nil)
+	mockRepo.On("FindAPIKey", mock.Anything).Return(nil, domain.ErrAPIKeyNotFound)
+
+	principal, err := service.AuthenticateAPIKey(context.Background(), key.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, "api_key:key-1", principal.Subject)
+	assert.True(t, principal.HasScope(domain.ScopeReadStats))
+	assert.False(t, principal.HasScope(domain.ScopeReadDelegations))
+
+	_, err = service.AuthenticateAPIKey(context.Background(), key.Secret+"x")
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+}
+
+func TestService_FlushAPIKeyUsage(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	mockRepo.On("FindAPIKey", hashAPIKey("tzd_one")).Return(&domain.APIKey{ID: "key-1"}, nil)
+	mockRepo.On("FindAPIKey", hashAPIKey("tzd_two")).Return(&domain.APIKey{ID: "key-2"}, nil)
+	for _, secret := range []string{"tzd_one", "tzd_one", "tzd_two"} {
+		_, err := service.AuthenticateAPIKey(context.Background(), secret)
+		require.NoError(t, err)
+	}
+
+	counts := func(usage map[string]domain.APIKeyUsage) map[string]int64 {
+		c := make(map[string]int64, len(usage))
+		for id, u := range usage {
+			c[id] = u.Count
+		}
+		return c
+	}
+
+	var recorded map[string]domain.APIKeyUsage
+	record := func(args mock.Arguments) {
+		recorded = args.Get(0).(map[string]domain.APIKeyUsage)
+	}
+
+	// A failed flush keeps the uses for the next one.
+	mockRepo.On("RecordAPIKeyUsage", mock.Anything).Run(record).Return(errors.New("connection refused")).Once()
+	require.Error(t, service.FlushAPIKeyUsage(context.Background()))
+	assert.Equal(t, map[string]int64{"key-1": 2, "key-2": 1}, counts(recorded))
+
+	_, err := service.AuthenticateAPIKey(context.Background(), "tzd_two")
+	require.NoError(t, err)
+	mockRepo.On("RecordAPIKeyUsage", mock.Anything).Run(record).Return(nil).Once()
+	require.NoError(t, service.FlushAPIKeyUsage(context.Background()))
+	assert.Equal(t, map[string]int64{"key-1": 2, "key-2": 2}, counts(recorded))
+	assert.False(t, recorded["key-1"].LastUsedAt.IsZero())
+
+	// Nothing is written when no key was used.
+	require.NoError(t, service.FlushAPIKeyUsage(context.Background()))
+	mockRepo.AssertNumberOfCalls(t, "RecordAPIKeyUsage", 2)
+}
+
+func TestService_RotateAPIKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	mockRepo.On("RotateAPIKey", "key-1", mock.Anything, mock.Anything).Return(&domain.APIKey{ID: "key-1"}, nil)
+	mockRepo.On("RotateAPIKey", "missing", mock.Anything, mock.Anything).Return(nil, domain.ErrAPIKeyNotFound)
+
+	key, err := service.RotateAPIKey(context.Background(), "key-1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key.Secret, APIKeyPrefix))
+
+	_, err = service.RotateAPIKey(context.Background(), "missing")
+	assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+}
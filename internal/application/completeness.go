@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// backfillWatermarkTTL is how long the progress of historical indexing,
+// read from the job store, is reused. It is looked up on every API request.
+const backfillWatermarkTTL = 5 * time.Second
+
+// backfillWatermark caches how far historical indexing has stored
+// delegations. The job may run on another replica, so it is read from the
+// job store rather than tracked in memory.
+type backfillWatermark struct {
+	mu        sync.Mutex
+	level     int64
+	timestamp *time.Time
+	checkedAt time.Time
+}
+
+// DataCompleteness reports whether historical delegations are still being
+// indexed and up to which block every delegation is stored. The watermark
+// is the chain head polling last caught up with or, before that, the last
+// block historical indexing stored delegations of.
+func (s *Service) DataCompleteness(ctx context.Context) domain.DataCompleteness {
+	data := domain.DataCompleteness{State: domain.DataLive}
+
+	head, synced := s.progress.snapshot()
+	if head != nil {
+		data.HeadLevel = head.Level
+	}
+	switch {
+	case s.backfillFailed.Load():
+		data.State = domain.DataIncomplete
+	case s.backfilling.Load():
+		data.State = domain.DataBackfilling
+	case s.IndexerHealth().Status != domain.HealthUp:
+		data.State = domain.DataSyncing
+	}
+
+	if synced != nil {
+		timestamp := synced.Timestamp
+		data.CompleteThroughLevel = synced.Level
+		data.CompleteThrough = &timestamp
+		return data
+	}
+	data.CompleteThroughLevel, data.CompleteThrough = s.backfillProgress(ctx)
+	return data
+}
+
+// backfillProgress returns the level and time of the last block historical
+// indexing stored delegations of, if any. A newer job resumes from the last
+// delegation stored, so the latest job that stored one has the watermark.
+func (s *Service) backfillProgress(ctx context.Context) (int64, *time.Time) {
+	w := &s.backfill
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if time.Since(w.checkedAt) < backfillWatermarkTTL {
+		return w.level, w.timestamp
+	}
+
+	jobs, err := s.jobs.ListJobs(ctx, domain.JobQuery{Kind: domain.JobIndexHistorical, Limit: 10})
+	if err != nil {
+		s.logger.Warnw("Failed to read historical indexing progress", "error", err)
+		return w.level, w.timestamp
+	}
+	w.checkedAt = time.Now()
+	for _, job := range jobs {
+		if job.Progress.Timestamp != nil {
+			w.level, w.timestamp = job.Progress.Level, job.Progress.Timestamp
+			break
+		}
+	}
+	return w.level, w.timestamp
+}
@@ -0,0 +1,208 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/tzkt"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
+)
+
+const (
+	checkDatabase   = "database"
+	checkMigrations = "migrations"
+	checkTzkt       = "tzkt"
+	checkBackfill   = "backfill"
+)
+
+var defaultHealthConfig = config.Health{
+	CheckTimeout:        2 * time.Second,
+	IndexerMaxLagBlocks: 10,
+	IndexerMaxLag:       2 * time.Minute,
+}
+
+// indexerProgress records how far polling has caught up with the chain
+// head. The indexer is synced up to a head once a poll that started after
+// that head was observed found no more delegations to fetch.
+type indexerProgress struct {
+	mu     sync.RWMutex
+	head   *tzkt.HeadResponse
+	synced *tzkt.HeadResponse
+}
+
+func (p *indexerProgress) observeHead(head *tzkt.HeadResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.head = head
+	metrics.ChainHeadLevel.Set(float64(head.Level))
+}
+
+func (p *indexerProgress) markSynced(head *tzkt.HeadResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.synced = head
+	metrics.IndexerSyncedLevel.Set(float64(head.Level))
+}
+
+func (p *indexerProgress) snapshot() (head, synced *tzkt.HeadResponse) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.head, p.synced
+}
+
+// SetHealthConfig sets the check timeout and indexer lag thresholds.
+func (s *Service) SetHealthConfig(cfg config.Health) {
+	s.healthConfig = cfg
+}
+
+// CheckReadiness checks the database, its schema version and the last known
+// state of TzKT, and reports how complete the stored delegations are. The
+// service is down when the database is unusable. TzKT being unreachable or
+// historical indexing being under way only degrades it: stored delegations
+// can still be served.
+func (s *Service) CheckReadiness(ctx context.Context) domain.HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, s.healthConfig.CheckTimeout)
+	defer cancel()
+
+	report := domain.HealthReport{
+		Status: domain.HealthUp,
+		Checks: make(map[string]domain.DependencyHealth),
+	}
+
+	checker, ok := s.repo.(domain.HealthRepository)
+	if ok {
+		report.Checks[checkDatabase] = s.checkDatabase(ctx, checker)
+		report.Checks[checkMigrations] = s.checkMigrations(ctx, checker)
+	} else {
+		unsupported := domain.DependencyHealth{Status: domain.HealthDown, Detail: "health checks not supported"}
+		report.Checks[checkDatabase] = unsupported
+		report.Checks[checkMigrations] = unsupported
+	}
+	if s.tzktClient != nil {
+		report.Checks[checkTzkt] = checkTzktReachability(s.tzktClient.Reachability())
+	}
+
+	data := s.DataCompleteness(ctx)
+	report.Data = &data
+	if data.State == domain.DataBackfilling || data.State == domain.DataIncomplete {
+		check := domain.DependencyHealth{Status: domain.HealthDegraded, Detail: "indexing historical delegations"}
+		if data.State == domain.DataIncomplete {
+			check.Detail = "historical indexing failed"
+		}
+		if data.CompleteThrough != nil {
+			check.Detail += ", complete through " + data.CompleteThrough.UTC().Format(time.RFC3339)
+		}
+		report.Checks[checkBackfill] = check
+	}
+
+	for name, check := range report.Checks {
+		switch {
+		case check.Status == domain.HealthUp:
+		case name == checkTzkt || name == checkBackfill:
+			if report.Status == domain.HealthUp {
+				report.Status = domain.HealthDegraded
+			}
+		default:
+			report.Status = domain.HealthDown
+		}
+	}
+
+	return report
+}
+
+func (s *Service) checkDatabase(ctx context.Context, checker domain.HealthRepository) domain.DependencyHealth {
+	start := time.Now()
+	err := checker.Ping(ctx)
+	check := domain.DependencyHealth{Status: domain.HealthUp, LatencyMS: millis(time.Since(start)), CheckedAt: &start}
+
+	if err != nil {
+		s.logger.Warnw("Database health check failed", "error", err)
+		check.Status = domain.HealthDown
+		check.Detail = "ping failed"
+	}
+	return check
+}
+
+func (s *Service) checkMigrations(ctx context.Context, checker domain.HealthRepository) domain.DependencyHealth {
+	start := time.Now()
+	status, err := checker.MigrationStatus(ctx)
+	check := domain.DependencyHealth{Status: domain.HealthUp, LatencyMS: millis(time.Since(start)), CheckedAt: &start}
+
+	switch {
+	case err != nil:
+		s.logger.Warnw("Migration health check failed", "error", err)
+		check.Status = domain.HealthDown
+		check.Detail = "schema version unavailable"
+	case status.Applied < status.Expected:
+		check.Status = domain.HealthDown
+		check.Detail = fmt.Sprintf("schema version %d, expected %d", status.Applied, status.Expected)
+	default:
+		check.Detail = fmt.Sprintf("schema version %d", status.Applied)
+	}
+	return check
+}
+
+// checkTzktReachability reports the outcome of the last TzKT request made
+// while indexing instead of calling TzKT on every probe.
+func checkTzktReachability(state tzkt.Reachability) domain.DependencyHealth {
+	check := domain.DependencyHealth{Status: domain.HealthUp, LatencyMS: millis(state.Latency)}
+	if !state.CheckedAt.IsZero() {
+		check.CheckedAt = &state.CheckedAt
+	}
+
+	switch {
+	case state.CheckedAt.IsZero():
+		check.Status = domain.HealthDegraded
+		check.Detail = "no request made yet"
+	case state.Err != nil && state.LastSuccess.IsZero():
+		check.Status = domain.HealthDown
+		check.Detail = "unreachable"
+	case state.Err != nil:
+		check.Status = domain.HealthDown
+		check.Detail = "unreachable since " + state.LastSuccess.UTC().Format(time.RFC3339)
+	}
+	return check
+}
+
+// IndexerHealth reports how far the indexer is behind the chain head.
+func (s *Service) IndexerHealth() domain.IndexerHealth {
+	health := domain.IndexerHealth{
+		Status:        domain.HealthUp,
+		MaxLagBlocks:  s.healthConfig.IndexerMaxLagBlocks,
+		MaxLagSeconds: s.healthConfig.IndexerMaxLag.Seconds(),
+	}
+
+	head, synced := s.progress.snapshot()
+	if head != nil {
+		health.HeadLevel = head.Level
+	}
+	if synced == nil {
+		health.Status = domain.HealthDegraded
+		health.Detail = "indexer has not caught up with the chain head yet"
+		return health
+	}
+
+	syncedAt := synced.Timestamp
+	health.SyncedLevel = synced.Level
+	health.LastSyncedAt = &syncedAt
+	health.LagBlocks = max(health.HeadLevel-synced.Level, 0)
+	health.LagSeconds = max(time.Since(syncedAt).Seconds(), 0)
+
+	switch {
+	case health.LagBlocks > health.MaxLagBlocks:
+		health.Status = domain.HealthDegraded
+		health.Detail = fmt.Sprintf("indexer is %d blocks behind the chain head", health.LagBlocks)
+	case health.LagSeconds > health.MaxLagSeconds:
+		health.Status = domain.HealthDegraded
+		health.Detail = fmt.Sprintf("indexer has not caught up with the chain head for %s", time.Duration(health.LagSeconds*float64(time.Second)).Round(time.Second))
+	}
+	return health
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,217 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/tzkt"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockHealthRepository struct {
+	MockRepository
+}
+
+func (m *MockHealthRepository) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockHealthRepository) MigrationStatus(ctx context.Context) (domain.MigrationStatus, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(domain.MigrationStatus), args.Error(1)
+}
+
+// fakeTzkt serves the chain head and, while delegationsFail is unset, an
+// empty delegation list.
+type fakeTzkt struct {
+	head            atomic.Int64
+	delegationsFail atomic.Bool
+}
+
+func (f *fakeTzkt) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/head":
+		json.NewEncoder(w).Encode(tzkt.HeadResponse{Level: f.head.Load(), Timestamp: time.Now()})
+	case "/v1/operations/delegations":
+		if f.delegationsFail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode([]tzkt.DelegationResponse{})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestService_CheckReadiness(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+
+	testCases := []struct {
+		name       string
+		pingErr    error
+		migrations domain.MigrationStatus
+		tzktUp     bool
+		expected   domain.HealthStatus
+	}{
+		{"All up", nil, domain.MigrationStatus{Applied: 31, Expected: 31}, true, domain.HealthUp},
+		{"TzKT unreachable", nil, domain.MigrationStatus{Applied: 31, Expected: 31}, false, domain.HealthDegraded},
+		{"Pending migrations", nil, domain.MigrationStatus{Applied: 30, Expected: 31}, true, domain.HealthDown},
+		{"Database down", errors.New("dial tcp: connection refused"), domain.MigrationStatus{Applied: 31, Expected: 31}, true, domain.HealthDown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tc.tzktUp {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				json.NewEncoder(w).Encode(tzkt.HeadResponse{Level: 1})
+			}))
+			defer server.Close()
+
+			client := tzkt.NewClient(server.URL, 5*time.Second, 0, time.Millisecond, log)
+			_, _ = client.GetHead(context.Background())
+
+			mockRepo := new(MockHealthRepository)
+			mockRepo.On("Ping", mock.Anything).Return(tc.pingErr)
+			mockRepo.On("MigrationStatus", mock.Anything).Return(tc.migrations, nil)
+
+			service := NewService(mockRepo, client, &config.TzktAPI{}, log)
+			report := service.CheckReadiness(context.Background())
+
+			assert.Equal(t, tc.expected, report.Status)
+			require.Len(t, report.Checks, 3)
+			for name, check := range report.Checks {
+				assert.NotNil(t, check.CheckedAt, name)
+				assert.NotContains(t, check.Detail, "connection refused", name)
+			}
+		})
+	}
+}
+
+func TestService_CheckReadinessUnsupportedRepository(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+	service := NewService(new(MockRepository), nil, &config.TzktAPI{}, log)
+
+	report := service.CheckReadiness(context.Background())
+
+	assert.Equal(t, domain.HealthDown, report.Status)
+	assert.NotContains(t, report.Checks, "tzkt")
+}
+
+func TestService_IndexerHealth(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+
+	fake := &fakeTzkt{}
+	fake.head.Store(5000000)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetLastIndexedLevel").Return(int64(4999990), nil)
+
+	client := tzkt.NewClient(server.URL, 5*time.Second, 0, time.Millisecond, log)
+	service := NewService(mockRepo, client, &config.TzktAPI{}, log)
+	service.SetHealthConfig(config.Health{CheckTimeout: time.Second, IndexerMaxLagBlocks: 10, IndexerMaxLag: time.Minute})
+
+	health := service.IndexerHealth()
+	assert.Equal(t, domain.HealthDegraded, health.Status)
+	assert.Nil(t, health.LastSyncedAt)
+
+	service.pollOnce()
+
+	health = service.IndexerHealth()
+	assert.Equal(t, domain.HealthUp, health.Status)
+	assert.Equal(t, int64(5000000), health.SyncedLevel)
+	assert.Equal(t, int64(0), health.LagBlocks)
+	assert.NotNil(t, health.LastSyncedAt)
+
+	// The head moves on while fetching delegations fails.
+	fake.head.Store(5000050)
+	fake.delegationsFail.Store(true)
+	service.pollOnce()
+
+	health = service.IndexerHealth()
+	assert.Equal(t, domain.HealthDegraded, health.Status)
+	assert.Equal(t, int64(5000050), health.HeadLevel)
+	assert.Equal(t, int64(5000000), health.SyncedLevel)
+	assert.Equal(t, int64(50), health.LagBlocks)
+	assert.Contains(t, health.Detail, "50 blocks behind")
+}
+
+func TestService_DataCompleteness(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+
+	fake := &fakeTzkt{}
+	fake.head.Store(5000000)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	mockRepo := new(MockHealthRepository)
+	mockRepo.On("GetLastIndexedLevel").Return(int64(4999990), nil)
+	mockRepo.On("Ping", mock.Anything).Return(nil)
+	mockRepo.On("MigrationStatus", mock.Anything).Return(domain.MigrationStatus{Applied: 31, Expected: 31}, nil)
+
+	client := tzkt.NewClient(server.URL, 5*time.Second, 0, time.Millisecond, log)
+	service := NewService(mockRepo, client, &config.TzktAPI{}, log)
+	service.SetHealthConfig(config.Health{CheckTimeout: time.Second, IndexerMaxLagBlocks: 10, IndexerMaxLag: time.Minute})
+
+	// Nothing is known before historical indexing stores delegations.
+	service.backfilling.Store(true)
+	data := service.DataCompleteness(context.Background())
+	assert.Equal(t, domain.DataBackfilling, data.State)
+	assert.Nil(t, data.CompleteThrough)
+
+	through := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	_, err := service.jobs.CreateJob(context.Background(), &domain.Job{
+		ID:       "job-1",
+		Kind:     domain.JobIndexHistorical,
+		Status:   domain.JobRunning,
+		Progress: domain.JobProgress{Level: 1350000, Timestamp: &through, Delegations: 1000},
+	})
+	require.NoError(t, err)
+	service.backfill.checkedAt = time.Time{}
+
+	data = service.DataCompleteness(context.Background())
+	assert.Equal(t, domain.DataBackfilling, data.State)
+	assert.Equal(t, int64(1350000), data.CompleteThroughLevel)
+	require.NotNil(t, data.CompleteThrough)
+	assert.True(t, through.Equal(*data.CompleteThrough))
+
+	// Backfilling degrades readiness without taking the service down.
+	report := service.CheckReadiness(context.Background())
+	assert.Equal(t, domain.HealthDegraded, report.Status)
+	assert.Equal(t, domain.HealthDegraded, report.Checks[checkBackfill].Status)
+	assert.Contains(t, report.Checks[checkBackfill].Detail, "2021-03-01T12:00:00Z")
+	require.NotNil(t, report.Data)
+	assert.Equal(t, domain.DataBackfilling, report.Data.State)
+
+	// Once polling catches up, the watermark is the synced head.
+	service.backfilling.Store(false)
+	data = service.DataCompleteness(context.Background())
+	assert.Equal(t, domain.DataSyncing, data.State)
+	assert.Equal(t, int64(1350000), data.CompleteThroughLevel)
+
+	service.pollOnce()
+
+	data = service.DataCompleteness(context.Background())
+	assert.Equal(t, domain.DataLive, data.State)
+	assert.Equal(t, int64(5000000), data.CompleteThroughLevel)
+	assert.Equal(t, int64(5000000), data.HeadLevel)
+
+	report = service.CheckReadiness(context.Background())
+	assert.NotContains(t, report.Checks, checkBackfill)
+}
@@ -0,0 +1,363 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// jobStaleAfter is how long a running job may go without its progress
+	// being saved before it is handed to another worker. It is raised to
+	// four poll intervals when those are longer.
+	jobStaleAfter = 2 * time.Minute
+	// jobMaintenanceInterval is how often stale jobs are requeued and old
+	// ones deleted.
+	jobMaintenanceInterval = time.Minute
+	maxJobRetryBackoff     = time.Hour
+)
+
+// JobRunner runs the jobs stored by the service: it claims due jobs, up to
+// Concurrency at a time, saves their progress as they run, retries failed
+// attempts with exponential backoff and enqueues scheduled jobs. Replicas
+// share the work; jobs of the same kind never run at the same time.
+type JobRunner struct {
+	service   *Service
+	config    *config.Jobs
+	logger    *logger.Logger
+	worker    string
+	schedules []*jobSchedule
+	now       func() time.Time
+
+	// ctx is cancelled on Stop, interrupting the jobs under way.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// jobSchedule enqueues a job of kind at the times given by a cron
+// expression. A run is skipped while the previous one is pending or running.
+type jobSchedule struct {
+	kind     domain.JobKind
+	spec     string
+	schedule cron.Schedule
+	next     time.Time
+}
+
+// NewJobRunner parses the job schedules; they take standard five-field cron
+// expressions or descriptors such as @daily and @every 6h, in the local time
+// zone unless prefixed with CRON_TZ=.
+func NewJobRunner(service *Service, cfg *config.Jobs, logger *logger.Logger) (*JobRunner, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+
+	r := &JobRunner{
+		service: service,
+		config:  cfg,
+		logger:  logger,
+		worker:  hostname + "-" + uuid.New().String()[:8],
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	for kind, spec := range cfg.Schedules {
+		if !domain.JobKind(kind).Schedulable() {
+			return nil, fmt.Errorf("job kind %q cannot be scheduled", kind)
+		}
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule for %s: %w", kind, err)
+		}
+		r.schedules = append(r.schedules, &jobSchedule{
+			kind:     domain.JobKind(kind),
+			spec:     spec,
+			schedule: schedule,
+			next:     schedule.Next(r.now()),
+		})
+	}
+
+	return r, nil
+}
+
+func (r *JobRunner) Start() {
+	for i := 0; i < max(r.config.Concurrency, 1); i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.work()
+		}()
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.maintain()
+	}()
+
+	for _, schedule := range r.schedules {
+		r.logger.Infow("Job scheduled", "kind", schedule.kind, "schedule", schedule.spec, "next", schedule.next)
+	}
+	r.logger.Infow("Job runner started", "worker", r.worker, "concurrency", r.config.Concurrency)
+}
+
+// Stop interrupts the jobs under way and waits for them to be handed back.
+// They are queued again, without counting the attempt.
+func (r *JobRunner) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		r.cancel()
+		r.wg.Wait()
+		r.logger.Info("Job runner stopped")
+	})
+}
+
+func (r *JobRunner) work() {
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		ran, err := r.RunNext(r.ctx)
+		if err != nil {
+			r.logger.Errorw("Failed to claim job", "error", err)
+		}
+		if ran {
+			continue
+		}
+
+		select {
+		case <-r.service.jobWake:
+		case <-time.After(r.config.PollInterval):
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// RunNext runs an attempt of the job due first, if any, and reports whether
+// there was one.
+func (r *JobRunner) RunNext(ctx context.Context) (bool, error) {
+	job, err := r.service.jobs.ClaimJob(ctx, r.worker)
+	if err != nil || job == nil {
+		return false, err
+	}
+	r.run(ctx, job)
+	return true, nil
+}
+
+func (r *JobRunner) run(ctx context.Context, job *domain.Job) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r.logger.Infow("Job started", "jobID", job.ID, "kind", job.Kind, "attempt", job.Attempts, "maxAttempts", job.MaxAttempts)
+
+	run := &runningJob{job: *job}
+	var cancelled, leaseLost atomic.Bool
+	done := make(chan struct{})
+	heartbeat := make(chan struct{})
+	go func() {
+		defer close(heartbeat)
+		r.heartbeat(run, done, func(lost bool) {
+			if lost {
+				leaseLost.Store(true)
+			} else {
+				cancelled.Store(true)
+			}
+			cancel()
+		})
+	}()
+
+	err := r.service.runJob(ctx, run)
+	close(done)
+	<-heartbeat
+
+	if leaseLost.Load() {
+		r.abandon(run.snapshot(), err)
+		return
+	}
+	r.finish(run.snapshot(), err, cancelled.Load())
+}
+
+// heartbeat saves the progress of a job until done is closed, and calls stop
+// when the job has been cancelled, or with leaseLost set when it has been
+// taken away from this worker.
+func (r *JobRunner) heartbeat(run *runningJob, done <-chan struct{}, stop func(leaseLost bool)) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			job := run.snapshot()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			cancelled, err := r.service.jobs.SaveJobProgress(ctx, &job)
+			cancel()
+			switch {
+			case errors.Is(err, domain.ErrJobLeaseLost):
+				stop(true)
+				return
+			case err != nil:
+				r.logger.Warnw("Failed to save job progress", "jobID", job.ID, "error", err)
+			case cancelled:
+				stop(false)
+				return
+			}
+		}
+	}
+}
+
+// finish records the outcome of an attempt. An attempt interrupted by Stop
+// is given back, and failed ones are retried while attempts remain.
+func (r *JobRunner) finish(job domain.Job, err error, stopped bool) {
+	now := r.now()
+	result := ""
+	switch {
+	case err == nil:
+		job.Status = domain.JobSucceeded
+		job.Error = ""
+		job.Progress.Percent = 100
+		job.FinishedAt = &now
+	case stopped:
+		job.Status = domain.JobCancelled
+		job.FinishedAt = &now
+	case r.ctx.Err() != nil:
+		job.Status = domain.JobPending
+		job.Attempts--
+		job.RunAt = now
+		result = "interrupted"
+	case job.Attempts < job.MaxAttempts:
+		job.Status = domain.JobPending
+		job.Error = err.Error()
+		job.RunAt = now.Add(r.backoff(job.Attempts))
+		result = "retried"
+	default:
+		job.Status = domain.JobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+	}
+	if result == "" {
+		result = string(job.Status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.service.jobs.FinishJob(ctx, &job); err != nil {
+		r.logger.Errorw("Failed to record job outcome", "jobID", job.ID, "status", job.Status, "error", err)
+	}
+	metrics.JobRuns.WithLabelValues(string(job.Kind), result).Inc()
+
+	switch result {
+	case "failed":
+		r.logger.Errorw("Job failed", "jobID", job.ID, "kind", job.Kind, "attempt", job.Attempts, "error", err)
+	case "retried":
+		r.logger.Warnw("Job attempt failed, retrying", "jobID", job.ID, "kind", job.Kind,
+			"attempt", job.Attempts, "retryAt", job.RunAt, "error", err)
+	default:
+		r.logger.Infow("Job finished", "jobID", job.ID, "kind", job.Kind, "result", result,
+			"delegations", job.Progress.Delegations)
+	}
+}
+
+// abandon ends an attempt of a job another worker has taken over, typically
+// after this one stopped saving its progress for too long. The job is left
+// to its new worker, so no outcome is recorded.
+func (r *JobRunner) abandon(job domain.Job, err error) {
+	metrics.JobRuns.WithLabelValues(string(job.Kind), "lease_lost").Inc()
+	r.logger.Warnw("Job taken over by another worker, abandoning attempt", "jobID", job.ID, "kind", job.Kind,
+		"attempt", job.Attempts, "error", err)
+}
+
+// backoff returns the delay before the attempt following attempt number
+// attempts: the retry backoff, doubled after each failure, capped.
+func (r *JobRunner) backoff(attempts int) time.Duration {
+	delay := r.config.RetryBackoff
+	for i := 1; i < attempts && delay < maxJobRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxJobRetryBackoff)
+}
+
+func (r *JobRunner) maintain() {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	var lastMaintenance time.Time
+	for {
+		r.EnqueueScheduled(r.ctx)
+
+		if r.now().Sub(lastMaintenance) >= jobMaintenanceInterval {
+			lastMaintenance = r.now()
+			r.cleanUp(r.ctx)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// EnqueueScheduled queues the scheduled jobs that are due. Runs missed while
+// no replica was up are not caught up on.
+func (r *JobRunner) EnqueueScheduled(ctx context.Context) {
+	now := r.now()
+	for _, schedule := range r.schedules {
+		if now.Before(schedule.next) {
+			continue
+		}
+		due := schedule.next
+		schedule.next = schedule.schedule.Next(now)
+
+		job, created, err := r.service.enqueueJob(ctx, domain.Job{
+			Kind:         schedule.kind,
+			Trigger:      domain.TriggerSchedule,
+			Singleton:    true,
+			ScheduledFor: &due,
+		})
+		switch {
+		case err != nil:
+			r.logger.Errorw("Failed to queue scheduled job", "kind", schedule.kind, "error", err)
+		case !created:
+			r.logger.Debugw("Scheduled job already queued or running", "kind", schedule.kind, "scheduledFor", due)
+		default:
+			r.logger.Infow("Scheduled job queued", "jobID", job.ID, "kind", schedule.kind, "next", schedule.next)
+		}
+	}
+}
+
+func (r *JobRunner) cleanUp(ctx context.Context) {
+	staleAfter := max(jobStaleAfter, 4*r.config.PollInterval)
+	requeued, err := r.service.jobs.RequeueStaleJobs(ctx, r.now().Add(-staleAfter))
+	if err != nil {
+		r.logger.Errorw("Failed to requeue stale jobs", "error", err)
+	} else if requeued > 0 {
+		r.logger.Warnw("Requeued jobs of unresponsive workers", "count", requeued)
+	}
+
+	if r.config.Retention > 0 {
+		if _, err := r.service.jobs.DeleteFinishedJobs(ctx, r.now().Add(-r.config.Retention)); err != nil {
+			r.logger.Errorw("Failed to delete old jobs", "error", err)
+		}
+	}
+}
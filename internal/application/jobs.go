@@ -0,0 +1,464 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/backup"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+)
+
+const (
+	defaultJobsLimit = 50
+	maxJobsLimit     = 500
+
+	// verifyChunkLevels is the size of the level ranges whose counts are
+	// compared, and reindexed when they differ.
+	verifyChunkLevels = 10_000
+
+	// bakerBackfillBatch is how many levels a backfill_bakers job looks up
+	// at once, and levels up to bakerBackfillGap apart are fetched as one
+	// range.
+	bakerBackfillBatch = 1000
+	bakerBackfillGap   = 100
+)
+
+var (
+	errIndexerUnavailable = errors.New("indexer not available")
+	errBackupsUnavailable = errors.New("backups not configured")
+)
+
+var defaultJobConfig = config.Jobs{
+	Concurrency:  2,
+	PollInterval: 5 * time.Second,
+	MaxAttempts:  3,
+	RetryBackoff: time.Minute,
+	Retention:    30 * 24 * time.Hour,
+}
+
+// SetJobConfig sets how many attempts jobs get and how often the process
+// looks for jobs.
+func (s *Service) SetJobConfig(cfg config.Jobs) {
+	s.jobConfig = cfg
+}
+
+// SetBackupManager sets where backup jobs write archives. Without it, they
+// fail.
+func (s *Service) SetBackupManager(backups *backup.Manager) {
+	s.backups = backups
+}
+
+// SetSnapshot sets the snapshot the historical indexing job seeds a fresh
+// deployment from, before indexing the delegations that follow it from TzKT.
+func (s *Service) SetSnapshot(cfg config.Snapshot) {
+	s.snapshot = cfg
+}
+
+// StartReindex queues a job fetching and storing the delegations from
+// fromLevel to toLevel again. A zero toLevel stands for the chain head when
+// the job starts.
+func (s *Service) StartReindex(ctx context.Context, fromLevel, toLevel int64) (*domain.Job, error) {
+	if err := s.checkLevelRange(fromLevel, toLevel); err != nil {
+		return nil, err
+	}
+	job, _, err := s.enqueueJob(ctx, domain.Job{Kind: domain.JobReindex, Trigger: domain.TriggerManual, FromLevel: fromLevel, ToLevel: toLevel})
+	return job, err
+}
+
+// StartVerification queues a job comparing the number of delegations stored
+// from fromLevel to toLevel with TzKT. With repair, level ranges missing
+// delegations are reindexed.
+func (s *Service) StartVerification(ctx context.Context, fromLevel, toLevel int64, repair bool) (*domain.Job, error) {
+	if _, ok := s.repo.(domain.LevelCountRepository); !ok {
+		return nil, fmt.Errorf("repository does not support counting delegations by level")
+	}
+	if err := s.checkLevelRange(fromLevel, toLevel); err != nil {
+		return nil, err
+	}
+	job, _, err := s.enqueueJob(ctx, domain.Job{Kind: domain.JobVerify, Trigger: domain.TriggerManual, FromLevel: fromLevel, ToLevel: toLevel, Repair: repair})
+	return job, err
+}
+
+func (s *Service) checkLevelRange(fromLevel, toLevel int64) error {
+	if fromLevel < 1 || (toLevel != 0 && toLevel < fromLevel) {
+		return domain.NewValidationError("invalid_level_range", "from_level must be positive and to_level, when set, at least from_level")
+	}
+	if s.tzktClient == nil {
+		return errIndexerUnavailable
+	}
+	return nil
+}
+
+// ListJobs returns the job history, newest first.
+func (s *Service) ListJobs(ctx context.Context, query domain.JobQuery) ([]domain.Job, error) {
+	query.Limit = clampLimit(query.Limit, defaultJobsLimit, maxJobsLimit)
+	return s.jobs.ListJobs(ctx, query)
+}
+
+func (s *Service) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	return s.jobs.GetJob(ctx, id)
+}
+
+// CancelJob cancels a pending job, or asks a running one to stop. It is
+// reported as cancelled once it has, on whichever replica runs it.
+func (s *Service) CancelJob(ctx context.Context, id string) (*domain.Job, error) {
+	job, err := s.jobs.CancelJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Infow("Cancelling job", "jobID", id, "kind", job.Kind)
+	return job, nil
+}
+
+// enqueueJob stores a pending job due now, and reports whether it was
+// created; see domain.JobRepository.CreateJob.
+func (s *Service) enqueueJob(ctx context.Context, job domain.Job) (*domain.Job, bool, error) {
+	now := time.Now()
+	job.ID = uuid.New().String()
+	job.Status = domain.JobPending
+	job.MaxAttempts = max(s.jobConfig.MaxAttempts, 1)
+	job.RunAt = now
+	job.CreatedAt = now
+
+	created, err := s.jobs.CreateJob(ctx, &job)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		return nil, false, nil
+	}
+
+	s.logger.Infow("Job queued", "jobID", job.ID, "kind", job.Kind, "trigger", job.Trigger,
+		"fromLevel", job.FromLevel, "toLevel", job.ToLevel)
+	select {
+	case s.jobWake <- struct{}{}:
+	default:
+	}
+	return &job, true, nil
+}
+
+// runningJob is the state of a job while it runs, saved by the runner as
+// the job goes.
+type runningJob struct {
+	mu  sync.Mutex
+	job domain.Job
+}
+
+func (r *runningJob) update(fn func(*domain.Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.job)
+}
+
+func (r *runningJob) snapshot() domain.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return cloneJob(r.job)
+}
+
+// runJob runs one attempt of a job.
+func (s *Service) runJob(ctx context.Context, run *runningJob) error {
+	job := run.snapshot()
+
+	switch job.Kind {
+	case domain.JobReindex, domain.JobVerify:
+		if s.tzktClient == nil {
+			return errIndexerUnavailable
+		}
+		if job.ToLevel == 0 {
+			head, err := s.tzktClient.GetHead(ctx)
+			if err != nil {
+				return err
+			}
+			run.update(func(j *domain.Job) { j.ToLevel = head.Level })
+		}
+		if job.Kind == domain.JobReindex {
+			return s.runReindex(ctx, run)
+		}
+		return s.runVerification(ctx, run)
+	case domain.JobIndexHistorical:
+		return s.runIndexHistorical(ctx, run)
+	case domain.JobVerifySync:
+		return s.runVerifySync(ctx, run)
+	case domain.JobBackup:
+		return s.runBackup(ctx, run)
+	case domain.JobBackfillBakers:
+		return s.runBackfillBakers(ctx, run)
+	}
+	return fmt.Errorf("unknown job kind %q", job.Kind)
+}
+
+// advance records that a job has gone through level.
+func advance(j *domain.Job, level int64, delegations int) {
+	j.Progress.Level = level
+	j.Progress.Delegations += int64(delegations)
+	if span := j.ToLevel - j.FromLevel + 1; span > 0 {
+		j.Progress.Percent = min(100, float64(level-j.FromLevel+1)/float64(span)*100)
+	}
+}
+
+func (s *Service) runReindex(ctx context.Context, run *runningJob) error {
+	job := run.snapshot()
+	return s.indexLevels(ctx, job.FromLevel, job.ToLevel, func(level int64, stored int) {
+		run.update(func(j *domain.Job) { advance(j, level, stored) })
+	})
+}
+
+func (s *Service) runVerification(ctx context.Context, run *runningJob) error {
+	counter, ok := s.repo.(domain.LevelCountRepository)
+	if !ok {
+		return fmt.Errorf("repository does not support counting delegations by level")
+	}
+	job := run.snapshot()
+	result := domain.VerificationResult{Mismatches: []domain.LevelRangeCount{}}
+
+	for from := job.FromLevel; from <= job.ToLevel; from += verifyChunkLevels {
+		to := min(from+verifyChunkLevels-1, job.ToLevel)
+
+		expected, err := s.tzktClient.CountDelegations(ctx, from, to)
+		if err != nil {
+			return err
+		}
+		stored, err := counter.CountDelegationsInLevels(ctx, from, to)
+		if err != nil {
+			return err
+		}
+		result.Expected += expected
+		result.Stored += stored
+
+		repaired := 0
+		if expected != stored {
+			mismatch := domain.LevelRangeCount{FromLevel: from, ToLevel: to, Expected: expected, Stored: stored}
+			// Delegations are stored idempotently, so reindexing only adds
+			// the missing ones; surplus ones are only reported.
+			if job.Repair && stored < expected {
+				if err := s.indexLevels(ctx, from, to, func(_ int64, count int) { repaired += count }); err != nil {
+					return err
+				}
+				mismatch.Repaired = true
+			}
+			result.Mismatches = append(result.Mismatches, mismatch)
+			s.logger.Warnw("Stored delegations differ from TzKT", "jobID", job.ID,
+				"fromLevel", from, "toLevel", to, "expected", expected, "stored", stored)
+		}
+
+		snapshot := cloneJob(domain.Job{Result: &result}).Result
+		run.update(func(j *domain.Job) {
+			advance(j, to, repaired)
+			j.Result = snapshot
+		})
+	}
+
+	return nil
+}
+
+// runIndexHistorical indexes the delegations from the configured start
+// date, then queues a verification and, when delegations were added, a
+// backup.
+func (s *Service) runIndexHistorical(ctx context.Context, run *runningJob) error {
+	if s.tzktClient == nil {
+		return errIndexerUnavailable
+	}
+
+	// Sync cycles first so that historical delegations are tagged on insert.
+	s.syncCyclesIfStale(ctx)
+
+	seeded, err := s.seedFromSnapshot(ctx, run)
+	if err != nil {
+		return err
+	}
+
+	processed, err := s.indexHistorical(ctx, func(count int, last domain.Delegation) {
+		timestamp := last.Timestamp
+		level, _ := strconv.ParseInt(last.Level, 10, 64)
+		run.update(func(j *domain.Job) {
+			j.Progress.Level = level
+			j.Progress.Timestamp = &timestamp
+			j.Progress.Delegations += int64(count)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	followUps := []domain.JobKind{domain.JobVerifySync}
+	if processed > 0 || seeded > 0 {
+		followUps = append(followUps, domain.JobBackup)
+	}
+	for _, kind := range followUps {
+		if _, _, err := s.enqueueJob(ctx, domain.Job{Kind: kind, Trigger: domain.TriggerJob, Singleton: true}); err != nil {
+			s.logger.Errorw("Failed to queue job", "kind", kind, "error", err)
+		}
+	}
+	return nil
+}
+
+// seedFromSnapshot imports the configured snapshot, if any, and returns how
+// many delegations it added. Historical indexing then resumes after the
+// snapshot's checkpoint. Nothing is imported once a checkpoint was restored,
+// or when the stored delegations go beyond the snapshot.
+func (s *Service) seedFromSnapshot(ctx context.Context, run *runningJob) (int64, error) {
+	if s.snapshot.Source == "" {
+		return 0, nil
+	}
+	if s.backups == nil {
+		return 0, errBackupsUnavailable
+	}
+
+	result, err := s.backups.Seed(ctx, s.snapshot.Source, s.snapshot.SHA256, s.checkSnapshotComplete, func(delegations int64) {
+		run.update(func(j *domain.Job) { j.Progress.Delegations = delegations })
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to seed from snapshot: %w", err)
+	}
+	if result == nil {
+		return 0, nil
+	}
+
+	// Exports list the newest delegations first, so the data is only
+	// complete through the checkpoint once the import is.
+	timestamp := result.Checkpoint.Timestamp
+	run.update(func(j *domain.Job) {
+		j.Progress.Level = result.Checkpoint.Level
+		j.Progress.Timestamp = &timestamp
+	})
+	return result.Restored, nil
+}
+
+// checkSnapshotComplete rejects snapshots that do not hold every delegation
+// TzKT has from their first level to their checkpoint, such as exports
+// filtered by baker or delegator, and snapshots starting after the
+// historical start date, such as exports of a single year. Indexing resumes
+// after the checkpoint, so the delegations they lack would never be indexed.
+func (s *Service) checkSnapshotComplete(ctx context.Context, snapshot *backup.Snapshot) error {
+	expected, err := s.tzktClient.CountDelegations(ctx, snapshot.FirstLevel, snapshot.Checkpoint.Level)
+	if err != nil {
+		return fmt.Errorf("failed to count delegations on TzKT: %w", err)
+	}
+	if snapshot.Delegations != expected {
+		return fmt.Errorf("snapshot holds %d delegations from level %d to %d, TzKT has %d: it is filtered or incomplete",
+			snapshot.Delegations, snapshot.FirstLevel, snapshot.Checkpoint.Level, expected)
+	}
+
+	startDate, err := time.Parse("2006-01-02", s.config.HistoricalStartDate)
+	if err != nil {
+		return fmt.Errorf("invalid historical start date: %w", err)
+	}
+	first, err := s.tzktClient.GetDelegationsSince(ctx, startDate, 1)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the first delegation on TzKT: %w", err)
+	}
+	if len(first) > 0 && first[0].Level < snapshot.FirstLevel {
+		return fmt.Errorf("snapshot starts at level %d, after the first delegation since %s at level %d",
+			snapshot.FirstLevel, s.config.HistoricalStartDate, first[0].Level)
+	}
+	return nil
+}
+
+// runVerifySync compares the delegations stored since the configured start
+// date with TzKT. Differences are reported in the result, not as a failure,
+// as retrying would not resolve them.
+func (s *Service) runVerifySync(ctx context.Context, run *runningJob) error {
+	startDate, err := time.Parse("2006-01-02", s.config.HistoricalStartDate)
+	if err != nil {
+		return fmt.Errorf("invalid historical start date: %w", err)
+	}
+
+	result, err := s.verifySyncCompleteness(ctx, startDate)
+	if err != nil {
+		return err
+	}
+	run.update(func(j *domain.Job) { j.Result = result })
+	return nil
+}
+
+// runBackup writes the stored delegations to a new backup archive and
+// deletes the oldest ones beyond those kept.
+func (s *Service) runBackup(ctx context.Context, run *runningJob) error {
+	if s.backups == nil {
+		return errBackupsUnavailable
+	}
+
+	info, err := s.backups.Backup(ctx, func(delegations int64) {
+		run.update(func(j *domain.Job) { j.Progress.Delegations = delegations })
+	})
+	if err != nil {
+		return err
+	}
+	if info.Checkpoint != nil {
+		timestamp := info.Checkpoint.Timestamp
+		run.update(func(j *domain.Job) {
+			j.Progress.Level = info.Checkpoint.Level
+			j.Progress.Timestamp = &timestamp
+		})
+	}
+	return nil
+}
+
+// queueBakerBackfill queues a backfill_bakers job when delegations lack
+// their baker, which is the case of those stored by releases that did not
+// record it.
+func (s *Service) queueBakerBackfill(ctx context.Context) {
+	repo, ok := s.repo.(domain.BakerBackfillRepository)
+	if !ok {
+		return
+	}
+
+	levels, err := repo.LevelsMissingBaker(ctx, 0, 1)
+	if err == nil && len(levels) > 0 {
+		_, _, err = s.enqueueJob(ctx, domain.Job{Kind: domain.JobBackfillBakers, Trigger: domain.TriggerStartup, Singleton: true})
+	}
+	if err != nil {
+		s.logger.Errorw("Failed to queue baker backfill", "error", err)
+	}
+}
+
+// runBackfillBakers fetches again the levels holding delegations without
+// their baker: storing them again fills the bakers in. Levels close to each
+// other are fetched as one range. A level TzKT has no bakers for either is
+// not retried, as the job moves past the levels it went through.
+func (s *Service) runBackfillBakers(ctx context.Context, run *runningJob) error {
+	repo, ok := s.repo.(domain.BakerBackfillRepository)
+	if !ok {
+		return fmt.Errorf("repository does not support backfilling bakers")
+	}
+	if s.tzktClient == nil {
+		return errIndexerUnavailable
+	}
+
+	after := run.snapshot().Progress.Level
+	for {
+		levels, err := repo.LevelsMissingBaker(ctx, after, bakerBackfillBatch)
+		if err != nil {
+			return err
+		}
+		if len(levels) == 0 {
+			return nil
+		}
+
+		for from, i := levels[0], 0; i < len(levels); i++ {
+			if i+1 < len(levels) && levels[i+1]-levels[i] <= bakerBackfillGap {
+				continue
+			}
+			stored := 0
+			if err := s.indexLevels(ctx, from, levels[i], func(_ int64, count int) { stored += count }); err != nil {
+				return err
+			}
+			level := levels[i]
+			run.update(func(j *domain.Job) {
+				j.Progress.Level = level
+				j.Progress.Delegations += int64(stored)
+			})
+			if i+1 < len(levels) {
+				from = levels[i+1]
+			}
+		}
+		after = levels[len(levels)-1]
+	}
+}
@@ -0,0 +1,712 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/backup"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/tzkt"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockLevelCountRepository struct {
+	MockRepository
+}
+
+func (m *MockLevelCountRepository) CountDelegationsInLevels(ctx context.Context, fromLevel, toLevel int64) (int64, error) {
+	args := m.Called(fromLevel, toLevel)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockBackupRepository struct {
+	MockRepository
+}
+
+func (m *MockBackupRepository) ScanDelegations(ctx context.Context, emit func(domain.Delegation) error) error {
+	args := m.Called()
+	for _, d := range args.Get(0).([]domain.Delegation) {
+		if err := emit(d); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockBackupRepository) RestoreDelegations(ctx context.Context, delegations []domain.Delegation) (int64, error) {
+	args := m.Called(delegations)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockBackupRepository) UpdateIndexingMetadata(ctx context.Context, level int64, timestamp time.Time) error {
+	args := m.Called(level, timestamp)
+	return args.Error(0)
+}
+
+func (m *MockBackupRepository) GetIndexingMetadata(ctx context.Context) (int64, *time.Time, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Get(1).(*time.Time), args.Error(2)
+}
+
+// levelTzkt serves one applied delegation per level from 1 up to head, and their
+// counts. While blocked, delegation requests hang until the client gives up;
+// while down, the head cannot be fetched.
+type levelTzkt struct {
+	head     int64
+	blocked  atomic.Bool
+	down     atomic.Bool
+	requests atomic.Int32
+}
+
+func (f *levelTzkt) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests.Add(1)
+	query := r.URL.Query()
+	from, _ := strconv.ParseInt(query.Get("level.ge"), 10, 64)
+	from = max(from, 1)
+	to, err := strconv.ParseInt(query.Get("level.le"), 10, 64)
+	if err != nil || to > f.head {
+		to = f.head
+	}
+
+	switch r.URL.Path {
+	case "/v1/head":
+		if f.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(tzkt.HeadResponse{Level: f.head, Timestamp: time.Now()})
+	case "/v1/operations/delegations/count":
+		json.NewEncoder(w).Encode(max(0, to-from+1))
+	case "/v1/operations/delegations":
+		if f.blocked.Load() {
+			<-r.Context().Done()
+			return
+		}
+		// IDs are the levels, so the ID cursor moves the start level.
+		if afterID, err := strconv.ParseInt(query.Get("id.gt"), 10, 64); err == nil {
+			from = max(from, afterID+1)
+		}
+		limit, _ := strconv.ParseInt(query.Get("limit"), 10, 64)
+		delegations := []tzkt.DelegationResponse{}
+		for level := from; level <= to && int64(len(delegations)) < limit; level++ {
+			delegations = append(delegations, tzkt.DelegationResponse{
+				ID: level, Level: level, Hash: "op" + strconv.FormatInt(level, 10),
+				Sender: tzkt.Sender{Address: "tz1delegator"}, Status: "applied",
+			})
+		}
+		json.NewEncoder(w).Encode(delegations)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var testJobConfig = config.Jobs{
+	Concurrency:  2,
+	PollInterval: 10 * time.Millisecond,
+	MaxAttempts:  1,
+	RetryBackoff: time.Millisecond,
+}
+
+func newJobService(t *testing.T, repo domain.DelegationRepository, fake *levelTzkt) *Service {
+	t.Helper()
+	service, runner := newJobRunner(t, repo, fake, testJobConfig)
+	runner.Start()
+	t.Cleanup(runner.Stop)
+	return service
+}
+
+// newJobRunner returns a service and a job runner that has not been started.
+func newJobRunner(t *testing.T, repo domain.DelegationRepository, fake *levelTzkt, cfg config.Jobs) (*Service, *JobRunner) {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	log, _ := logger.New("debug", "test")
+	client := tzkt.NewClient(server.URL, 5*time.Second, 0, time.Millisecond, log)
+	service := NewService(repo, client, &config.TzktAPI{}, log)
+	service.SetJobConfig(cfg)
+
+	runner, err := NewJobRunner(service, &cfg, log)
+	require.NoError(t, err)
+	return service, runner
+}
+
+func waitForJob(t *testing.T, service *Service, id string, status domain.JobStatus) *domain.Job {
+	t.Helper()
+	var job *domain.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = service.GetJob(context.Background(), id)
+		return err == nil && job.Status == status
+	}, 5*time.Second, 10*time.Millisecond, "job did not become %s", status)
+	return job
+}
+
+func TestService_Reindex(t *testing.T) {
+	mockRepo := new(MockRepository)
+	var stored atomic.Int32
+	mockRepo.On("SaveBatch", mock.Anything).Run(func(args mock.Arguments) {
+		stored.Add(int32(len(args.Get(0).([]domain.Delegation))))
+	}).Return(nil, nil)
+
+	service := newJobService(t, mockRepo, &levelTzkt{head: 1000})
+
+	job, err := service.StartReindex(context.Background(), 101, 350)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobReindex, job.Kind)
+
+	job = waitForJob(t, service, job.ID, domain.JobSucceeded)
+	assert.Equal(t, int32(250), stored.Load())
+	assert.Equal(t, int64(350), job.Progress.Level)
+	assert.Equal(t, int64(250), job.Progress.Delegations)
+	assert.Equal(t, float64(100), job.Progress.Percent)
+	assert.NotNil(t, job.StartedAt)
+	assert.NotNil(t, job.FinishedAt)
+
+	// Without an upper bound, the job goes up to the head.
+	job, err = service.StartReindex(context.Background(), 901, 0)
+	require.NoError(t, err)
+	job = waitForJob(t, service, job.ID, domain.JobSucceeded)
+	assert.Equal(t, int64(1000), job.ToLevel)
+
+	jobs, err := service.ListJobs(context.Background(), domain.JobQuery{})
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, job.ID, jobs[0].ID)
+	assert.Equal(t, domain.TriggerManual, jobs[0].Trigger)
+	assert.Equal(t, 1, jobs[0].Attempts)
+
+	jobs, err = service.ListJobs(context.Background(), domain.JobQuery{Kind: domain.JobVerify})
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+type MockBakerBackfillRepository struct {
+	MockRepository
+}
+
+func (m *MockBakerBackfillRepository) LevelsMissingBaker(ctx context.Context, afterLevel int64, limit int) ([]int64, error) {
+	args := m.Called(afterLevel, limit)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func TestService_BackfillBakers(t *testing.T) {
+	mockRepo := new(MockBakerBackfillRepository)
+	var levels []string
+	mockRepo.On("SaveBatch", mock.Anything).Run(func(args mock.Arguments) {
+		for _, d := range args.Get(0).([]domain.Delegation) {
+			levels = append(levels, d.Level)
+		}
+	}).Return(nil, nil)
+	mockRepo.On("LevelsMissingBaker", int64(0), 1).Return([]int64{5}, nil).Once()
+	mockRepo.On("LevelsMissingBaker", int64(0), bakerBackfillBatch).Return([]int64{5, 7, 500}, nil).Once()
+	mockRepo.On("LevelsMissingBaker", int64(500), bakerBackfillBatch).Return([]int64{}, nil).Once()
+
+	service, runner := newJobRunner(t, mockRepo, &levelTzkt{head: 1000}, testJobConfig)
+	service.queueBakerBackfill(context.Background())
+	jobs, err := service.ListJobs(context.Background(), domain.JobQuery{Kind: domain.JobBackfillBakers})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	runner.Start()
+	t.Cleanup(runner.Stop)
+	job := waitForJob(t, service, jobs[0].ID, domain.JobSucceeded)
+
+	// Close levels are fetched as one range, including the levels between.
+	assert.Equal(t, []string{"5", "6", "7", "500"}, levels)
+	assert.Equal(t, int64(500), job.Progress.Level)
+	assert.Equal(t, int64(4), job.Progress.Delegations)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_ReindexInvalidRange(t *testing.T) {
+	service := newJobService(t, new(MockRepository), &levelTzkt{head: 1000})
+
+	for _, levels := range [][2]int64{{0, 10}, {-1, 0}, {20, 10}} {
+		_, err := service.StartReindex(context.Background(), levels[0], levels[1])
+		assert.True(t, domain.IsKind(err, domain.KindValidation), levels)
+	}
+}
+
+func TestService_Verification(t *testing.T) {
+	mockRepo := new(MockLevelCountRepository)
+	mockRepo.On("CountDelegationsInLevels", int64(1), int64(10000)).Return(int64(10000), nil)
+	mockRepo.On("CountDelegationsInLevels", int64(10001), int64(12000)).Return(int64(1500), nil)
+	mockRepo.On("SaveBatch", mock.Anything).Return(nil, nil)
+
+	service := newJobService(t, mockRepo, &levelTzkt{head: 12000})
+
+	job, err := service.StartVerification(context.Background(), 1, 12000, false)
+	require.NoError(t, err)
+	job = waitForJob(t, service, job.ID, domain.JobSucceeded)
+
+	require.NotNil(t, job.Result)
+	assert.Equal(t, int64(12000), job.Result.Expected)
+	assert.Equal(t, int64(11500), job.Result.Stored)
+	assert.Equal(t, []domain.LevelRangeCount{{FromLevel: 10001, ToLevel: 12000, Expected: 2000, Stored: 1500}}, job.Result.Mismatches)
+	mockRepo.AssertNotCalled(t, "SaveBatch", mock.Anything)
+
+	job, err = service.StartVerification(context.Background(), 1, 12000, true)
+	require.NoError(t, err)
+	job = waitForJob(t, service, job.ID, domain.JobSucceeded)
+
+	require.Len(t, job.Result.Mismatches, 1)
+	assert.True(t, job.Result.Mismatches[0].Repaired)
+	assert.Equal(t, int64(2000), job.Progress.Delegations)
+}
+
+func TestService_VerificationUnsupportedRepository(t *testing.T) {
+	service := newJobService(t, new(MockRepository), &levelTzkt{head: 1000})
+
+	_, err := service.StartVerification(context.Background(), 1, 1000, true)
+	assert.Error(t, err)
+}
+
+func TestService_CancelJob(t *testing.T) {
+	fake := &levelTzkt{head: 1000}
+	fake.blocked.Store(true)
+	service := newJobService(t, new(MockRepository), fake)
+
+	running, err := service.StartReindex(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	waitForJob(t, service, running.ID, domain.JobRunning)
+
+	// Jobs of the same kind run one at a time.
+	pending, err := service.StartReindex(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobPending, pending.Status)
+
+	_, err = service.CancelJob(context.Background(), pending.ID)
+	require.NoError(t, err)
+	waitForJob(t, service, pending.ID, domain.JobCancelled)
+
+	_, err = service.CancelJob(context.Background(), running.ID)
+	require.NoError(t, err)
+	job := waitForJob(t, service, running.ID, domain.JobCancelled)
+	assert.Empty(t, job.Error)
+
+	_, err = service.CancelJob(context.Background(), running.ID)
+	assert.ErrorIs(t, err, domain.ErrJobFinished)
+	_, err = service.CancelJob(context.Background(), "unknown")
+	assert.ErrorIs(t, err, domain.ErrJobNotFound)
+}
+
+func TestService_AwaitHistoricalIndexing(t *testing.T) {
+	service, _ := newJobRunner(t, new(MockRepository), &levelTzkt{head: 1000}, testJobConfig)
+	service.backfilling.Store(true)
+
+	done := make(chan bool, 1)
+	go func() { done <- service.awaitHistoricalIndexing() }()
+
+	var queued []domain.Job
+	require.Eventually(t, func() bool {
+		queued, _ = service.ListJobs(context.Background(), domain.JobQuery{Kind: domain.JobIndexHistorical})
+		return len(queued) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// A cancelled run leaves the data incomplete, and polling waits.
+	_, err := service.CancelJob(context.Background(), queued[0].ID)
+	require.NoError(t, err)
+	require.Eventually(t, service.backfillFailed.Load, time.Second, 10*time.Millisecond)
+	assert.Equal(t, domain.DataIncomplete, service.DataCompleteness(context.Background()).State)
+	report := service.CheckReadiness(context.Background())
+	assert.Equal(t, domain.HealthDegraded, report.Checks[checkBackfill].Status)
+	assert.Contains(t, report.Checks[checkBackfill].Detail, "failed")
+	assert.Empty(t, done)
+
+	// A retried run that succeeds starts polling.
+	retry, _, err := service.enqueueJob(context.Background(), domain.Job{Kind: domain.JobIndexHistorical, Trigger: domain.TriggerManual})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return !service.backfillFailed.Load() }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, domain.DataBackfilling, service.DataCompleteness(context.Background()).State)
+
+	claimed, err := service.jobs.ClaimJob(context.Background(), "worker")
+	require.NoError(t, err)
+	require.Equal(t, retry.ID, claimed.ID)
+	claimed.Status = domain.JobSucceeded
+	require.NoError(t, service.jobs.FinishJob(context.Background(), claimed))
+
+	select {
+	case polling := <-done:
+		assert.True(t, polling)
+	case <-time.After(time.Second):
+		t.Fatal("polling did not start after historical indexing succeeded")
+	}
+}
+
+type MockPollingStateRepository struct {
+	MockRepository
+	paused atomic.Bool
+}
+
+func (m *MockPollingStateRepository) SetPollingPaused(ctx context.Context, paused bool) error {
+	m.paused.Store(paused)
+	return nil
+}
+
+func (m *MockPollingStateRepository) IsPollingPaused(ctx context.Context) (bool, error) {
+	return m.paused.Load(), nil
+}
+
+func TestService_PausePolling(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetLastIndexedLevel").Return(int64(1000), nil)
+	fake := &levelTzkt{head: 1000}
+	service := newJobService(t, mockRepo, fake)
+
+	require.NoError(t, service.PausePolling(context.Background()))
+	service.pollUnlessPaused()
+	assert.Equal(t, int32(0), fake.requests.Load())
+
+	status, err := service.IndexerStatus(context.Background())
+	require.NoError(t, err)
+	assert.True(t, status.Paused)
+	assert.False(t, status.Polling)
+	assert.Equal(t, int64(1000), status.LastIndexedLevel)
+
+	require.NoError(t, service.ResumePolling(context.Background()))
+	service.pollUnlessPaused()
+	assert.NotZero(t, fake.requests.Load())
+
+	status, err = service.IndexerStatus(context.Background())
+	require.NoError(t, err)
+	assert.False(t, status.Paused)
+	assert.Equal(t, int64(1000), status.HeadLevel)
+}
+
+func TestService_PausePollingIsShared(t *testing.T) {
+	repo := new(MockPollingStateRepository)
+	repo.On("GetLastIndexedLevel").Return(int64(1000), nil)
+	fake := &levelTzkt{head: 1000}
+	service := newJobService(t, repo, fake)
+	replica := newJobService(t, repo, fake)
+
+	// A pause on one replica stops the others.
+	require.NoError(t, service.PausePolling(context.Background()))
+	assert.True(t, repo.paused.Load())
+	replica.pollUnlessPaused()
+	assert.Equal(t, int32(0), fake.requests.Load())
+
+	status, err := replica.IndexerStatus(context.Background())
+	require.NoError(t, err)
+	assert.True(t, status.Paused)
+
+	require.NoError(t, replica.ResumePolling(context.Background()))
+	service.pollUnlessPaused()
+	assert.NotZero(t, fake.requests.Load())
+}
+
+func TestJobRunner_Retries(t *testing.T) {
+	fake := &levelTzkt{head: 1000}
+	fake.down.Store(true)
+	cfg := testJobConfig
+	cfg.MaxAttempts = 3
+	cfg.RetryBackoff = 20 * time.Millisecond
+	mockRepo := new(MockRepository)
+	mockRepo.On("SaveBatch", mock.Anything).Return(nil, nil)
+	service, runner := newJobRunner(t, mockRepo, fake, cfg)
+	runner.Start()
+	t.Cleanup(runner.Stop)
+
+	job, err := service.StartReindex(context.Background(), 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.MaxAttempts)
+
+	job = waitForJob(t, service, job.ID, domain.JobFailed)
+	assert.Equal(t, 3, job.Attempts)
+	assert.NotEmpty(t, job.Error)
+	assert.NotNil(t, job.FinishedAt)
+
+	// A job recovering before its last attempt succeeds.
+	job, err = service.StartReindex(context.Background(), 991, 0)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		job, err = service.GetJob(context.Background(), job.ID)
+		return err == nil && job.Status == domain.JobPending && job.Attempts == 1
+	}, 5*time.Second, time.Millisecond)
+	assert.NotEmpty(t, job.Error)
+	assert.True(t, job.RunAt.After(job.CreatedAt))
+	fake.down.Store(false)
+
+	job = waitForJob(t, service, job.ID, domain.JobSucceeded)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Empty(t, job.Error)
+}
+
+func TestJobRunner_RetryBackoff(t *testing.T) {
+	runner := &JobRunner{config: &config.Jobs{RetryBackoff: time.Minute}}
+
+	assert.Equal(t, time.Minute, runner.backoff(1))
+	assert.Equal(t, 4*time.Minute, runner.backoff(3))
+	assert.Equal(t, maxJobRetryBackoff, runner.backoff(20))
+}
+
+func TestJobRunner_StopRequeuesRunningJobs(t *testing.T) {
+	fake := &levelTzkt{head: 1000}
+	fake.blocked.Store(true)
+	service, runner := newJobRunner(t, new(MockRepository), fake, testJobConfig)
+	runner.Start()
+
+	job, err := service.StartReindex(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	waitForJob(t, service, job.ID, domain.JobRunning)
+
+	runner.Stop()
+
+	job, err = service.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobPending, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+	assert.Empty(t, job.Worker)
+}
+
+// finishRecorder records the outcomes a job runner tries to store.
+type finishRecorder struct {
+	*memoryJobStore
+	finished atomic.Int32
+}
+
+func (f *finishRecorder) FinishJob(ctx context.Context, job *domain.Job) error {
+	f.finished.Add(1)
+	return f.memoryJobStore.FinishJob(ctx, job)
+}
+
+func TestJobRunner_LeaseLost(t *testing.T) {
+	fake := &levelTzkt{head: 1000}
+	fake.blocked.Store(true)
+	service, runner := newJobRunner(t, new(MockRepository), fake, testJobConfig)
+	store := service.jobs.(*memoryJobStore)
+	recorder := &finishRecorder{memoryJobStore: store}
+	service.jobs = recorder
+
+	job, err := service.StartReindex(context.Background(), 1, 1000)
+	require.NoError(t, err)
+
+	// The job goes stale and another worker claims it while this one is
+	// still fetching.
+	go func() {
+		waitForJob(t, service, job.ID, domain.JobRunning)
+		store.mu.Lock()
+		store.find(job.ID).Worker = "other-worker"
+		store.mu.Unlock()
+	}()
+
+	ran, err := runner.RunNext(context.Background())
+	require.NoError(t, err)
+	require.True(t, ran)
+
+	// The attempt was abandoned without recording an outcome over the new
+	// worker's.
+	job, err = service.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobRunning, job.Status)
+	assert.Equal(t, "other-worker", job.Worker)
+	assert.Nil(t, job.FinishedAt)
+	assert.Zero(t, recorder.finished.Load())
+}
+
+func TestJobRunner_Schedules(t *testing.T) {
+	cfg := testJobConfig
+	cfg.Schedules = map[string]string{"backup": "0 3 * * *"}
+	service, runner := newJobRunner(t, new(MockRepository), &levelTzkt{head: 1000}, cfg)
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	runner.now = func() time.Time { return start }
+	runner.schedules[0].next = runner.schedules[0].schedule.Next(start)
+
+	runner.EnqueueScheduled(context.Background())
+	jobs, err := service.ListJobs(context.Background(), domain.JobQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, jobs, "not due yet")
+
+	due := time.Date(2024, 5, 2, 3, 0, 0, 0, time.Local)
+	runner.now = func() time.Time { return due.Add(time.Second) }
+	runner.EnqueueScheduled(context.Background())
+
+	jobs, err = service.ListJobs(context.Background(), domain.JobQuery{Kind: domain.JobBackup})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, domain.TriggerSchedule, jobs[0].Trigger)
+	require.NotNil(t, jobs[0].ScheduledFor)
+	assert.True(t, due.Equal(*jobs[0].ScheduledFor))
+	assert.Equal(t, due.Add(24*time.Hour), runner.schedules[0].next)
+
+	// Another replica due at the same time does not queue it again, nor does
+	// the next run while this one is pending.
+	replica := &JobRunner{service: service, logger: runner.logger, now: runner.now,
+		schedules: []*jobSchedule{{kind: domain.JobBackup, schedule: runner.schedules[0].schedule, next: due}}}
+	replica.EnqueueScheduled(context.Background())
+
+	runner.now = func() time.Time { return due.Add(24 * time.Hour) }
+	runner.EnqueueScheduled(context.Background())
+
+	jobs, err = service.ListJobs(context.Background(), domain.JobQuery{Kind: domain.JobBackup})
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+}
+
+func TestNewJobRunner_InvalidSchedules(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+	service := NewService(new(MockRepository), nil, &config.TzktAPI{}, log)
+
+	for _, schedules := range []map[string]string{
+		{"reindex": "@daily"},
+		{"unknown": "@daily"},
+		{"backup": "every day"},
+		{"backup": "0 3 * *"},
+	} {
+		_, err := NewJobRunner(service, &config.Jobs{Schedules: schedules}, log)
+		assert.Error(t, err, schedules)
+	}
+
+	runner, err := NewJobRunner(service, &config.Jobs{Schedules: map[string]string{
+		"backup":           "CRON_TZ=UTC 0 3 * * 1,4",
+		"verify_sync":      "@every 6h",
+		"index_historical": "@daily",
+	}}, log)
+	require.NoError(t, err)
+	assert.Len(t, runner.schedules, 3)
+}
+
+func TestService_BackupJob(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo := new(MockBackupRepository)
+	mockRepo.On("ScanDelegations").Return([]domain.Delegation{
+		{OperationHash: "op1", Level: "100", Timestamp: timestamp.Add(-time.Minute), Amount: "1", Delegator: "tz1a"},
+		{OperationHash: "op2", Level: "200", Timestamp: timestamp, Amount: "2", Delegator: "tz1b"},
+	}, nil)
+
+	service := newJobService(t, mockRepo, &levelTzkt{head: 1000})
+
+	// Without a backup store, the job fails.
+	job, _, err := service.enqueueJob(context.Background(), domain.Job{Kind: domain.JobBackup, Trigger: domain.TriggerManual})
+	require.NoError(t, err)
+	job = waitForJob(t, service, job.ID, domain.JobFailed)
+	assert.Contains(t, job.Error, "backups not configured")
+
+	dir := t.TempDir()
+	log, _ := logger.New("debug", "test")
+	store, err := backup.NewStore(&config.Backup{Target: dir})
+	require.NoError(t, err)
+	service.SetBackupManager(backup.NewManager(mockRepo, store, 1, log))
+
+	job, _, err = service.enqueueJob(context.Background(), domain.Job{Kind: domain.JobBackup, Trigger: domain.TriggerManual})
+	require.NoError(t, err)
+	job = waitForJob(t, service, job.ID, domain.JobSucceeded)
+
+	assert.Equal(t, int64(2), job.Progress.Delegations)
+	assert.Equal(t, int64(200), job.Progress.Level)
+	require.NotNil(t, job.Progress.Timestamp)
+	assert.True(t, timestamp.Equal(*job.Progress.Timestamp))
+
+	objects, err := store.List(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, objects, 1)
+}
+
+func TestService_IndexHistoricalSeedsFromSnapshot(t *testing.T) {
+	// The snapshot is recent: TzKT has nothing to add after it.
+	timestamp := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Second)
+	seeded := []domain.Delegation{
+		{OperationHash: "op2", Level: "2", Timestamp: timestamp, Amount: "2", Delegator: "tz1b", BlockHash: "b2", Baker: "tz1baker"},
+		{OperationHash: "op1", Level: "1", Timestamp: timestamp.Add(-time.Minute), Amount: "1", Delegator: "tz1a", BlockHash: "b1", Baker: "tz1baker"},
+	}
+	path := writeExport(t, seeded)
+
+	mockRepo := new(MockBackupRepository)
+	mockRepo.On("GetIndexingMetadata").Return(int64(0), (*time.Time)(nil), nil)
+	mockRepo.On("GetLastIndexedLevel").Return(int64(0), nil)
+	mockRepo.On("RestoreDelegations", mock.Anything).Return(int64(2), nil).Once()
+	mockRepo.On("UpdateIndexingMetadata", int64(2), timestamp).Return(nil).Once()
+	mockRepo.On("GetLastTimestamp").Return(&timestamp, nil)
+	mockRepo.On("ScanDelegations").Return(seeded, nil)
+
+	service := newSeedingService(t, mockRepo, path)
+
+	job, _, err := service.enqueueJob(context.Background(), domain.Job{Kind: domain.JobIndexHistorical, Trigger: domain.TriggerManual})
+	require.NoError(t, err)
+	job = waitForJob(t, service, job.ID, domain.JobSucceeded)
+
+	assert.Equal(t, int64(2), job.Progress.Delegations)
+	assert.Equal(t, int64(2), job.Progress.Level)
+	require.NotNil(t, job.Progress.Timestamp)
+	assert.True(t, timestamp.Equal(*job.Progress.Timestamp))
+	mockRepo.AssertExpectations(t)
+}
+
+// writeExport writes delegations as an NDJSON export.
+func writeExport(t *testing.T, delegations []domain.Delegation) string {
+	t.Helper()
+	var export bytes.Buffer
+	for _, d := range delegations {
+		require.NoError(t, json.NewEncoder(&export).Encode(map[string]any{
+			"timestamp": d.Timestamp, "amount": d.Amount, "delegator": d.Delegator, "level": d.Level,
+			"operation_hash": d.OperationHash, "block_hash": d.BlockHash, "baker": d.Baker, "prev_baker": d.PrevBaker,
+		}))
+	}
+	path := filepath.Join(t.TempDir(), "delegations.ndjson")
+	require.NoError(t, os.WriteFile(path, export.Bytes(), 0o644))
+	return path
+}
+
+func newSeedingService(t *testing.T, repo *MockBackupRepository, snapshot string) *Service {
+	t.Helper()
+	service := newJobService(t, repo, &levelTzkt{head: 1000})
+	service.config.HistoricalStartDate = "2024-01-01"
+	log, _ := logger.New("debug", "test")
+	store, err := backup.NewStore(&config.Backup{Target: t.TempDir()})
+	require.NoError(t, err)
+	service.SetBackupManager(backup.NewManager(repo, store, 1, log))
+	service.SetSnapshot(config.Snapshot{Source: snapshot})
+	return service
+}
+
+func TestService_IndexHistoricalRejectsFilteredSnapshot(t *testing.T) {
+	// TzKT has a delegation at level 2 that the snapshot lacks.
+	path := writeExport(t, []domain.Delegation{
+		{OperationHash: "op3", Level: "3", Timestamp: time.Now(), Amount: "3", Delegator: "tz1b", BlockHash: "b3", Baker: "tz1baker"},
+		{OperationHash: "op1", Level: "1", Timestamp: time.Now(), Amount: "1", Delegator: "tz1b", BlockHash: "b1", Baker: "tz1baker"},
+	})
+
+	mockRepo := new(MockBackupRepository)
+	mockRepo.On("GetIndexingMetadata").Return(int64(0), (*time.Time)(nil), nil)
+	mockRepo.On("GetLastIndexedLevel").Return(int64(0), nil)
+	service := newSeedingService(t, mockRepo, path)
+
+	job, _, err := service.enqueueJob(context.Background(), domain.Job{Kind: domain.JobIndexHistorical, Trigger: domain.TriggerManual})
+	require.NoError(t, err)
+	job = waitForJob(t, service, job.ID, domain.JobFailed)
+
+	assert.Contains(t, job.Error, "filtered or incomplete")
+	mockRepo.AssertNotCalled(t, "RestoreDelegations", mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateIndexingMetadata", mock.Anything, mock.Anything)
+}
+
+func TestService_IndexHistoricalRejectsCorruptSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "delegations.csv")
+	require.NoError(t, os.WriteFile(path, []byte("timestamp,amount,delegator,level,operation_hash,block_hash,baker,prev_baker\nyesterday,1,tz1a,1,op1,b1,tz1baker,\n"), 0o644))
+
+	mockRepo := new(MockBackupRepository)
+	mockRepo.On("GetIndexingMetadata").Return(int64(0), (*time.Time)(nil), nil)
+	service := newSeedingService(t, mockRepo, path)
+
+	job, _, err := service.enqueueJob(context.Background(), domain.Job{Kind: domain.JobIndexHistorical, Trigger: domain.TriggerManual})
+	require.NoError(t, err)
+	job = waitForJob(t, service, job.ID, domain.JobFailed)
+
+	assert.Contains(t, job.Error, "snapshot is corrupt")
+	mockRepo.AssertNotCalled(t, "RestoreDelegations", mock.Anything)
+}
@@ -0,0 +1,215 @@
+package application
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// maxRetainedJobs bounds how many finished jobs the in-memory store keeps;
+// the oldest are forgotten first.
+const maxRetainedJobs = 100
+
+// memoryJobStore keeps jobs in memory when the repository does not store
+// them. They are lost on restart and not shared with other replicas.
+type memoryJobStore struct {
+	mu   sync.Mutex
+	jobs []*domain.Job
+	now  func() time.Time
+}
+
+func newMemoryJobStore() *memoryJobStore {
+	return &memoryJobStore{now: time.Now}
+}
+
+func (m *memoryJobStore) CreateJob(ctx context.Context, job *domain.Job) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.jobs {
+		if other.Kind != job.Kind {
+			continue
+		}
+		if job.Singleton && other.Singleton && !other.Status.Finished() {
+			return false, nil
+		}
+		if job.ScheduledFor != nil && other.ScheduledFor != nil && job.ScheduledFor.Equal(*other.ScheduledFor) {
+			return false, nil
+		}
+	}
+
+	stored := cloneJob(*job)
+	m.jobs = append(m.jobs, &stored)
+	m.prune()
+	return true, nil
+}
+
+// prune drops the oldest finished jobs beyond maxRetainedJobs.
+func (m *memoryJobStore) prune() {
+	for i := 0; len(m.jobs) > maxRetainedJobs && i < len(m.jobs); {
+		if m.jobs[i].Status.Finished() {
+			m.jobs = slices.Delete(m.jobs, i, i+1)
+			continue
+		}
+		i++
+	}
+}
+
+func (m *memoryJobStore) find(id string) *domain.Job {
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func (m *memoryJobStore) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.find(id)
+	if job == nil {
+		return nil, domain.ErrJobNotFound
+	}
+	clone := cloneJob(*job)
+	return &clone, nil
+}
+
+func (m *memoryJobStore) ListJobs(ctx context.Context, query domain.JobQuery) ([]domain.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []domain.Job
+	for i := len(m.jobs) - 1; i >= 0 && len(jobs) < query.Limit; i-- {
+		job := m.jobs[i]
+		if query.Kind != "" && job.Kind != query.Kind {
+			continue
+		}
+		if len(query.Statuses) > 0 && !slices.Contains(query.Statuses, job.Status) {
+			continue
+		}
+		jobs = append(jobs, cloneJob(*job))
+	}
+	return jobs, nil
+}
+
+func (m *memoryJobStore) ClaimJob(ctx context.Context, worker string) (*domain.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	running := make(map[domain.JobKind]bool)
+	for _, job := range m.jobs {
+		if job.Status == domain.JobRunning {
+			running[job.Kind] = true
+		}
+	}
+
+	var due *domain.Job
+	for _, job := range m.jobs {
+		if job.Status != domain.JobPending || job.RunAt.After(now) || running[job.Kind] {
+			continue
+		}
+		if due == nil || job.RunAt.Before(due.RunAt) {
+			due = job
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+
+	due.Status = domain.JobRunning
+	due.Attempts++
+	due.Worker = worker
+	due.StartedAt = &now
+	due.FinishedAt = nil
+	clone := cloneJob(*due)
+	return &clone, nil
+}
+
+func (m *memoryJobStore) SaveJobProgress(ctx context.Context, job *domain.Job) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.find(job.ID)
+	if stored == nil || stored.Status != domain.JobRunning || stored.Worker != job.Worker {
+		return false, domain.ErrJobLeaseLost
+	}
+	stored.Progress = job.Progress
+	stored.Result = cloneJob(*job).Result
+	stored.FromLevel, stored.ToLevel = job.FromLevel, job.ToLevel
+	return stored.CancelRequested, nil
+}
+
+func (m *memoryJobStore) FinishJob(ctx context.Context, job *domain.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.find(job.ID)
+	if stored == nil || stored.Status != domain.JobRunning || stored.Worker != job.Worker {
+		return nil
+	}
+	cancelRequested := stored.CancelRequested
+	*stored = cloneJob(*job)
+	stored.CancelRequested = cancelRequested
+	stored.Worker = ""
+	return nil
+}
+
+func (m *memoryJobStore) CancelJob(ctx context.Context, id string) (*domain.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.find(id)
+	if job == nil {
+		return nil, domain.ErrJobNotFound
+	}
+	if job.Status.Finished() {
+		return nil, domain.ErrJobFinished
+	}
+
+	job.CancelRequested = true
+	if job.Status == domain.JobPending {
+		now := m.now()
+		job.Status = domain.JobCancelled
+		job.FinishedAt = &now
+	}
+	clone := cloneJob(*job)
+	return &clone, nil
+}
+
+// RequeueStaleJobs has nothing to do: jobs in memory only run on the
+// process holding them.
+func (m *memoryJobStore) RequeueStaleJobs(ctx context.Context, staleBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryJobStore) DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	m.jobs = slices.DeleteFunc(m.jobs, func(job *domain.Job) bool {
+		if job.Status.Finished() && job.FinishedAt != nil && job.FinishedAt.Before(before) {
+			deleted++
+			return true
+		}
+		return false
+	})
+	return deleted, nil
+}
+
+// cloneJob copies a job so that callers do not share its result with the
+// goroutine running it.
+func cloneJob(job domain.Job) domain.Job {
+	if job.Result != nil {
+		result := *job.Result
+		result.Mismatches = slices.Clone(job.Result.Mismatches)
+		job.Result = &result
+	}
+	return job
+}
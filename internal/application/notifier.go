@@ -0,0 +1,45 @@
+package application
+
+import (
+	"sync"
+)
+
+// commitNotifier wakes subscribers after delegations are committed. Signals
+// are coalesced: a subscriber that has not consumed the previous signal yet
+// is not signalled again, and is expected to re-read the store when it wakes.
+type commitNotifier struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func newCommitNotifier() *commitNotifier {
+	return &commitNotifier{
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+func (n *commitNotifier) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	n.subscribers[ch] = struct{}{}
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		delete(n.subscribers, ch)
+		n.mu.Unlock()
+	}
+}
+
+func (n *commitNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
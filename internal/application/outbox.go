@@ -0,0 +1,120 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
+)
+
+const outboxPruneInterval = time.Hour
+
+// OutboxRelay moves events from the transactional outbox to a Publisher.
+// A batch is marked published only after the publisher accepted all of it,
+// so a failure or crash leads to the batch being published again, never to
+// an event being skipped.
+type OutboxRelay struct {
+	repo      domain.OutboxRepository
+	publisher domain.Publisher
+	config    *config.Outbox
+	logger    *logger.Logger
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+	lastPrune time.Time
+}
+
+func NewOutboxRelay(repo domain.OutboxRepository, publisher domain.Publisher, cfg *config.Outbox, logger *logger.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		repo:      repo,
+		publisher: publisher,
+		config:    cfg,
+		logger:    logger,
+		stop:      make(chan struct{}),
+	}
+}
+
+func (r *OutboxRelay) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			// Keep relaying while full batches come back.
+			for {
+				n, err := r.RelayOnce(context.Background())
+				if err != nil {
+					metrics.OutboxRelayErrors.Inc()
+					r.logger.Errorw("Failed to relay outbox events", "error", err)
+				}
+				if err != nil || n < r.config.BatchSize {
+					break
+				}
+			}
+			r.pruneIfDue()
+
+			select {
+			case <-ticker.C:
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+
+	r.logger.Infow("Outbox relay started", "interval", r.config.PollInterval)
+}
+
+// Stop waits for the batch being published, then closes the publisher.
+func (r *OutboxRelay) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		r.wg.Wait()
+		if err := r.publisher.Close(); err != nil {
+			r.logger.Errorw("Failed to close outbox publisher", "error", err)
+		}
+		r.logger.Info("Outbox relay stopped")
+	})
+}
+
+// RelayOnce publishes one batch of pending events and returns its size.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.config.PublishTimeout)
+	defer cancel()
+
+	n, err := r.repo.RelayOutbox(ctx, r.config.BatchSize, r.publisher.Publish)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > 0 {
+		metrics.OutboxEventsPublished.Add(float64(n))
+		r.logger.Debugw("Relayed outbox events", "count", n)
+	}
+	return n, nil
+}
+
+func (r *OutboxRelay) pruneIfDue() {
+	if r.config.Retention <= 0 || time.Since(r.lastPrune) < outboxPruneInterval {
+		return
+	}
+	r.lastPrune = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pruned, err := r.repo.PruneOutbox(ctx, time.Now().Add(-r.config.Retention))
+	if err != nil {
+		r.logger.Errorw("Failed to prune outbox", "error", err)
+		return
+	}
+	if pruned > 0 {
+		r.logger.Infow("Pruned published outbox events", "count", pruned)
+	}
+}
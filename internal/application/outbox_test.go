@@ -0,0 +1,132 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOutbox hands its pending events to publish and drops them only when
+// publishing succeeds, like the Postgres implementation.
+type fakeOutbox struct {
+	mock.Mock
+	pending []domain.OutboxEvent
+}
+
+func (f *fakeOutbox) RelayOutbox(ctx context.Context, limit int, publish func(ctx context.Context, events []domain.OutboxEvent) error) (int, error) {
+	batch := f.pending
+	if len(batch) > limit {
+		batch = batch[:limit]
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(ctx, batch); err != nil {
+		return 0, err
+	}
+	f.pending = f.pending[len(batch):]
+	return len(batch), nil
+}
+
+func (f *fakeOutbox) PruneOutbox(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	args := f.Called(publishedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(ctx context.Context, events []domain.OutboxEvent) error {
+	args := m.Called(events)
+	return args.Error(0)
+}
+
+func (m *MockPublisher) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func newTestRelay(repo domain.OutboxRepository, publisher domain.Publisher) *OutboxRelay {
+	log, _ := logger.New("debug", "test")
+	return NewOutboxRelay(repo, publisher, &config.Outbox{
+		PollInterval:   time.Second,
+		PublishTimeout: 5 * time.Second,
+		BatchSize:      2,
+	}, log)
+}
+
+func TestOutboxRelay_RelayOnce(t *testing.T) {
+	events := []domain.OutboxEvent{
+		{ID: 1, Topic: domain.TopicDelegationCreated, Key: "op1", Payload: json.RawMessage(`{}`)},
+		{ID: 2, Topic: domain.TopicDelegationCreated, Key: "op2", Payload: json.RawMessage(`{}`)},
+		{ID: 3, Topic: domain.TopicDelegationCreated, Key: "op3", Payload: json.RawMessage(`{}`)},
+	}
+	outbox := &fakeOutbox{pending: events}
+	publisher := new(MockPublisher)
+	publisher.On("Publish", events[:2]).Return(nil).Once()
+	publisher.On("Publish", events[2:]).Return(nil).Once()
+
+	relay := newTestRelay(outbox, publisher)
+
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	n, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	publisher.AssertExpectations(t)
+}
+
+func TestOutboxRelay_RetriesFailedBatch(t *testing.T) {
+	events := []domain.OutboxEvent{
+		{ID: 1, Topic: domain.TopicDelegationCreated, Key: "op1", Payload: json.RawMessage(`{}`)},
+	}
+	outbox := &fakeOutbox{pending: events}
+	publisher := new(MockPublisher)
+	publisher.On("Publish", events).Return(errors.New("bus unavailable")).Once()
+	publisher.On("Publish", events).Return(nil).Once()
+
+	relay := newTestRelay(outbox, publisher)
+
+	_, err := relay.RelayOnce(context.Background())
+	require.Error(t, err)
+	assert.Len(t, outbox.pending, 1)
+
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, outbox.pending)
+
+	publisher.AssertExpectations(t)
+}
+
+func TestOutboxRelay_StopClosesPublisher(t *testing.T) {
+	outbox := &fakeOutbox{}
+	outbox.On("PruneOutbox", mock.Anything).Return(int64(0), nil)
+	publisher := new(MockPublisher)
+	publisher.On("Close").Return(nil)
+
+	relay := newTestRelay(outbox, publisher)
+	relay.config.Retention = time.Hour
+	relay.Start()
+	relay.Stop()
+	relay.Stop()
+
+	publisher.AssertNumberOfCalls(t, "Close", 1)
+}
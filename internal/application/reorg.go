@@ -0,0 +1,78 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+const (
+	// reorgDepth is how many of the latest indexed levels are compared with
+	// TzKT before each poll. Tenderbake finalises a block two levels later,
+	// so reorganisations do not reach further back.
+	reorgDepth = 5
+	// reorgCheckLimit bounds the delegations fetched for the comparison.
+	reorgCheckLimit = 1000
+)
+
+// rollBackReorg compares the delegations stored at the latest levels with
+// TzKT. When a level differs, a reorganisation replaced its block: the
+// delegations stored from that level on are deleted, with the state they
+// projected, and the last level still indexed is returned so that polling
+// fetches the levels again.
+func (s *Service) rollBackReorg(ctx context.Context, lastLevel int64) (int64, error) {
+	reorgs, ok := s.repo.(domain.ReorgRepository)
+	if !ok || lastLevel == 0 {
+		return lastLevel, nil
+	}
+
+	fromLevel := max(lastLevel-reorgDepth+1, 1)
+	stored, err := reorgs.GetBlockHashes(ctx, fromLevel)
+	if err != nil {
+		return 0, err
+	}
+	fetched, err := s.tzktClient.GetDelegationsInLevelRange(ctx, fromLevel, lastLevel, 0, reorgCheckLimit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch delegations from level %d: %w", fromLevel, err)
+	}
+	if len(fetched) == reorgCheckLimit {
+		s.logger.Warnw("Too many recent delegations to check for a reorganisation", "fromLevel", fromLevel)
+		return lastLevel, nil
+	}
+
+	current := make(map[int64]string, len(fetched))
+	for _, d := range fetched {
+		if d.Status == "applied" {
+			current[d.Level] = d.Block
+		}
+	}
+
+	var fork int64
+	diverges := func(level int64) {
+		if fork == 0 || level < fork {
+			fork = level
+		}
+	}
+	for level, hash := range stored {
+		if current[level] != hash {
+			diverges(level)
+		}
+	}
+	for level := range current {
+		if _, ok := stored[level]; !ok {
+			diverges(level)
+		}
+	}
+	if fork == 0 {
+		return lastLevel, nil
+	}
+
+	removed, err := reorgs.RollbackLevels(ctx, fork)
+	if err != nil {
+		return 0, err
+	}
+	s.logger.Warnw("Chain reorganisation, removed the delegations of replaced blocks",
+		"fromLevel", fork, "removed", removed)
+	return fork - 1, nil
+}
@@ -0,0 +1,102 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/tzkt"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReorgRepository struct {
+	MockRepository
+}
+
+func (m *MockReorgRepository) GetBlockHashes(ctx context.Context, fromLevel int64) (map[int64]string, error) {
+	args := m.Called(fromLevel)
+	return args.Get(0).(map[int64]string), args.Error(1)
+}
+
+func (m *MockReorgRepository) RollbackLevels(ctx context.Context, fromLevel int64) (int64, error) {
+	args := m.Called(fromLevel)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// blockTzkt serves one applied delegation per level in blocks, included in
+// the block of the given hash.
+func blockTzkt(head int64, blocks map[int64]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/head":
+			json.NewEncoder(w).Encode(tzkt.HeadResponse{Level: head, Timestamp: time.Now()})
+		case "/v1/operations/delegations":
+			from, _ := strconv.ParseInt(r.URL.Query().Get("level.ge"), 10, 64)
+			to, err := strconv.ParseInt(r.URL.Query().Get("level.le"), 10, 64)
+			if err != nil {
+				to = head
+			}
+			delegations := []tzkt.DelegationResponse{}
+			for level := from; level <= to; level++ {
+				if block, ok := blocks[level]; ok {
+					delegations = append(delegations, tzkt.DelegationResponse{
+						ID: level, Level: level, Block: block, Hash: "op" + strconv.FormatInt(level, 10),
+						Sender: tzkt.Sender{Address: "tz1delegator"}, Status: "applied",
+					})
+				}
+			}
+			json.NewEncoder(w).Encode(delegations)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestService_PollRollsBackReorg(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+
+	tests := []struct {
+		name       string
+		stored     map[int64]string
+		fork       int64
+		firstSaved int64
+		rollback   bool
+	}{
+		{"same chain", map[int64]string{98: "B98", 100: "B100"}, 0, 101, false},
+		{"replaced block", map[int64]string{98: "B98", 100: "old100"}, 100, 100, true},
+		{"dropped delegation", map[int64]string{98: "B98", 99: "B99", 100: "B100"}, 99, 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(blockTzkt(101, map[int64]string{98: "B98", 100: "B100", 101: "B101"}))
+			defer server.Close()
+
+			mockRepo := new(MockReorgRepository)
+			mockRepo.On("GetLastIndexedLevel").Return(int64(100), nil)
+			mockRepo.On("GetBlockHashes", int64(96)).Return(tt.stored, nil)
+			if tt.rollback {
+				mockRepo.On("RollbackLevels", tt.fork).Return(int64(1), nil)
+			}
+			mockRepo.On("SaveBatch", mock.MatchedBy(func(delegations []domain.Delegation) bool {
+				return len(delegations) > 0 && delegations[0].Level == strconv.FormatInt(tt.firstSaved, 10)
+			})).Return(nil, nil)
+
+			client := tzkt.NewClient(server.URL, 5*time.Second, 0, time.Millisecond, log)
+			service := NewService(mockRepo, client, &config.TzktAPI{}, log)
+			service.pollOnce()
+
+			mockRepo.AssertExpectations(t)
+			if !tt.rollback {
+				mockRepo.AssertNotCalled(t, "RollbackLevels", mock.Anything)
+			}
+		})
+	}
+}
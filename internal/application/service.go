@@ -4,15 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"net/netip"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/backup"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/tzkt"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/pubsub"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
@@ -28,15 +31,69 @@ type Service struct {
 	pollingTicker  *time.Ticker
 	stopPolling    chan struct{}
 	pollingStarted bool
+	pollingPaused  bool
 	mu             sync.RWMutex
+
+	largeMovementThreshold int64
+	lastCycleSync          time.Time
+	commits                *commitNotifier
+	hub                    *pubsub.Hub
+	healthConfig           config.Health
+	progress               indexerProgress
+	jobs                   domain.JobRepository
+	jobConfig              config.Jobs
+	// jobWake tells the job runner that a job was queued.
+	jobWake chan struct{}
+	// backfilling is set while polling waits for historical indexing, and
+	// backfillFailed while the last historical indexing job failed or was
+	// cancelled.
+	backfilling    atomic.Bool
+	backfillFailed atomic.Bool
+	backfill       backfillWatermark
+	backups        *backup.Manager
+	snapshot       config.Snapshot
+	apiKeyUsage    apiKeyUsage
+	// allowPrivateWebhooks lets webhooks target private networks, and
+	// lookupHost resolves webhook hosts before they are checked.
+	allowPrivateWebhooks bool
+	lookupHost           func(ctx context.Context, host string) ([]netip.Addr, error)
 }
 
+const (
+	defaultTopLimit        = 10
+	maxTopLimit            = 100
+	defaultMovementsLimit  = 100
+	maxMovementsLimit      = 1000
+	defaultDelegatorsLimit = 100
+	maxDelegatorsLimit     = 1000
+	defaultCyclesLimit     = 50
+	maxCyclesLimit         = 500
+	defaultPageLimit       = 20
+	maxPageLimit           = 100
+
+	cyclePageSize     = 1000
+	cycleSyncInterval = time.Hour
+
+	streamBatchSize = 500
+	// streamHeartbeatInterval also bounds how long a stream takes to pick up
+	// delegations committed by another replica.
+	streamHeartbeatInterval = 15 * time.Second
+
+	// DefaultLargeMovementThreshold is 100k XTZ, expressed in mutez.
+	DefaultLargeMovementThreshold int64 = 100_000_000_000
+)
+
 func NewService(
 	repo domain.DelegationRepository,
 	tzktClient *tzkt.Client,
 	config *config.TzktAPI,
 	logger *logger.Logger,
 ) *Service {
+	jobs, ok := repo.(domain.JobRepository)
+	if !ok {
+		jobs = newMemoryJobStore()
+	}
+
 	return &Service{
 		repo:        repo,
 		tzktClient:  tzktClient,
@@ -44,19 +101,441 @@ func NewService(
 		logger:      logger,
 		httpClient:  resty.New().SetTimeout(30 * time.Second),
 		stopPolling: make(chan struct{}),
+
+		largeMovementThreshold: DefaultLargeMovementThreshold,
+		commits:                newCommitNotifier(),
+		hub:                    pubsub.NewHub(),
+		healthConfig:           defaultHealthConfig,
+		jobs:                   jobs,
+		jobConfig:              defaultJobConfig,
+		jobWake:                make(chan struct{}, 1),
+		lookupHost:             lookupWebhookHost,
 	}
 }
 
-func (s *Service) GetDelegations(year *int) ([]domain.Delegation, error) {
-	return s.repo.FindAll(year)
+// SetLargeMovementThreshold sets the minimum amount, in mutez, used by
+// GetLargeMovements when the query does not specify one.
+func (s *Service) SetLargeMovementThreshold(threshold int64) {
+	s.largeMovementThreshold = threshold
 }
 
-func (s *Service) IndexDelegations(fromLevel int64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+func (s *Service) GetDelegations(ctx context.Context, year *int) ([]domain.Delegation, error) {
+	return s.repo.FindAll(ctx, year)
+}
+
+// ListDelegations returns delegations matching filter. Filters other than
+// the year require a repository implementing FilteredDelegationRepository.
+func (s *Service) ListDelegations(ctx context.Context, filter domain.DelegationFilter) ([]domain.Delegation, error) {
+	filtered, ok := s.repo.(domain.FilteredDelegationRepository)
+	if !ok {
+		if filter.Cycle != nil || filter.Baker != "" || filter.Delegator != "" {
+			return nil, fmt.Errorf("repository does not support filtered queries")
+		}
+		return s.repo.FindAll(ctx, filter.Year)
+	}
+
+	return filtered.FindByFilter(ctx, filter)
+}
+
+// ExportDelegations passes every delegation matching filter to emit, newest
+// first, without loading them all at once.
+func (s *Service) ExportDelegations(ctx context.Context, filter domain.DelegationFilter, emit func(domain.Delegation) error) error {
+	exporter, ok := s.repo.(domain.ExportRepository)
+	if !ok {
+		return fmt.Errorf("repository does not support exports")
+	}
+	return exporter.ExportDelegations(ctx, filter, emit)
+}
+
+// ListDelegationsPage returns one page of delegations, newest first, and
+// whether older ones remain.
+func (s *Service) ListDelegationsPage(ctx context.Context, query domain.DelegationPageQuery) (*domain.DelegationPage, error) {
+	paged, ok := s.repo.(domain.PagedDelegationRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support paginated queries")
+	}
+
+	query.Limit = clampLimit(query.Limit, defaultPageLimit, maxPageLimit)
+	limit := query.Limit
+	// One extra row tells whether there is a next page.
+	query.Limit++
+
+	delegations, err := paged.FindPage(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.DelegationPage{Data: delegations}
+	if len(delegations) > limit {
+		page.Data = delegations[:limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
+
+func (s *Service) GetDelegatorStates(ctx context.Context, delegators []string) ([]domain.DelegatorState, error) {
+	batch, ok := s.repo.(domain.BatchStateRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support batched state queries")
+	}
+	return batch.GetDelegatorStates(ctx, delegators)
+}
+
+// GetBakerSummaries returns one summary per requested baker, zero for
+// bakers without delegators.
+func (s *Service) GetBakerSummaries(ctx context.Context, bakers []string) ([]domain.BakerSummary, error) {
+	batch, ok := s.repo.(domain.BatchStateRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support batched state queries")
+	}
+
+	found, err := batch.GetBakerSummaries(ctx, bakers)
+	if err != nil {
+		return nil, err
+	}
+
+	byBaker := make(map[string]domain.BakerSummary, len(found))
+	for _, summary := range found {
+		byBaker[summary.Baker] = summary
+	}
+
+	summaries := make([]domain.BakerSummary, len(bakers))
+	for i, baker := range bakers {
+		summary, ok := byBaker[baker]
+		if !ok {
+			summary = domain.BakerSummary{Baker: baker, TotalAmount: "0"}
+		}
+		summaries[i] = summary
+	}
+	return summaries, nil
+}
+
+func (s *Service) GetCycleStats(ctx context.Context, query domain.CycleStatsQuery) ([]domain.CycleStats, error) {
+	cycles, ok := s.repo.(domain.CycleRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support cycle queries")
+	}
+
+	if query.FromCycle != nil && query.ToCycle != nil && *query.FromCycle > *query.ToCycle {
+		return nil, fmt.Errorf("from_cycle must not be after to_cycle")
+	}
+	query.Limit = clampLimit(query.Limit, defaultCyclesLimit, maxCyclesLimit)
+
+	return cycles.GetCycleStats(ctx, query)
+}
+
+// SyncCycles fetches cycle boundaries from TzKT, starting at the current
+// stored cycle so that projected boundaries of upcoming cycles get refreshed.
+func (s *Service) SyncCycles(ctx context.Context) error {
+	cycles, ok := s.repo.(domain.CycleRepository)
+	if !ok {
+		return nil
+	}
+
+	fromIndex, err := cycles.GetCurrentCycleIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current cycle: %w", err)
+	}
+
+	for {
+		tzktCycles, err := s.tzktClient.GetCycles(ctx, fromIndex, cyclePageSize)
+		if err != nil {
+			return fmt.Errorf("failed to fetch cycles from %d: %w", fromIndex, err)
+		}
+		if len(tzktCycles) == 0 {
+			break
+		}
+
+		domainCycles := make([]domain.Cycle, 0, len(tzktCycles))
+		for _, c := range tzktCycles {
+			domainCycles = append(domainCycles, domain.Cycle{
+				Index:      c.Index,
+				FirstLevel: c.FirstLevel,
+				LastLevel:  c.LastLevel,
+				StartTime:  c.StartTime,
+				EndTime:    c.EndTime,
+			})
+		}
+
+		if err := cycles.SaveCycles(ctx, domainCycles); err != nil {
+			return fmt.Errorf("failed to save cycles: %w", err)
+		}
+
+		if len(tzktCycles) < cyclePageSize {
+			break
+		}
+		fromIndex = tzktCycles[len(tzktCycles)-1].Index + 1
+	}
+
+	s.mu.Lock()
+	s.lastCycleSync = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *Service) syncCyclesIfStale(ctx context.Context) {
+	s.mu.RLock()
+	stale := time.Since(s.lastCycleSync) >= cycleSyncInterval
+	s.mu.RUnlock()
+
+	if !stale {
+		return
+	}
+
+	if err := s.SyncCycles(ctx); err != nil {
+		s.logger.Errorw("Failed to sync cycles", "error", err)
+	}
+}
+
+// StreamDelegations calls emit with delegations matching query as they are
+// committed, until ctx is done or emit fails. When query.AfterSeq is set,
+// stored delegations after it are replayed first. emit is called with a nil
+// slice at every heartbeat interval while the stream is idle.
+func (s *Service) StreamDelegations(ctx context.Context, query domain.StreamQuery, emit func([]domain.Delegation) error) error {
+	stream, ok := s.repo.(domain.StreamRepository)
+	if !ok {
+		return fmt.Errorf("repository does not support streaming")
+	}
+
+	// Subscribe before reading the store so no commit is missed in between.
+	updates, unsubscribe := s.commits.subscribe()
+	defer unsubscribe()
+
+	var after int64
+	if query.AfterSeq != nil {
+		after = *query.AfterSeq
+	} else {
+		latest, err := stream.GetLatestSeq(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get latest sequence: %w", err)
+		}
+		after = latest
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		latest, err := stream.GetLatestSeq(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get latest sequence: %w", err)
+		}
+
+		for after < latest {
+			batch, err := stream.FindAfterSeq(ctx, after, latest, query.Filter, streamBatchSize)
+			if err != nil {
+				return fmt.Errorf("failed to read delegations after %d: %w", after, err)
+			}
+			if len(batch) > 0 {
+				if err := emit(batch); err != nil {
+					return err
+				}
+			}
+			if len(batch) < streamBatchSize {
+				after = latest
+			} else {
+				after = batch[len(batch)-1].Seq
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-updates:
+		case <-heartbeat.C:
+			if err := emit(nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// saveBatch stores delegations and, once they are committed, wakes live
+// streams and publishes those that were not stored before to subscribers.
+func (s *Service) saveBatch(ctx context.Context, delegations []domain.Delegation) error {
+	created, err := s.repo.SaveBatch(ctx, delegations)
+	if err != nil {
+		return err
+	}
+	if len(created) > 0 {
+		s.commits.notify()
+		s.hub.Publish(created)
+	}
+	return nil
+}
+
+// SubscribeDelegations registers a live subscriber for delegations committed
+// by this instance from now on. Callers must Close the subscription.
+func (s *Service) SubscribeDelegations(buffer int) *pubsub.Subscription {
+	return s.hub.Subscribe(buffer)
+}
+
+func (s *Service) GetTimeSeries(ctx context.Context, query domain.TimeSeriesQuery) ([]domain.TimeSeriesBucket, error) {
+	analytics, ok := s.repo.(domain.AnalyticsRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support analytics queries")
+	}
+
+	if query.Interval == "" {
+		query.Interval = domain.IntervalDay
+	}
+	if !query.Interval.Valid() {
+		return nil, fmt.Errorf("invalid interval %q", query.Interval)
+	}
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+
+	return analytics.GetTimeSeries(ctx, query)
+}
+
+func (s *Service) GetTopReport(ctx context.Context, query domain.TopQuery) (*domain.TopReport, error) {
+	analytics, ok := s.repo.(domain.AnalyticsRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support analytics queries")
+	}
+
+	query.Limit = clampLimit(query.Limit, defaultTopLimit, maxTopLimit)
+
+	topDelegations, err := analytics.GetTopDelegations(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top delegations: %w", err)
+	}
+
+	topDelegators, err := analytics.GetTopDelegators(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top delegators: %w", err)
+	}
+
+	report := &domain.TopReport{
+		TopDelegations: topDelegations,
+		TopDelegators:  topDelegators,
+	}
+	if report.TopDelegations == nil {
+		report.TopDelegations = []domain.Movement{}
+	}
+	if report.TopDelegators == nil {
+		report.TopDelegators = []domain.TopDelegator{}
+	}
+
+	return report, nil
+}
+
+func (s *Service) GetLargeMovements(ctx context.Context, query domain.MovementQuery) (*domain.MovementsResponse, error) {
+	analytics, ok := s.repo.(domain.AnalyticsRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support analytics queries")
+	}
+
+	if query.MinAmount <= 0 {
+		query.MinAmount = s.largeMovementThreshold
+	}
+	query.Limit = clampLimit(query.Limit, defaultMovementsLimit, maxMovementsLimit)
+
+	movements, err := analytics.GetLargeMovements(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get large movements: %w", err)
+	}
+	if movements == nil {
+		movements = []domain.Movement{}
+	}
+
+	return &domain.MovementsResponse{
+		MinAmount: strconv.FormatInt(query.MinAmount, 10),
+		Data:      movements,
+	}, nil
+}
+
+func (s *Service) GetDelegatorState(ctx context.Context, query domain.DelegatorQuery) (*domain.DelegatorState, error) {
+	state, ok := s.repo.(domain.StateRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support delegation state queries")
+	}
+
+	if err := s.checkAsOf(ctx, state, query.AsOf); err != nil {
+		return nil, err
+	}
+
+	return state.GetDelegatorState(ctx, query)
+}
+
+func (s *Service) GetBakerDelegators(ctx context.Context, query domain.BakerDelegatorsQuery) (*domain.BakerDelegators, error) {
+	state, ok := s.repo.(domain.StateRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support delegation state queries")
+	}
+
+	if err := s.checkAsOf(ctx, state, query.AsOf); err != nil {
+		return nil, err
+	}
 
+	query.Limit = clampLimit(query.Limit, defaultDelegatorsLimit, maxDelegatorsLimit)
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	return state.GetBakerDelegators(ctx, query)
+}
+
+// checkAsOf rejects points in time outside the stored delegations, since the
+// replayed state there would silently miss delegations: before the oldest
+// one, the service started indexing later, whether from a recent window, a
+// snapshot or HistoricalStartDate, and after the latest one it has not
+// indexed yet.
+func (s *Service) checkAsOf(ctx context.Context, state domain.StateRepository, asOf *domain.AsOf) error {
+	if asOf == nil {
+		return nil
+	}
+
+	indexed, err := state.GetIndexedRange(ctx)
+	if err != nil {
+		return err
+	}
+	if indexed == nil {
+		return domain.ErrAsOfOutOfRange
+	}
+
+	switch {
+	case asOf.Timestamp != nil:
+		if asOf.Timestamp.Before(indexed.FirstTimestamp) || asOf.Timestamp.After(indexed.LastTimestamp) {
+			return domain.ErrAsOfOutOfRange
+		}
+	case asOf.Level != nil:
+		if *asOf.Level < indexed.FirstLevel || *asOf.Level > indexed.LastLevel {
+			return domain.ErrAsOfOutOfRange
+		}
+	default:
+		return domain.ErrAsOfOutOfRange
+	}
+	return nil
+}
+
+func clampLimit(limit, defaultLimit, maxLimit int) int {
+	if limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+// IndexDelegations fetches and stores delegations from fromLevel up to the
+// chain head. It stops early when ctx is done.
+func (s *Service) IndexDelegations(ctx context.Context, fromLevel int64) error {
+	return s.indexLevels(ctx, fromLevel, 0, nil)
+}
+
+// indexLevels fetches and stores the delegations from fromLevel to toLevel
+// included, or up to the chain head when toLevel is zero. progress, when
+// set, is called after each batch with the last level fetched and the
+// number of delegations stored.
+func (s *Service) indexLevels(ctx context.Context, fromLevel, toLevel int64, progress func(level int64, stored int)) error {
 	batchSize := 100
-	currentLevel := fromLevel
+	// Pages follow TzKT operation IDs, which grow with the level, so that a
+	// level holding more than a page of delegations is read in full.
+	var lastID int64
 
 	for {
 		select {
@@ -65,10 +544,10 @@ func (s *Service) IndexDelegations(fromLevel int64) error {
 		default:
 		}
 
-		delegations, err := s.tzktClient.GetDelegationsFromLevel(ctx, currentLevel, batchSize)
+		delegations, err := s.tzktClient.GetDelegationsInLevelRange(ctx, fromLevel, toLevel, lastID, batchSize)
 		if err != nil {
-			s.logger.Errorw("Failed to fetch delegations", "error", err, "level", currentLevel)
-			return fmt.Errorf("failed to fetch delegations from level %d: %w", currentLevel, err)
+			s.logger.Errorw("Failed to fetch delegations", "error", err, "level", fromLevel, "afterID", lastID)
+			return fmt.Errorf("failed to fetch delegations from level %d after operation %d: %w", fromLevel, lastID, err)
 		}
 
 		if len(delegations) == 0 {
@@ -78,13 +557,16 @@ func (s *Service) IndexDelegations(fromLevel int64) error {
 
 		domainDelegations := s.convertToDomainDelegations(delegations)
 
-		if err := s.repo.SaveBatch(domainDelegations); err != nil {
+		if err := s.saveBatch(ctx, domainDelegations); err != nil {
 			s.logger.Errorw("Failed to save batch", "error", err)
 			return fmt.Errorf("failed to save batch: %w", err)
 		}
 
 		lastDelegation := delegations[len(delegations)-1]
-		currentLevel = lastDelegation.Level + 1
+		lastID = lastDelegation.ID
+		if progress != nil {
+			progress(lastDelegation.Level, len(domainDelegations))
+		}
 
 		s.logger.Infow("Indexed batch of delegations",
 			"count", len(delegations),
@@ -96,12 +578,20 @@ func (s *Service) IndexDelegations(fromLevel int64) error {
 			break
 		}
 
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
 	}
 
 	return nil
 }
 
+// StartPolling starts polling TzKT for new delegations in the background.
+// With historical indexing, polling starts once the historical indexing job,
+// queued here, has succeeded; meanwhile the data is reported as backfilling,
+// or as incomplete while the job has failed or was cancelled.
 func (s *Service) StartPolling() error {
 	s.mu.Lock()
 	if s.pollingStarted {
@@ -109,25 +599,79 @@ func (s *Service) StartPolling() error {
 		return fmt.Errorf("polling already started")
 	}
 	s.pollingStarted = true
+	s.pollingTicker = time.NewTicker(s.config.PollingInterval)
+	s.backfilling.Store(s.config.HistoricalIndexing)
 	s.mu.Unlock()
 
-	if s.config.HistoricalIndexing {
-		s.logger.Info("Starting historical indexing...")
-		if err := s.indexHistorical(); err != nil {
-			s.logger.Errorw("Historical indexing failed", "error", err)
-		} else {
-			s.logger.Info("Historical indexing completed successfully")
+	go func() {
+		s.queueBakerBackfill(context.Background())
+		if s.config.HistoricalIndexing {
+			if !s.awaitHistoricalIndexing() {
+				return
+			}
+			s.backfilling.Store(false)
 		}
-	}
+		s.logger.Infow("Polling started", "interval", s.config.PollingInterval)
+		s.pollLoop()
+	}()
 
-	s.pollingTicker = time.NewTicker(s.config.PollingInterval)
+	return nil
+}
 
-	go s.pollLoop()
+// awaitHistoricalIndexing queues a historical indexing job, unless one is
+// already queued, and waits until the latest one has succeeded. Polling
+// would skip the delegations a failed or cancelled job left out, so it keeps
+// waiting until the job is retried. It returns false if polling was stopped
+// in the meantime.
+func (s *Service) awaitHistoricalIndexing() bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stopPolling:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
-	s.logger.Infow("Polling started", "interval", s.config.PollingInterval)
-	return nil
+	s.queueHistoricalIndexing(ctx)
+
+	for {
+		latest, err := s.jobs.ListJobs(ctx, domain.JobQuery{Kind: domain.JobIndexHistorical, Limit: 1})
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				s.logger.Warnw("Failed to check historical indexing", "error", err)
+			}
+		case len(latest) == 0:
+			s.queueHistoricalIndexing(ctx)
+		case latest[0].Status == domain.JobSucceeded:
+			s.backfillFailed.Store(false)
+			return true
+		case latest[0].Status.Finished():
+			if !s.backfillFailed.Swap(true) {
+				s.logger.Errorw("Historical indexing did not complete, polling waits until it is retried",
+					"job_id", latest[0].ID, "status", latest[0].Status, "error", latest[0].Error)
+			}
+		default:
+			s.backfillFailed.Store(false)
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(s.jobConfig.PollInterval):
+		}
+	}
 }
 
+func (s *Service) queueHistoricalIndexing(ctx context.Context) {
+	if _, _, err := s.enqueueJob(ctx, domain.Job{Kind: domain.JobIndexHistorical, Trigger: domain.TriggerStartup, Singleton: true}); err != nil {
+		s.logger.Errorw("Failed to queue historical indexing", "error", err)
+	}
+}
+
+// StopPolling stops the polling loop.
 func (s *Service) StopPolling() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -144,13 +688,104 @@ func (s *Service) StopPolling() {
 	s.logger.Info("Polling stopped")
 }
 
+// PausePolling stops polling TzKT until ResumePolling is called. A poll
+// under way is completed. With a repository that stores the polling state,
+// the pause applies to every replica and survives restarts.
+func (s *Service) PausePolling(ctx context.Context) error {
+	return s.setPollingPaused(ctx, true)
+}
+
+// ResumePolling undoes PausePolling; the next poll happens at the next tick.
+func (s *Service) ResumePolling(ctx context.Context) error {
+	return s.setPollingPaused(ctx, false)
+}
+
+func (s *Service) setPollingPaused(ctx context.Context, paused bool) error {
+	if state, ok := s.repo.(domain.PollingStateRepository); ok {
+		if err := state.SetPollingPaused(ctx, paused); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pollingPaused != paused {
+		s.pollingPaused = paused
+		if paused {
+			s.logger.Info("Polling paused")
+		} else {
+			s.logger.Info("Polling resumed")
+		}
+	}
+	return nil
+}
+
+// isPollingPaused reads the stored polling state, which another replica may
+// have changed. If it cannot be read, the last state known is kept.
+func (s *Service) isPollingPaused(ctx context.Context) bool {
+	if state, ok := s.repo.(domain.PollingStateRepository); ok {
+		paused, err := state.IsPollingPaused(ctx)
+		if err != nil {
+			s.logger.Warnw("Failed to read polling state", "error", err)
+		} else {
+			s.mu.Lock()
+			s.pollingPaused = paused
+			s.mu.Unlock()
+			return paused
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pollingPaused
+}
+
+// IndexerStatus reports whether this instance polls and how far the index
+// has got.
+func (s *Service) IndexerStatus(ctx context.Context) (*domain.IndexerStatus, error) {
+	lastLevel, err := s.repo.GetLastIndexedLevel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	running, err := s.jobs.ListJobs(ctx, domain.JobQuery{Statuses: []domain.JobStatus{domain.JobRunning}, Limit: maxJobsLimit})
+	if err != nil {
+		return nil, err
+	}
+
+	paused := s.isPollingPaused(ctx)
+
+	s.mu.RLock()
+	status := &domain.IndexerStatus{
+		Polling:          s.pollingStarted && !paused,
+		Paused:           paused,
+		LastIndexedLevel: lastLevel,
+		RunningJobs:      len(running),
+	}
+	s.mu.RUnlock()
+
+	head, synced := s.progress.snapshot()
+	if head != nil {
+		status.HeadLevel = head.Level
+	}
+	if synced != nil {
+		status.SyncedLevel = synced.Level
+	}
+	return status, nil
+}
+
+func (s *Service) pollUnlessPaused() {
+	if !s.isPollingPaused(context.Background()) {
+		s.pollOnce()
+	}
+}
+
 func (s *Service) pollLoop() {
-	s.pollOnce()
+	s.pollUnlessPaused()
 
 	for {
 		select {
 		case <-s.pollingTicker.C:
-			s.pollOnce()
+			s.pollUnlessPaused()
 		case <-s.stopPolling:
 			return
 		}
@@ -161,7 +796,19 @@ func (s *Service) pollOnce() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	lastLevel, err := s.repo.GetLastIndexedLevel()
+	s.syncCyclesIfStale(ctx)
+
+	// The head is read first: once the delegations fetched below are saved,
+	// everything up to it is indexed.
+	head, err := s.tzktClient.GetHead(ctx)
+	if err != nil {
+		s.logger.Warnw("Failed to fetch chain head", "error", err)
+		head = nil
+	} else {
+		s.progress.observeHead(head)
+	}
+
+	lastLevel, err := s.repo.GetLastIndexedLevel(ctx)
 	if err != nil {
 		s.logger.Errorw("Failed to get last indexed level", "error", err)
 		metrics.PollingErrors.Inc()
@@ -169,9 +816,11 @@ func (s *Service) pollOnce() {
 	}
 	metrics.UpdateLastIndexedLevel(lastLevel)
 
+	const recentLimit, newLimit = 1000, 100
+
 	if lastLevel == 0 {
 		thirtyDaysAgo := time.Now().Add(-30 * 24 * time.Hour)
-		delegations, err := s.tzktClient.GetDelegationsSince(ctx, thirtyDaysAgo, 1000)
+		delegations, err := s.tzktClient.GetDelegationsSince(ctx, thirtyDaysAgo, recentLimit)
 		if err != nil {
 			s.logger.Errorw("Failed to fetch recent delegations", "error", err)
 			return
@@ -179,17 +828,27 @@ func (s *Service) pollOnce() {
 
 		if len(delegations) > 0 {
 			domainDelegations := s.convertToDomainDelegations(delegations)
-			if err := s.repo.SaveBatch(domainDelegations); err != nil {
+			if err := s.saveBatch(ctx, domainDelegations); err != nil {
 				s.logger.Errorw("Failed to save delegations", "error", err)
 				metrics.RecordDelegationProcessed("error")
-			} else {
-				s.logger.Infow("Saved recent delegations", "count", len(delegations))
-				metrics.DelegationsStored.Add(float64(len(delegations)))
-				metrics.RecordDelegationProcessed("success")
+				return
 			}
+			s.logger.Infow("Saved recent delegations", "count", len(delegations))
+			metrics.DelegationsStored.Add(float64(len(delegations)))
+			metrics.RecordDelegationProcessed("success")
+		}
+		if head != nil && len(delegations) < recentLimit {
+			s.progress.markSynced(head)
 		}
 	} else {
-		delegations, err := s.tzktClient.GetDelegationsFromLevel(ctx, lastLevel+1, 100)
+		lastLevel, err = s.rollBackReorg(ctx, lastLevel)
+		if err != nil {
+			s.logger.Errorw("Failed to check for a chain reorganisation", "error", err)
+			metrics.PollingErrors.Inc()
+			return
+		}
+
+		delegations, err := s.tzktClient.GetDelegationsFromLevel(ctx, lastLevel+1, newLimit)
 		if err != nil {
 			s.logger.Errorw("Failed to fetch new delegations", "error", err, "fromLevel", lastLevel+1)
 			metrics.PollingErrors.Inc()
@@ -198,46 +857,51 @@ func (s *Service) pollOnce() {
 
 		if len(delegations) > 0 {
 			domainDelegations := s.convertToDomainDelegations(delegations)
-			if err := s.repo.SaveBatch(domainDelegations); err != nil {
+			if err := s.saveBatch(ctx, domainDelegations); err != nil {
 				s.logger.Errorw("Failed to save new delegations", "error", err)
 				metrics.RecordDelegationProcessed("error")
-			} else {
-				s.logger.Infow("Saved new delegations", "count", len(delegations), "fromLevel", lastLevel+1)
-				metrics.DelegationsStored.Add(float64(len(delegations)))
-				metrics.RecordDelegationProcessed("success")
-				metrics.UpdateLastIndexedLevel(lastLevel + 1)
+				return
 			}
+			s.logger.Infow("Saved new delegations", "count", len(delegations), "fromLevel", lastLevel+1)
+			metrics.DelegationsStored.Add(float64(len(delegations)))
+			metrics.RecordDelegationProcessed("success")
+			metrics.UpdateLastIndexedLevel(lastLevel + 1)
+		}
+		if head != nil && len(delegations) < newLimit {
+			s.progress.markSynced(head)
 		}
 	}
 }
 
-func (s *Service) indexHistorical() error {
+// indexHistorical indexes the delegations from the configured start date,
+// or from the last one stored, and returns how many it processed. progress,
+// when set, is called after each batch is stored with its size and its last
+// delegation: delegations are fetched in chain order, so every one up to
+// that delegation is stored.
+func (s *Service) indexHistorical(ctx context.Context, progress func(count int, last domain.Delegation)) (int, error) {
+	stats, err := s.statsRepository()
+	if err != nil {
+		return 0, err
+	}
+
 	// Check for existing data first
-	existingDelegations, err := s.repo.FindAll(nil)
+	lastTimestamp, err := stats.GetLastTimestamp(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to check existing data: %w", err)
+		return 0, fmt.Errorf("failed to check existing data: %w", err)
 	}
 
 	var startDate time.Time
-	if len(existingDelegations) > 0 {
-		// Find the most recent delegation
-		var lastTimestamp time.Time
-		for _, d := range existingDelegations {
-			if d.Timestamp.After(lastTimestamp) {
-				lastTimestamp = d.Timestamp
-			}
-		}
+	if lastTimestamp != nil {
 		// Start from 1 second after the last timestamp to avoid duplicates
 		startDate = lastTimestamp.Add(1 * time.Second)
-		s.logger.Infow("Continuing from existing data", 
-			"existingCount", len(existingDelegations),
-			"lastTimestamp", lastTimestamp,
+		s.logger.Infow("Continuing from existing data",
+			"lastTimestamp", *lastTimestamp,
 			"resumeFrom", startDate)
 	} else {
 		// No existing data, start from configured date
 		startDate, err = time.Parse("2006-01-02", s.config.HistoricalStartDate)
 		if err != nil {
-			return fmt.Errorf("invalid historical start date: %w", err)
+			return 0, fmt.Errorf("invalid historical start date: %w", err)
 		}
 		s.logger.Infow("Starting fresh historical indexing", "startDate", startDate)
 	}
@@ -245,12 +909,9 @@ func (s *Service) indexHistorical() error {
 	// Skip if we're already up to date (within last hour)
 	if time.Since(startDate) < 1*time.Hour {
 		s.logger.Info("Historical data is up to date, skipping historical indexing")
-		return nil
+		return 0, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
-	defer cancel()
-
 	g, gctx := errgroup.WithContext(ctx)
 
 	delegationsChan, errorChan := s.tzktClient.GetHistoricalDelegations(gctx, startDate, 500)
@@ -264,12 +925,15 @@ func (s *Service) indexHistorical() error {
 			case delegations, ok := <-delegationsChan:
 				if !ok {
 					if len(batchBuffer) > 0 {
-						if err := s.repo.SaveBatch(batchBuffer); err != nil {
+						if err := s.saveBatch(gctx, batchBuffer); err != nil {
 							return fmt.Errorf("failed to save final batch: %w", err)
 						}
 						metrics.DelegationsStored.Add(float64(len(batchBuffer)))
 						metrics.RecordDelegationProcessed("success")
 						s.logger.Infow("Saved final batch", "count", len(batchBuffer))
+						if progress != nil {
+							progress(len(batchBuffer), batchBuffer[len(batchBuffer)-1])
+						}
 					}
 					return nil
 				}
@@ -279,11 +943,14 @@ func (s *Service) indexHistorical() error {
 				processedCount += len(delegations)
 
 				if len(batchBuffer) >= 1000 {
-					if err := s.repo.SaveBatch(batchBuffer); err != nil {
+					if err := s.saveBatch(gctx, batchBuffer); err != nil {
 						return fmt.Errorf("failed to save batch: %w", err)
 					}
 					metrics.DelegationsStored.Add(float64(len(batchBuffer)))
 					metrics.RecordDelegationProcessed("success")
+					if progress != nil {
+						progress(len(batchBuffer), batchBuffer[len(batchBuffer)-1])
+					}
 					s.logger.Infow("Historical indexing progress",
 						"processed", processedCount,
 						"lastTimestamp", delegations[len(delegations)-1].Timestamp,
@@ -302,86 +969,71 @@ func (s *Service) indexHistorical() error {
 	})
 
 	if err := g.Wait(); err != nil {
-		return fmt.Errorf("historical indexing failed: %w", err)
+		return processedCount, fmt.Errorf("historical indexing failed: %w", err)
 	}
 
 	metrics.HistoricalIndexingProgress.Set(100)
 	s.logger.Infow("Historical indexing completed", "totalProcessed", processedCount)
-	
-	// Verify sync completeness
-	if err := s.verifySyncCompleteness(startDate); err != nil {
-		s.logger.Warnw("Sync verification detected issues", "error", err)
-	}
-	
-	// Create a backup after successful indexing
-	if processedCount > 0 {
-		s.createBackup()
-	}
-	
-	return nil
+
+	return processedCount, nil
 }
 
-func (s *Service) verifySyncCompleteness(startDate time.Time) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// verifySyncCompleteness compares the number of delegations stored since
+// startDate with the number TzKT reports as applied.
+func (s *Service) verifySyncCompleteness(ctx context.Context, startDate time.Time) (*domain.VerificationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	
+
 	// Use a simple HTTP request to get the count from TzKT (only applied/successful)
-	url := fmt.Sprintf("%s/v1/operations/delegations/count?timestamp.ge=%s&status=applied", 
+	url := fmt.Sprintf("%s/v1/operations/delegations/count?timestamp.ge=%s&status=applied",
 		s.config.BaseURL, startDate.Format("2006-01-02"))
-	
+
 	resp, err := s.httpClient.R().
 		SetContext(ctx).
 		Get(url)
-	
+
 	if err != nil {
-		return fmt.Errorf("failed to get TzKT count: %w", err)
+		return nil, fmt.Errorf("failed to get TzKT count: %w", err)
 	}
-	
+
 	var tzktCount int
 	if err := json.Unmarshal(resp.Body(), &tzktCount); err != nil {
-		return fmt.Errorf("failed to parse TzKT count: %w", err)
+		return nil, fmt.Errorf("failed to parse TzKT count: %w", err)
 	}
-	
+
 	// Get count from our database
-	dbDelegations, err := s.repo.FindAll(nil)
+	stats, err := s.statsRepository()
 	if err != nil {
-		return fmt.Errorf("failed to get DB count: %w", err)
+		return nil, err
 	}
-	
-	dbCount := 0
-	for _, d := range dbDelegations {
-		if d.Timestamp.After(startDate) || d.Timestamp.Equal(startDate) {
-			dbCount++
-		}
+	stored, err := stats.CountDelegations(ctx, &startDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DB count: %w", err)
 	}
-	
+	dbCount := int(stored)
+
 	difference := tzktCount - dbCount
-	percentage := float64(difference) / float64(tzktCount) * 100
-	
+	percentage := 0.0
+	if tzktCount > 0 {
+		percentage = float64(difference) / float64(tzktCount) * 100
+	}
+
 	s.logger.Infow("Sync verification complete",
 		"dbCount", dbCount,
 		"tzktCount", tzktCount,
 		"difference", difference,
 		"percentageMissing", fmt.Sprintf("%.2f%%", percentage))
-	
+
 	if difference > 0 {
-		return fmt.Errorf("missing %d delegations (%.2f%%) from TzKT", difference, percentage)
+		s.logger.Warnw("Sync verification detected missing delegations",
+			"missing", difference, "percentageMissing", fmt.Sprintf("%.2f%%", percentage))
 	}
-	
-	return nil
-}
 
-func (s *Service) createBackup() {
-	s.logger.Info("Creating database backup...")
-	// Run backup script in background
-	go func() {
-		cmd := exec.Command("/app/backup.sh")
-		if err := cmd.Run(); err != nil {
-			s.logger.Errorw("Failed to create backup", "error", err)
-		} else {
-			s.logger.Info("Database backup created successfully")
-		}
-	}()
+	return &domain.VerificationResult{
+		Expected:   int64(tzktCount),
+		Stored:     int64(dbCount),
+		Mismatches: []domain.LevelRangeCount{},
+	}, nil
 }
 
 func (s *Service) convertToDomainDelegations(tzktDelegations []tzkt.DelegationResponse) []domain.Delegation {
@@ -392,7 +1044,15 @@ func (s *Service) convertToDomainDelegations(tzktDelegations []tzkt.DelegationRe
 		if d.Status != "applied" {
 			continue
 		}
-		
+
+		baker, prevBaker := "", ""
+		if d.NewDelegate != nil {
+			baker = d.NewDelegate.Address
+		}
+		if d.PrevDelegate != nil {
+			prevBaker = d.PrevDelegate.Address
+		}
+
 		delegation := domain.Delegation{
 			ID:            uuid.New().String(),
 			Timestamp:     d.Timestamp,
@@ -401,6 +1061,8 @@ func (s *Service) convertToDomainDelegations(tzktDelegations []tzkt.DelegationRe
 			Level:         strconv.FormatInt(d.Level, 10),
 			BlockHash:     d.Block,
 			OperationHash: d.Hash,
+			Baker:         baker,
+			PrevBaker:     prevBaker,
 			CreatedAt:     time.Now(),
 		}
 		delegations = append(delegations, delegation)
@@ -409,31 +1071,20 @@ func (s *Service) convertToDomainDelegations(tzktDelegations []tzkt.DelegationRe
 	return delegations
 }
 
-func (s *Service) GetStats() (map[string]interface{}, error) {
-	delegations, err := s.repo.FindAll(nil)
-	if err != nil {
-		return nil, err
-	}
-
-	stats := make(map[string]interface{})
-	stats["total_delegations"] = len(delegations)
-
-	if len(delegations) > 0 {
-		stats["latest_delegation"] = delegations[0].Timestamp
-		stats["oldest_delegation"] = delegations[len(delegations)-1].Timestamp
+func (s *Service) statsRepository() (domain.DelegationStatsRepository, error) {
+	stats, ok := s.repo.(domain.DelegationStatsRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support delegation statistics")
 	}
+	return stats, nil
+}
 
-	uniqueDelegators := make(map[string]bool)
-	totalAmount := int64(0)
-	for _, d := range delegations {
-		uniqueDelegators[d.Delegator] = true
-		if amount, err := strconv.ParseInt(d.Amount, 10, 64); err == nil {
-			totalAmount += amount
-		}
+// GetStats returns the totals of the stored delegations, aggregated by the
+// repository.
+func (s *Service) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	stats, err := s.statsRepository()
+	if err != nil {
+		return nil, err
 	}
-
-	stats["unique_delegators"] = len(uniqueDelegators)
-	stats["total_amount"] = strconv.FormatInt(totalAmount, 10)
-
-	return stats, nil
+	return stats.GetStats(ctx)
 }
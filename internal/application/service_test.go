@@ -2,6 +2,9 @@ package application
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 	"time"
@@ -20,31 +23,73 @@ type MockRepository struct {
 	mock.Mock
 }
 
-func (m *MockRepository) Save(delegation *domain.Delegation) error {
+func (m *MockRepository) Save(ctx context.Context, delegation *domain.Delegation) error {
 	args := m.Called(delegation)
 	return args.Error(0)
 }
 
-func (m *MockRepository) SaveBatch(delegations []domain.Delegation) error {
+func (m *MockRepository) SaveBatch(ctx context.Context, delegations []domain.Delegation) ([]domain.Delegation, error) {
 	args := m.Called(delegations)
-	return args.Error(0)
+	created, _ := args.Get(0).([]domain.Delegation)
+	return created, args.Error(1)
 }
 
-func (m *MockRepository) FindAll(year *int) ([]domain.Delegation, error) {
+func (m *MockRepository) FindAll(ctx context.Context, year *int) ([]domain.Delegation, error) {
 	args := m.Called(year)
 	return args.Get(0).([]domain.Delegation), args.Error(1)
 }
 
-func (m *MockRepository) GetLastIndexedLevel() (int64, error) {
+func (m *MockRepository) GetLastIndexedLevel(ctx context.Context) (int64, error) {
 	args := m.Called()
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRepository) Exists(delegator string, level string) (bool, error) {
+func (m *MockRepository) Exists(ctx context.Context, delegator string, level string) (bool, error) {
 	args := m.Called(delegator, level)
 	return args.Get(0).(bool), args.Error(1)
 }
 
+func (m *MockRepository) GetLastTimestamp(ctx context.Context) (*time.Time, error) {
+	args := m.Called()
+	last, _ := args.Get(0).(*time.Time)
+	return last, args.Error(1)
+}
+
+func (m *MockRepository) CountDelegations(ctx context.Context, since *time.Time) (int64, error) {
+	args := m.Called(since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	args := m.Called()
+	stats, _ := args.Get(0).(map[string]interface{})
+	return stats, args.Error(1)
+}
+
+type MockAnalyticsRepository struct {
+	MockRepository
+}
+
+func (m *MockAnalyticsRepository) GetTimeSeries(ctx context.Context, query domain.TimeSeriesQuery) ([]domain.TimeSeriesBucket, error) {
+	args := m.Called(query)
+	return args.Get(0).([]domain.TimeSeriesBucket), args.Error(1)
+}
+
+func (m *MockAnalyticsRepository) GetTopDelegations(ctx context.Context, query domain.TopQuery) ([]domain.Movement, error) {
+	args := m.Called(query)
+	return args.Get(0).([]domain.Movement), args.Error(1)
+}
+
+func (m *MockAnalyticsRepository) GetTopDelegators(ctx context.Context, query domain.TopQuery) ([]domain.TopDelegator, error) {
+	args := m.Called(query)
+	return args.Get(0).([]domain.TopDelegator), args.Error(1)
+}
+
+func (m *MockAnalyticsRepository) GetLargeMovements(ctx context.Context, query domain.MovementQuery) ([]domain.Movement, error) {
+	args := m.Called(query)
+	return args.Get(0).([]domain.Movement), args.Error(1)
+}
+
 type MockTzktClient struct {
 	mock.Mock
 }
@@ -108,7 +153,7 @@ func TestService_GetDelegations(t *testing.T) {
 
 	mockRepo.On("FindAll", (*int)(nil)).Return(expectedDelegations, nil)
 
-	delegations, err := service.GetDelegations(nil)
+	delegations, err := service.GetDelegations(context.Background(), nil)
 	require.NoError(t, err)
 	assert.Len(t, delegations, 2)
 	assert.Equal(t, "tz1abc123", delegations[0].Delegator)
@@ -140,7 +185,7 @@ func TestService_GetDelegationsWithYear(t *testing.T) {
 
 	mockRepo.On("FindAll", &year).Return(expectedDelegations, nil)
 
-	delegations, err := service.GetDelegations(&year)
+	delegations, err := service.GetDelegations(context.Background(), &year)
 	require.NoError(t, err)
 	assert.Len(t, delegations, 1)
 	assert.Equal(t, 2023, delegations[0].Timestamp.Year())
@@ -180,7 +225,7 @@ func TestService_ConvertToDomainDelegations(t *testing.T) {
 	
 	mockRepo.On("FindAll", (*int)(nil)).Return(expectedDelegations, nil)
 	
-	delegations, err := service.GetDelegations(nil)
+	delegations, err := service.GetDelegations(context.Background(), nil)
 	require.NoError(t, err)
 	
 	assert.Len(t, delegations, 2)
@@ -202,6 +247,55 @@ func TestService_IndexDelegations(t *testing.T) {
 	t.Skip("Skipping IndexDelegations test - requires integration testing")
 }
 
+func TestService_IndexLevelsReadsBusyLevels(t *testing.T) {
+	// 250 delegations in level 500, then one in level 501.
+	var all []tzkt.DelegationResponse
+	for id := int64(1); id <= 250; id++ {
+		all = append(all, tzkt.DelegationResponse{ID: id, Level: 500, Hash: "op" + strconv.FormatInt(id, 10), Status: "applied"})
+	}
+	all = append(all, tzkt.DelegationResponse{ID: 251, Level: 501, Hash: "op251", Status: "applied"})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "500", query.Get("level.ge"))
+		assert.Equal(t, "501", query.Get("level.le"))
+		afterID, _ := strconv.ParseInt(query.Get("id.gt"), 10, 64)
+		limit, _ := strconv.Atoi(query.Get("limit"))
+
+		page := []tzkt.DelegationResponse{}
+		for _, d := range all {
+			if d.ID > afterID && len(page) < limit {
+				page = append(page, d)
+			}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	log, _ := logger.New("debug", "test")
+	client := tzkt.NewClient(server.URL, 5*time.Second, 0, time.Millisecond, log)
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, client, &config.TzktAPI{}, log)
+
+	saved := map[string]bool{}
+	mockRepo.On("SaveBatch", mock.Anything).Run(func(args mock.Arguments) {
+		for _, d := range args.Get(0).([]domain.Delegation) {
+			saved[d.OperationHash] = true
+		}
+	}).Return(nil, nil)
+
+	var levels []int64
+	err := service.indexLevels(context.Background(), 500, 501, func(level int64, stored int) {
+		levels = append(levels, level)
+	})
+
+	require.NoError(t, err)
+	assert.Len(t, saved, 251)
+	assert.True(t, saved["op250"])
+	assert.True(t, saved["op251"])
+	assert.Equal(t, []int64{500, 500, 501}, levels)
+}
+
 func TestService_GetStats(t *testing.T) {
 	mockRepo := new(MockRepository)
 	log, _ := logger.New("debug", "test")
@@ -209,40 +303,439 @@ func TestService_GetStats(t *testing.T) {
 
 	service := NewService(mockRepo, nil, cfg, log)
 
-	delegations := []domain.Delegation{
-		{
-			ID:        uuid.New().String(),
-			Timestamp: time.Now().Add(-24 * time.Hour),
-			Amount:    "1000000",
-			Delegator: "tz1abc123",
-			Level:     "1000",
-		},
-		{
-			ID:        uuid.New().String(),
-			Timestamp: time.Now().Add(-12 * time.Hour),
-			Amount:    "2000000",
-			Delegator: "tz1def456",
-			Level:     "1001",
-		},
-		{
-			ID:        uuid.New().String(),
-			Timestamp: time.Now().Add(-6 * time.Hour),
-			Amount:    "3000000",
-			Delegator: "tz1abc123",
-			Level:     "1002",
-		},
-	}
-
-	mockRepo.On("FindAll", (*int)(nil)).Return(delegations, nil)
+	latest := time.Now().Add(-6 * time.Hour)
+	mockRepo.On("GetStats").Return(map[string]interface{}{
+		"total_delegations": int64(3),
+		"unique_delegators": int64(2),
+		"total_amount":      "6000000",
+		"latest_delegation": latest,
+		"oldest_delegation": latest.Add(-18 * time.Hour),
+	}, nil)
 
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(context.Background())
 	require.NoError(t, err)
 
-	assert.Equal(t, 3, stats["total_delegations"])
-	assert.Equal(t, 2, stats["unique_delegators"])
+	assert.Equal(t, int64(3), stats["total_delegations"])
+	assert.Equal(t, int64(2), stats["unique_delegators"])
 	assert.Equal(t, strconv.FormatInt(6000000, 10), stats["total_amount"])
 	assert.NotNil(t, stats["latest_delegation"])
 	assert.NotNil(t, stats["oldest_delegation"])
 
+	// The delegations are aggregated by the repository, not loaded.
+	mockRepo.AssertNotCalled(t, "FindAll", mock.Anything)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestService_VerifySyncCompleteness(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/operations/delegations/count", r.URL.Path)
+		assert.Equal(t, "2024-01-01", r.URL.Query().Get("timestamp.ge"))
+		w.Write([]byte("120"))
+	}))
+	defer server.Close()
+
+	mockRepo := new(MockRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{BaseURL: server.URL}, log)
+
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("CountDelegations", &startDate).Return(int64(100), nil)
+
+	result, err := service.verifySyncCompleteness(context.Background(), startDate)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(120), result.Expected)
+	assert.Equal(t, int64(100), result.Stored)
+	mockRepo.AssertNotCalled(t, "FindAll", mock.Anything)
+}
+
+func TestService_GetTimeSeries(t *testing.T) {
+	mockRepo := new(MockAnalyticsRepository)
+	log, _ := logger.New("debug", "test")
+	cfg := &config.TzktAPI{}
+
+	service := NewService(mockRepo, nil, cfg, log)
+
+	buckets := []domain.TimeSeriesBucket{
+		{BucketStart: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Count: 2, TotalAmount: "10"},
+	}
+	mockRepo.On("GetTimeSeries", domain.TimeSeriesQuery{Interval: domain.IntervalDay}).Return(buckets, nil)
+
+	result, err := service.GetTimeSeries(context.Background(), domain.TimeSeriesQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, buckets, result)
+
+	_, err = service.GetTimeSeries(context.Background(), domain.TimeSeriesQuery{Interval: "hour"})
+	assert.Error(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetTimeSeriesUnsupportedRepository(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+	service := NewService(new(MockRepository), nil, &config.TzktAPI{}, log)
+
+	_, err := service.GetTimeSeries(context.Background(), domain.TimeSeriesQuery{})
+	assert.Error(t, err)
+}
+
+func TestService_GetTopReport(t *testing.T) {
+	mockRepo := new(MockAnalyticsRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	expectedQuery := domain.TopQuery{Limit: maxTopLimit}
+	mockRepo.On("GetTopDelegations", expectedQuery).Return([]domain.Movement{{Delegator: "tz1whale", Amount: "10"}}, nil)
+	mockRepo.On("GetTopDelegators", expectedQuery).Return([]domain.TopDelegator(nil), nil)
+
+	report, err := service.GetTopReport(context.Background(), domain.TopQuery{Limit: 5000})
+	require.NoError(t, err)
+	assert.Len(t, report.TopDelegations, 1)
+	assert.NotNil(t, report.TopDelegators)
+	assert.Empty(t, report.TopDelegators)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetLargeMovementsDefaultThreshold(t *testing.T) {
+	mockRepo := new(MockAnalyticsRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+	service.SetLargeMovementThreshold(42)
+
+	mockRepo.On("GetLargeMovements", domain.MovementQuery{MinAmount: 42, Limit: defaultMovementsLimit}).
+		Return([]domain.Movement{{Delegator: "tz1whale", Amount: "50"}}, nil)
+
+	response, err := service.GetLargeMovements(context.Background(), domain.MovementQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, "42", response.MinAmount)
+	assert.Len(t, response.Data, 1)
+
+	mockRepo.AssertExpectations(t)
+}
+
+type MockStateRepository struct {
+	MockRepository
+}
+
+func (m *MockStateRepository) GetDelegatorState(ctx context.Context, query domain.DelegatorQuery) (*domain.DelegatorState, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DelegatorState), args.Error(1)
+}
+
+func (m *MockStateRepository) GetBakerDelegators(ctx context.Context, query domain.BakerDelegatorsQuery) (*domain.BakerDelegators, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BakerDelegators), args.Error(1)
+}
+
+func (m *MockStateRepository) GetIndexedRange(ctx context.Context) (*domain.IndexedRange, error) {
+	args := m.Called()
+	indexed, _ := args.Get(0).(*domain.IndexedRange)
+	return indexed, args.Error(1)
+}
+
+func TestService_GetBakerDelegatorsAsOf(t *testing.T) {
+	mockRepo := new(MockStateRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	indexed := int64(1000)
+	beyond := int64(1001)
+	before := int64(899)
+	mockRepo.On("GetIndexedRange").Return(&domain.IndexedRange{
+		FirstLevel:     900,
+		LastLevel:      1000,
+		FirstTimestamp: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		LastTimestamp:  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}, nil)
+
+	query := domain.BakerDelegatorsQuery{Baker: "tz1baker", AsOf: &domain.AsOf{Level: &indexed}}
+	expected := &domain.BakerDelegators{Baker: "tz1baker", DelegatorCount: 1}
+	mockRepo.On("GetBakerDelegators", domain.BakerDelegatorsQuery{
+		Baker: "tz1baker",
+		Limit: defaultDelegatorsLimit,
+		AsOf:  query.AsOf,
+	}).Return(expected, nil)
+
+	result, err := service.GetBakerDelegators(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	_, err = service.GetBakerDelegators(context.Background(), domain.BakerDelegatorsQuery{
+		Baker: "tz1baker",
+		AsOf:  &domain.AsOf{Level: &beyond},
+	})
+	assert.ErrorIs(t, err, domain.ErrAsOfOutOfRange)
+
+	_, err = service.GetBakerDelegators(context.Background(), domain.BakerDelegatorsQuery{
+		Baker: "tz1baker",
+		AsOf:  &domain.AsOf{Level: &before},
+	})
+	assert.ErrorIs(t, err, domain.ErrAsOfOutOfRange)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetDelegatorStateAsOfTimestamp(t *testing.T) {
+	mockRepo := new(MockStateRepository)
+	log, _ := logger.New("debug", "test")
+	// Recent-window mode: nothing bounds as_of but the stored delegations.
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo.On("GetIndexedRange").Return(&domain.IndexedRange{
+		FirstLevel:     100,
+		LastLevel:      200,
+		FirstTimestamp: first,
+		LastTimestamp:  last,
+	}, nil)
+	mockRepo.On("GetDelegatorState", mock.Anything).Return(&domain.DelegatorState{Delegator: "tz1one"}, nil)
+
+	tests := []struct {
+		name     string
+		asOf     time.Time
+		rejected bool
+	}{
+		{"last indexed", last, false},
+		{"oldest indexed", first, false},
+		{"after last indexed", last.Add(time.Second), true},
+		{"future", time.Now().Add(time.Hour), true},
+		{"before oldest indexed", first.Add(-time.Second), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asOf := tt.asOf
+			_, err := service.GetDelegatorState(context.Background(), domain.DelegatorQuery{
+				Delegator: "tz1one",
+				AsOf:      &domain.AsOf{Timestamp: &asOf},
+			})
+			if tt.rejected {
+				assert.ErrorIs(t, err, domain.ErrAsOfOutOfRange)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_SyncCycles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/cycles", r.URL.Path)
+		assert.Equal(t, "700", r.URL.Query().Get("index.ge"))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]tzkt.CycleResponse{
+			{Index: 700, FirstLevel: 100, LastLevel: 199},
+			{Index: 701, FirstLevel: 200, LastLevel: 299},
+		})
+	}))
+	defer server.Close()
+
+	log, _ := logger.New("debug", "test")
+	client := tzkt.NewClient(server.URL, 5*time.Second, 0, time.Millisecond, log)
+
+	mockRepo := new(MockCycleRepository)
+	mockRepo.On("GetCurrentCycleIndex").Return(int64(700), nil)
+	mockRepo.On("SaveCycles", []domain.Cycle{
+		{Index: 700, FirstLevel: 100, LastLevel: 199},
+		{Index: 701, FirstLevel: 200, LastLevel: 299},
+	}).Return(nil)
+
+	service := NewService(mockRepo, client, &config.TzktAPI{}, log)
+
+	err := service.SyncCycles(context.Background())
+	require.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+type MockCycleRepository struct {
+	MockRepository
+}
+
+func (m *MockCycleRepository) SaveCycles(ctx context.Context, cycles []domain.Cycle) error {
+	args := m.Called(cycles)
+	return args.Error(0)
+}
+
+func (m *MockCycleRepository) GetCurrentCycleIndex(ctx context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCycleRepository) GetCycleStats(ctx context.Context, query domain.CycleStatsQuery) ([]domain.CycleStats, error) {
+	args := m.Called(query)
+	return args.Get(0).([]domain.CycleStats), args.Error(1)
+}
+
+type MockStreamRepository struct {
+	MockRepository
+}
+
+func (m *MockStreamRepository) GetLatestSeq(ctx context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockStreamRepository) FindAfterSeq(ctx context.Context, afterSeq, upToSeq int64, filter domain.DelegationFilter, limit int) ([]domain.Delegation, error) {
+	args := m.Called(afterSeq, upToSeq, filter, limit)
+	return args.Get(0).([]domain.Delegation), args.Error(1)
+}
+
+func TestService_StreamDelegationsReplaysFromSeq(t *testing.T) {
+	mockRepo := new(MockStreamRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	cycle := int64(700)
+	filter := domain.DelegationFilter{Cycle: &cycle}
+	mockRepo.On("GetLatestSeq").Return(int64(2), nil)
+	mockRepo.On("FindAfterSeq", int64(0), int64(2), filter, streamBatchSize).Return([]domain.Delegation{
+		{Delegator: "tz1one", Seq: 1},
+		{Delegator: "tz1two", Seq: 2},
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	after := int64(0)
+	var received []domain.Delegation
+	err := service.StreamDelegations(ctx, domain.StreamQuery{Filter: filter, AfterSeq: &after}, func(batch []domain.Delegation) error {
+		received = append(received, batch...)
+		cancel()
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+	assert.Equal(t, "tz1two", received[1].Delegator)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_StreamDelegationsWakesOnCommit(t *testing.T) {
+	mockRepo := new(MockStreamRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	newDelegation := domain.Delegation{Delegator: "tz1new", Seq: 6}
+	mockRepo.On("GetLatestSeq").Return(int64(5), nil).Twice()
+	mockRepo.On("GetLatestSeq").Return(int64(6), nil)
+	mockRepo.On("FindAfterSeq", int64(5), int64(6), domain.DelegationFilter{}, streamBatchSize).
+		Return([]domain.Delegation{newDelegation}, nil).Once()
+	mockRepo.On("SaveBatch", mock.Anything).Return([]domain.Delegation{newDelegation}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = service.saveBatch(context.Background(), []domain.Delegation{newDelegation})
+			}
+		}
+	}()
+
+	var received []domain.Delegation
+	err := service.StreamDelegations(ctx, domain.StreamQuery{}, func(batch []domain.Delegation) error {
+		received = append(received, batch...)
+		cancel()
+		return nil
+	})
+	close(done)
+
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, "tz1new", received[0].Delegator)
+}
+
+func TestService_SaveBatchPublishesNewDelegations(t *testing.T) {
+	mockRepo := new(MockRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	stored := domain.Delegation{Delegator: "tz1stored", OperationHash: "op1"}
+	created := domain.Delegation{Delegator: "tz1new", OperationHash: "op2"}
+	mockRepo.On("SaveBatch", []domain.Delegation{stored, created}).Return([]domain.Delegation{created}, nil).Once()
+	mockRepo.On("SaveBatch", []domain.Delegation{stored}).Return(nil, nil).Once()
+
+	sub := service.SubscribeDelegations(10)
+	defer sub.Close()
+
+	require.NoError(t, service.saveBatch(context.Background(), []domain.Delegation{stored, created}))
+	require.NoError(t, service.saveBatch(context.Background(), []domain.Delegation{stored}))
+
+	require.Len(t, sub.C(), 1)
+	assert.Equal(t, "op2", (<-sub.C()).OperationHash)
+	mockRepo.AssertExpectations(t)
+}
+
+type MockPagedRepository struct {
+	MockRepository
+}
+
+func (m *MockPagedRepository) FindPage(ctx context.Context, query domain.DelegationPageQuery) ([]domain.Delegation, error) {
+	args := m.Called(query)
+	return args.Get(0).([]domain.Delegation), args.Error(1)
+}
+
+func (m *MockPagedRepository) GetDelegatorStates(ctx context.Context, delegators []string) ([]domain.DelegatorState, error) {
+	args := m.Called(delegators)
+	return args.Get(0).([]domain.DelegatorState), args.Error(1)
+}
+
+func (m *MockPagedRepository) GetBakerSummaries(ctx context.Context, bakers []string) ([]domain.BakerSummary, error) {
+	args := m.Called(bakers)
+	return args.Get(0).([]domain.BakerSummary), args.Error(1)
+}
+
+func TestService_ListDelegationsPage(t *testing.T) {
+	mockRepo := new(MockPagedRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	before := domain.DelegationCursor{Level: 100, Seq: 10}
+	mockRepo.On("FindPage", domain.DelegationPageQuery{Before: &before, Limit: 3}).Return([]domain.Delegation{
+		{Seq: 9}, {Seq: 8}, {Seq: 7},
+	}, nil)
+
+	page, err := service.ListDelegationsPage(context.Background(), domain.DelegationPageQuery{Before: &before, Limit: 2})
+
+	require.NoError(t, err)
+	assert.True(t, page.HasMore)
+	require.Len(t, page.Data, 2)
+	assert.Equal(t, int64(8), page.Data[1].Seq)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetBakerSummariesFillsMissing(t *testing.T) {
+	mockRepo := new(MockPagedRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	mockRepo.On("GetBakerSummaries", []string{"tz1empty", "tz1baker"}).Return([]domain.BakerSummary{
+		{Baker: "tz1baker", DelegatorCount: 2, TotalAmount: "300"},
+	}, nil)
+
+	summaries, err := service.GetBakerSummaries(context.Background(), []string{"tz1empty", "tz1baker"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []domain.BakerSummary{
+		{Baker: "tz1empty", TotalAmount: "0"},
+		{Baker: "tz1baker", DelegatorCount: 2, TotalAmount: "300"},
+	}, summaries)
+}
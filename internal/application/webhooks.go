@@ -0,0 +1,424 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 1000
+
+	// Headers sent with every webhook request. The signature is the hex
+	// HMAC-SHA256, keyed by the webhook secret, of "<timestamp>.<body>".
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+
+	webhookSecretBytes = 32
+	// webhookErrorBodyLimit bounds how much of a failed response is kept in
+	// the delivery log.
+	webhookErrorBodyLimit = 512
+)
+
+// webhookEvent is the body POSTed to webhooks.
+type webhookEvent struct {
+	ID        int64           `json:"id"`
+	WebhookID string          `json:"webhook_id"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// errPrivateWebhookTarget is returned when a webhook would reach a loopback,
+// private or link-local address, such as a cloud metadata endpoint.
+var errPrivateWebhookTarget = errors.New("webhook target is not a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// netip.Addr.IsPrivate does not cover.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress reports whether webhooks may be delivered to addr.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+func lookupWebhookHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// SetWebhookConfig sets whether webhooks may target private networks.
+func (s *Service) SetWebhookConfig(cfg config.Webhooks) {
+	s.allowPrivateWebhooks = cfg.AllowPrivateTargets
+}
+
+// checkWebhookTarget rejects URLs whose host is, or resolves to, an address
+// that is not public. The dispatcher checks the dialed address again, since
+// DNS may change after registration.
+func (s *Service) checkWebhookTarget(ctx context.Context, rawURL string) error {
+	if s.allowPrivateWebhooks {
+		return nil
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Hostname() == "" {
+		return domain.NewValidationError("invalid_webhook_url", "Invalid url. Must be an absolute http or https URL")
+	}
+
+	addrs, err := s.lookupHost(ctx, target.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return domain.NewValidationError("invalid_webhook_url", "Invalid url. Its host could not be resolved")
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return domain.NewValidationError("invalid_webhook_url", "Invalid url. Must not target a loopback, private or link-local address")
+		}
+	}
+	return nil
+}
+
+func (s *Service) webhookRepository() (domain.WebhookRepository, error) {
+	repo, ok := s.repo.(domain.WebhookRepository)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support webhooks")
+	}
+	return repo, nil
+}
+
+// CreateWebhook registers an active webhook with a freshly generated secret,
+// returned only here.
+func (s *Service) CreateWebhook(ctx context.Context, url string, filter domain.SubscriptionFilter) (*domain.Webhook, error) {
+	repo, err := s.webhookRepository()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkWebhookTarget(ctx, url); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	webhook := &domain.Webhook{
+		URL:    url,
+		Secret: hex.EncodeToString(secret),
+		Filter: filter,
+		Active: true,
+	}
+	if err := repo.CreateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	s.logger.Infow("Webhook registered", "webhook_id", webhook.ID, "url", webhook.URL)
+	return webhook, nil
+}
+
+func (s *Service) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	repo, err := s.webhookRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := repo.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (s *Service) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	repo, err := s.webhookRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, id string) error {
+	repo, err := s.webhookRepository()
+	if err != nil {
+		return err
+	}
+
+	if err := repo.DeleteWebhook(ctx, id); err != nil {
+		return err
+	}
+
+	s.logger.Infow("Webhook deleted", "webhook_id", id)
+	return nil
+}
+
+func (s *Service) ListWebhookDeliveries(ctx context.Context, query domain.WebhookDeliveryQuery) ([]domain.WebhookDelivery, error) {
+	repo, err := s.webhookRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := repo.GetWebhook(ctx, query.WebhookID); err != nil {
+		return nil, err
+	}
+
+	query.Limit = clampLimit(query.Limit, defaultDeliveriesLimit, maxDeliveriesLimit)
+	return repo.ListDeliveries(ctx, query)
+}
+
+// RedeliverWebhookDelivery queues a delivery again, typically one that was
+// dead-lettered once the receiving endpoint has been fixed.
+func (s *Service) RedeliverWebhookDelivery(ctx context.Context, webhookID string, id int64) error {
+	repo, err := s.webhookRepository()
+	if err != nil {
+		return err
+	}
+	return repo.Redeliver(ctx, webhookID, id)
+}
+
+// WebhookDispatcher drains the webhook outbox. Deliveries are claimed with a
+// lease so that several replicas can dispatch concurrently, and each is
+// retried with exponential backoff until it succeeds or runs out of
+// attempts, at which point it is dead-lettered.
+type WebhookDispatcher struct {
+	repo     domain.WebhookRepository
+	config   *config.Webhooks
+	logger   *logger.Logger
+	client   *http.Client
+	now      func() time.Time
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func NewWebhookDispatcher(repo domain.WebhookRepository, cfg *config.Webhooks, logger *logger.Logger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:   repo,
+		config: cfg,
+		logger: logger,
+		client: newWebhookClient(cfg),
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+}
+
+// newWebhookClient returns the client deliveries are sent with. Unless
+// private targets are allowed, it refuses to connect to addresses that are
+// not public, whatever the webhook host resolves to when dialing and
+// wherever redirects lead. Proxies are not used, as they would hide the
+// address being reached.
+func newWebhookClient(cfg *config.Webhooks) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.RequestTimeout}
+	if !cfg.AllowPrivateTargets {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddress(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", errPrivateWebhookTarget, addrPort.Addr())
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: cfg.RequestTimeout, Transport: transport}
+}
+
+func (d *WebhookDispatcher) Start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			// Keep draining while full batches come back.
+			for {
+				n, err := d.DispatchDue(context.Background())
+				if err != nil {
+					d.logger.Errorw("Failed to dispatch webhook deliveries", "error", err)
+				}
+				if err != nil || n < d.config.BatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+
+	d.logger.Infow("Webhook dispatcher started", "interval", d.config.PollInterval)
+}
+
+// Stop waits for in-flight deliveries to finish.
+func (d *WebhookDispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		d.wg.Wait()
+		d.logger.Info("Webhook dispatcher stopped")
+	})
+}
+
+// DispatchDue attempts one batch of due deliveries and returns its size.
+func (d *WebhookDispatcher) DispatchDue(ctx context.Context) (int, error) {
+	// The lease outlasts an attempt, so a delivery is only picked up again
+	// if this instance died while sending it.
+	lease := d.config.RequestTimeout + 30*time.Second
+
+	due, err := d.repo.ClaimDueDeliveries(ctx, d.config.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(d.config.Concurrency, 1))
+	for _, delivery := range due {
+		g.Go(func() error {
+			d.deliver(ctx, delivery)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return len(due), nil
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery domain.DueDelivery) {
+	body, err := json.Marshal(webhookEvent{
+		ID:        delivery.ID,
+		WebhookID: delivery.WebhookID,
+		Type:      delivery.Type,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		d.recordFailure(ctx, delivery, 0, fmt.Sprintf("failed to encode event: %v", err))
+		return
+	}
+
+	timestamp := d.now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		d.recordFailure(ctx, delivery, 0, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tezos-delegation-service-webhooks")
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(delivery.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	metrics.WebhookDeliveryDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		d.recordFailure(ctx, delivery, 0, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+		reason := fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		if len(snippet) > 0 {
+			reason = fmt.Sprintf("%s: %s", reason, snippet)
+		}
+		d.recordFailure(ctx, delivery, resp.StatusCode, reason)
+		return
+	}
+
+	if err := d.repo.MarkDelivered(ctx, delivery.ID, resp.StatusCode); err != nil {
+		d.logger.Errorw("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+		return
+	}
+	metrics.WebhookDeliveryAttempts.WithLabelValues("delivered").Inc()
+}
+
+func (d *WebhookDispatcher) recordFailure(ctx context.Context, delivery domain.DueDelivery, statusCode int, reason string) {
+	var retryAt *time.Time
+	result := "dead"
+	if delivery.Attempts < d.config.MaxAttempts {
+		next := d.now().Add(d.backoff(delivery.Attempts))
+		retryAt = &next
+		result = "failed"
+	}
+
+	d.logger.Warnw("Webhook delivery failed",
+		"delivery_id", delivery.ID,
+		"webhook_id", delivery.WebhookID,
+		"attempt", delivery.Attempts,
+		"status_code", statusCode,
+		"reason", reason,
+		"dead_lettered", retryAt == nil,
+	)
+
+	if err := d.repo.MarkFailed(ctx, delivery.ID, statusCode, reason, retryAt); err != nil {
+		d.logger.Errorw("Failed to record webhook delivery failure", "delivery_id", delivery.ID, "error", err)
+		return
+	}
+	metrics.WebhookDeliveryAttempts.WithLabelValues(result).Inc()
+}
+
+// backoff returns the delay before the attempt following attempt number
+// attempts: the initial backoff, doubled after each failure, capped.
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.config.MaxBackoff {
+			return d.config.MaxBackoff
+		}
+	}
+	return min(delay, d.config.MaxBackoff)
+}
+
+// SignWebhookPayload returns the hex signature receivers recompute to
+// authenticate a request: HMAC-SHA256 of "<timestamp>.<body>".
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,285 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockWebhookRepository struct {
+	MockRepository
+}
+
+func (m *MockWebhookRepository) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	args := m.Called(webhook)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	args := m.Called()
+	return args.Get(0).([]domain.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) DeleteWebhook(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) ListDeliveries(ctx context.Context, query domain.WebhookDeliveryQuery) ([]domain.WebhookDelivery, error) {
+	args := m.Called(query)
+	return args.Get(0).([]domain.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.DueDelivery, error) {
+	args := m.Called(limit, lease)
+	return args.Get(0).([]domain.DueDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	args := m.Called(id, statusCode)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) MarkFailed(ctx context.Context, id int64, statusCode int, reason string, retryAt *time.Time) error {
+	args := m.Called(id, statusCode, reason, retryAt)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) Redeliver(ctx context.Context, webhookID string, id int64) error {
+	args := m.Called(webhookID, id)
+	return args.Error(0)
+}
+
+func newTestDispatcher(repo domain.WebhookRepository, now time.Time) *WebhookDispatcher {
+	log, _ := logger.New("debug", "test")
+	dispatcher := NewWebhookDispatcher(repo, &config.Webhooks{
+		PollInterval:   time.Second,
+		RequestTimeout: 5 * time.Second,
+		BatchSize:      10,
+		Concurrency:    2,
+		MaxAttempts:    3,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     time.Hour,
+		// Test receivers listen on loopback.
+		AllowPrivateTargets: true,
+	}, log)
+	dispatcher.now = func() time.Time { return now }
+	return dispatcher
+}
+
+func TestService_CreateWebhook(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+	service.lookupHost = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("93.184.215.14")}, nil
+	}
+
+	filter := domain.SubscriptionFilter{Bakers: []string{"tz1baker"}, MinAmount: 1000}
+	mockRepo.On("CreateWebhook", mock.MatchedBy(func(w *domain.Webhook) bool {
+		return w.URL == "https://example.com/hook" && w.Active && w.Filter.MinAmount == 1000 && len(w.Secret) == 64
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Webhook).ID = "wh-1"
+	}).Return(nil)
+
+	webhook, err := service.CreateWebhook(context.Background(), "https://example.com/hook", filter)
+
+	require.NoError(t, err)
+	assert.Equal(t, "wh-1", webhook.ID)
+	assert.Len(t, webhook.Secret, 64)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_CreateWebhookRejectsPrivateTargets(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+	service.lookupHost = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "internal.example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("10.1.2.3")}, nil
+		case "missing.example.com":
+			return nil, errors.New("no such host")
+		}
+		return lookupWebhookHost(ctx, host)
+	}
+
+	for _, target := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://127.0.0.1:8080/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://100.64.0.1/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0/hook",
+		"https://internal.example.com/hook",
+		"https://missing.example.com/hook",
+	} {
+		_, err := service.CreateWebhook(context.Background(), target, domain.SubscriptionFilter{})
+
+		assert.True(t, domain.IsKind(err, domain.KindValidation), target)
+	}
+	mockRepo.AssertNotCalled(t, "CreateWebhook", mock.Anything)
+
+	service.SetWebhookConfig(config.Webhooks{AllowPrivateTargets: true})
+	mockRepo.On("CreateWebhook", mock.Anything).Return(nil)
+
+	_, err := service.CreateWebhook(context.Background(), "http://10.0.0.1/hook", domain.SubscriptionFilter{})
+	require.NoError(t, err)
+}
+
+func TestService_ListWebhooksHidesSecrets(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	mockRepo.On("ListWebhooks").Return([]domain.Webhook{
+		{ID: "wh-1", URL: "https://example.com/hook", Secret: "s3cret", Active: true},
+	}, nil)
+
+	webhooks, err := service.ListWebhooks(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Empty(t, webhooks[0].Secret)
+}
+
+func TestService_ListWebhookDeliveries(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	log, _ := logger.New("debug", "test")
+	service := NewService(mockRepo, nil, &config.TzktAPI{}, log)
+
+	mockRepo.On("GetWebhook", "missing").Return(nil, domain.ErrWebhookNotFound)
+	mockRepo.On("GetWebhook", "wh-1").Return(&domain.Webhook{ID: "wh-1"}, nil)
+	mockRepo.On("ListDeliveries", domain.WebhookDeliveryQuery{WebhookID: "wh-1", Limit: maxDeliveriesLimit}).
+		Return([]domain.WebhookDelivery{{ID: 7, WebhookID: "wh-1"}}, nil)
+
+	_, err := service.ListWebhookDeliveries(context.Background(), domain.WebhookDeliveryQuery{WebhookID: "missing"})
+	assert.ErrorIs(t, err, domain.ErrWebhookNotFound)
+
+	deliveries, err := service.ListWebhookDeliveries(context.Background(), domain.WebhookDeliveryQuery{WebhookID: "wh-1", Limit: 5000})
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+}
+
+func TestWebhookDispatcher_DeliversSignedEvent(t *testing.T) {
+	now := time.Date(2024, 5, 5, 6, 30, 0, 0, time.UTC)
+	payload := json.RawMessage(`{"delegator":"tz1delegator","amount":"5000"}`)
+
+	var received webhookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+
+		assert.Equal(t, now.Unix(), timestamp)
+		assert.Equal(t, "42", r.Header.Get(WebhookDeliveryHeader))
+		assert.Equal(t, "sha256="+SignWebhookPayload("s3cret", timestamp, body), r.Header.Get(WebhookSignatureHeader))
+		assert.NoError(t, json.Unmarshal(body, &received))
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("ClaimDueDeliveries", 10, 35*time.Second).Return([]domain.DueDelivery{{
+		WebhookDelivery: domain.WebhookDelivery{ID: 42, WebhookID: "wh-1", Type: domain.WebhookEventDelegation, Payload: payload, Attempts: 1},
+		URL:             server.URL,
+		Secret:          "s3cret",
+	}}, nil)
+	mockRepo.On("MarkDelivered", int64(42), http.StatusNoContent).Return(nil)
+
+	n, err := newTestDispatcher(mockRepo, now).DispatchDue(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int64(42), received.ID)
+	assert.Equal(t, "delegation", received.Type)
+	assert.JSONEq(t, string(payload), string(received.Data))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWebhookDispatcher_RetriesAndDeadLetters(t *testing.T) {
+	now := time.Date(2024, 5, 5, 6, 30, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("maintenance"))
+	}))
+	defer server.Close()
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("ClaimDueDeliveries", 10, 35*time.Second).Return([]domain.DueDelivery{
+		{WebhookDelivery: domain.WebhookDelivery{ID: 1, Payload: json.RawMessage(`{}`), Attempts: 2}, URL: server.URL},
+		{WebhookDelivery: domain.WebhookDelivery{ID: 2, Payload: json.RawMessage(`{}`), Attempts: 3}, URL: server.URL},
+	}, nil)
+
+	retryAt := now.Add(time.Minute)
+	mockRepo.On("MarkFailed", int64(1), http.StatusServiceUnavailable, "unexpected status 503: maintenance", &retryAt).Return(nil)
+	mockRepo.On("MarkFailed", int64(2), http.StatusServiceUnavailable, "unexpected status 503: maintenance", (*time.Time)(nil)).Return(nil)
+
+	_, err := newTestDispatcher(mockRepo, now).DispatchDue(context.Background())
+
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWebhookDispatcher_RefusesPrivateTargets(t *testing.T) {
+	var hits atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer receiver.Close()
+
+	now := time.Date(2024, 5, 5, 6, 0, 0, 0, time.UTC)
+	mockRepo := new(MockWebhookRepository)
+	dispatcher := newTestDispatcher(mockRepo, now)
+	dispatcher.client = newWebhookClient(&config.Webhooks{RequestTimeout: 5 * time.Second})
+
+	mockRepo.On("ClaimDueDeliveries", 10, mock.Anything).Return([]domain.DueDelivery{
+		{WebhookDelivery: domain.WebhookDelivery{ID: 1, WebhookID: "wh-1", Payload: json.RawMessage(`{}`), Attempts: 1}, URL: receiver.URL},
+	}, nil).Once()
+	mockRepo.On("MarkFailed", int64(1), 0, mock.MatchedBy(func(reason string) bool {
+		return strings.Contains(reason, errPrivateWebhookTarget.Error())
+	}), mock.Anything).Return(nil).Once()
+
+	_, err := dispatcher.DispatchDue(context.Background())
+
+	require.NoError(t, err)
+	assert.Zero(t, hits.Load())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWebhookDispatcher_Backoff(t *testing.T) {
+	dispatcher := newTestDispatcher(new(MockWebhookRepository), time.Now())
+
+	assert.Equal(t, 30*time.Second, dispatcher.backoff(1))
+	assert.Equal(t, time.Minute, dispatcher.backoff(2))
+	assert.Equal(t, 4*time.Minute, dispatcher.backoff(4))
+	assert.Equal(t, time.Hour, dispatcher.backoff(10))
+	assert.Equal(t, time.Hour, dispatcher.backoff(1000))
+}
@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type TimeSeriesInterval string
+
+const (
+	IntervalDay   TimeSeriesInterval = "day"
+	IntervalWeek  TimeSeriesInterval = "week"
+	IntervalMonth TimeSeriesInterval = "month"
+)
+
+func (i TimeSeriesInterval) Valid() bool {
+	switch i {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+		return true
+	}
+	return false
+}
+
+type TimeSeriesQuery struct {
+	Interval TimeSeriesInterval
+	From     *time.Time
+	To       *time.Time
+	Baker    string
+}
+
+// TimeSeriesBucket aggregates delegation activity over one interval.
+// A delegator is "new" in the bucket holding its first ever delegation
+// (to Baker when the query is scoped to one) and "returning" otherwise.
+type TimeSeriesBucket struct {
+	BucketStart         time.Time `json:"bucket_start"`
+	Count               int64     `json:"count"`
+	TotalAmount         string    `json:"total_amount"`
+	UniqueDelegators    int64     `json:"unique_delegators"`
+	NewDelegators       int64     `json:"new_delegators"`
+	ReturningDelegators int64     `json:"returning_delegators"`
+}
+
+type TimeSeriesResponse struct {
+	Interval TimeSeriesInterval `json:"interval"`
+	Data     []TimeSeriesBucket `json:"data"`
+}
+
+type TopQuery struct {
+	Limit int
+	From  *time.Time
+	To    *time.Time
+}
+
+type MovementQuery struct {
+	MinAmount int64
+	Limit     int
+	From      *time.Time
+	To        *time.Time
+}
+
+// Movement is a single delegation viewed as stake moving from PrevBaker
+// (empty for a first delegation) to Baker (empty for an undelegation).
+type Movement struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Amount        string    `json:"amount"`
+	Delegator     string    `json:"delegator"`
+	PrevBaker     string    `json:"prev_baker,omitempty"`
+	Baker         string    `json:"baker,omitempty"`
+	Level         string    `json:"level"`
+	OperationHash string    `json:"operation_hash"`
+}
+
+type TopDelegator struct {
+	Delegator       string `json:"delegator"`
+	TotalAmount     string `json:"total_amount"`
+	DelegationCount int64  `json:"delegation_count"`
+}
+
+type TopReport struct {
+	TopDelegations []Movement     `json:"top_delegations"`
+	TopDelegators  []TopDelegator `json:"top_delegators"`
+}
+
+type MovementsResponse struct {
+	MinAmount string     `json:"min_amount"`
+	Data      []Movement `json:"data"`
+}
+
+type AnalyticsRepository interface {
+	GetTimeSeries(ctx context.Context, query TimeSeriesQuery) ([]TimeSeriesBucket, error)
+	GetTopDelegations(ctx context.Context, query TopQuery) ([]Movement, error)
+	GetTopDelegators(ctx context.Context, query TopQuery) ([]TopDelegator, error)
+	GetLargeMovements(ctx context.Context, query MovementQuery) ([]Movement, error)
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Scope grants access to a group of operations.
+type Scope string
+
+const (
+	ScopeReadDelegations Scope = "read:delegations"
+	ScopeReadStats       Scope = "read:stats"
+	// ScopeAdmin grants every other scope as well.
+	ScopeAdmin Scope = "admin"
+)
+
+func (s Scope) Valid() bool {
+	switch s {
+	case ScopeReadDelegations, ScopeReadStats, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrAPIKeyNotFound         = NewNotFoundError("api_key_not_found", "API key not found")
+	ErrInvalidCredentials     = NewUnauthenticatedError("invalid_credentials", "invalid credentials")
+	ErrAuthenticationRequired = NewUnauthenticatedError("authentication_required", "authentication required")
+	ErrInsufficientScope      = NewForbiddenError("insufficient_scope", "credentials do not grant access to this operation")
+)
+
+// APIKey identifies a client. Only a hash of the key is stored: Secret is
+// set when the key is created or rotated, and never returned again. Prefix
+// is the start of the key, to tell keys apart.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Secret     string     `json:"secret,omitempty"`
+	Scopes     []Scope    `json:"scopes"`
+	UsageCount int64      `json:"usage_count"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyRepository stores API keys by the hash of their secret.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *APIKey, hash []byte) error
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	// RotateAPIKey replaces the hash of a key that was not revoked.
+	RotateAPIKey(ctx context.Context, id, prefix string, hash []byte) (*APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	// FindAPIKey returns the unrevoked key with the given hash.
+	FindAPIKey(ctx context.Context, hash []byte) (*APIKey, error)
+	// RecordAPIKeyUsage adds uses of keys, by key ID, to their usage counts.
+	RecordAPIKeyUsage(ctx context.Context, usage map[string]APIKeyUsage) error
+}
+
+// APIKeyUsage counts the uses of a key since it was last recorded.
+type APIKeyUsage struct {
+	Count      int64
+	LastUsedAt time.Time
+}
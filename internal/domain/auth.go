@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the client a request is authenticated as.
+type Principal struct {
+	// Subject names the client, e.g. "api_key:<id>", and is unique across
+	// authentication methods.
+	Subject string
+	Scopes  []Scope
+}
+
+// HasScope reports whether the principal may perform operations requiring
+// scope.
+func (p *Principal) HasScope(scope Scope) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the principal a request
+// is authenticated as.
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, or nil for
+// anonymous requests.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// TokenAuthenticator verifies bearer tokens issued by an identity provider.
+type TokenAuthenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (*Principal, error)
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Checkpoint is the last block the stored delegations go through: polling
+// resumes after it.
+type Checkpoint struct {
+	Level     int64     `json:"level"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// BackupRepository is implemented by repositories that can be backed up and
+// restored.
+type BackupRepository interface {
+	// ScanDelegations streams every stored delegation in chain order.
+	ScanDelegations(ctx context.Context, emit func(Delegation) error) error
+	// RestoreDelegations stores the delegations whose operation hash is not
+	// stored yet and returns how many it added. Unlike SaveBatch, it queues
+	// no webhook deliveries or outbox events: restored delegations are not
+	// new.
+	RestoreDelegations(ctx context.Context, delegations []Delegation) (int64, error)
+	UpdateIndexingMetadata(ctx context.Context, level int64, timestamp time.Time) error
+	// GetIndexingMetadata returns the checkpoint last restored, at level 0
+	// if none was.
+	GetIndexingMetadata(ctx context.Context) (int64, *time.Time, error)
+	GetLastIndexedLevel(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Cycle is a Tezos cycle as reported by TzKT. Cycle lengths differ between
+// protocols, so level boundaries are always taken from this data.
+type Cycle struct {
+	Index      int64     `json:"index"`
+	FirstLevel int64     `json:"first_level"`
+	LastLevel  int64     `json:"last_level"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+}
+
+type CycleStatsQuery struct {
+	FromCycle *int64
+	ToCycle   *int64
+	Baker     string
+	Limit     int
+}
+
+type CycleStats struct {
+	Cycle
+	Count            int64  `json:"count"`
+	TotalAmount      string `json:"total_amount"`
+	UniqueDelegators int64  `json:"unique_delegators"`
+}
+
+type CycleStatsResponse struct {
+	Data []CycleStats `json:"data"`
+}
+
+type CycleRepository interface {
+	// SaveCycles upserts cycles and tags stored delegations whose level
+	// falls inside them.
+	SaveCycles(ctx context.Context, cycles []Cycle) error
+	// GetCurrentCycleIndex returns the latest stored cycle that has already
+	// started, or 0 when none is stored.
+	GetCurrentCycleIndex(ctx context.Context) (int64, error)
+	GetCycleStats(ctx context.Context, query CycleStatsQuery) ([]CycleStats, error)
+}
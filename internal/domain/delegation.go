@@ -1,6 +1,10 @@
 package domain
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +16,10 @@ type Delegation struct {
 	Level         string    `json:"level" db:"level"`
 	BlockHash     string    `json:"-" db:"block_hash"`
 	OperationHash string    `json:"operation_hash" db:"operation_hash"`
+	Baker         string    `json:"-" db:"baker"`
+	PrevBaker     string    `json:"-" db:"prev_baker"`
+	Cycle         *int64    `json:"cycle,omitempty" db:"cycle"`
+	Seq           int64     `json:"-" db:"seq"`
 	CreatedAt     time.Time `json:"-" db:"created_at"`
 }
 
@@ -19,17 +27,116 @@ type DelegationResponse struct {
 	Data []Delegation `json:"data"`
 }
 
+// DelegationFilter narrows a delegation listing. Nil or empty fields do not
+// filter.
+type DelegationFilter struct {
+	Year      *int
+	Cycle     *int64
+	Baker     string
+	Delegator string
+}
+
+// DelegationPageQuery selects delegations newest first, by level then in
+// storage order within a level, starting strictly after the Before cursor
+// when it is set.
+type DelegationPageQuery struct {
+	Filter DelegationFilter
+	Before *DelegationCursor
+	Limit  int
+}
+
+// DelegationCursor is the position of a delegation in pages. The level comes
+// first: delegations are not stored in chain order, since backfills and
+// reindexing store older levels after newer ones.
+type DelegationCursor struct {
+	Level int64
+	Seq   int64
+}
+
+// CursorOf returns the position of d in pages.
+func CursorOf(d Delegation) DelegationCursor {
+	level, _ := strconv.ParseInt(d.Level, 10, 64)
+	return DelegationCursor{Level: level, Seq: d.Seq}
+}
+
+// String encodes the cursor as "level:seq".
+func (c DelegationCursor) String() string {
+	return strconv.FormatInt(c.Level, 10) + ":" + strconv.FormatInt(c.Seq, 10)
+}
+
+// ParseDelegationCursor decodes a cursor encoded by String.
+func ParseDelegationCursor(s string) (DelegationCursor, error) {
+	rawLevel, rawSeq, ok := strings.Cut(s, ":")
+	level, levelErr := strconv.ParseInt(rawLevel, 10, 64)
+	seq, seqErr := strconv.ParseInt(rawSeq, 10, 64)
+	if !ok || levelErr != nil || seqErr != nil {
+		return DelegationCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return DelegationCursor{Level: level, Seq: seq}, nil
+}
+
+type DelegationPage struct {
+	Data    []Delegation
+	HasMore bool
+}
+
 type DelegationRepository interface {
-	Save(delegation *Delegation) error
-	SaveBatch(delegations []Delegation) error
-	FindAll(year *int) ([]Delegation, error)
-	GetLastIndexedLevel() (int64, error)
-	Exists(delegator string, level string) (bool, error)
+	Save(ctx context.Context, delegation *Delegation) error
+	// SaveBatch stores delegations, updating those already stored, and
+	// returns the ones that were not stored before.
+	SaveBatch(ctx context.Context, delegations []Delegation) ([]Delegation, error)
+	FindAll(ctx context.Context, year *int) ([]Delegation, error)
+	GetLastIndexedLevel(ctx context.Context) (int64, error)
+	Exists(ctx context.Context, delegator string, level string) (bool, error)
+}
+
+// DelegationStatsRepository aggregates the stored delegations in the
+// database, without loading them.
+type DelegationStatsRepository interface {
+	// GetLastTimestamp returns the timestamp of the latest stored
+	// delegation, or nil when none is stored.
+	GetLastTimestamp(ctx context.Context) (*time.Time, error)
+	// CountDelegations counts the stored delegations, only those at or after
+	// since when it is set.
+	CountDelegations(ctx context.Context, since *time.Time) (int64, error)
+	// GetStats returns the totals reported by the stats endpoints.
+	GetStats(ctx context.Context) (map[string]interface{}, error)
 }
 
 type DelegationService interface {
-	GetDelegations(year *int) ([]Delegation, error)
-	IndexDelegations(fromLevel int64) error
+	GetDelegations(ctx context.Context, year *int) ([]Delegation, error)
+	IndexDelegations(ctx context.Context, fromLevel int64) error
 	StartPolling() error
 	StopPolling()
 }
+
+// ReorgRepository removes the delegations of blocks a chain reorganisation
+// replaced.
+type ReorgRepository interface {
+	// GetBlockHashes returns the block hash of the delegations stored at each
+	// level from fromLevel on.
+	GetBlockHashes(ctx context.Context, fromLevel int64) (map[int64]string, error)
+	// RollbackLevels deletes the delegations stored from fromLevel on and
+	// rebuilds the current state of their delegators from the delegations
+	// that remain. When any were deleted it records a
+	// TopicDelegationsRolledBack event. It returns how many were deleted.
+	RollbackLevels(ctx context.Context, fromLevel int64) (int64, error)
+}
+
+// FilteredDelegationRepository lists delegations matching a DelegationFilter.
+type FilteredDelegationRepository interface {
+	FindByFilter(ctx context.Context, filter DelegationFilter) ([]Delegation, error)
+}
+
+// ExportRepository walks every delegation matching a filter, newest first,
+// without holding the whole result in memory. Iteration stops at the first
+// error returned by emit.
+type ExportRepository interface {
+	ExportDelegations(ctx context.Context, filter DelegationFilter, emit func(Delegation) error) error
+}
+
+// PagedDelegationRepository lists delegations with keyset pagination on
+// their storage sequence number.
+type PagedDelegationRepository interface {
+	FindPage(ctx context.Context, query DelegationPageQuery) ([]Delegation, error)
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 	"time"
@@ -195,15 +196,15 @@ func TestDelegationService_Interface(t *testing.T) {
 // Mock implementations for interface testing
 type mockRepo struct{}
 
-func (m *mockRepo) Save(delegation *Delegation) error                   { return nil }
-func (m *mockRepo) SaveBatch(delegations []Delegation) error           { return nil }
-func (m *mockRepo) FindAll(year *int) ([]Delegation, error)            { return nil, nil }
-func (m *mockRepo) GetLastIndexedLevel() (int64, error)                { return 0, nil }
-func (m *mockRepo) Exists(delegator string, level string) (bool, error) { return false, nil }
+func (m *mockRepo) Save(ctx context.Context, delegation *Delegation) error                   { return nil }
+func (m *mockRepo) SaveBatch(ctx context.Context, delegations []Delegation) ([]Delegation, error) { return nil, nil }
+func (m *mockRepo) FindAll(ctx context.Context, year *int) ([]Delegation, error)            { return nil, nil }
+func (m *mockRepo) GetLastIndexedLevel(ctx context.Context) (int64, error)                  { return 0, nil }
+func (m *mockRepo) Exists(ctx context.Context, delegator string, level string) (bool, error) { return false, nil }
 
 type mockService struct{}
 
-func (m *mockService) GetDelegations(year *int) ([]Delegation, error) { return nil, nil }
-func (m *mockService) IndexDelegations(fromLevel int64) error         { return nil }
-func (m *mockService) StartPolling() error                            { return nil }
-func (m *mockService) StopPolling()                                   {}
\ No newline at end of file
+func (m *mockService) GetDelegations(ctx context.Context, year *int) ([]Delegation, error) { return nil, nil }
+func (m *mockService) IndexDelegations(ctx context.Context, fromLevel int64) error         { return nil }
+func (m *mockService) StartPolling() error                                                 { return nil }
+func (m *mockService) StopPolling()                                                        {}
\ No newline at end of file
@@ -0,0 +1,96 @@
+package domain
+
+import "errors"
+
+// ErrorKind classifies failures so that interfaces can report them without
+// knowing which layer produced them.
+type ErrorKind string
+
+const (
+	KindValidation          ErrorKind = "validation"
+	KindNotFound            ErrorKind = "not_found"
+	KindUpstreamUnavailable ErrorKind = "upstream_unavailable"
+	KindStorageUnavailable  ErrorKind = "storage_unavailable"
+	KindUnauthenticated     ErrorKind = "unauthenticated"
+	KindForbidden           ErrorKind = "forbidden"
+	KindConflict            ErrorKind = "conflict"
+)
+
+// Stable error codes reported to API clients.
+const (
+	CodeInvalidParameter    = "invalid_parameter"
+	CodeInvalidBody         = "invalid_body"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeStorageUnavailable  = "storage_unavailable"
+)
+
+// Error is a failure with a kind and a stable, machine-readable code. Message
+// is safe to show to clients; Err, when set, is the underlying cause and is
+// only meant for logs.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NewValidationError(code, message string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+func NewNotFoundError(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+// NewUnauthenticatedError reports missing or invalid credentials.
+func NewUnauthenticatedError(code, message string) *Error {
+	return &Error{Kind: KindUnauthenticated, Code: code, Message: message}
+}
+
+// NewForbiddenError reports credentials that do not grant the operation.
+func NewForbiddenError(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+// NewConflictError reports an operation that the current state of a
+// resource does not allow.
+func NewConflictError(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+// NewUpstreamUnavailableError reports that an external service, such as the
+// TzKT API, could not be reached or kept failing.
+func NewUpstreamUnavailableError(message string, err error) *Error {
+	return &Error{Kind: KindUpstreamUnavailable, Code: CodeUpstreamUnavailable, Message: message, Err: err}
+}
+
+// NewStorageUnavailableError reports that the database could not be reached.
+func NewStorageUnavailableError(err error) *Error {
+	return &Error{Kind: KindStorageUnavailable, Code: CodeStorageUnavailable, Message: "storage unavailable", Err: err}
+}
+
+// AsError returns the first *Error in err's chain.
+func AsError(err error) (*Error, bool) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
+
+// IsKind reports whether err's chain contains an *Error of the given kind.
+func IsKind(err error, kind ErrorKind) bool {
+	domainErr, ok := AsError(err)
+	return ok && domainErr.Kind == kind
+}
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type HealthStatus string
+
+const (
+	HealthUp       HealthStatus = "up"
+	HealthDegraded HealthStatus = "degraded"
+	HealthDown     HealthStatus = "down"
+)
+
+// DependencyHealth is the result of checking one dependency. Detail never
+// carries raw error messages, which may describe the infrastructure.
+type DependencyHealth struct {
+	Status    HealthStatus `json:"status"`
+	LatencyMS float64      `json:"latency_ms"`
+	Detail    string       `json:"detail,omitempty"`
+	CheckedAt *time.Time   `json:"checked_at,omitempty"`
+}
+
+// HealthReport is the readiness of the service. It is down when a dependency
+// the API cannot serve without is down, and degraded when only the indexing
+// side is affected.
+type HealthReport struct {
+	Status HealthStatus                `json:"status"`
+	Checks map[string]DependencyHealth `json:"checks"`
+	Data   *DataCompleteness           `json:"data,omitempty"`
+}
+
+// DataState tells how complete the stored delegations are.
+type DataState string
+
+const (
+	// DataBackfilling means historical delegations are still being indexed:
+	// the stored ones are only complete through the watermark.
+	DataBackfilling DataState = "backfilling"
+	// DataIncomplete means historical indexing failed or was cancelled: the
+	// stored delegations are only complete through the watermark until a
+	// new historical indexing job succeeds.
+	DataIncomplete DataState = "incomplete"
+	// DataSyncing means history is indexed but polling has not caught up
+	// with the chain head.
+	DataSyncing DataState = "syncing"
+	DataLive    DataState = "live"
+)
+
+// DataCompleteness is the watermark up to which every delegation is stored:
+// responses include all delegations up to CompleteThroughLevel, the block
+// produced at CompleteThrough, and may miss later ones. The watermark is
+// omitted while it is not known yet.
+type DataCompleteness struct {
+	State                DataState  `json:"state"`
+	CompleteThroughLevel int64      `json:"complete_through_level,omitempty"`
+	CompleteThrough      *time.Time `json:"complete_through,omitempty"`
+	HeadLevel            int64      `json:"head_level,omitempty"`
+}
+
+// IndexerHealth compares the indexed chain level with the chain head. The
+// indexer is synced up to SyncedLevel, the head level observed the last time
+// it caught up, and LagSeconds is the time since that head was produced.
+type IndexerHealth struct {
+	Status        HealthStatus `json:"status"`
+	Detail        string       `json:"detail,omitempty"`
+	HeadLevel     int64        `json:"head_level"`
+	SyncedLevel   int64        `json:"synced_level"`
+	LagBlocks     int64        `json:"lag_blocks"`
+	LagSeconds    float64      `json:"lag_seconds"`
+	LastSyncedAt  *time.Time   `json:"last_synced_at,omitempty"`
+	MaxLagBlocks  int64        `json:"max_lag_blocks"`
+	MaxLagSeconds float64      `json:"max_lag_seconds"`
+}
+
+// MigrationStatus compares the schema version recorded in the database with
+// the one the running code expects.
+type MigrationStatus struct {
+	Applied  int `json:"applied"`
+	Expected int `json:"expected"`
+}
+
+// HealthRepository is implemented by repositories that can report on the
+// health of their storage.
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	MigrationStatus(ctx context.Context) (MigrationStatus, error)
+}
@@ -0,0 +1,216 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrJobNotFound = NewNotFoundError("job_not_found", "job not found")
+	ErrJobFinished = NewConflictError("job_finished", "job has already finished")
+	// ErrJobLeaseLost is returned to a worker saving the progress of a job
+	// it no longer holds, typically because the job went stale and was
+	// handed to another worker.
+	ErrJobLeaseLost = errors.New("job is no longer held by this worker")
+)
+
+type JobKind string
+
+const (
+	// JobReindex fetches and stores the delegations of a level range again.
+	JobReindex JobKind = "reindex"
+	// JobVerify compares the delegations stored in a level range with TzKT,
+	// and reindexes the parts that differ when repairing.
+	JobVerify JobKind = "verify"
+	// JobIndexHistorical indexes the delegations from the configured start
+	// date, or from the last one stored, up to now.
+	JobIndexHistorical JobKind = "index_historical"
+	// JobVerifySync compares the number of delegations stored since the
+	// configured start date with TzKT.
+	JobVerifySync JobKind = "verify_sync"
+	// JobBackup backs the database up.
+	JobBackup JobKind = "backup"
+	// JobBackfillBakers fetches again the levels of delegations stored
+	// before their baker and previous baker were.
+	JobBackfillBakers JobKind = "backfill_bakers"
+)
+
+func (k JobKind) Valid() bool {
+	switch k {
+	case JobReindex, JobVerify, JobIndexHistorical, JobVerifySync, JobBackup, JobBackfillBakers:
+		return true
+	}
+	return false
+}
+
+// Schedulable reports whether jobs of this kind need no parameters, and so
+// can be enqueued by a schedule.
+func (k JobKind) Schedulable() bool {
+	return k == JobIndexHistorical || k == JobVerifySync || k == JobBackup
+}
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+func (s JobStatus) Valid() bool {
+	switch s {
+	case JobPending, JobRunning, JobSucceeded, JobFailed, JobCancelled:
+		return true
+	}
+	return false
+}
+
+// Finished reports whether a job in this status will not run any more.
+func (s JobStatus) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// JobTrigger tells what enqueued a job.
+type JobTrigger string
+
+const (
+	TriggerManual   JobTrigger = "manual"
+	TriggerSchedule JobTrigger = "schedule"
+	TriggerStartup  JobTrigger = "startup"
+	// TriggerJob marks the jobs enqueued by another job once it succeeded.
+	TriggerJob JobTrigger = "job"
+)
+
+// Job is an operation run in the background. Reindex and verify jobs work
+// on the levels from FromLevel to ToLevel included.
+//
+// A failed attempt is retried, after a delay given by RunAt, until
+// MaxAttempts have been made. A singleton job is not enqueued while another
+// of its kind is pending or running.
+type Job struct {
+	ID              string              `json:"id"`
+	Kind            JobKind             `json:"kind"`
+	Status          JobStatus           `json:"status"`
+	Trigger         JobTrigger          `json:"trigger"`
+	FromLevel       int64               `json:"from_level,omitempty"`
+	ToLevel         int64               `json:"to_level,omitempty"`
+	Repair          bool                `json:"repair,omitempty"`
+	Progress        JobProgress         `json:"progress"`
+	Result          *VerificationResult `json:"result,omitempty"`
+	Error           string              `json:"error,omitempty"`
+	Attempts        int                 `json:"attempts"`
+	MaxAttempts     int                 `json:"max_attempts"`
+	CancelRequested bool                `json:"cancel_requested,omitempty"`
+	Singleton       bool                `json:"-"`
+	// ScheduledFor is the time a scheduled job was due; a schedule enqueues
+	// a single job per time, whichever replica gets there first.
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+	// Worker identifies the process running the job.
+	Worker     string     `json:"worker,omitempty"`
+	RunAt      time.Time  `json:"run_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobProgress tells how far a job has gone through its level range.
+type JobProgress struct {
+	Level int64 `json:"level"`
+	// Timestamp is the time of the block at Level, for jobs going through
+	// the chain in time order.
+	Timestamp   *time.Time `json:"timestamp,omitempty"`
+	Delegations int64      `json:"delegations"`
+	Percent     float64    `json:"percent"`
+}
+
+// VerificationResult compares the delegations stored with those TzKT
+// reports as applied. Mismatches lists the level ranges whose counts differ.
+type VerificationResult struct {
+	Expected   int64             `json:"expected"`
+	Stored     int64             `json:"stored"`
+	Mismatches []LevelRangeCount `json:"mismatches"`
+}
+
+// LevelRangeCount is a level range whose stored delegations differ from
+// TzKT's. Repaired is set once the range has been reindexed; Stored is the
+// count before that.
+type LevelRangeCount struct {
+	FromLevel int64 `json:"from_level"`
+	ToLevel   int64 `json:"to_level"`
+	Expected  int64 `json:"expected"`
+	Stored    int64 `json:"stored"`
+	Repaired  bool  `json:"repaired,omitempty"`
+}
+
+// JobQuery selects jobs, newest first. Empty fields match any job.
+type JobQuery struct {
+	Kind     JobKind
+	Statuses []JobStatus
+	Limit    int
+}
+
+// JobRepository persists background jobs, so that they outlive the process
+// that enqueued them and replicas share the work.
+type JobRepository interface {
+	// CreateJob stores a pending job. It stores nothing and returns false
+	// for a singleton job when another of its kind is pending or running,
+	// and for a scheduled job already enqueued for the same time.
+	CreateJob(ctx context.Context, job *Job) (bool, error)
+	GetJob(ctx context.Context, id string) (*Job, error)
+	ListJobs(ctx context.Context, query JobQuery) ([]Job, error)
+	// ClaimJob starts the attempt of the pending job due first whose kind has
+	// no job running, on behalf of worker. It returns nil when there is none.
+	ClaimJob(ctx context.Context, worker string) (*Job, error)
+	// SaveJobProgress records the progress, result and level range of a job
+	// running on job.Worker. It reports whether the job has been cancelled,
+	// and returns ErrJobLeaseLost when the worker no longer holds it.
+	SaveJobProgress(ctx context.Context, job *Job) (bool, error)
+	// FinishJob records the outcome of an attempt of a job running on
+	// job.Worker: its status, error, attempts, progress and result, RunAt for
+	// a retry and FinishedAt.
+	FinishJob(ctx context.Context, job *Job) error
+	// CancelJob cancels a pending job, or asks a running one to stop.
+	CancelJob(ctx context.Context, id string) (*Job, error)
+	// RequeueStaleJobs hands the running jobs whose worker has not saved
+	// their progress since staleBefore to another worker, or fails them when
+	// they are out of attempts.
+	RequeueStaleJobs(ctx context.Context, staleBefore time.Time) (int64, error)
+	// DeleteFinishedJobs forgets the jobs finished before the given time.
+	DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error)
+}
+
+// IndexerStatus describes the polling loop of this instance. Paused applies
+// to every replica, and RunningJobs counts the jobs running on any replica.
+type IndexerStatus struct {
+	Polling          bool  `json:"polling"`
+	Paused           bool  `json:"paused"`
+	LastIndexedLevel int64 `json:"last_indexed_level"`
+	HeadLevel        int64 `json:"head_level,omitempty"`
+	SyncedLevel      int64 `json:"synced_level,omitempty"`
+	RunningJobs      int   `json:"running_jobs"`
+}
+
+// PollingStateRepository stores whether polling is paused, so that a pause
+// applies to every replica and survives restarts.
+type PollingStateRepository interface {
+	SetPollingPaused(ctx context.Context, paused bool) error
+	IsPollingPaused(ctx context.Context) (bool, error)
+}
+
+// BakerBackfillRepository finds the delegations stored before the baker
+// columns existed: they have neither a baker nor a previous baker, which no
+// delegation stored since lacks.
+type BakerBackfillRepository interface {
+	// LevelsMissingBaker returns up to limit levels above afterLevel, in
+	// ascending order, holding such delegations.
+	LevelsMissingBaker(ctx context.Context, afterLevel int64, limit int) ([]int64, error)
+}
+
+// LevelCountRepository counts the delegations stored from fromLevel to
+// toLevel included.
+type LevelCountRepository interface {
+	CountDelegationsInLevels(ctx context.Context, fromLevel, toLevel int64) (int64, error)
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// TopicDelegationCreated is published once for every delegation the first
+// time it is stored.
+const TopicDelegationCreated = "delegation.created"
+
+// TopicDelegationsRolledBack is published when a chain reorganisation
+// removes the delegations stored from a level on. Consumers should drop what
+// they derived from those delegations; the delegations of the new branch are
+// published as delegation.created once they are indexed.
+const TopicDelegationsRolledBack = "delegations.rolled_back"
+
+// Rollback is the payload of a delegations.rolled_back event.
+type Rollback struct {
+	FromLevel int64 `json:"from_level"`
+	Removed   int64 `json:"removed"`
+}
+
+// OutboxEvent is an event recorded in the transaction that produced it and
+// relayed to the event bus afterwards. ID increases with commit order of
+// events written by one writer and identifies the event across redeliveries.
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Publisher delivers outbox events to an event bus. Publish returns only
+// once the bus has accepted every event; on error the whole batch is retried,
+// so consumers must tolerate duplicates (at-least-once delivery).
+type Publisher interface {
+	Publish(ctx context.Context, events []OutboxEvent) error
+	Close() error
+}
+
+type OutboxRepository interface {
+	// RelayOutbox hands the oldest unpublished events, up to limit, to
+	// publish and marks them published if it succeeds. It returns how many
+	// events were published.
+	RelayOutbox(ctx context.Context, limit int, publish func(ctx context.Context, events []OutboxEvent) error) (int, error)
+	// PruneOutbox deletes events published before the given time.
+	PruneOutbox(ctx context.Context, publishedBefore time.Time) (int64, error)
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// RateLimit is a token bucket holding up to Burst tokens and refilled with
+// PerSecond tokens every second. Every request takes one token.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// RateLimitDecision is the outcome of taking a token. Reset is the time
+// until the bucket is full again and RetryAfter, for a rejected request,
+// the time until a token is available.
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimiter takes a token from the bucket identified by key, creating a
+// full bucket with the given limit if it does not exist yet.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitDecision, error)
+}
+
+// NewRateLimitDecision describes a bucket left with tokens after a request
+// was allowed or rejected.
+func NewRateLimitDecision(limit RateLimit, tokens float64, allowed bool) RateLimitDecision {
+	tokens = math.Max(tokens, 0)
+	decision := RateLimitDecision{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     refillTime(float64(limit.Burst)-tokens, limit.PerSecond),
+	}
+	if !allowed {
+		decision.RetryAfter = refillTime(1-tokens, limit.PerSecond)
+	}
+	return decision
+}
+
+func refillTime(tokens, perSecond float64) time.Duration {
+	if tokens <= 0 || perSecond <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / perSecond * float64(time.Second))
+}
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	ErrDelegatorNotFound = NewNotFoundError("delegator_not_found", "delegator not found")
+	ErrAsOfOutOfRange    = NewValidationError("as_of_out_of_range", "as_of is outside the indexed history")
+)
+
+// AsOf selects a point in chain history, by block level or by timestamp.
+// Exactly one of the fields is set.
+type AsOf struct {
+	Level     *int64     `json:"level,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// DelegatorState is the current delegation of one account, projected from
+// the delegation event log. An empty Baker means the account undelegated.
+type DelegatorState struct {
+	Delegator      string    `json:"delegator"`
+	Baker          string    `json:"baker,omitempty"`
+	SinceLevel     int64     `json:"since_level"`
+	SinceTimestamp time.Time `json:"since_timestamp"`
+	LastAmount     string    `json:"last_amount"`
+	OperationHash  string    `json:"operation_hash"`
+}
+
+type DelegatorQuery struct {
+	Delegator string
+	AsOf      *AsOf
+}
+
+type BakerDelegatorsQuery struct {
+	Baker  string
+	Limit  int
+	Offset int
+	AsOf   *AsOf
+}
+
+type BakerDelegators struct {
+	Baker          string           `json:"baker"`
+	AsOf           *AsOf            `json:"as_of,omitempty"`
+	DelegatorCount int64            `json:"delegator_count"`
+	TotalAmount    string           `json:"total_amount"`
+	Data           []DelegatorState `json:"data"`
+}
+
+// StateRepository answers "who delegates to whom". Queries without AsOf read
+// the current projection; queries with AsOf replay the stored event history.
+type StateRepository interface {
+	GetDelegatorState(ctx context.Context, query DelegatorQuery) (*DelegatorState, error)
+	GetBakerDelegators(ctx context.Context, query BakerDelegatorsQuery) (*BakerDelegators, error)
+	// GetIndexedRange returns the bounds of the stored delegations, or nil
+	// when none is stored.
+	GetIndexedRange(ctx context.Context) (*IndexedRange, error)
+}
+
+// IndexedRange is the levels and timestamps of the oldest and latest stored
+// delegations, outside which the replayed state would be incomplete.
+type IndexedRange struct {
+	FirstLevel     int64
+	LastLevel      int64
+	FirstTimestamp time.Time
+	LastTimestamp  time.Time
+}
+
+// BakerSummary aggregates the current delegators of a baker.
+type BakerSummary struct {
+	Baker          string `json:"baker"`
+	DelegatorCount int64  `json:"delegator_count"`
+	TotalAmount    string `json:"total_amount"`
+}
+
+// BatchStateRepository looks up the current state of many accounts at once.
+// Accounts without state are left out of the result.
+type BatchStateRepository interface {
+	GetDelegatorStates(ctx context.Context, delegators []string) ([]DelegatorState, error)
+	GetBakerSummaries(ctx context.Context, bakers []string) ([]BakerSummary, error)
+}
@@ -0,0 +1,17 @@
+package domain
+
+import "context"
+
+// StreamQuery selects the delegations pushed to a live stream. When AfterSeq
+// is set the stream first replays stored delegations with a greater Seq.
+type StreamQuery struct {
+	Filter   DelegationFilter
+	AfterSeq *int64
+}
+
+type StreamRepository interface {
+	GetLatestSeq(ctx context.Context) (int64, error)
+	// FindAfterSeq returns up to limit delegations matching filter with
+	// afterSeq < Seq <= upToSeq, in Seq order.
+	FindAfterSeq(ctx context.Context, afterSeq, upToSeq int64, filter DelegationFilter, limit int) ([]Delegation, error)
+}
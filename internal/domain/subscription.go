@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"strconv"
+)
+
+// SubscriptionFilter selects live delegations. Empty fields match everything;
+// Bakers and Delegators match when any listed address matches.
+type SubscriptionFilter struct {
+	Bakers     []string `json:"bakers,omitempty"`
+	Delegators []string `json:"delegators,omitempty"`
+	MinAmount  int64    `json:"min_amount,omitempty"`
+}
+
+func (f SubscriptionFilter) Matches(d Delegation) bool {
+	if len(f.Bakers) > 0 && !contains(f.Bakers, d.Baker) {
+		return false
+	}
+	if len(f.Delegators) > 0 && !contains(f.Delegators, d.Delegator) {
+		return false
+	}
+	if f.MinAmount > 0 {
+		amount, err := strconv.ParseInt(d.Amount, 10, 64)
+		if err != nil || amount < f.MinAmount {
+			return false
+		}
+	}
+	return true
+}
+
+// Movement returns the delegation with its bakers exposed, the shape used
+// by live subscriptions.
+func (d Delegation) Movement() Movement {
+	return Movement{
+		Timestamp:     d.Timestamp,
+		Amount:        d.Amount,
+		Delegator:     d.Delegator,
+		PrevBaker:     d.PrevBaker,
+		Baker:         d.Baker,
+		Level:         d.Level,
+		OperationHash: d.OperationHash,
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionFilter_Matches(t *testing.T) {
+	d := Delegation{
+		Delegator: "tz1delegator",
+		Baker:     "tz1baker",
+		Amount:    "5000000",
+	}
+
+	tests := []struct {
+		name   string
+		filter SubscriptionFilter
+		want   bool
+	}{
+		{"empty filter", SubscriptionFilter{}, true},
+		{"matching baker", SubscriptionFilter{Bakers: []string{"tz1other", "tz1baker"}}, true},
+		{"other baker", SubscriptionFilter{Bakers: []string{"tz1other"}}, false},
+		{"matching delegator", SubscriptionFilter{Delegators: []string{"tz1delegator"}}, true},
+		{"other delegator", SubscriptionFilter{Delegators: []string{"tz1other"}}, false},
+		{"amount at threshold", SubscriptionFilter{MinAmount: 5000000}, true},
+		{"amount below threshold", SubscriptionFilter{MinAmount: 5000001}, false},
+		{"all criteria", SubscriptionFilter{Bakers: []string{"tz1baker"}, Delegators: []string{"tz1delegator"}, MinAmount: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(d))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound         = NewNotFoundError("webhook_not_found", "webhook not found")
+	ErrWebhookDeliveryNotFound = NewNotFoundError("webhook_delivery_not_found", "webhook delivery not found")
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"
+)
+
+// WebhookEventDelegation is the type of a delivery carrying one delegation.
+// Rollbacks are delivered with type TopicDelegationsRolledBack.
+const WebhookEventDelegation = "delegation"
+
+func (s WebhookDeliveryStatus) Valid() bool {
+	switch s {
+	case WebhookDeliveryPending, WebhookDeliveryDelivered, WebhookDeliveryDead:
+		return true
+	}
+	return false
+}
+
+// Webhook is an endpoint receiving delegations that match Filter once they
+// are committed. Secret signs each request and is only returned on creation.
+type Webhook struct {
+	ID        string             `json:"id"`
+	URL       string             `json:"url"`
+	Secret    string             `json:"secret,omitempty"`
+	Filter    SubscriptionFilter `json:"filter"`
+	Active    bool               `json:"active"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// WebhookDelivery is one event queued for one webhook, a delegation or a
+// rollback depending on Type. Payload is the data as posted, fixed when the
+// delivery is enqueued. For a rollback, OperationHash holds the event key.
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	WebhookID      string                `json:"webhook_id"`
+	OperationHash  string                `json:"operation_hash"`
+	Type           string                `json:"type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int                  `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
+
+// DueDelivery is a delivery claimed for an attempt, with the endpoint it
+// goes to.
+type DueDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+type WebhookDeliveryQuery struct {
+	WebhookID string
+	Status    WebhookDeliveryStatus
+	Limit     int
+}
+
+// WebhookRepository stores webhooks and their delivery outbox. Deliveries
+// are enqueued by the delegation repository in the transaction that commits
+// the delegations.
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	GetWebhook(ctx context.Context, id string) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, query WebhookDeliveryQuery) ([]WebhookDelivery, error)
+	// ClaimDueDeliveries counts an attempt for up to limit pending deliveries
+	// and hides them from other claimers for lease.
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error)
+	MarkDelivered(ctx context.Context, id int64, statusCode int) error
+	// MarkFailed records a failed attempt; a nil retryAt dead-letters the
+	// delivery. statusCode is 0 when no response was received.
+	MarkFailed(ctx context.Context, id int64, statusCode int, reason string, retryAt *time.Time) error
+	// Redeliver queues a delivery of webhookID again, whatever its status.
+	Redeliver(ctx context.Context, webhookID string, id int64) error
+}
@@ -0,0 +1,287 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// An archive is gzip-compressed NDJSON: a header line, one line per
+// delegation in chain order, and a trailer line with the number of
+// delegations, the checkpoint and the SHA-256 of every line before it.
+const (
+	Format = "tezos-delegations"
+	// Version is the archive version written. Readers accept archives up to
+	// this version.
+	Version = 1
+)
+
+const readBufferSize = 64 * 1024
+
+var (
+	// ErrCorrupt is returned for archives whose content does not match
+	// their trailer, and for snapshots that cannot be read.
+	ErrCorrupt = errors.New("snapshot is corrupt")
+	// ErrTruncated is returned for archives that end before their trailer,
+	// and for compressed snapshots that end early.
+	ErrTruncated = errors.New("snapshot is truncated")
+)
+
+// Header is the first line of an archive.
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Trailer is the last line of an archive.
+type Trailer struct {
+	Delegations int64              `json:"delegations"`
+	Checkpoint  *domain.Checkpoint `json:"checkpoint,omitempty"`
+	SHA256      string             `json:"sha256"`
+}
+
+// Record is a delegation as stored in an archive.
+type Record struct {
+	ID            string    `json:"id,omitempty"`
+	OperationHash string    `json:"operation_hash"`
+	Level         int64     `json:"level"`
+	Timestamp     time.Time `json:"timestamp"`
+	BlockHash     string    `json:"block_hash,omitempty"`
+	Delegator     string    `json:"delegator"`
+	Baker         string    `json:"baker,omitempty"`
+	PrevBaker     string    `json:"prev_baker,omitempty"`
+	Amount        string    `json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// line is any line after the header: a record, or the trailer.
+type line struct {
+	Record
+	Trailer *Trailer `json:"trailer,omitempty"`
+}
+
+func toRecord(d domain.Delegation) (Record, error) {
+	level, err := strconv.ParseInt(d.Level, 10, 64)
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid level %q of %s: %w", d.Level, d.OperationHash, err)
+	}
+	return Record{
+		ID:            d.ID,
+		OperationHash: d.OperationHash,
+		Level:         level,
+		Timestamp:     d.Timestamp.UTC(),
+		BlockHash:     d.BlockHash,
+		Delegator:     d.Delegator,
+		Baker:         d.Baker,
+		PrevBaker:     d.PrevBaker,
+		Amount:        d.Amount,
+		CreatedAt:     d.CreatedAt.UTC(),
+	}, nil
+}
+
+func (r Record) delegation() domain.Delegation {
+	return domain.Delegation{
+		ID:            r.ID,
+		OperationHash: r.OperationHash,
+		Level:         strconv.FormatInt(r.Level, 10),
+		Timestamp:     r.Timestamp,
+		BlockHash:     r.BlockHash,
+		Delegator:     r.Delegator,
+		Baker:         r.Baker,
+		PrevBaker:     r.PrevBaker,
+		Amount:        r.Amount,
+		CreatedAt:     r.CreatedAt,
+	}
+}
+
+// Writer writes an archive.
+type Writer struct {
+	gz      *gzip.Writer
+	hash    hash.Hash
+	enc     *json.Encoder
+	trailer Trailer
+}
+
+// NewWriter writes the header of an archive created at createdAt to w.
+func NewWriter(w io.Writer, createdAt time.Time) (*Writer, error) {
+	gz := gzip.NewWriter(w)
+	h := sha256.New()
+	aw := &Writer{gz: gz, hash: h, enc: json.NewEncoder(io.MultiWriter(gz, h))}
+
+	if err := aw.enc.Encode(Header{Format: Format, Version: Version, CreatedAt: createdAt.UTC()}); err != nil {
+		return nil, fmt.Errorf("failed to write archive header: %w", err)
+	}
+	return aw, nil
+}
+
+func (w *Writer) Write(d domain.Delegation) error {
+	record, err := toRecord(d)
+	if err != nil {
+		return err
+	}
+	if err := w.enc.Encode(record); err != nil {
+		return fmt.Errorf("failed to write delegation %s: %w", d.OperationHash, err)
+	}
+
+	w.trailer.Delegations++
+	w.trailer.Checkpoint = advanceCheckpoint(w.trailer.Checkpoint, record.Level, record.Timestamp)
+	return nil
+}
+
+// advanceCheckpoint returns the later of checkpoint and the block at level.
+func advanceCheckpoint(checkpoint *domain.Checkpoint, level int64, timestamp time.Time) *domain.Checkpoint {
+	if checkpoint == nil || level >= checkpoint.Level {
+		return &domain.Checkpoint{Level: level, Timestamp: timestamp}
+	}
+	return checkpoint
+}
+
+// Close writes the trailer and flushes the archive. It does not close the
+// underlying writer.
+func (w *Writer) Close() (Trailer, error) {
+	w.trailer.SHA256 = hex.EncodeToString(w.hash.Sum(nil))
+	if err := json.NewEncoder(w.gz).Encode(struct {
+		Trailer Trailer `json:"trailer"`
+	}{w.trailer}); err != nil {
+		return Trailer{}, fmt.Errorf("failed to write archive trailer: %w", err)
+	}
+	if err := w.gz.Close(); err != nil {
+		return Trailer{}, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	return w.trailer, nil
+}
+
+// Reader reads an archive, checking it against its trailer as it goes.
+// Delegations returned before the trailer is reached have not been
+// verified yet; see Verify.
+type Reader struct {
+	r       *bufio.Reader
+	hash    hash.Hash
+	header  Header
+	trailer *Trailer
+	read    int64
+}
+
+// NewReader reads the header of the archive in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return readArchive(bufio.NewReaderSize(gz, readBufferSize))
+}
+
+// readArchive reads the header of the decompressed archive in r.
+func readArchive(r *bufio.Reader) (*Reader, error) {
+	ar := &Reader{r: r, hash: sha256.New()}
+
+	raw, err := ar.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &ar.header); err != nil || ar.header.Format != Format {
+		return nil, fmt.Errorf("%w: not a %s archive", ErrCorrupt, Format)
+	}
+	if ar.header.Version < 1 || ar.header.Version > Version {
+		return nil, fmt.Errorf("unsupported backup archive version %d", ar.header.Version)
+	}
+	ar.hash.Write(raw)
+	return ar, nil
+}
+
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Trailer returns the trailer, once Next has returned io.EOF.
+func (r *Reader) Trailer() Trailer {
+	if r.trailer == nil {
+		return Trailer{}
+	}
+	return *r.trailer
+}
+
+// Next returns the next delegation. It returns io.EOF after the trailer,
+// once the archive has been checked against it.
+func (r *Reader) Next() (domain.Delegation, error) {
+	if r.trailer != nil {
+		return domain.Delegation{}, io.EOF
+	}
+
+	raw, err := r.readLine()
+	if err != nil {
+		return domain.Delegation{}, err
+	}
+	var l line
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return domain.Delegation{}, fmt.Errorf("%w: line %d: %v", ErrCorrupt, r.read+2, err)
+	}
+
+	if l.Trailer != nil {
+		r.trailer = l.Trailer
+		if err := r.check(); err != nil {
+			return domain.Delegation{}, err
+		}
+		return domain.Delegation{}, io.EOF
+	}
+
+	r.hash.Write(raw)
+	r.read++
+	return l.Record.delegation(), nil
+}
+
+// check compares what was read with the trailer, and makes sure nothing
+// follows it.
+func (r *Reader) check() error {
+	if r.trailer.Delegations != r.read {
+		return fmt.Errorf("%w: %d delegations read, %d expected", ErrCorrupt, r.read, r.trailer.Delegations)
+	}
+	if sum := hex.EncodeToString(r.hash.Sum(nil)); sum != r.trailer.SHA256 {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	if _, err := r.r.ReadByte(); err != io.EOF {
+		return fmt.Errorf("%w: data after the trailer", ErrCorrupt)
+	}
+	return nil
+}
+
+// readLine returns the next line, with its newline.
+func (r *Reader) readLine() ([]byte, error) {
+	raw, err := r.r.ReadBytes('\n')
+	switch {
+	case err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF):
+		return nil, ErrTruncated
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	case len(bytes.TrimSpace(raw)) == 0:
+		return nil, fmt.Errorf("%w: empty line", ErrCorrupt)
+	}
+	return raw, nil
+}
+
+// Verify reads a whole archive and checks it against its trailer.
+func Verify(r io.Reader) (Header, Trailer, error) {
+	ar, err := NewReader(r)
+	if err != nil {
+		return Header{}, Trailer{}, err
+	}
+	for {
+		if _, err := ar.Next(); err == io.EOF {
+			return ar.Header(), ar.Trailer(), nil
+		} else if err != nil {
+			return Header{}, Trailer{}, err
+		}
+	}
+}
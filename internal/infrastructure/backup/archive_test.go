@@ -0,0 +1,138 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func delegationAt(level int64, hash string) domain.Delegation {
+	return domain.Delegation{
+		ID:            "id-" + hash,
+		Timestamp:     time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(level) * time.Second),
+		Amount:        "150000000000",
+		Delegator:     "tz1delegator",
+		Baker:         "tz1baker",
+		Level:         strconv.FormatInt(level, 10),
+		BlockHash:     "block" + hash,
+		OperationHash: hash,
+		CreatedAt:     time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func writeArchive(t *testing.T, delegations ...domain.Delegation) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	for _, d := range delegations {
+		require.NoError(t, w.Write(d))
+	}
+	_, err = w.Close()
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+// rewrite decompresses an archive, edits its text and compresses it again.
+func rewrite(t *testing.T, archive []byte, edit func(string) string) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+	text, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err = w.Write([]byte(edit(string(text))))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	delegations := []domain.Delegation{delegationAt(100, "op1"), delegationAt(250, "op2"), delegationAt(180, "op3")}
+	archive := writeArchive(t, delegations...)
+
+	r, err := NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+	assert.Equal(t, Format, r.Header().Format)
+	assert.Equal(t, Version, r.Header().Version)
+
+	var read []domain.Delegation
+	for {
+		d, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		read = append(read, d)
+	}
+	assert.Equal(t, delegations, read)
+
+	trailer := r.Trailer()
+	assert.Equal(t, int64(3), trailer.Delegations)
+	require.NotNil(t, trailer.Checkpoint)
+	assert.Equal(t, int64(250), trailer.Checkpoint.Level)
+	assert.Equal(t, delegations[1].Timestamp, trailer.Checkpoint.Timestamp)
+	assert.Len(t, trailer.SHA256, 64)
+}
+
+func TestArchive_Empty(t *testing.T) {
+	_, trailer, err := Verify(bytes.NewReader(writeArchive(t)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), trailer.Delegations)
+	assert.Nil(t, trailer.Checkpoint)
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	archive := writeArchive(t, delegationAt(100, "op1"), delegationAt(200, "op2"))
+
+	testCases := []struct {
+		name     string
+		archive  []byte
+		expected error
+	}{
+		{"Tampered delegation", rewrite(t, archive, func(s string) string {
+			return strings.Replace(s, "tz1baker", "tz1other", 1)
+		}), ErrCorrupt},
+		{"Missing delegation", rewrite(t, archive, func(s string) string {
+			lines := strings.SplitAfter(s, "\n")
+			return lines[0] + lines[2] + lines[3]
+		}), ErrCorrupt},
+		{"Missing trailer", rewrite(t, archive, func(s string) string {
+			return s[:strings.Index(s, `{"trailer"`)]
+		}), ErrTruncated},
+		{"Cut short", archive[:len(archive)/2], ErrTruncated},
+		{"Data after trailer", rewrite(t, archive, func(s string) string {
+			return s + `{"operation_hash":"op3"}` + "\n"
+		}), ErrCorrupt},
+		{"Not an archive", rewrite(t, archive, func(s string) string {
+			return `{"format":"other"}` + "\n"
+		}), ErrCorrupt},
+		{"Not gzip", []byte("operation_hash,level\n"), ErrCorrupt},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Verify(bytes.NewReader(tc.archive))
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+func TestVerify_UnsupportedVersion(t *testing.T) {
+	archive := rewrite(t, writeArchive(t), func(s string) string {
+		return strings.Replace(s, `"version":1`, `"version":2`, 1)
+	})
+
+	_, _, err := Verify(bytes.NewReader(archive))
+	assert.ErrorContains(t, err, "unsupported backup archive version 2")
+}
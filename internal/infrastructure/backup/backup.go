@@ -0,0 +1,351 @@
+// Package backup backs the stored delegations up to versioned, checksummed
+// archives, in a local directory or an S3-compatible bucket, and restores
+// them, or delegation exports, into a deployment.
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const (
+	// restoreBatchSize is how many delegations are restored per
+	// transaction.
+	restoreBatchSize = 1000
+	// progressInterval is how many delegations are backed up or restored
+	// between progress reports.
+	progressInterval = 10_000
+)
+
+// Latest designates the most recent archive of a store.
+const Latest = "latest"
+
+// Info describes an archive written by Backup.
+type Info struct {
+	Name        string             `json:"name"`
+	Location    string             `json:"location"`
+	Size        int64              `json:"size"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Delegations int64              `json:"delegations"`
+	Checkpoint  *domain.Checkpoint `json:"checkpoint,omitempty"`
+	SHA256      string             `json:"sha256"`
+}
+
+// RestoreResult tells how many delegations a snapshot held and how many of
+// them were not stored yet.
+type RestoreResult struct {
+	Name        string             `json:"name"`
+	Delegations int64              `json:"delegations"`
+	Restored    int64              `json:"restored"`
+	Checkpoint  *domain.Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// Manager backs up a repository to a store and restores it.
+type Manager struct {
+	repo   domain.BackupRepository
+	store  Store
+	keep   int
+	logger *logger.Logger
+	now    func() time.Time
+}
+
+// NewManager returns a manager keeping the keep latest archives, or all of
+// them when keep is zero.
+func NewManager(repo domain.BackupRepository, store Store, keep int, logger *logger.Logger) *Manager {
+	return &Manager{repo: repo, store: store, keep: keep, logger: logger, now: time.Now}
+}
+
+func (m *Manager) Store() Store {
+	return m.store
+}
+
+// Backup writes every stored delegation to a new archive, then deletes the
+// archives beyond the ones kept. progress, when set, is called with the
+// number of delegations written so far.
+func (m *Manager) Backup(ctx context.Context, progress func(delegations int64)) (*Info, error) {
+	createdAt := m.now()
+	name := archiveName(createdAt)
+
+	m.logger.Infow("Creating backup", "name", name, "location", m.store.Location())
+
+	pr, pw := io.Pipe()
+	written := make(chan Trailer, 1)
+	go func() {
+		trailer, err := m.write(ctx, pw, createdAt, progress)
+		written <- trailer
+		pw.CloseWithError(err)
+	}()
+
+	size, err := m.store.Put(ctx, name, pr)
+	// Unblock the writer if the upload stopped reading.
+	pr.CloseWithError(io.ErrClosedPipe)
+	trailer := <-written
+	if err != nil {
+		return nil, fmt.Errorf("backup failed: %w", err)
+	}
+
+	info := &Info{
+		Name:        name,
+		Location:    m.store.Location(),
+		Size:        size,
+		CreatedAt:   createdAt,
+		Delegations: trailer.Delegations,
+		Checkpoint:  trailer.Checkpoint,
+		SHA256:      trailer.SHA256,
+	}
+	m.logger.Infow("Backup created", "name", name, "delegations", info.Delegations, "size", size)
+
+	if _, err := m.Prune(ctx); err != nil {
+		m.logger.Warnw("Failed to delete old backups", "error", err)
+	}
+	return info, nil
+}
+
+func (m *Manager) write(ctx context.Context, w io.Writer, createdAt time.Time, progress func(int64)) (Trailer, error) {
+	aw, err := NewWriter(w, createdAt)
+	if err != nil {
+		return Trailer{}, err
+	}
+
+	var count int64
+	err = m.repo.ScanDelegations(ctx, func(d domain.Delegation) error {
+		if err := aw.Write(d); err != nil {
+			return err
+		}
+		count++
+		if progress != nil && count%progressInterval == 0 {
+			progress(count)
+		}
+		return nil
+	})
+	if err != nil {
+		return Trailer{}, err
+	}
+
+	trailer, err := aw.Close()
+	if err == nil && progress != nil {
+		progress(count)
+	}
+	return trailer, err
+}
+
+// Prune deletes the archives beyond the keep latest ones and returns how
+// many it deleted.
+func (m *Manager) Prune(ctx context.Context) (int, error) {
+	if m.keep == 0 {
+		return 0, nil
+	}
+	objects, err := m.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, object := range objects[min(m.keep, len(objects)):] {
+		if err := m.store.Delete(ctx, object.Name); err != nil {
+			return deleted, err
+		}
+		deleted++
+		m.logger.Infow("Deleted old backup", "name", object.Name)
+	}
+	return deleted, nil
+}
+
+// List returns the archives in the store, newest first.
+func (m *Manager) List(ctx context.Context) ([]Object, error) {
+	return m.store.List(ctx)
+}
+
+// Restore restores the archive called name, or the latest one, from the
+// store. See RestoreFrom.
+func (m *Manager) Restore(ctx context.Context, name string, progress func(delegations int64)) (*RestoreResult, error) {
+	if name == "" || name == Latest {
+		objects, err := m.store.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(objects) == 0 {
+			return nil, fmt.Errorf("%w in %s", ErrNotFound, m.store.Location())
+		}
+		name = objects[0].Name
+	}
+
+	result, err := m.RestoreFrom(ctx, func() (io.ReadCloser, error) { return m.store.Open(ctx, name) }, progress)
+	if err != nil {
+		return nil, err
+	}
+	result.Name = name
+	return result, nil
+}
+
+// RestoreFrom verifies the snapshot open returns, a backup archive or a
+// delegation export, then imports it. The snapshot is read twice, so that
+// nothing is restored from a corrupt one. Restoring it again adds nothing.
+func (m *Manager) RestoreFrom(ctx context.Context, open func() (io.ReadCloser, error), progress func(delegations int64)) (*RestoreResult, error) {
+	snapshot, err := m.verify(open, "")
+	if err != nil {
+		return nil, err
+	}
+	return m.Import(ctx, open, snapshot, progress)
+}
+
+// verify checks the snapshot open returns and, when checksum is set, that
+// the file has this SHA-256 digest.
+func (m *Manager) verify(open func() (io.ReadCloser, error), checksum string) (*Snapshot, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	snapshot, err := VerifySnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != "" && !strings.EqualFold(checksum, snapshot.SHA256) {
+		return nil, fmt.Errorf("%w: sha256 is %s, %s expected", ErrCorrupt, snapshot.SHA256, checksum)
+	}
+	m.logger.Infow("Snapshot verified", "format", snapshot.Format, "delegations", snapshot.Delegations, "sha256", snapshot.SHA256)
+	return snapshot, nil
+}
+
+// Import stores the delegations of a snapshot VerifySnapshot accepted that
+// are not stored yet, then records its checkpoint. It fails if the snapshot
+// open returns is no longer the one verified.
+func (m *Manager) Import(ctx context.Context, open func() (io.ReadCloser, error), snapshot *Snapshot, progress func(delegations int64)) (*RestoreResult, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	tee := io.TeeReader(r, h)
+	dr, _, err := openSnapshot(tee)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := m.restore(ctx, dr, progress)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return nil, readError(err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != snapshot.SHA256 {
+		return nil, fmt.Errorf("%w: the snapshot changed since it was verified", ErrCorrupt)
+	}
+
+	result.Checkpoint = snapshot.Checkpoint
+	if result.Checkpoint != nil {
+		if err := m.repo.UpdateIndexingMetadata(ctx, result.Checkpoint.Level, result.Checkpoint.Timestamp); err != nil {
+			return nil, err
+		}
+	}
+	m.logger.Infow("Snapshot restored", "delegations", result.Delegations, "restored", result.Restored)
+	return result, nil
+}
+
+// SeedCheck rejects a verified snapshot that does not hold every delegation
+// up to its checkpoint, such as a filtered export.
+type SeedCheck func(ctx context.Context, snapshot *Snapshot) error
+
+// Seed imports the snapshot at source, a path or an http(s) URL, into a
+// fresh deployment, once check accepts it. It returns a nil result,
+// importing nothing, when a checkpoint was already restored or the stored
+// delegations go beyond the snapshot. An import that failed part way is
+// therefore resumed by seeding again. checksum, when set, is the SHA-256
+// digest the file must have.
+func (m *Manager) Seed(ctx context.Context, source, checksum string, check SeedCheck, progress func(delegations int64)) (*RestoreResult, error) {
+	restored, _, err := m.repo.GetIndexingMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if restored > 0 {
+		m.logger.Infow("Not seeding from snapshot, a checkpoint was already restored", "level", restored)
+		return nil, nil
+	}
+
+	m.logger.Infow("Seeding from snapshot", "source", source)
+	open, cleanup, err := FetchSnapshot(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	snapshot, err := m.verify(open, checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := m.repo.GetLastIndexedLevel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Checkpoint == nil {
+		return nil, fmt.Errorf("%w: the snapshot holds no delegations", ErrCorrupt)
+	}
+	if stored > snapshot.Checkpoint.Level {
+		m.logger.Infow("Not seeding from snapshot, the stored delegations go beyond it", "storedLevel", stored)
+		return nil, nil
+	}
+	if err := check(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	result, err := m.Import(ctx, open, snapshot, progress)
+	if err != nil {
+		return nil, err
+	}
+	result.Name = source
+	return result, nil
+}
+
+func (m *Manager) restore(ctx context.Context, dr delegationReader, progress func(int64)) (*RestoreResult, error) {
+	result := &RestoreResult{}
+	batch := make([]domain.Delegation, 0, restoreBatchSize)
+	flush := func() error {
+		restored, err := m.repo.RestoreDelegations(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.Delegations += int64(len(batch))
+		result.Restored += restored
+		batch = batch[:0]
+		if progress != nil {
+			progress(result.Delegations)
+		}
+		return nil
+	}
+
+	for {
+		d, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, d)
+		if len(batch) == restoreBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
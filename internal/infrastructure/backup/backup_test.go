@@ -0,0 +1,226 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRepository stores delegations by operation hash, like the
+// delegations table.
+type memoryRepository struct {
+	mu          sync.Mutex
+	delegations []domain.Delegation
+	checkpoint  *domain.Checkpoint
+	scanErr     error
+}
+
+func (r *memoryRepository) ScanDelegations(ctx context.Context, emit func(domain.Delegation) error) error {
+	r.mu.Lock()
+	delegations := append([]domain.Delegation(nil), r.delegations...)
+	r.mu.Unlock()
+
+	for i, d := range delegations {
+		if r.scanErr != nil && i == len(delegations)/2 {
+			return r.scanErr
+		}
+		if err := emit(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) RestoreDelegations(ctx context.Context, delegations []domain.Delegation) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var restored int64
+	for _, d := range delegations {
+		if !r.stored(d.OperationHash) {
+			r.delegations = append(r.delegations, d)
+			restored++
+		}
+	}
+	return restored, nil
+}
+
+func (r *memoryRepository) stored(hash string) bool {
+	for _, d := range r.delegations {
+		if d.OperationHash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryRepository) UpdateIndexingMetadata(ctx context.Context, level int64, timestamp time.Time) error {
+	r.checkpoint = &domain.Checkpoint{Level: level, Timestamp: timestamp}
+	return nil
+}
+
+func (r *memoryRepository) GetIndexingMetadata(ctx context.Context) (int64, *time.Time, error) {
+	if r.checkpoint == nil {
+		return 0, nil, nil
+	}
+	return r.checkpoint.Level, &r.checkpoint.Timestamp, nil
+}
+
+func (r *memoryRepository) GetLastIndexedLevel(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last int64
+	for _, d := range r.delegations {
+		level, _ := strconv.ParseInt(d.Level, 10, 64)
+		last = max(last, level)
+	}
+	return last, nil
+}
+
+func newTestManager(t *testing.T, repo *memoryRepository, dir string, keep int) *Manager {
+	t.Helper()
+	log, _ := logger.New("debug", "test")
+	store, err := NewStore(&config.Backup{Target: dir})
+	require.NoError(t, err)
+	return NewManager(repo, store, keep, log)
+}
+
+func TestManager_BackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	source := &memoryRepository{delegations: []domain.Delegation{
+		delegationAt(100, "op1"), delegationAt(200, "op2"), delegationAt(300, "op3"),
+	}}
+	manager := newTestManager(t, source, dir, 0)
+
+	var progress int64
+	info, err := manager.Backup(context.Background(), func(n int64) { progress = n })
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Delegations)
+	assert.Equal(t, int64(3), progress)
+	require.NotNil(t, info.Checkpoint)
+	assert.Equal(t, int64(300), info.Checkpoint.Level)
+
+	stat, err := os.Stat(filepath.Join(dir, info.Name))
+	require.NoError(t, err)
+	assert.Equal(t, stat.Size(), info.Size)
+
+	// One delegation is already stored in the target.
+	target := &memoryRepository{delegations: []domain.Delegation{delegationAt(200, "op2")}}
+	restorer := newTestManager(t, target, dir, 0)
+
+	result, err := restorer.Restore(context.Background(), Latest, nil)
+	require.NoError(t, err)
+	assert.Equal(t, info.Name, result.Name)
+	assert.Equal(t, int64(3), result.Delegations)
+	assert.Equal(t, int64(2), result.Restored)
+	assert.Len(t, target.delegations, 3)
+	assert.Equal(t, info.Checkpoint, target.checkpoint)
+
+	// Restoring again adds nothing.
+	result, err = restorer.Restore(context.Background(), info.Name, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Restored)
+	assert.Len(t, target.delegations, 3)
+}
+
+func TestManager_Retention(t *testing.T) {
+	dir := t.TempDir()
+	manager := newTestManager(t, &memoryRepository{delegations: []domain.Delegation{delegationAt(100, "op1")}}, dir, 2)
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var names []string
+	for i := 0; i < 4; i++ {
+		manager.now = func() time.Time { return now.Add(time.Duration(i) * time.Hour) }
+		info, err := manager.Backup(context.Background(), nil)
+		require.NoError(t, err)
+		names = append(names, info.Name)
+	}
+
+	objects, err := manager.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	assert.Equal(t, names[3], objects[0].Name)
+	assert.Equal(t, names[2], objects[1].Name)
+}
+
+func TestManager_FailedBackupStoresNothing(t *testing.T) {
+	dir := t.TempDir()
+	repo := &memoryRepository{
+		delegations: []domain.Delegation{delegationAt(100, "op1"), delegationAt(200, "op2")},
+		scanErr:     errors.New("connection reset"),
+	}
+	manager := newTestManager(t, repo, dir, 0)
+
+	_, err := manager.Backup(context.Background(), nil)
+	assert.ErrorContains(t, err, "connection reset")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestManager_RestoreVerifiesFirst(t *testing.T) {
+	archive := writeArchive(t, delegationAt(100, "op1"), delegationAt(200, "op2"))
+	corrupt := append(archive[:len(archive)-20:len(archive)-20], make([]byte, 20)...)
+
+	target := &memoryRepository{}
+	manager := newTestManager(t, target, t.TempDir(), 0)
+
+	_, err := manager.RestoreFrom(context.Background(), func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(corrupt)), nil
+	}, nil)
+	assert.Error(t, err)
+	assert.Empty(t, target.delegations)
+	assert.Nil(t, target.checkpoint)
+}
+
+func TestManager_RestoreWithoutArchives(t *testing.T) {
+	manager := newTestManager(t, &memoryRepository{}, t.TempDir(), 0)
+
+	_, err := manager.Restore(context.Background(), Latest, nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = manager.Restore(context.Background(), "delegations-20240601T000000Z.ndjson.gz", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = manager.Restore(context.Background(), "../etc/passwd", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestParseS3Target(t *testing.T) {
+	testCases := []struct {
+		target, bucket, prefix string
+		valid                  bool
+	}{
+		{"s3://backups", "backups", "", true},
+		{"s3://backups/tezos/prod/", "backups", "tezos/prod/", true},
+		{"s3://backups/tezos", "backups", "tezos/", true},
+		{"s3:///tezos", "", "", false},
+		{"https://backups/tezos", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		bucket, prefix, err := parseS3Target(tc.target)
+		if !tc.valid {
+			assert.Error(t, err, tc.target)
+			continue
+		}
+		require.NoError(t, err, tc.target)
+		assert.Equal(t, tc.bucket, bucket, tc.target)
+		assert.Equal(t, tc.prefix, prefix, tc.target)
+	}
+}
@@ -0,0 +1,344 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// SnapshotFormat is the format of a file delegations are restored from.
+type SnapshotFormat string
+
+const (
+	// FormatArchive is the format of backup archives.
+	FormatArchive SnapshotFormat = "archive"
+	// FormatNDJSON and FormatCSV are the formats of delegation exports,
+	// such as GET /xtz/delegations?format=ndjson.
+	FormatNDJSON SnapshotFormat = "ndjson"
+	FormatCSV    SnapshotFormat = "csv"
+)
+
+// sniffSize is how much of a snapshot is read to detect its format.
+const sniffSize = 4096
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// requiredCSVColumns are the columns of delegation CSV exports. Without the
+// block and the bakers, delegations could not be stored as indexed.
+var requiredCSVColumns = []string{"timestamp", "amount", "delegator", "level", "operation_hash", "block_hash", "baker", "prev_baker"}
+
+// Snapshot describes a snapshot checked by VerifySnapshot.
+type Snapshot struct {
+	Format      SnapshotFormat `json:"format"`
+	Delegations int64          `json:"delegations"`
+	// FirstLevel is the level of the earliest delegation.
+	FirstLevel int64              `json:"first_level"`
+	Checkpoint *domain.Checkpoint `json:"checkpoint,omitempty"`
+	// SHA256 is the digest of the file, compressed or not.
+	SHA256 string `json:"sha256"`
+}
+
+// delegationReader returns the delegations of a snapshot, then io.EOF.
+type delegationReader interface {
+	Next() (domain.Delegation, error)
+}
+
+// openSnapshot detects the format of the snapshot in r: a backup archive, or
+// a delegation export as NDJSON or CSV, possibly gzip-compressed.
+func openSnapshot(r io.Reader) (delegationReader, SnapshotFormat, error) {
+	br := bufio.NewReaderSize(r, readBufferSize)
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		br = bufio.NewReaderSize(gz, readBufferSize)
+	}
+
+	start, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", readError(err)
+	}
+	start = bytes.TrimLeft(start, " \t\r\n")
+
+	switch {
+	case len(start) == 0:
+		return nil, "", fmt.Errorf("%w: empty snapshot", ErrCorrupt)
+	case start[0] != '{':
+		cr, err := newCSVReader(br)
+		return cr, FormatCSV, err
+	case isArchiveHeader(start):
+		ar, err := readArchive(br)
+		return ar, FormatArchive, err
+	default:
+		return &ndjsonReader{r: br}, FormatNDJSON, nil
+	}
+}
+
+func isArchiveHeader(start []byte) bool {
+	first, _, _ := bytes.Cut(start, []byte{'\n'})
+	var header Header
+	return json.Unmarshal(first, &header) == nil && header.Format == Format
+}
+
+func readError(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrTruncated
+	}
+	return fmt.Errorf("%w: %v", ErrCorrupt, err)
+}
+
+// checkDelegation rejects delegations that could not have been exported by
+// the service.
+func checkDelegation(d domain.Delegation) error {
+	switch {
+	case d.OperationHash == "":
+		return errors.New("missing operation_hash")
+	case d.Delegator == "":
+		return errors.New("missing delegator")
+	case d.BlockHash == "":
+		return errors.New("missing block_hash")
+	case d.Timestamp.IsZero():
+		return errors.New("missing timestamp")
+	}
+	if level, err := strconv.ParseInt(d.Level, 10, 64); err != nil || level < 1 {
+		return fmt.Errorf("invalid level %q", d.Level)
+	}
+	if amount, err := strconv.ParseInt(d.Amount, 10, 64); err != nil || amount < 0 {
+		return fmt.Errorf("invalid amount %q", d.Amount)
+	}
+	return nil
+}
+
+// exportRecord is a line of an NDJSON export. Levels and amounts are
+// accepted as strings, as exported, or as numbers.
+type exportRecord struct {
+	OperationHash string         `json:"operation_hash"`
+	Level         json.Number    `json:"level"`
+	Timestamp     time.Time      `json:"timestamp"`
+	BlockHash     string         `json:"block_hash"`
+	Delegator     string         `json:"delegator"`
+	Baker         requiredString `json:"baker"`
+	PrevBaker     requiredString `json:"prev_baker"`
+	Amount        json.Number    `json:"amount"`
+}
+
+// requiredString is a field that must be present, though it may be empty or
+// null: delegations without a baker are undelegations.
+type requiredString struct {
+	value string
+	set   bool
+}
+
+func (s *requiredString) UnmarshalJSON(data []byte) error {
+	s.set = true
+	if string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, &s.value)
+}
+
+type ndjsonReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (r *ndjsonReader) Next() (domain.Delegation, error) {
+	for {
+		raw, err := r.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return domain.Delegation{}, readError(err)
+		}
+		if len(bytes.TrimSpace(raw)) == 0 {
+			if err == io.EOF {
+				return domain.Delegation{}, io.EOF
+			}
+			r.line++
+			continue
+		}
+		r.line++
+
+		var record exportRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return domain.Delegation{}, fmt.Errorf("%w: line %d: %v", ErrCorrupt, r.line, err)
+		}
+		if !record.Baker.set || !record.PrevBaker.set {
+			return domain.Delegation{}, fmt.Errorf("%w: line %d: missing baker or prev_baker", ErrCorrupt, r.line)
+		}
+		d := domain.Delegation{
+			OperationHash: record.OperationHash,
+			Level:         record.Level.String(),
+			Timestamp:     record.Timestamp,
+			BlockHash:     record.BlockHash,
+			Delegator:     record.Delegator,
+			Baker:         record.Baker.value,
+			PrevBaker:     record.PrevBaker.value,
+			Amount:        record.Amount.String(),
+		}
+		if err := checkDelegation(d); err != nil {
+			return domain.Delegation{}, fmt.Errorf("%w: line %d: %v", ErrCorrupt, r.line, err)
+		}
+		return d, nil
+	}
+}
+
+// csvReader reads a CSV export. Columns are found by the names in its
+// header.
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a delegation export", ErrCorrupt)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: not a delegation export, the %s column is missing", ErrCorrupt, name)
+		}
+	}
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (r *csvReader) Next() (domain.Delegation, error) {
+	record, err := r.r.Read()
+	if err == io.EOF {
+		return domain.Delegation{}, io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return domain.Delegation{}, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		return domain.Delegation{}, readError(err)
+	}
+	line, _ := r.r.FieldPos(0)
+
+	field := func(name string) string {
+		return strings.TrimSpace(record[r.columns[name]])
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, field("timestamp"))
+	if err != nil {
+		return domain.Delegation{}, fmt.Errorf("%w: line %d: invalid timestamp %q", ErrCorrupt, line, field("timestamp"))
+	}
+	d := domain.Delegation{
+		OperationHash: field("operation_hash"),
+		Level:         field("level"),
+		Timestamp:     timestamp,
+		BlockHash:     field("block_hash"),
+		Delegator:     field("delegator"),
+		Baker:         field("baker"),
+		PrevBaker:     field("prev_baker"),
+		Amount:        field("amount"),
+	}
+	if err := checkDelegation(d); err != nil {
+		return domain.Delegation{}, fmt.Errorf("%w: line %d: %v", ErrCorrupt, line, err)
+	}
+	return d, nil
+}
+
+// VerifySnapshot reads a whole snapshot: an archive is checked against its
+// trailer, and every delegation of an export must be valid. The checkpoint
+// of an export is its latest delegation.
+func VerifySnapshot(r io.Reader) (*Snapshot, error) {
+	h := sha256.New()
+	r = io.TeeReader(r, h)
+
+	dr, format, err := openSnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Format: format}
+	for {
+		d, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		level, err := strconv.ParseInt(d.Level, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid level %q of %s", ErrCorrupt, d.Level, d.OperationHash)
+		}
+		if snapshot.Delegations == 0 || level < snapshot.FirstLevel {
+			snapshot.FirstLevel = level
+		}
+		snapshot.Delegations++
+		snapshot.Checkpoint = advanceCheckpoint(snapshot.Checkpoint, level, d.Timestamp)
+	}
+
+	// Hash what the readers left unread, such as gzip padding.
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, readError(err)
+	}
+	snapshot.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return snapshot, nil
+}
+
+// FetchSnapshot returns a function opening the snapshot at source, a path or
+// an http(s) URL. A URL is downloaded once to a temporary file, which
+// cleanup removes, so that every read sees the same content.
+func FetchSnapshot(ctx context.Context, source string) (open func() (io.ReadCloser, error), cleanup func(), err error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		open = func() (io.ReadCloser, error) { return os.Open(source) }
+		return open, func() {}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid snapshot URL: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to download snapshot: %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp("", "snapshot-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	cleanup = func() { os.Remove(tmp.Name()) }
+
+	_, err = io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to download snapshot: %w", err)
+	}
+
+	open = func() (io.ReadCloser, error) { return os.Open(tmp.Name()) }
+	return open, cleanup, nil
+}
@@ -0,0 +1,210 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Exports list the newest delegations first.
+const (
+	ndjsonExport = `{"timestamp":"2024-05-01T00:05:00Z","amount":"150000000000","delegator":"tz1b","level":"300","operation_hash":"op3","block_hash":"b3","baker":"tz1baker","prev_baker":""}
+{"timestamp":"2024-05-01T00:03:20Z","amount":"2500","delegator":"tz1a","level":"200","operation_hash":"op2","block_hash":"b2","baker":null,"prev_baker":"tz1baker"}
+{"timestamp":"2024-05-01T00:01:40Z","amount":"0","delegator":"tz1a","level":"100","operation_hash":"op1","block_hash":"b1","baker":"tz1baker","prev_baker":""}
+`
+	csvExport = `timestamp,amount,delegator,level,operation_hash,block_hash,baker,prev_baker
+2024-05-01T00:05:00Z,150000000000,tz1b,300,op3,b3,tz1baker,
+2024-05-01T00:03:20Z,2500,tz1a,200,op2,b2,,tz1baker
+2024-05-01T00:01:40Z,0,tz1a,100,op1,b1,tz1baker,
+`
+)
+
+func acceptAll(ctx context.Context, snapshot *Snapshot) error {
+	return nil
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func writeSnapshot(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "snapshot")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	return path
+}
+
+func TestVerifySnapshot_Formats(t *testing.T) {
+	archive := writeArchive(t, delegationAt(100, "op1"), delegationAt(200, "op2"), delegationAt(300, "op3"))
+
+	testCases := []struct {
+		name   string
+		data   []byte
+		format SnapshotFormat
+	}{
+		{"archive", archive, FormatArchive},
+		{"ndjson", []byte(ndjsonExport), FormatNDJSON},
+		{"gzipped ndjson", gzipped(t, []byte(ndjsonExport)), FormatNDJSON},
+		{"csv", []byte(csvExport), FormatCSV},
+		{"gzipped csv", gzipped(t, []byte(csvExport)), FormatCSV},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			snapshot, err := VerifySnapshot(bytes.NewReader(tc.data))
+			require.NoError(t, err)
+			assert.Equal(t, tc.format, snapshot.Format)
+			assert.Equal(t, int64(3), snapshot.Delegations)
+			assert.Equal(t, int64(100), snapshot.FirstLevel)
+			require.NotNil(t, snapshot.Checkpoint)
+			assert.Equal(t, int64(300), snapshot.Checkpoint.Level)
+			assert.Equal(t, "2024-05-01T00:05:00Z", snapshot.Checkpoint.Timestamp.UTC().Format("2006-01-02T15:04:05Z"))
+
+			sum := sha256.Sum256(tc.data)
+			assert.Equal(t, hex.EncodeToString(sum[:]), snapshot.SHA256)
+		})
+	}
+}
+
+func TestVerifySnapshot_Rejects(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"invalid level", []byte(`{"timestamp":"2024-05-01T00:00:00Z","amount":"1","delegator":"tz1a","level":"abc","operation_hash":"op1","block_hash":"b1","baker":"","prev_baker":""}` + "\n")},
+		{"missing operation hash", []byte(`{"timestamp":"2024-05-01T00:00:00Z","amount":"1","delegator":"tz1a","level":"1","block_hash":"b1","baker":"","prev_baker":""}` + "\n")},
+		{"ndjson without bakers", []byte(`{"timestamp":"2024-05-01T00:00:00Z","amount":"1","delegator":"tz1a","level":"1","operation_hash":"op1","block_hash":"b1"}` + "\n")},
+		{"ndjson without block", []byte(`{"timestamp":"2024-05-01T00:00:00Z","amount":"1","delegator":"tz1a","level":"1","operation_hash":"op1","baker":"","prev_baker":""}` + "\n")},
+		{"csv without bakers", []byte("timestamp,amount,delegator,level,operation_hash\n2024-05-01T00:00:00Z,1,tz1a,1,op1\n")},
+		{"cut line", []byte(ndjsonExport[:len(ndjsonExport)-30])},
+		{"truncated gzip", gzipped(t, []byte(ndjsonExport))[:40]},
+		{"csv without level", []byte("timestamp,amount,delegator,operation_hash,block_hash,baker,prev_baker\n2024-05-01T00:00:00Z,1,tz1a,op1,b1,,\n")},
+		{"csv with invalid amount", []byte("timestamp,amount,delegator,level,operation_hash,block_hash,baker,prev_baker\n2024-05-01T00:00:00Z,1.5,tz1a,1,op1,b1,,\n")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := VerifySnapshot(bytes.NewReader(tc.data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestManager_Seed(t *testing.T) {
+	path := writeSnapshot(t, []byte(ndjsonExport))
+	repo := &memoryRepository{}
+	manager := newTestManager(t, repo, t.TempDir(), 0)
+
+	var progress int64
+	result, err := manager.Seed(context.Background(), path, "", acceptAll, func(n int64) { progress = n })
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, int64(3), result.Restored)
+	assert.Equal(t, int64(3), progress)
+	assert.Len(t, repo.delegations, 3)
+	require.NotNil(t, repo.checkpoint)
+	assert.Equal(t, int64(300), repo.checkpoint.Level)
+
+	// Once the checkpoint is recorded, the snapshot is not read again.
+	require.NoError(t, os.Remove(path))
+	result, err = manager.Seed(context.Background(), path, "", acceptAll, nil)
+	require.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestManager_SeedFromURL(t *testing.T) {
+	archive := writeArchive(t, delegationAt(100, "op1"), delegationAt(200, "op2"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/snapshot.ndjson.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	repo := &memoryRepository{}
+	manager := newTestManager(t, repo, t.TempDir(), 0)
+
+	_, err := manager.Seed(context.Background(), server.URL+"/missing", "", acceptAll, nil)
+	assert.ErrorContains(t, err, "404")
+
+	sum := sha256.Sum256(archive)
+	result, err := manager.Seed(context.Background(), server.URL+"/snapshot.ndjson.gz", hex.EncodeToString(sum[:]), acceptAll, nil)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, int64(2), result.Restored)
+	assert.Equal(t, int64(200), repo.checkpoint.Level)
+}
+
+func TestManager_SeedChecksumMismatch(t *testing.T) {
+	path := writeSnapshot(t, []byte(csvExport))
+	repo := &memoryRepository{}
+	manager := newTestManager(t, repo, t.TempDir(), 0)
+
+	_, err := manager.Seed(context.Background(), path, "00"+hex.EncodeToString(make([]byte, 31)), acceptAll, nil)
+	assert.ErrorIs(t, err, ErrCorrupt)
+	assert.Empty(t, repo.delegations)
+	assert.Nil(t, repo.checkpoint)
+}
+
+func TestManager_SeedResumesPartialImport(t *testing.T) {
+	path := writeSnapshot(t, []byte(ndjsonExport))
+
+	// An earlier attempt stored the newest delegation, then failed.
+	repo := &memoryRepository{delegations: []domain.Delegation{delegationAt(300, "op3")}}
+	manager := newTestManager(t, repo, t.TempDir(), 0)
+
+	result, err := manager.Seed(context.Background(), path, "", acceptAll, nil)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, int64(2), result.Restored)
+	assert.Len(t, repo.delegations, 3)
+}
+
+func TestManager_SeedSkipsDeploymentsAhead(t *testing.T) {
+	path := writeSnapshot(t, []byte(ndjsonExport))
+	repo := &memoryRepository{delegations: []domain.Delegation{delegationAt(400, "op4")}}
+	manager := newTestManager(t, repo, t.TempDir(), 0)
+
+	result, err := manager.Seed(context.Background(), path, "", acceptAll, nil)
+	require.NoError(t, err)
+	assert.Nil(t, result)
+	assert.Len(t, repo.delegations, 1)
+}
+
+func TestManager_SeedRejectedByCheck(t *testing.T) {
+	path := writeSnapshot(t, []byte(ndjsonExport))
+	repo := &memoryRepository{}
+	manager := newTestManager(t, repo, t.TempDir(), 0)
+
+	var checked *Snapshot
+	_, err := manager.Seed(context.Background(), path, "", func(ctx context.Context, snapshot *Snapshot) error {
+		checked = snapshot
+		return errors.New("snapshot is filtered")
+	}, nil)
+	assert.ErrorContains(t, err, "snapshot is filtered")
+	require.NotNil(t, checked)
+	assert.Equal(t, int64(3), checked.Delegations)
+	assert.Empty(t, repo.delegations)
+	assert.Nil(t, repo.checkpoint)
+}
@@ -0,0 +1,294 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+)
+
+const (
+	namePrefix = "delegations-"
+	// Extension is the file extension of archives.
+	Extension  = ".ndjson.gz"
+	nameLayout = "20060102T150405Z"
+
+	// s3PartSize bounds the memory an upload of unknown size buffers.
+	s3PartSize = 16 << 20
+)
+
+// ErrNotFound is returned for archives that are not in the store.
+var ErrNotFound = errors.New("backup not found")
+
+// Object is an archive in a store.
+type Object struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Store keeps archives. Put stores an archive under name once r has been
+// read in full, and stores nothing when reading r fails.
+type Store interface {
+	Put(ctx context.Context, name string, r io.Reader) (int64, error)
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the archives, newest first.
+	List(ctx context.Context) ([]Object, error)
+	Delete(ctx context.Context, name string) error
+	// Location describes the store in logs.
+	Location() string
+}
+
+// archiveName names the archive created at t. Names sort by creation time.
+func archiveName(t time.Time) string {
+	return namePrefix + t.UTC().Format(nameLayout) + Extension
+}
+
+func isArchiveName(name string) bool {
+	return strings.HasPrefix(name, namePrefix) && strings.HasSuffix(name, Extension) && path.Base(name) == name
+}
+
+// newestFirst sorts objects by name, which is by creation time.
+func newestFirst(objects []Object) []Object {
+	slices.SortFunc(objects, func(a, b Object) int { return strings.Compare(b.Name, a.Name) })
+	return objects
+}
+
+// NewStore returns the store cfg.Target designates: an S3 bucket for an
+// s3://bucket/prefix URL, a local directory otherwise.
+func NewStore(cfg *config.Backup) (Store, error) {
+	if strings.HasPrefix(cfg.Target, "s3://") {
+		return newS3Store(cfg.Target, &cfg.S3)
+	}
+	return &localStore{dir: cfg.Target}, nil
+}
+
+// localStore keeps archives in a directory. Archives are written to a
+// temporary file, renamed once complete.
+type localStore struct {
+	dir string
+}
+
+func (s *localStore) path(name string) (string, error) {
+	if !isArchiveName(name) {
+		return "", fmt.Errorf("%w: invalid archive name %q", ErrNotFound, name)
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+func (s *localStore) Put(ctx context.Context, name string, r io.Reader) (int64, error) {
+	target, err := s.path(name)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-"+name+"-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return 0, fmt.Errorf("failed to store backup file: %w", err)
+	}
+	return size, nil
+}
+
+func (s *localStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	target, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+	return f, nil
+}
+
+func (s *localStore) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var objects []Object
+	for _, entry := range entries {
+		if entry.IsDir() || !isArchiveName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, Object{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return newestFirst(objects), nil
+}
+
+func (s *localStore) Delete(ctx context.Context, name string) error {
+	target, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete backup file: %w", err)
+	}
+	return nil
+}
+
+func (s *localStore) Location() string {
+	return s.dir
+}
+
+// s3Store keeps archives in an S3-compatible bucket, under a prefix.
+type s3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// parseS3Target splits s3://bucket/prefix into the bucket and the key
+// prefix, which ends with a slash unless empty.
+func parseS3Target(target string) (bucket, prefix string, err error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid S3 target %q, expected s3://bucket/prefix", target)
+	}
+	prefix = strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return u.Host, prefix, nil
+}
+
+func newS3Store(target string, cfg *config.S3) (*s3Store, error) {
+	bucket, prefix, err := parseS3Target(target)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+	})
+	if cfg.AccessKeyID != "" {
+		creds = credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return &s3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *s3Store) key(name string) (string, error) {
+	if !isArchiveName(name) {
+		return "", fmt.Errorf("%w: invalid archive name %q", ErrNotFound, name)
+	}
+	return s.prefix + name, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, name string, r io.Reader) (int64, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return 0, err
+	}
+	info, err := s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType: "application/gzip",
+		PartSize:    s3PartSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload backup: %w", err)
+	}
+	return info.Size, nil
+}
+
+func (s *s3Store) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err == nil {
+		// GetObject is lazy: Stat reports a missing object.
+		_, err = object.Stat()
+	}
+	if err != nil {
+		if object != nil {
+			object.Close()
+		}
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	return object, nil
+}
+
+func (s *s3Store) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list backups: %w", info.Err)
+		}
+		name := strings.TrimPrefix(info.Key, s.prefix)
+		if isArchiveName(name) {
+			objects = append(objects, Object{Name: name, Size: info.Size, ModTime: info.LastModified})
+		}
+	}
+	return newestFirst(objects), nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, name string) error {
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete backup: %w", err)
+	}
+	return nil
+}
+
+func (s *s3Store) Location() string {
+	return "s3://" + s.bucket + "/" + s.prefix
+}
@@ -0,0 +1,32 @@
+package eventbus
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+)
+
+const (
+	PublisherNATS   = "nats"
+	PublisherStdout = "stdout"
+	PublisherFile   = "file"
+)
+
+// New returns the publisher selected by cfg.Publisher.
+func New(cfg *config.Outbox) (domain.Publisher, error) {
+	switch cfg.Publisher {
+	case PublisherNATS:
+		return NewNATSPublisher(cfg.NATSURL, cfg.NATSSubjectPrefix, cfg.NATSJetStream)
+	case PublisherStdout:
+		return NewWriterPublisher(os.Stdout), nil
+	case PublisherFile:
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("OUTBOX_FILE_PATH is required by the file publisher")
+		}
+		return NewFilePublisher(cfg.FilePath)
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", cfg.Publisher)
+	}
+}
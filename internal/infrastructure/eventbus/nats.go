@@ -0,0 +1,79 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// Header carrying the outbox event key, the operation hash for delegations.
+const EventKeyHeader = "Event-Key"
+
+// NATSPublisher publishes each event on <prefix><topic>. With JetStream,
+// every publish waits for the stream's acknowledgement and carries the event
+// ID as Nats-Msg-Id, so redeliveries within the stream's duplicate window are
+// dropped by the server. Core NATS only guarantees the server received the
+// batch.
+type NATSPublisher struct {
+	conn          *nats.Conn
+	js            jetstream.JetStream
+	subjectPrefix string
+}
+
+func NewNATSPublisher(url, subjectPrefix string, useJetStream bool) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("tezos-delegation-service"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	p := &NATSPublisher{
+		conn:          conn,
+		subjectPrefix: subjectPrefix,
+	}
+
+	if useJetStream {
+		p.js, err = jetstream.New(conn)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to initialize JetStream: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, events []domain.OutboxEvent) error {
+	for _, event := range events {
+		msg := nats.NewMsg(p.subjectPrefix + event.Topic)
+		msg.Data = event.Payload
+		msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(event.ID, 10))
+		msg.Header.Set(EventKeyHeader, event.Key)
+
+		if p.js != nil {
+			if _, err := p.js.PublishMsg(ctx, msg); err != nil {
+				return fmt.Errorf("failed to publish event %d: %w", event.ID, err)
+			}
+			continue
+		}
+
+		if err := p.conn.PublishMsg(msg); err != nil {
+			return fmt.Errorf("failed to publish event %d: %w", event.ID, err)
+		}
+	}
+
+	if p.js == nil {
+		if err := p.conn.FlushWithContext(ctx); err != nil {
+			return fmt.Errorf("failed to flush NATS connection: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,78 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSPublisher_CoreNATS(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	server := natsserver.RunServer(&opts)
+	defer server.Shutdown()
+
+	conn, err := nats.Connect(server.ClientURL())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sub, err := conn.SubscribeSync("tezos.>")
+	require.NoError(t, err)
+	require.NoError(t, conn.Flush())
+
+	publisher, err := NewNATSPublisher(server.ClientURL(), "tezos.", false)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, publisher.Publish(ctx, testEvents()))
+
+	msg, err := sub.NextMsg(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "tezos.delegation.created", msg.Subject)
+	assert.Equal(t, "1", msg.Header.Get(nats.MsgIdHdr))
+	assert.Equal(t, "op1", msg.Header.Get(EventKeyHeader))
+	assert.JSONEq(t, `{"delegator":"tz1one"}`, string(msg.Data))
+}
+
+func TestNATSPublisher_JetStreamDeduplicatesRedelivery(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := natsserver.RunServer(&opts)
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := nats.Connect(server.ClientURL())
+	require.NoError(t, err)
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	require.NoError(t, err)
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     "TEZOS",
+		Subjects: []string{"tezos.>"},
+	})
+	require.NoError(t, err)
+
+	publisher, err := NewNATSPublisher(server.ClientURL(), "tezos.", true)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// A batch published again after a failed commit is dropped by the server.
+	require.NoError(t, publisher.Publish(ctx, testEvents()))
+	require.NoError(t, publisher.Publish(ctx, testEvents()))
+
+	info, err := stream.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), info.State.Msgs)
+}
@@ -0,0 +1,75 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// WriterPublisher writes events as newline-delimited JSON. It backs the
+// stdout and file publishers, meant for development and tests.
+type WriterPublisher struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	sync   func() error
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// NewFilePublisher appends events to the file at path, syncing it to disk
+// before acknowledging each batch.
+func NewFilePublisher(path string) (*WriterPublisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event file: %w", err)
+	}
+
+	return &WriterPublisher{
+		w:      file,
+		closer: file,
+		sync:   file.Sync,
+	}, nil
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, events []domain.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buf := bufio.NewWriter(p.w)
+	enc := json.NewEncoder(buf)
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := enc.Encode(event); err != nil {
+			return fmt.Errorf("failed to write event %d: %w", event.ID, err)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	if p.sync != nil {
+		if err := p.sync(); err != nil {
+			return fmt.Errorf("failed to sync events: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *WriterPublisher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
@@ -0,0 +1,61 @@
+package eventbus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvents() []domain.OutboxEvent {
+	created := time.Date(2024, 5, 5, 6, 29, 14, 0, time.UTC)
+	return []domain.OutboxEvent{
+		{ID: 1, Topic: domain.TopicDelegationCreated, Key: "op1", Payload: json.RawMessage(`{"delegator":"tz1one"}`), CreatedAt: created},
+		{ID: 2, Topic: domain.TopicDelegationCreated, Key: "op2", Payload: json.RawMessage(`{"delegator":"tz1two"}`), CreatedAt: created},
+	}
+}
+
+func TestWriterPublisher_Publish(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf)
+
+	require.NoError(t, publisher.Publish(context.Background(), testEvents()))
+	require.NoError(t, publisher.Close())
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"id":1,"topic":"delegation.created","key":"op1","data":{"delegator":"tz1one"},"created_at":"2024-05-05T06:29:14Z"}`, string(lines[0]))
+}
+
+func TestFilePublisher_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	events := testEvents()
+
+	for _, event := range events {
+		publisher, err := NewFilePublisher(path)
+		require.NoError(t, err)
+		require.NoError(t, publisher.Publish(context.Background(), []domain.OutboxEvent{event}))
+		require.NoError(t, publisher.Close())
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var ids []int64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event domain.OutboxEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		ids = append(ids, event.ID)
+	}
+	assert.Equal(t, []int64{1, 2}, ids)
+}
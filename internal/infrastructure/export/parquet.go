@@ -0,0 +1,233 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// Partitioning selects the directory layout of a Parquet export.
+type Partitioning string
+
+const (
+	PartitionByYear  Partitioning = "year"
+	PartitionByMonth Partitioning = "month"
+)
+
+func (p Partitioning) Valid() bool {
+	switch p {
+	case PartitionByYear, PartitionByMonth:
+		return true
+	}
+	return false
+}
+
+const rowBuffer = 1024
+
+// Row is the Parquet schema of an exported delegation.
+type Row struct {
+	Timestamp     time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Amount        int64     `parquet:"amount"`
+	Delegator     string    `parquet:"delegator,dict"`
+	Baker         string    `parquet:"baker,dict"`
+	PrevBaker     string    `parquet:"prev_baker,dict"`
+	Level         int64     `parquet:"level"`
+	Cycle         *int64    `parquet:"cycle,optional"`
+	OperationHash string    `parquet:"operation_hash"`
+}
+
+func toRow(d domain.Delegation) (Row, error) {
+	amount, err := strconv.ParseInt(d.Amount, 10, 64)
+	if err != nil {
+		return Row{}, fmt.Errorf("invalid amount %q of %s: %w", d.Amount, d.OperationHash, err)
+	}
+	level, err := strconv.ParseInt(d.Level, 10, 64)
+	if err != nil {
+		return Row{}, fmt.Errorf("invalid level %q of %s: %w", d.Level, d.OperationHash, err)
+	}
+
+	return Row{
+		Timestamp:     d.Timestamp.UTC(),
+		Amount:        amount,
+		Delegator:     d.Delegator,
+		Baker:         d.Baker,
+		PrevBaker:     d.PrevBaker,
+		Level:         level,
+		Cycle:         d.Cycle,
+		OperationHash: d.OperationHash,
+	}, nil
+}
+
+// ParquetWriter writes delegations to Hive-style partition directories such
+// as year=2024/month=05 under a root directory. Delegations arrive in time
+// order, so one partition file is open at a time. Partitions touched by an
+// export are replaced: their existing Parquet files are removed first.
+type ParquetWriter struct {
+	dir          string
+	partitioning Partitioning
+
+	partition string
+	file      *os.File
+	writer    *parquet.GenericWriter[Row]
+	buffer    []Row
+
+	parts map[string]int
+	files []string
+	rows  int64
+}
+
+func NewParquetWriter(dir string, partitioning Partitioning) (*ParquetWriter, error) {
+	if !partitioning.Valid() {
+		return nil, fmt.Errorf("invalid partitioning %q: must be year or month", partitioning)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	return &ParquetWriter{
+		dir:          dir,
+		partitioning: partitioning,
+		buffer:       make([]Row, 0, rowBuffer),
+		parts:        make(map[string]int),
+	}, nil
+}
+
+func (w *ParquetWriter) partitionOf(t time.Time) string {
+	t = t.UTC()
+	if w.partitioning == PartitionByYear {
+		return fmt.Sprintf("year=%04d", t.Year())
+	}
+	return filepath.Join(fmt.Sprintf("year=%04d", t.Year()), fmt.Sprintf("month=%02d", int(t.Month())))
+}
+
+// Write adds one delegation to the file of its partition.
+func (w *ParquetWriter) Write(d domain.Delegation) error {
+	row, err := toRow(d)
+	if err != nil {
+		return err
+	}
+
+	if partition := w.partitionOf(row.Timestamp); partition != w.partition || w.writer == nil {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+		if err := w.openFile(partition); err != nil {
+			return err
+		}
+	}
+
+	w.buffer = append(w.buffer, row)
+	w.rows++
+	if len(w.buffer) == cap(w.buffer) {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *ParquetWriter) openFile(partition string) error {
+	dir := filepath.Join(w.dir, partition)
+
+	part, seen := w.parts[partition]
+	if !seen {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create partition directory: %w", err)
+		}
+		stale, err := filepath.Glob(filepath.Join(dir, "*.parquet"))
+		if err != nil {
+			return err
+		}
+		for _, path := range stale {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to replace partition %s: %w", partition, err)
+			}
+		}
+	}
+	w.parts[partition] = part + 1
+
+	path := filepath.Join(dir, fmt.Sprintf("part-%05d.parquet", part))
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	w.partition = partition
+	w.file = file
+	w.writer = parquet.NewGenericWriter[Row](file, parquet.Compression(&parquet.Zstd))
+	return nil
+}
+
+func (w *ParquetWriter) flush() error {
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	if _, err := w.writer.Write(w.buffer); err != nil {
+		return fmt.Errorf("failed to write Parquet rows: %w", err)
+	}
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+// closeFile completes the open file and moves it into place, so readers
+// never see a partial file.
+func (w *ParquetWriter) closeFile() error {
+	if w.writer == nil {
+		return nil
+	}
+
+	tmp := w.file.Name()
+	err := w.flush()
+	if err == nil {
+		err = w.writer.Close()
+	}
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.writer = nil
+	w.file = nil
+
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to complete export file: %w", err)
+	}
+
+	path := tmp[:len(tmp)-len(".tmp")]
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to complete export file: %w", err)
+	}
+	w.files = append(w.files, path)
+	return nil
+}
+
+// Close completes the last file. It must be called for the export to be
+// visible.
+func (w *ParquetWriter) Close() error {
+	return w.closeFile()
+}
+
+// Abort discards the file being written.
+func (w *ParquetWriter) Abort() {
+	if w.file != nil {
+		w.file.Close()
+		os.Remove(w.file.Name())
+		w.file = nil
+		w.writer = nil
+	}
+}
+
+// Files lists the completed files.
+func (w *ParquetWriter) Files() []string {
+	return w.files
+}
+
+// Rows returns the number of delegations written.
+func (w *ParquetWriter) Rows() int64 {
+	return w.rows
+}
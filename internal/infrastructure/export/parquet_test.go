@@ -0,0 +1,92 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func delegationAt(ts time.Time, hash string) domain.Delegation {
+	return domain.Delegation{
+		Timestamp:     ts,
+		Amount:        "150000000000",
+		Delegator:     "tz1delegator",
+		Baker:         "tz1baker",
+		Level:         "5000000",
+		OperationHash: hash,
+	}
+}
+
+func TestParquetWriter_PartitionsByMonth(t *testing.T) {
+	dir := t.TempDir()
+	cycle := int64(700)
+
+	// A stale file from an earlier export of the same partition is replaced.
+	stale := filepath.Join(dir, "year=2024", "month=05")
+	require.NoError(t, os.MkdirAll(stale, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(stale, "part-00003.parquet"), []byte("stale"), 0o644))
+
+	w, err := NewParquetWriter(dir, PartitionByMonth)
+	require.NoError(t, err)
+
+	withCycle := delegationAt(time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC), "op3")
+	withCycle.Cycle = &cycle
+	for _, d := range []domain.Delegation{
+		withCycle,
+		delegationAt(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "op2"),
+		delegationAt(time.Date(2024, 4, 30, 23, 59, 59, 0, time.UTC), "op1"),
+	} {
+		require.NoError(t, w.Write(d))
+	}
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, int64(3), w.Rows())
+	assert.Equal(t, []string{
+		filepath.Join(dir, "year=2024", "month=05", "part-00000.parquet"),
+		filepath.Join(dir, "year=2024", "month=04", "part-00000.parquet"),
+	}, w.Files())
+	assert.NoFileExists(t, filepath.Join(stale, "part-00003.parquet"))
+
+	rows, err := parquet.ReadFile[Row](w.Files()[0])
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "op3", rows[0].OperationHash)
+	assert.Equal(t, int64(150000000000), rows[0].Amount)
+	assert.Equal(t, int64(5000000), rows[0].Level)
+	require.NotNil(t, rows[0].Cycle)
+	assert.Equal(t, int64(700), *rows[0].Cycle)
+	assert.Nil(t, rows[1].Cycle)
+	assert.True(t, rows[0].Timestamp.Equal(withCycle.Timestamp))
+}
+
+func TestParquetWriter_PartitionsByYear(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewParquetWriter(dir, PartitionByYear)
+	require.NoError(t, err)
+
+	require.NoError(t, w.Write(delegationAt(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "op2")))
+	require.NoError(t, w.Write(delegationAt(time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), "op1")))
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "year=2024", "part-00000.parquet"),
+		filepath.Join(dir, "year=2023", "part-00000.parquet"),
+	}, w.Files())
+}
+
+func TestParquetWriter_InvalidInput(t *testing.T) {
+	_, err := NewParquetWriter(t.TempDir(), Partitioning("day"))
+	assert.Error(t, err)
+
+	w, err := NewParquetWriter(t.TempDir(), PartitionByYear)
+	require.NoError(t, err)
+	d := delegationAt(time.Now(), "op")
+	d.Amount = "not a number"
+	assert.Error(t, w.Write(d))
+}
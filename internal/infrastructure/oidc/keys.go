@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwk is a public JSON Web Key (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+var errUnsupportedKey = errors.New("unsupported key")
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// keySet holds verification keys by key ID. Keys without an ID, such as
+// PEM keys, are tried in turn for tokens they might have signed.
+type keySet struct {
+	byID    map[string]crypto.PublicKey
+	unnamed []crypto.PublicKey
+}
+
+func (s *keySet) add(kid string, key crypto.PublicKey) {
+	if kid == "" {
+		s.unnamed = append(s.unnamed, key)
+		return
+	}
+	s.byID[kid] = key
+}
+
+// lookup returns the key, or set of candidate keys, for a token header's
+// kid.
+func (s *keySet) lookup(kid string) (interface{}, bool) {
+	if key, ok := s.byID[kid]; ok && kid != "" {
+		return key, true
+	}
+
+	candidates := s.unnamed
+	if kid == "" {
+		for _, key := range s.byID {
+			candidates = append(candidates, key)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	set := jwt.VerificationKeySet{}
+	for _, key := range candidates {
+		set.Keys = append(set.Keys, key)
+	}
+	return set, true
+}
+
+// parseKeySet reads a JWK set, or PEM-encoded public keys and certificates.
+// Providers may publish keys of types or curves that are not supported, such
+// as encryption keys; those are skipped, but a supported key that is
+// malformed fails the whole set.
+func parseKeySet(data []byte) (*keySet, error) {
+	keys := &keySet{byID: make(map[string]crypto.PublicKey)}
+
+	if block, rest := pem.Decode(data); block != nil {
+		for ; block != nil; block, rest = pem.Decode(rest) {
+			key, err := parsePEMBlock(block)
+			if err != nil {
+				return nil, err
+			}
+			keys.add("", key)
+		}
+		return keys, nil
+	}
+
+	var set jwkSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWK set: %w", err)
+	}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if errors.Is(err, errUnsupportedKey) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", k.Kid, err)
+		}
+		keys.add(k.Kid, key)
+	}
+	if len(keys.byID)+len(keys.unnamed) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return keys, nil
+}
+
+func parsePEMBlock(block *pem.Block) (crypto.PublicKey, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("%w: type %q", errUnsupportedKey, k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
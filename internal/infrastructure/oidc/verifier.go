@@ -0,0 +1,238 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const (
+	// minRefetchInterval bounds how often an unknown key ID makes the JWK
+	// set be fetched again, so that forged tokens cannot hammer the
+	// provider.
+	minRefetchInterval = time.Minute
+	fetchTimeout       = 10 * time.Second
+	maxJWKSSize        = 1 << 20
+)
+
+// signingMethods are the algorithms accepted. HMAC is left out: the keys
+// are public.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+var errNoKeys = errors.New("no verification keys available")
+
+// Verifier authenticates JWTs signed by the keys of a JWK set fetched from
+// a URL, and refreshed periodically, or read from a file once.
+type Verifier struct {
+	cfg        config.JWT
+	roleScopes map[string][]domain.Scope
+	parser     *jwt.Parser
+	client     *http.Client
+	logger     *logger.Logger
+	now        func() time.Time
+
+	mu        sync.Mutex
+	keys      *keySet
+	fetchedAt time.Time
+}
+
+func New(cfg *config.JWT, logger *logger.Logger) (*Verifier, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	roleScopes := make(map[string][]domain.Scope, len(cfg.RoleScopes))
+	for role, scopes := range cfg.RoleScopes {
+		for _, scope := range scopes {
+			if !domain.Scope(scope).Valid() {
+				return nil, fmt.Errorf("role %q maps to unknown scope %q", role, scope)
+			}
+			roleScopes[role] = append(roleScopes[role], domain.Scope(scope))
+		}
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+	)
+
+	v := &Verifier{
+		cfg:        *cfg,
+		roleScopes: roleScopes,
+		parser:     parser,
+		client:     &http.Client{Timeout: fetchTimeout},
+		logger:     logger,
+		now:        time.Now,
+	}
+
+	switch {
+	case cfg.KeyFile != "":
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT key file: %w", err)
+		}
+		if v.keys, err = parseKeySet(data); err != nil {
+			return nil, fmt.Errorf("failed to parse JWT key file: %w", err)
+		}
+	case cfg.JWKSURL != "":
+		// The provider may be down at startup; keys are fetched again
+		// with the first token.
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		defer cancel()
+		v.fetchedAt = v.now()
+		if keys, err := v.fetch(ctx); err != nil {
+			logger.Warnw("Failed to fetch JWK set", "error", err, "url", cfg.JWKSURL)
+		} else {
+			v.keys = keys
+		}
+	default:
+		return nil, errors.New("either a JWKS URL or a key file is required")
+	}
+
+	return v, nil
+}
+
+// AuthenticateToken verifies a JWT and maps the roles it carries to scopes.
+// Tokens that are invalid, expired, or meant for another issuer or audience
+// are rejected with domain.ErrInvalidCredentials.
+func (v *Verifier) AuthenticateToken(ctx context.Context, token string) (*domain.Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if errors.Is(err, errNoKeys) {
+		return nil, domain.NewUpstreamUnavailableError("identity provider unavailable", err)
+	}
+	if err != nil {
+		v.logger.Debugw("Rejected bearer token", "error", err)
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	var scopes []domain.Scope
+	for _, role := range roles(claims, v.cfg.RolesClaim) {
+		scopes = append(scopes, v.roleScopes[role]...)
+	}
+
+	return &domain.Principal{Subject: "jwt:" + subject, Scopes: scopes}, nil
+}
+
+// key returns the verification key for kid, fetching the JWK set again
+// when it is stale or does not know kid. The fetch runs without holding
+// v.mu: other tokens are verified with the current keys meanwhile.
+func (v *Verifier) key(ctx context.Context, kid string) (interface{}, error) {
+	if v.cfg.KeyFile == "" && v.refreshDue(kid) {
+		keys, err := v.fetch(ctx)
+		if err != nil {
+			// Keep verifying with the previous keys.
+			v.logger.Warnw("Failed to refresh JWK set", "error", err, "url", v.cfg.JWKSURL)
+		} else {
+			v.mu.Lock()
+			v.keys = keys
+			v.mu.Unlock()
+		}
+	}
+
+	v.mu.Lock()
+	keys := v.keys
+	v.mu.Unlock()
+
+	if keys == nil {
+		return nil, errNoKeys
+	}
+	key, ok := keys.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	return key, nil
+}
+
+// refreshDue reports whether the JWK set should be fetched for kid, and if
+// so records the attempt. Failed attempts count too, so an unreachable
+// provider is not retried for every request, and concurrent requests do not
+// fetch the set again.
+func (v *Verifier) refreshDue(kid string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	known := false
+	if v.keys != nil {
+		_, known = v.keys.lookup(kid)
+	}
+	age := v.now().Sub(v.fetchedAt)
+	if (!known || age >= v.cfg.JWKSRefresh) && age >= minRefetchInterval {
+		v.fetchedAt = v.now()
+		return true
+	}
+	return false
+}
+
+// fetch downloads and parses the JWK set.
+func (v *Verifier) fetch(ctx context.Context) (*keySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKeySet(data)
+}
+
+// roles reads the roles at a dot-separated claim path. The claim is either
+// a list of strings or, like the OAuth scope claim, a space-separated
+// string.
+func roles(claims jwt.MapClaims, path string) []string {
+	var value interface{} = map[string]interface{}(claims)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		roles := make([]string, 0, len(value))
+		for _, role := range value {
+			if role, ok := role.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://id.example.com/"
+	testAudience = "tezos-delegation-service"
+)
+
+type testKey struct {
+	kid    string
+	method jwt.SigningMethod
+	signer interface{}
+	jwk    jwk
+}
+
+func newRSAKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return testKey{kid: kid, method: jwt.SigningMethodRS256, signer: key, jwk: jwk{
+		Kty: "RSA", Kid: kid, Use: "sig",
+		N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}
+}
+
+func newECKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return testKey{kid: kid, method: jwt.SigningMethodES256, signer: key, jwk: jwk{
+		Kty: "EC", Kid: kid, Crv: "P-256",
+		X: base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y: base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}}
+}
+
+func (k testKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+	signed, err := token.SignedString(k.signer)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims(roles ...string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "alice",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": roles,
+	}
+}
+
+// jwksServer serves the JWK set of the keys currently in keys.
+func jwksServer(t *testing.T, keys *atomic.Pointer[[]testKey], fetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		var set jwkSet
+		for _, k := range *keys.Load() {
+			set.Keys = append(set.Keys, k.jwk)
+		}
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testConfig() config.JWT {
+	return config.JWT{
+		Issuer:      testIssuer,
+		Audience:    testAudience,
+		RolesClaim:  "roles",
+		RoleScopes:  map[string][]string{"admin": {"admin"}, "reader": {"read:delegations", "read:stats"}},
+		JWKSRefresh: time.Hour,
+		Leeway:      time.Second,
+	}
+}
+
+func TestVerifier_JWKS(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t, "rsa-1"), newECKey(t, "ec-1")
+	var keys atomic.Pointer[[]testKey]
+	keys.Store(&[]testKey{rsaKey, ecKey})
+	var fetches atomic.Int32
+	server := jwksServer(t, &keys, &fetches)
+
+	cfg := testConfig()
+	cfg.JWKSURL = server.URL
+	log, _ := logger.New("debug", "test")
+	verifier, err := New(&cfg, log)
+	require.NoError(t, err)
+
+	principal, err := verifier.AuthenticateToken(context.Background(), rsaKey.sign(t, validClaims("admin")))
+	require.NoError(t, err)
+	assert.Equal(t, "jwt:alice", principal.Subject)
+	assert.True(t, principal.HasScope(domain.ScopeAdmin))
+
+	principal, err = verifier.AuthenticateToken(context.Background(), ecKey.sign(t, validClaims("reader", "unknown")))
+	require.NoError(t, err)
+	assert.True(t, principal.HasScope(domain.ScopeReadStats))
+	assert.False(t, principal.HasScope(domain.ScopeAdmin))
+
+	expired := validClaims("admin")
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	otherIssuer := validClaims("admin")
+	otherIssuer["iss"] = "https://evil.example.com/"
+	otherAudience := validClaims("admin")
+	otherAudience["aud"] = "another-service"
+	noExpiry := validClaims("admin")
+	delete(noExpiry, "exp")
+	noSubject := validClaims("admin")
+	delete(noSubject, "sub")
+
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims("admin")).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	forged := newRSAKey(t, "rsa-1")
+
+	for name, token := range map[string]string{
+		"Expired":        rsaKey.sign(t, expired),
+		"Other issuer":   rsaKey.sign(t, otherIssuer),
+		"Other audience": rsaKey.sign(t, otherAudience),
+		"No expiry":      rsaKey.sign(t, noExpiry),
+		"No subject":     rsaKey.sign(t, noSubject),
+		"HMAC":           hmac,
+		"Forged":         forged.sign(t, validClaims("admin")),
+		"Garbage":        "not.a.jwt",
+	} {
+		_, err := verifier.AuthenticateToken(context.Background(), token)
+		assert.ErrorIs(t, err, domain.ErrInvalidCredentials, name)
+	}
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestVerifier_KeyRotation(t *testing.T) {
+	oldKey, newKey := newRSAKey(t, "old"), newRSAKey(t, "new")
+	var keys atomic.Pointer[[]testKey]
+	keys.Store(&[]testKey{oldKey})
+	var fetches atomic.Int32
+	server := jwksServer(t, &keys, &fetches)
+
+	cfg := testConfig()
+	cfg.JWKSURL = server.URL
+	log, _ := logger.New("debug", "test")
+	verifier, err := New(&cfg, log)
+	require.NoError(t, err)
+
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	keys.Store(&[]testKey{oldKey, newKey})
+
+	// Unknown key IDs do not refetch the set more than once a minute.
+	_, err = verifier.AuthenticateToken(context.Background(), newKey.sign(t, validClaims("admin")))
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	now = now.Add(minRefetchInterval)
+	_, err = verifier.AuthenticateToken(context.Background(), newKey.sign(t, validClaims("admin")))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestVerifier_RefreshDoesNotBlock(t *testing.T) {
+	known, unknown := newRSAKey(t, "known"), newRSAKey(t, "unknown")
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		_ = json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{known.jwk}})
+	}))
+	t.Cleanup(server.Close)
+	defer close(release)
+
+	cfg := testConfig()
+	cfg.JWKSURL = server.URL
+	log, _ := logger.New("debug", "test")
+	verifier, err := New(&cfg, log)
+	require.NoError(t, err)
+	now := time.Now().Add(minRefetchInterval)
+	verifier.now = func() time.Time { return now }
+
+	// An unknown key ID refetches the set, which hangs.
+	go verifier.AuthenticateToken(context.Background(), unknown.sign(t, validClaims("admin")))
+	require.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := verifier.AuthenticateToken(context.Background(), known.sign(t, validClaims("admin")))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("verification waited for the JWK set refresh")
+	}
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestParseKeySet_SkipsUnsupportedKeys(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa-1")
+	data, err := json.Marshal(jwkSet{Keys: []jwk{
+		{Kty: "oct", Kid: "hmac"},
+		{Kty: "EC", Kid: "k1", Crv: "secp256k1", X: "AA", Y: "AA"},
+		{Kty: "OKP", Kid: "x25519", Crv: "X25519", X: "AA"},
+		rsaKey.jwk,
+	}})
+	require.NoError(t, err)
+
+	keys, err := parseKeySet(data)
+	require.NoError(t, err)
+	_, ok := keys.lookup("rsa-1")
+	assert.True(t, ok)
+	_, ok = keys.lookup("k1")
+	assert.False(t, ok)
+
+	// A supported key that is malformed still fails the set.
+	data, err = json.Marshal(jwkSet{Keys: []jwk{{Kty: "RSA", Kid: "broken", N: "!", E: "AQAB"}, rsaKey.jwk}})
+	require.NoError(t, err)
+	_, err = parseKeySet(data)
+	assert.Error(t, err)
+
+	data, err = json.Marshal(jwkSet{Keys: []jwk{{Kty: "oct", Kid: "hmac"}}})
+	require.NoError(t, err)
+	_, err = parseKeySet(data)
+	assert.Error(t, err)
+}
+
+func TestVerifier_ProviderUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := testConfig()
+	cfg.JWKSURL = server.URL
+	log, _ := logger.New("debug", "test")
+	verifier, err := New(&cfg, log)
+	require.NoError(t, err)
+
+	_, err = verifier.AuthenticateToken(context.Background(), newRSAKey(t, "rsa-1").sign(t, validClaims("admin")))
+	assert.True(t, domain.IsKind(err, domain.KindUpstreamUnavailable))
+}
+
+func TestVerifier_PEMKeyFile(t *testing.T) {
+	key := newECKey(t, "")
+	der, err := x509.MarshalPKIXPublicKey(&key.signer.(*ecdsa.PrivateKey).PublicKey)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	cfg := testConfig()
+	cfg.KeyFile = path
+	cfg.RolesClaim = "realm_access.roles"
+	log, _ := logger.New("debug", "test")
+	verifier, err := New(&cfg, log)
+	require.NoError(t, err)
+
+	claims := validClaims()
+	claims["realm_access"] = map[string]interface{}{"roles": []string{"admin"}}
+	principal, err := verifier.AuthenticateToken(context.Background(), key.sign(t, claims))
+	require.NoError(t, err)
+	assert.True(t, principal.HasScope(domain.ScopeAdmin))
+
+	_, err = verifier.AuthenticateToken(context.Background(), newECKey(t, "").sign(t, claims))
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+
+	for _, cfg := range []config.JWT{
+		{JWKSURL: "http://127.0.0.1:0", Audience: testAudience},
+		{JWKSURL: "http://127.0.0.1:0", Issuer: testIssuer},
+	} {
+		_, err := New(&cfg, log)
+		assert.Error(t, err)
+	}
+
+	cfg := testConfig()
+	_, err := New(&cfg, log)
+	assert.Error(t, err)
+
+	cfg.KeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = New(&cfg, log)
+	assert.Error(t, err)
+
+	cfg = testConfig()
+	cfg.JWKSURL = "http://127.0.0.1:0"
+	cfg.RoleScopes = map[string][]string{"admin": {"write:everything"}}
+	_, err = New(&cfg, log)
+	assert.Error(t, err)
+}
+
+func TestRoles(t *testing.T) {
+	claims := jwt.MapClaims{
+		"scope": "read:delegations admin",
+		"roles": []interface{}{"reader", 42},
+		"realm": map[string]interface{}{"roles": []interface{}{"admin"}},
+	}
+
+	assert.Equal(t, []string{"read:delegations", "admin"}, roles(claims, "scope"))
+	assert.Equal(t, []string{"reader"}, roles(claims, "roles"))
+	assert.Equal(t, []string{"admin"}, roles(claims, "realm.roles"))
+	assert.Empty(t, roles(claims, "realm.missing"))
+	assert.Empty(t, roles(claims, "scope.nested"))
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+func (r *Repository) GetTimeSeries(ctx context.Context, query domain.TimeSeriesQuery) ([]domain.TimeSeriesBucket, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	args := []interface{}{string(query.Interval)}
+	bakerFilter := ""
+	if query.Baker != "" {
+		args = append(args, query.Baker)
+		bakerFilter = fmt.Sprintf("WHERE baker = $%d", len(args))
+	}
+
+	var conditions []string
+	if bakerFilter != "" {
+		conditions = append(conditions, fmt.Sprintf("d.baker = $%d", len(args)))
+	}
+	conditions, args = appendTimeRange(conditions, args, "d.timestamp", query.From, query.To)
+	where := whereClause(conditions)
+
+	// Buckets are computed in UTC so that day/week/month boundaries do not
+	// depend on the session time zone.
+	sqlQuery := fmt.Sprintf(`
+		WITH first_seen AS (
+			SELECT delegator, date_trunc($1::text, MIN(timestamp) AT TIME ZONE 'UTC') AS first_bucket
+			FROM delegations
+			%s
+			GROUP BY delegator
+		)
+		SELECT
+			date_trunc($1::text, d.timestamp AT TIME ZONE 'UTC') AS bucket,
+			COUNT(*),
+			COALESCE(SUM(CAST(d.amount AS NUMERIC)), 0)::TEXT,
+			COUNT(DISTINCT d.delegator),
+			COUNT(DISTINCT d.delegator) FILTER (
+				WHERE f.first_bucket = date_trunc($1::text, d.timestamp AT TIME ZONE 'UTC')
+			)
+		FROM delegations d
+		JOIN first_seen f ON f.delegator = d.delegator
+		%s
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`, bakerFilter, where)
+
+	rows, err := r.db.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query time series: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []domain.TimeSeriesBucket
+	for rows.Next() {
+		var b domain.TimeSeriesBucket
+		if err := rows.Scan(
+			&b.BucketStart,
+			&b.Count,
+			&b.TotalAmount,
+			&b.UniqueDelegators,
+			&b.NewDelegators,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan time series bucket: %w", err)
+		}
+		b.BucketStart = b.BucketStart.UTC()
+		b.ReturningDelegators = b.UniqueDelegators - b.NewDelegators
+		buckets = append(buckets, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return buckets, nil
+}
+
+func (r *Repository) GetTopDelegations(ctx context.Context, query domain.TopQuery) ([]domain.Movement, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	conditions, args := appendTimeRange(nil, nil, "timestamp", query.From, query.To)
+	args = append(args, query.Limit)
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT timestamp, amount, delegator, COALESCE(prev_baker, ''), COALESCE(baker, ''), level, COALESCE(operation_hash, '')
+		FROM delegations
+		%s
+		ORDER BY CAST(amount AS NUMERIC) DESC, timestamp DESC
+		LIMIT $%d
+	`, whereClause(conditions), len(args))
+
+	return r.queryMovements(ctx, sqlQuery, args...)
+}
+
+func (r *Repository) GetTopDelegators(ctx context.Context, query domain.TopQuery) ([]domain.TopDelegator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	conditions, args := appendTimeRange(nil, nil, "timestamp", query.From, query.To)
+	args = append(args, query.Limit)
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT delegator, SUM(CAST(amount AS NUMERIC))::TEXT AS total, COUNT(*)
+		FROM delegations
+		%s
+		GROUP BY delegator
+		ORDER BY SUM(CAST(amount AS NUMERIC)) DESC, delegator ASC
+		LIMIT $%d
+	`, whereClause(conditions), len(args))
+
+	rows, err := r.db.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top delegators: %w", err)
+	}
+	defer rows.Close()
+
+	var delegators []domain.TopDelegator
+	for rows.Next() {
+		var d domain.TopDelegator
+		if err := rows.Scan(&d.Delegator, &d.TotalAmount, &d.DelegationCount); err != nil {
+			return nil, fmt.Errorf("failed to scan top delegator: %w", err)
+		}
+		delegators = append(delegators, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return delegators, nil
+}
+
+func (r *Repository) GetLargeMovements(ctx context.Context, query domain.MovementQuery) ([]domain.Movement, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	args := []interface{}{query.MinAmount}
+	conditions := []string{"CAST(amount AS NUMERIC) >= $1"}
+	conditions, args = appendTimeRange(conditions, args, "timestamp", query.From, query.To)
+	args = append(args, query.Limit)
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT timestamp, amount, delegator, COALESCE(prev_baker, ''), COALESCE(baker, ''), level, COALESCE(operation_hash, '')
+		FROM delegations
+		%s
+		ORDER BY timestamp DESC
+		LIMIT $%d
+	`, whereClause(conditions), len(args))
+
+	return r.queryMovements(ctx, sqlQuery, args...)
+}
+
+func (r *Repository) queryMovements(ctx context.Context, query string, args ...interface{}) ([]domain.Movement, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query movements: %w", err)
+	}
+	defer rows.Close()
+
+	var movements []domain.Movement
+	for rows.Next() {
+		var m domain.Movement
+		if err := rows.Scan(
+			&m.Timestamp,
+			&m.Amount,
+			&m.Delegator,
+			&m.PrevBaker,
+			&m.Baker,
+			&m.Level,
+			&m.OperationHash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan movement: %w", err)
+		}
+		movements = append(movements, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return movements, nil
+}
+
+func appendTimeRange(conditions []string, args []interface{}, column string, from, to *time.Time) ([]string, []interface{}) {
+	if from != nil {
+		args = append(args, *from)
+		conditions = append(conditions, fmt.Sprintf("%s >= $%d", column, len(args)))
+	}
+	if to != nil {
+		args = append(args, *to)
+		conditions = append(conditions, fmt.Sprintf("%s < $%d", column, len(args)))
+	}
+	return conditions, args
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
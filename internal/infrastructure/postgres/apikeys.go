@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+const apiKeyColumns = `id, name, prefix, scopes, usage_count, last_used_at, created_at, rotated_at, revoked_at`
+
+func (r *Repository) CreateAPIKey(ctx context.Context, key *domain.APIKey, hash []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, key.Name, key.Prefix, hash, scopeStrings(key.Scopes)).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r *Repository) RotateAPIKey(ctx context.Context, id, prefix string, hash []byte) (*domain.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRow(ctx, `
+		UPDATE api_keys SET prefix = $2, key_hash = $3, rotated_at = NOW()
+		WHERE id::text = $1 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns, id, prefix, hash)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (r *Repository) RevokeAPIKey(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE id::text = $1 AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// FindAPIKey runs for every authenticated request, so it only reads: uses
+// are counted by the caller and written by RecordAPIKeyUsage.
+func (r *Repository) FindAPIKey(ctx context.Context, hash []byte) (*domain.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRow(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`, hash)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// RecordAPIKeyUsage updates all keys in a single statement.
+func (r *Repository) RecordAPIKeyUsage(ctx context.Context, usage map[string]domain.APIKeyUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	ids := make([]string, 0, len(usage))
+	counts := make([]int64, 0, len(usage))
+	lastUsed := make([]time.Time, 0, len(usage))
+	for id, u := range usage {
+		ids = append(ids, id)
+		counts = append(counts, u.Count)
+		lastUsed = append(lastUsed, u.LastUsedAt)
+	}
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE api_keys AS k
+		SET usage_count = k.usage_count + u.count,
+			last_used_at = GREATEST(k.last_used_at, u.last_used_at)
+		FROM unnest($1::text[], $2::bigint[], $3::timestamptz[]) AS u(id, count, last_used_at)
+		WHERE k.id::text = u.id
+	`, ids, counts, lastUsed)
+	if err != nil {
+		return fmt.Errorf("failed to record API key usage: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (*domain.APIKey, error) {
+	var (
+		key    domain.APIKey
+		scopes []string
+	)
+	if err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&key.UsageCount,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+		&key.RotatedAt,
+		&key.RevokedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+
+	key.Scopes = make([]domain.Scope, len(scopes))
+	for i, scope := range scopes {
+		key.Scopes[i] = domain.Scope(scope)
+	}
+	return &key, nil
+}
+
+func scopeStrings(scopes []domain.Scope) []string {
+	strs := make([]string, len(scopes))
+	for i, scope := range scopes {
+		strs[i] = string(scope)
+	}
+	return strs
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// restoreDelegationQuery inserts a delegation unless it is already stored,
+// so that restoring an archive twice adds nothing.
+const restoreDelegationQuery = `
+	INSERT INTO delegations (id, timestamp, amount, delegator, level, block_hash, operation_hash, created_at, baker, prev_baker, cycle)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''),
+		(SELECT cycle_index FROM cycles WHERE CAST($5 AS BIGINT) BETWEEN first_level AND last_level))
+	ON CONFLICT DO NOTHING
+`
+
+// ScanDelegations streams every delegation by level. Like
+// ExportDelegations, it has no timeout of its own.
+func (r *Repository) ScanDelegations(ctx context.Context, emit func(domain.Delegation) error) error {
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM delegations
+		ORDER BY CAST(level AS BIGINT), seq
+	`, delegationColumns))
+	if err != nil {
+		return fmt.Errorf("failed to query delegations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanDelegation(rows)
+		if err != nil {
+			return err
+		}
+		if err := emit(d); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) RestoreDelegations(ctx context.Context, delegations []domain.Delegation) (int64, error) {
+	if len(delegations) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		tx.Rollback(context.Background())
+	}()
+
+	if err := lockDelegationWrites(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	batch := &pgx.Batch{}
+	for _, d := range delegations {
+		if d.ID == "" {
+			d.ID = uuid.New().String()
+		}
+		if d.CreatedAt.IsZero() {
+			d.CreatedAt = time.Now()
+		}
+		batch.Queue(restoreDelegationQuery,
+			d.ID,
+			d.Timestamp,
+			d.Amount,
+			d.Delegator,
+			d.Level,
+			d.BlockHash,
+			d.OperationHash,
+			d.CreatedAt,
+			d.Baker,
+			d.PrevBaker,
+		)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	var restored int64
+	for i := 0; i < batch.Len(); i++ {
+		tag, err := br.Exec()
+		if err != nil {
+			br.Close()
+			return 0, fmt.Errorf("failed to restore delegation %s: %w", delegations[i].OperationHash, err)
+		}
+		restored += tag.RowsAffected()
+	}
+	if err := br.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close batch result: %w", err)
+	}
+
+	if err := r.applyStateChanges(ctx, tx, delegations); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return restored, nil
+}
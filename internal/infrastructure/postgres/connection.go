@@ -37,42 +37,191 @@ func NewConnection(cfg *config.Database, logger *logger.Logger) (*pgxpool.Pool,
 	return pool, nil
 }
 
+// migrations are applied in order on every start, so each must be
+// idempotent. New statements are only ever appended: the position of the last
+// one is the schema version.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS delegations (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+		amount TEXT NOT NULL,
+		delegator TEXT NOT NULL,
+		level TEXT NOT NULL,
+		block_hash TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		UNIQUE(delegator, level)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_timestamp ON delegations(timestamp DESC)`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_delegator ON delegations(delegator)`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_level ON delegations(level)`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_created_at ON delegations(created_at DESC)`,
+	`CREATE TABLE IF NOT EXISTS indexing_metadata (
+		id SERIAL PRIMARY KEY,
+		last_indexed_level BIGINT NOT NULL DEFAULT 0,
+		last_indexed_timestamp TIMESTAMP WITH TIME ZONE,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	)`,
+	`INSERT INTO indexing_metadata (id, last_indexed_level, last_indexed_timestamp)
+	VALUES (1, 0, NULL)
+	ON CONFLICT (id) DO NOTHING`,
+	`ALTER TABLE delegations ADD COLUMN IF NOT EXISTS baker TEXT`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_baker ON delegations(baker)`,
+	`ALTER TABLE delegations ADD COLUMN IF NOT EXISTS prev_baker TEXT`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_amount_numeric ON delegations((CAST(amount AS NUMERIC)) DESC)`,
+	`CREATE TABLE IF NOT EXISTS delegation_state (
+		delegator TEXT PRIMARY KEY,
+		baker TEXT,
+		since_level BIGINT NOT NULL,
+		since_timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+		last_amount TEXT NOT NULL,
+		operation_hash TEXT,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_delegation_state_baker ON delegation_state(baker)`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_delegator_level_numeric ON delegations(delegator, (CAST(level AS BIGINT)) DESC)`,
+	`CREATE TABLE IF NOT EXISTS cycles (
+		cycle_index BIGINT PRIMARY KEY,
+		first_level BIGINT NOT NULL,
+		last_level BIGINT NOT NULL,
+		start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+		end_time TIMESTAMP WITH TIME ZONE NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_cycles_levels ON cycles(first_level, last_level)`,
+	`ALTER TABLE delegations ADD COLUMN IF NOT EXISTS cycle BIGINT`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_cycle ON delegations(cycle)`,
+	`ALTER TABLE delegations ADD COLUMN IF NOT EXISTS seq BIGSERIAL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_delegations_seq ON delegations(seq)`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		bakers TEXT[] NOT NULL DEFAULT '{}',
+		delegators TEXT[] NOT NULL DEFAULT '{}',
+		min_amount BIGINT NOT NULL DEFAULT 0,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		start_seq BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		operation_hash TEXT NOT NULL,
+		payload JSONB NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		last_status_code INTEGER,
+		last_error TEXT,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		delivered_at TIMESTAMP WITH TIME ZONE,
+		UNIQUE(webhook_id, operation_hash)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending'`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id DESC)`,
+	`CREATE TABLE IF NOT EXISTS outbox_events (
+		id BIGSERIAL PRIMARY KEY,
+		topic TEXT NOT NULL,
+		event_key TEXT NOT NULL,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		published_at TIMESTAMP WITH TIME ZONE
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events(id) WHERE published_at IS NULL`,
+	`CREATE TABLE IF NOT EXISTS schema_version (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+		key TEXT PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		allowed BOOLEAN NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at)`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash BYTEA NOT NULL UNIQUE,
+		scopes TEXT[] NOT NULL,
+		usage_count BIGINT NOT NULL DEFAULT 0,
+		last_used_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		rotated_at TIMESTAMP WITH TIME ZONE,
+		revoked_at TIMESTAMP WITH TIME ZONE
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_level_numeric ON delegations((CAST(level AS BIGINT)))`,
+	`CREATE TABLE IF NOT EXISTS jobs (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		kind TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		trigger TEXT NOT NULL,
+		from_level BIGINT NOT NULL DEFAULT 0,
+		to_level BIGINT NOT NULL DEFAULT 0,
+		repair BOOLEAN NOT NULL DEFAULT FALSE,
+		progress JSONB NOT NULL DEFAULT '{}',
+		result JSONB,
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 1,
+		cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+		singleton BOOLEAN NOT NULL DEFAULT FALSE,
+		scheduled_for TIMESTAMP WITH TIME ZONE,
+		worker TEXT,
+		heartbeat_at TIMESTAMP WITH TIME ZONE,
+		run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		started_at TIMESTAMP WITH TIME ZONE,
+		finished_at TIMESTAMP WITH TIME ZONE
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(run_at) WHERE status = 'pending'`,
+	`CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at DESC)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_singleton ON jobs(kind) WHERE singleton AND status IN ('pending', 'running')`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_scheduled_for ON jobs(kind, scheduled_for)`,
+	`ALTER TABLE indexing_metadata ADD COLUMN IF NOT EXISTS polling_paused BOOLEAN NOT NULL DEFAULT FALSE`,
+	`UPDATE jobs SET status = 'pending', worker = NULL, heartbeat_at = NULL
+	WHERE status = 'running' AND id NOT IN (
+		SELECT DISTINCT ON (kind) id FROM jobs WHERE status = 'running' ORDER BY kind, started_at ASC
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_running ON jobs(kind) WHERE status = 'running'`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_level_seq ON delegations((CAST(level AS BIGINT)) DESC, seq DESC)`,
+	// The backfill of 006_create_delegation_state.sql, for databases whose
+	// delegation_state table was created empty by an earlier release.
+	`INSERT INTO delegation_state (delegator, baker, since_level, since_timestamp, last_amount, operation_hash)
+	SELECT DISTINCT ON (delegator)
+		delegator, baker, CAST(level AS BIGINT), timestamp, amount, operation_hash
+	FROM delegations
+	WHERE NOT EXISTS (SELECT 1 FROM delegation_state)
+	ORDER BY delegator, CAST(level AS BIGINT) DESC, timestamp DESC
+	ON CONFLICT (delegator) DO NOTHING`,
+	`ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS event_type TEXT NOT NULL DEFAULT 'delegation'`,
+}
+
+// SchemaVersion is the schema version the running code expects.
+var SchemaVersion = len(migrations)
+
 func RunMigrations(pool *pgxpool.Pool, logger *logger.Logger) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS delegations (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
-			amount TEXT NOT NULL,
-			delegator TEXT NOT NULL,
-			level TEXT NOT NULL,
-			block_hash TEXT NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			UNIQUE(delegator, level)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_delegations_timestamp ON delegations(timestamp DESC)`,
-		`CREATE INDEX IF NOT EXISTS idx_delegations_delegator ON delegations(delegator)`,
-		`CREATE INDEX IF NOT EXISTS idx_delegations_level ON delegations(level)`,
-		`CREATE INDEX IF NOT EXISTS idx_delegations_created_at ON delegations(created_at DESC)`,
-		`CREATE TABLE IF NOT EXISTS indexing_metadata (
-			id SERIAL PRIMARY KEY,
-			last_indexed_level BIGINT NOT NULL DEFAULT 0,
-			last_indexed_timestamp TIMESTAMP WITH TIME ZONE,
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-		)`,
-		`INSERT INTO indexing_metadata (id, last_indexed_level, last_indexed_timestamp)
-		VALUES (1, 0, NULL)
-		ON CONFLICT (id) DO NOTHING`,
-	}
-
 	for i, migration := range migrations {
 		if _, err := pool.Exec(ctx, migration); err != nil {
 			return fmt.Errorf("failed to run migration %d: %w", i+1, err)
 		}
 	}
 
-	logger.Info("Successfully ran database migrations")
+	// A replica running older code must not lower the recorded version.
+	if _, err := pool.Exec(ctx, `
+		INSERT INTO schema_version (id, version) VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET
+			version = GREATEST(schema_version.version, EXCLUDED.version),
+			applied_at = NOW()
+	`, SchemaVersion); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	logger.Infow("Successfully ran database migrations", "schemaVersion", SchemaVersion)
 	return nil
 }
@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+func (r *Repository) SaveCycles(ctx context.Context, cycles []domain.Cycle) error {
+	if len(cycles) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		tx.Rollback(context.Background())
+	}()
+
+	batch := &pgx.Batch{}
+	for _, c := range cycles {
+		batch.Queue(`
+			INSERT INTO cycles (cycle_index, first_level, last_level, start_time, end_time, updated_at)
+			VALUES ($1, $2, $3, $4, $5, NOW())
+			ON CONFLICT (cycle_index) DO UPDATE SET
+				first_level = EXCLUDED.first_level,
+				last_level = EXCLUDED.last_level,
+				start_time = EXCLUDED.start_time,
+				end_time = EXCLUDED.end_time,
+				updated_at = NOW()
+		`, c.Index, c.FirstLevel, c.LastLevel, c.StartTime, c.EndTime)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			br.Close()
+			return fmt.Errorf("failed to save cycle %d: %w", cycles[i].Index, err)
+		}
+	}
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("failed to close batch result: %w", err)
+	}
+
+	// Delegations stored before their cycle was known are tagged now.
+	tag, err := tx.Exec(ctx, `
+		UPDATE delegations d
+		SET cycle = c.cycle_index
+		FROM cycles c
+		WHERE d.cycle IS NULL
+		  AND CAST(d.level AS BIGINT) BETWEEN c.first_level AND c.last_level
+		  AND c.cycle_index BETWEEN $1 AND $2
+	`, cycles[0].Index, cycles[len(cycles)-1].Index)
+	if err != nil {
+		return fmt.Errorf("failed to tag delegations with cycles: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Infow("Saved cycles", "count", len(cycles), "taggedDelegations", tag.RowsAffected())
+	return nil
+}
+
+func (r *Repository) GetCurrentCycleIndex(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var index sql.NullInt64
+	err := r.db.QueryRow(ctx, `
+		SELECT MAX(cycle_index)
+		FROM cycles
+		WHERE start_time <= NOW()
+	`).Scan(&index)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current cycle: %w", err)
+	}
+
+	if !index.Valid {
+		return 0, nil
+	}
+
+	return index.Int64, nil
+}
+
+func (r *Repository) GetCycleStats(ctx context.Context, query domain.CycleStatsQuery) ([]domain.CycleStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var args []interface{}
+	join := "d.cycle = c.cycle_index"
+	if query.Baker != "" {
+		args = append(args, query.Baker)
+		join += fmt.Sprintf(" AND d.baker = $%d", len(args))
+	}
+
+	conditions := []string{"c.start_time <= NOW()"}
+	if query.FromCycle != nil {
+		args = append(args, *query.FromCycle)
+		conditions = append(conditions, fmt.Sprintf("c.cycle_index >= $%d", len(args)))
+	}
+	if query.ToCycle != nil {
+		args = append(args, *query.ToCycle)
+		conditions = append(conditions, fmt.Sprintf("c.cycle_index <= $%d", len(args)))
+	}
+	args = append(args, query.Limit)
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT
+			c.cycle_index, c.first_level, c.last_level, c.start_time, c.end_time,
+			COUNT(d.id),
+			COALESCE(SUM(CAST(d.amount AS NUMERIC)), 0)::TEXT,
+			COUNT(DISTINCT d.delegator)
+		FROM cycles c
+		LEFT JOIN delegations d ON %s
+		%s
+		GROUP BY c.cycle_index
+		ORDER BY c.cycle_index DESC
+		LIMIT $%d
+	`, join, whereClause(conditions), len(args))
+
+	rows, err := r.db.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cycle stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []domain.CycleStats
+	for rows.Next() {
+		var s domain.CycleStats
+		if err := rows.Scan(
+			&s.Index,
+			&s.FirstLevel,
+			&s.LastLevel,
+			&s.StartTime,
+			&s.EndTime,
+			&s.Count,
+			&s.TotalAmount,
+			&s.UniqueDelegators,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan cycle stats: %w", err)
+		}
+		stats = append(stats, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// storageError marks errors that mean the database cannot be reached as
+// domain storage-unavailable errors. Query errors are returned unchanged.
+func storageError(err error) error {
+	if err == nil || !isUnavailable(err) {
+		return err
+	}
+	return domain.NewStorageUnavailableError(err)
+}
+
+func isUnavailable(err error) bool {
+	if errors.Is(err, pgx.ErrNoRows) || domain.IsKind(err, domain.KindStorageUnavailable) {
+		return false
+	}
+
+	var (
+		connectErr *pgconn.ConnectError
+		netErr     net.Error
+		pgErr      *pgconn.PgError
+	)
+	switch {
+	case errors.As(err, &connectErr), errors.As(err, &netErr):
+		return true
+	case pgconn.Timeout(err), errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &pgErr):
+		// Class 08 is connection exceptions; the others are the server
+		// shutting down, starting up or refusing connections.
+		switch pgErr.Code {
+		case "57P01", "57P02", "57P03", "53300":
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+	return false
+}
+
+// pool classifies the errors of a pgxpool.Pool with storageError, so that
+// every repository method reports an unreachable database the same way.
+type pool struct {
+	*pgxpool.Pool
+}
+
+func (p pool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tag, err := p.Pool.Exec(ctx, sql, args...)
+	return tag, storageError(err)
+}
+
+func (p pool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := p.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return classifiedRows{rows}, nil
+}
+
+func (p pool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return classifiedRow{p.Pool.QueryRow(ctx, sql, args...)}
+}
+
+func (p pool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.Pool.Begin(ctx)
+	return tx, storageError(err)
+}
+
+type classifiedRows struct {
+	pgx.Rows
+}
+
+func (r classifiedRows) Err() error {
+	return storageError(r.Rows.Err())
+}
+
+type classifiedRow struct {
+	pgx.Row
+}
+
+func (r classifiedRow) Scan(dest ...any) error {
+	return storageError(r.Row.Scan(dest...))
+}
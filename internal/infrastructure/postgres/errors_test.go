@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		err         error
+		unavailable bool
+	}{
+		{"No rows", pgx.ErrNoRows, false},
+		{"Syntax error", &pgconn.PgError{Code: "42601"}, false},
+		{"Unique violation", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"}), false},
+		{"Connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"Admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"Too many connections", &pgconn.PgError{Code: "53300"}, true},
+		{"Deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), true},
+		{"Cancelled", context.Canceled, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := storageError(tc.err)
+			assert.True(t, errors.Is(err, tc.err))
+			assert.Equal(t, tc.unavailable, domain.IsKind(err, domain.KindStorageUnavailable))
+		})
+	}
+
+	assert.NoError(t, storageError(nil))
+}
@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+func (r *Repository) Ping(ctx context.Context) error {
+	return storageError(r.db.Ping(ctx))
+}
+
+// MigrationStatus reads the schema version recorded by RunMigrations. A
+// database that was never migrated by this service reports version 0.
+func (r *Repository) MigrationStatus(ctx context.Context) (domain.MigrationStatus, error) {
+	status := domain.MigrationStatus{Expected: SchemaVersion}
+
+	err := r.db.QueryRow(ctx, `SELECT version FROM schema_version WHERE id = 1`).Scan(&status.Applied)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return status, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return status, nil
+}
@@ -0,0 +1,344 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+const jobColumns = `id, kind, status, trigger, from_level, to_level, repair, progress, result, error,
+	attempts, max_attempts, cancel_requested, singleton, scheduled_for, COALESCE(worker, ''),
+	run_at, created_at, started_at, finished_at`
+
+// qualifiedJobColumns are the job columns of an UPDATE ... FROM, where the
+// names must not be ambiguous.
+const qualifiedJobColumns = `jobs.id, jobs.kind, jobs.status, jobs.trigger, jobs.from_level, jobs.to_level,
+	jobs.repair, jobs.progress, jobs.result, jobs.error, jobs.attempts, jobs.max_attempts,
+	jobs.cancel_requested, jobs.singleton, jobs.scheduled_for, COALESCE(jobs.worker, ''),
+	jobs.run_at, jobs.created_at, jobs.started_at, jobs.finished_at`
+
+func (r *Repository) CreateJob(ctx context.Context, job *domain.Job) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Any unique violation is a singleton already active or a schedule
+	// already enqueued.
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO jobs (id, kind, status, trigger, from_level, to_level, repair,
+			max_attempts, singleton, scheduled_for, run_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT DO NOTHING
+	`, job.ID, string(job.Kind), string(job.Status), string(job.Trigger), job.FromLevel, job.ToLevel, job.Repair,
+		job.MaxAttempts, job.Singleton, job.ScheduledFor, job.RunAt, job.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *Repository) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	job, err := scanJob(r.db.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id::text = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrJobNotFound
+	}
+	return job, err
+}
+
+func (r *Repository) ListJobs(ctx context.Context, query domain.JobQuery) ([]domain.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var (
+		args       []interface{}
+		conditions []string
+	)
+	if query.Kind != "" {
+		args = append(args, string(query.Kind))
+		conditions = append(conditions, fmt.Sprintf("kind = $%d", len(args)))
+	}
+	if len(query.Statuses) > 0 {
+		statuses := make([]string, len(query.Statuses))
+		for i, status := range query.Statuses {
+			statuses[i] = string(status)
+		}
+		args = append(args, statuses)
+		conditions = append(conditions, fmt.Sprintf("status = ANY($%d)", len(args)))
+	}
+	args = append(args, query.Limit)
+
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM jobs
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d
+	`, jobColumns, whereClause(conditions), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []domain.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// ClaimJob skips pending jobs whose kind has a job running. Two workers may
+// still pick jobs of the same kind at once, as neither sees the other's
+// uncommitted claim: idx_jobs_running then fails the second claim, which
+// finds no job this time.
+func (r *Repository) ClaimJob(ctx context.Context, worker string) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRow(ctx, `
+		WITH due AS (
+			SELECT id
+			FROM jobs j
+			WHERE status = 'pending' AND run_at <= NOW()
+				AND NOT EXISTS (SELECT 1 FROM jobs r WHERE r.kind = j.kind AND r.status = 'running')
+			ORDER BY run_at ASC, created_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE jobs SET status = 'running', attempts = jobs.attempts + 1, worker = $1,
+			started_at = NOW(), heartbeat_at = NOW(), finished_at = NULL
+		FROM due
+		WHERE jobs.id = due.id
+		RETURNING `+qualifiedJobColumns, worker)
+
+	job, err := scanJob(row)
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "23505") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return job, nil
+}
+
+func (r *Repository) SaveJobProgress(ctx context.Context, job *domain.Job) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	progress, result, err := marshalJobState(job)
+	if err != nil {
+		return false, err
+	}
+
+	var cancelRequested bool
+	err = r.db.QueryRow(ctx, `
+		UPDATE jobs SET progress = $3, result = $4, from_level = $5, to_level = $6, heartbeat_at = NOW()
+		WHERE id::text = $1 AND worker = $2 AND status = 'running'
+		RETURNING cancel_requested
+	`, job.ID, job.Worker, progress, result, job.FromLevel, job.ToLevel).Scan(&cancelRequested)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, domain.ErrJobLeaseLost
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save job progress: %w", err)
+	}
+
+	return cancelRequested, nil
+}
+
+func (r *Repository) FinishJob(ctx context.Context, job *domain.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	progress, result, err := marshalJobState(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		UPDATE jobs SET status = $3, error = $4, attempts = $5, progress = $6, result = $7,
+			from_level = $8, to_level = $9, run_at = $10, finished_at = $11,
+			worker = NULL, heartbeat_at = NULL
+		WHERE id::text = $1 AND worker = $2 AND status = 'running'
+	`, job.ID, job.Worker, string(job.Status), job.Error, job.Attempts, progress, result,
+		job.FromLevel, job.ToLevel, job.RunAt, job.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to finish job: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) CancelJob(ctx context.Context, id string) (*domain.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Pending jobs are cancelled at once; running ones when their worker
+	// next saves their progress.
+	row := r.db.QueryRow(ctx, `
+		UPDATE jobs SET cancel_requested = TRUE,
+			status = CASE WHEN status = 'pending' THEN 'cancelled' ELSE status END,
+			finished_at = CASE WHEN status = 'pending' THEN NOW() ELSE finished_at END
+		WHERE id::text = $1 AND status IN ('pending', 'running')
+		RETURNING `+jobColumns, id)
+
+	job, err := scanJob(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := r.GetJob(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrJobFinished
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+	return job, nil
+}
+
+func (r *Repository) RequeueStaleJobs(ctx context.Context, staleBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE jobs SET
+			status = CASE
+				WHEN cancel_requested THEN 'cancelled'
+				WHEN attempts < max_attempts THEN 'pending'
+				ELSE 'failed'
+			END,
+			error = CASE WHEN cancel_requested THEN error ELSE 'worker stopped responding' END,
+			finished_at = CASE WHEN attempts < max_attempts AND NOT cancel_requested THEN NULL ELSE NOW() END,
+			run_at = NOW(), worker = NULL, heartbeat_at = NULL
+		WHERE status = 'running' AND heartbeat_at < $1
+	`, staleBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue stale jobs: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r *Repository) DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM jobs WHERE status IN ('succeeded', 'failed', 'cancelled') AND finished_at < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished jobs: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func marshalJobState(job *domain.Job) (progress, result []byte, err error) {
+	if progress, err = json.Marshal(job.Progress); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode job progress: %w", err)
+	}
+	if job.Result != nil {
+		if result, err = json.Marshal(job.Result); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode job result: %w", err)
+		}
+	}
+	return progress, result, nil
+}
+
+func scanJob(row pgx.Row) (*domain.Job, error) {
+	var (
+		job                   domain.Job
+		kind, status, trigger string
+		progress, result      []byte
+	)
+	if err := row.Scan(
+		&job.ID,
+		&kind,
+		&status,
+		&trigger,
+		&job.FromLevel,
+		&job.ToLevel,
+		&job.Repair,
+		&progress,
+		&result,
+		&job.Error,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.CancelRequested,
+		&job.Singleton,
+		&job.ScheduledFor,
+		&job.Worker,
+		&job.RunAt,
+		&job.CreatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan job: %w", err)
+	}
+
+	job.Kind = domain.JobKind(kind)
+	job.Status = domain.JobStatus(status)
+	job.Trigger = domain.JobTrigger(trigger)
+	if err := json.Unmarshal(progress, &job.Progress); err != nil {
+		return nil, fmt.Errorf("failed to decode job progress: %w", err)
+	}
+	if result != nil {
+		job.Result = &domain.VerificationResult{}
+		if err := json.Unmarshal(result, job.Result); err != nil {
+			return nil, fmt.Errorf("failed to decode job result: %w", err)
+		}
+	}
+	return &job, nil
+}
+
+func (r *Repository) LevelsMissingBaker(ctx context.Context, afterLevel int64, limit int) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT CAST(level AS BIGINT) AS level_number
+		FROM delegations
+		WHERE baker IS NULL AND prev_baker IS NULL AND CAST(level AS BIGINT) > $1
+		ORDER BY level_number
+		LIMIT $2
+	`, afterLevel, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query levels missing bakers: %w", err)
+	}
+	defer rows.Close()
+
+	var levels []int64
+	for rows.Next() {
+		var level int64
+		if err := rows.Scan(&level); err != nil {
+			return nil, fmt.Errorf("failed to scan level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return levels, nil
+}
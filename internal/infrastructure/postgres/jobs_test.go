@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Workers claiming at once never run two jobs of the same kind.
+func TestRepository_ClaimJobOnePerKind(t *testing.T) {
+	repo, db := newTestRepository(t)
+	ctx := context.Background()
+
+	_, err := db.Exec(ctx, "DELETE FROM jobs")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		now := time.Now()
+		_, err := repo.CreateJob(ctx, &domain.Job{
+			ID: uuid.New().String(), Kind: domain.JobReindex, Status: domain.JobPending, Trigger: domain.TriggerManual,
+			FromLevel: 1, ToLevel: 10, MaxAttempts: 1, RunAt: now, CreatedAt: now,
+		})
+		require.NoError(t, err)
+	}
+
+	const workers = 8
+	claimed := make(chan *domain.Job, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job, err := repo.ClaimJob(ctx, "worker")
+			assert.NoError(t, err)
+			if job != nil {
+				claimed <- job
+			}
+		}()
+	}
+	wg.Wait()
+	close(claimed)
+
+	assert.Len(t, claimed, 1)
+	running, err := repo.ListJobs(ctx, domain.JobQuery{Kind: domain.JobReindex, Statuses: []domain.JobStatus{domain.JobRunning}})
+	require.NoError(t, err)
+	assert.Len(t, running, 1)
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// EnableOutbox makes SaveBatch record a delegation.created event for every
+// newly stored delegation. It is off by default so that the outbox does not
+// grow when nothing relays it.
+func (r *Repository) EnableOutbox() {
+	r.outboxEnabled = true
+}
+
+// writeOutboxEvents records events within tx, so that an event exists if
+// and only if its delegation was committed. Callers pass only delegations
+// the transaction inserted, which keeps re-indexing from emitting duplicates.
+func (r *Repository) writeOutboxEvents(ctx context.Context, tx pgx.Tx, delegations []domain.Delegation) error {
+	if !r.outboxEnabled || len(delegations) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(delegations))
+	payloads := make([]string, 0, len(delegations))
+	for _, d := range delegations {
+		payload, err := json.Marshal(d.Movement())
+		if err != nil {
+			return fmt.Errorf("failed to encode outbox event for %s: %w", d.OperationHash, err)
+		}
+		keys = append(keys, d.OperationHash)
+		payloads = append(payloads, string(payload))
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO outbox_events (topic, event_key, payload)
+		SELECT $1, e.event_key, e.payload::jsonb
+		FROM unnest($2::text[], $3::text[]) WITH ORDINALITY AS e(event_key, payload, position)
+		ORDER BY e.position
+	`, domain.TopicDelegationCreated, keys, payloads)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox events: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) RelayOutbox(ctx context.Context, limit int, publish func(ctx context.Context, events []domain.OutboxEvent) error) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		tx.Rollback(context.Background())
+	}()
+
+	// Locking without SKIP LOCKED serializes relays across replicas, which
+	// keeps events published in ID order.
+	rows, err := tx.Query(ctx, `
+		SELECT id, topic, event_key, payload, created_at
+		FROM outbox_events
+		WHERE published_at IS NULL
+		ORDER BY id ASC
+		LIMIT $1
+		FOR UPDATE
+	`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query outbox events: %w", err)
+	}
+
+	var events []domain.OutboxEvent
+	var ids []int64
+	for rows.Next() {
+		var e domain.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Key, &e.Payload, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, e)
+		ids = append(ids, e.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(ctx, events); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE outbox_events SET published_at = NOW() WHERE id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("failed to mark outbox events published: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(events), nil
+}
+
+func (r *Repository) PruneOutbox(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM outbox_events WHERE published_at < $1`, publishedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
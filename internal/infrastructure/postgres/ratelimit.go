@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const (
+	// rateLimitPruneInterval is how often buckets idle for longer than
+	// rateLimitIdleTTL are deleted. A deleted bucket comes back full, so
+	// buckets slower to refill than the TTL are reset early.
+	rateLimitPruneInterval = 5 * time.Minute
+	rateLimitIdleTTL       = time.Hour
+)
+
+// takeTokenQuery refills a bucket for the time elapsed since it was last
+// used and takes a token if one is available. Doing both in one statement
+// keeps concurrent replicas from taking the same token.
+const takeTokenQuery = `
+	INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+	VALUES ($1, $2::float8 - 1, TRUE, NOW())
+	ON CONFLICT (key) DO UPDATE SET
+		allowed = ` + refilledTokens + ` >= 1,
+		tokens = ` + refilledTokens + ` - CASE WHEN ` + refilledTokens + ` >= 1 THEN 1 ELSE 0 END,
+		updated_at = NOW()
+	RETURNING tokens, allowed
+`
+
+const refilledTokens = `LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM NOW() - b.updated_at)::float8, 0) * $3::float8)`
+
+// RateLimiter keeps token buckets in the rate_limit_buckets table, so that
+// all replicas share the same limits.
+type RateLimiter struct {
+	db        pool
+	logger    *logger.Logger
+	lastPrune atomic.Int64
+}
+
+func NewRateLimiter(db *pgxpool.Pool, logger *logger.Logger) *RateLimiter {
+	return &RateLimiter{db: pool{db}, logger: logger}
+}
+
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitDecision, error) {
+	l.prune(ctx)
+
+	var tokens float64
+	var allowed bool
+	err := l.db.QueryRow(ctx, takeTokenQuery, key, float64(limit.Burst), limit.PerSecond).Scan(&tokens, &allowed)
+	if err != nil {
+		return domain.RateLimitDecision{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+
+	return domain.NewRateLimitDecision(limit, tokens, allowed), nil
+}
+
+// prune deletes idle buckets at most once per rateLimitPruneInterval from
+// this replica.
+func (l *RateLimiter) prune(ctx context.Context) {
+	now := time.Now()
+	last := l.lastPrune.Load()
+	if now.Sub(time.Unix(0, last)) < rateLimitPruneInterval || !l.lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	tag, err := l.db.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, now.Add(-rateLimitIdleTTL))
+	if err != nil {
+		l.logger.Warnw("Failed to prune rate limit buckets", "error", err)
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		l.logger.Debugw("Pruned idle rate limit buckets", "count", tag.RowsAffected())
+	}
+}
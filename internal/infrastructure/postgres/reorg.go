@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+func (r *Repository) GetBlockHashes(ctx context.Context, fromLevel int64) (map[int64]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT CAST(level AS BIGINT), block_hash
+		FROM delegations
+		WHERE CAST(level AS BIGINT) >= $1
+	`, fromLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query block hashes: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[int64]string)
+	for rows.Next() {
+		var (
+			level int64
+			hash  string
+		)
+		if err := rows.Scan(&level, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan block hash: %w", err)
+		}
+		hashes[level] = hash
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return hashes, nil
+}
+
+// RollbackLevels deletes the delegations and rebuilds the state rows in one
+// transaction, so readers never see an account delegating through a
+// delegation that no longer exists. The rollback event is written in the
+// same transaction, so consumers hear of every committed rollback.
+func (r *Repository) RollbackLevels(ctx context.Context, fromLevel int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := lockDelegationWrites(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM delegations
+		WHERE CAST(level AS BIGINT) >= $1
+		RETURNING delegator
+	`, fromLevel)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete delegations: %w", err)
+	}
+
+	var removed int64
+	seen := make(map[string]bool)
+	var delegators []string
+	for rows.Next() {
+		var delegator string
+		if err := rows.Scan(&delegator); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan deleted delegation: %w", err)
+		}
+		removed++
+		if !seen[delegator] {
+			seen[delegator] = true
+			delegators = append(delegators, delegator)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to delete delegations: %w", err)
+	}
+
+	if len(delegators) > 0 {
+		if _, err := tx.Exec(ctx, `DELETE FROM delegation_state WHERE delegator = ANY($1)`, delegators); err != nil {
+			return 0, fmt.Errorf("failed to delete delegation state: %w", err)
+		}
+		if _, err := tx.Exec(ctx, rebuildDelegationStateQuery, delegators); err != nil {
+			return 0, fmt.Errorf("failed to rebuild delegation state: %w", err)
+		}
+	}
+
+	if removed > 0 {
+		rollback := domain.Rollback{FromLevel: fromLevel, Removed: removed}
+		if err := r.writeRollbackEvent(ctx, tx, rollback); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return removed, nil
+}
+
+// writeRollbackEvent records a delegations.rolled_back event within tx, in
+// the outbox when it is enabled and for every active webhook. Webhook filters
+// are not applied: the removed delegations may have been delivered to any of
+// them.
+func (r *Repository) writeRollbackEvent(ctx context.Context, tx pgx.Tx, rollback domain.Rollback) error {
+	payload, err := json.Marshal(rollback)
+	if err != nil {
+		return fmt.Errorf("failed to encode rollback event: %w", err)
+	}
+
+	// The same levels can be rolled back more than once, so the key also
+	// names the transaction.
+	var txid int64
+	if err := tx.QueryRow(ctx, `SELECT txid_current()`).Scan(&txid); err != nil {
+		return fmt.Errorf("failed to read transaction id: %w", err)
+	}
+	key := fmt.Sprintf("rollback:%d:%d", rollback.FromLevel, txid)
+
+	if r.outboxEnabled {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO outbox_events (topic, event_key, payload)
+			VALUES ($1, $2, $3::jsonb)
+		`, domain.TopicDelegationsRolledBack, key, string(payload))
+		if err != nil {
+			return fmt.Errorf("failed to write rollback event: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, operation_hash, event_type, payload)
+		SELECT id, $1, $2, $3::jsonb
+		FROM webhooks
+		WHERE active
+	`, key, domain.TopicDelegationsRolledBack, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to enqueue rollback deliveries: %w", err)
+	}
+	return nil
+}
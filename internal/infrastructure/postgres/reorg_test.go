@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Rolling back levels restores the state of their delegators from the
+// delegations that remain, or removes it when none remain.
+func TestRepository_RollbackLevels(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	lastLevel, err := repo.GetLastIndexedLevel(ctx)
+	require.NoError(t, err)
+
+	moved, created := "tz1"+uuid.New().String(), "tz1"+uuid.New().String()
+	delegation := func(delegator, baker string, level int64) domain.Delegation {
+		return domain.Delegation{
+			ID:            uuid.New().String(),
+			Timestamp:     time.Now(),
+			Amount:        "1000",
+			Delegator:     delegator,
+			Baker:         baker,
+			Level:         strconv.FormatInt(level, 10),
+			BlockHash:     "block",
+			OperationHash: uuid.New().String(),
+			CreatedAt:     time.Now(),
+		}
+	}
+	_, err = repo.SaveBatch(ctx, []domain.Delegation{
+		delegation(moved, "tz1first", lastLevel+1),
+		delegation(moved, "tz1second", lastLevel+2),
+		delegation(created, "tz1first", lastLevel+2),
+	})
+	require.NoError(t, err)
+
+	removed, err := repo.RollbackLevels(ctx, lastLevel+2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+
+	state, err := repo.GetDelegatorState(ctx, domain.DelegatorQuery{Delegator: moved})
+	require.NoError(t, err)
+	assert.Equal(t, "tz1first", state.Baker)
+	assert.Equal(t, lastLevel+1, state.SinceLevel)
+
+	_, err = repo.GetDelegatorState(ctx, domain.DelegatorQuery{Delegator: created})
+	assert.ErrorIs(t, err, domain.ErrDelegatorNotFound)
+
+	hashes, err := repo.GetBlockHashes(ctx, lastLevel+1)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]string{lastLevel + 1: "block"}, hashes)
+}
@@ -5,7 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strconv"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,20 +16,48 @@ import (
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
 )
 
+// Repository stores delegations in PostgreSQL. Queries run with the
+// caller's context, so they are cancelled with the request or job that
+// issued them, and are further bounded by a timeout of their own.
 type Repository struct {
-	db     *pgxpool.Pool
+	db     pool
 	logger *logger.Logger
+
+	outboxEnabled bool
 }
 
+// insertDelegationQuery upserts a delegation by operation hash. The cycle is
+// resolved from the cycles table when it is already known, and is otherwise
+// filled in by SaveCycles once the cycle has been synced. It returns the
+// stored id, created_at, seq and cycle, which differ from the values passed
+// in when the row already existed, and whether the row was inserted rather
+// than updated: xmax is only zero for a row version that no transaction has
+// locked or updated.
+const insertDelegationQuery = `
+	INSERT INTO delegations (id, timestamp, amount, delegator, level, block_hash, operation_hash, created_at, baker, prev_baker, cycle)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''),
+		(SELECT cycle_index FROM cycles WHERE CAST($5 AS BIGINT) BETWEEN first_level AND last_level))
+	ON CONFLICT (operation_hash) DO UPDATE SET
+		timestamp = EXCLUDED.timestamp,
+		amount = EXCLUDED.amount,
+		block_hash = EXCLUDED.block_hash,
+		delegator = EXCLUDED.delegator,
+		level = EXCLUDED.level,
+		baker = COALESCE(EXCLUDED.baker, delegations.baker),
+		prev_baker = COALESCE(EXCLUDED.prev_baker, delegations.prev_baker),
+		cycle = COALESCE(EXCLUDED.cycle, delegations.cycle)
+	RETURNING id, created_at, seq, cycle, (xmax = 0)
+`
+
 func NewRepository(db *pgxpool.Pool, logger *logger.Logger) *Repository {
 	return &Repository{
-		db:     db,
+		db:     pool{db},
 		logger: logger,
 	}
 }
 
-func (r *Repository) Save(delegation *domain.Delegation) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *Repository) Save(ctx context.Context, delegation *domain.Delegation) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	if delegation.ID == "" {
@@ -39,65 +67,87 @@ func (r *Repository) Save(delegation *domain.Delegation) error {
 		delegation.CreatedAt = time.Now()
 	}
 
-	query := `
-		INSERT INTO delegations (id, timestamp, amount, delegator, level, block_hash, operation_hash, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (operation_hash) DO UPDATE SET
-			timestamp = EXCLUDED.timestamp,
-			amount = EXCLUDED.amount,
-			block_hash = EXCLUDED.block_hash,
-			delegator = EXCLUDED.delegator,
-			level = EXCLUDED.level
-	`
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		tx.Rollback(context.Background())
+	}()
+
+	if err := lockDelegationWrites(ctx, tx); err != nil {
+		return err
+	}
 
-	_, err := r.db.Exec(ctx, query,
+	var inserted bool
+	err = tx.QueryRow(ctx, insertDelegationQuery,
 		delegation.ID,
 		delegation.Timestamp,
 		delegation.Amount,
 		delegation.Delegator,
 		delegation.Level,
 		delegation.BlockHash,
+		delegation.OperationHash,
 		delegation.CreatedAt,
-	)
+		delegation.Baker,
+		delegation.PrevBaker,
+	).Scan(&delegation.ID, &delegation.CreatedAt, &delegation.Seq, &delegation.Cycle, &inserted)
 
 	if err != nil {
 		r.logger.Errorw("Failed to save delegation", "error", err, "delegation", delegation)
 		return fmt.Errorf("failed to save delegation: %w", err)
 	}
 
+	if err := r.applyStateChanges(ctx, tx, []domain.Delegation{*delegation}); err != nil {
+		return err
+	}
+
+	if err := r.enqueueWebhookDeliveries(ctx, tx, []domain.Delegation{*delegation}); err != nil {
+		return err
+	}
+
+	if inserted {
+		if err := r.writeOutboxEvents(ctx, tx, []domain.Delegation{*delegation}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
-func (r *Repository) SaveBatch(delegations []domain.Delegation) error {
+func (r *Repository) SaveBatch(ctx context.Context, delegations []domain.Delegation) ([]domain.Delegation, error) {
 	if len(delegations) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() {
 		// Use a fresh context for rollback to ensure it always works
 		tx.Rollback(context.Background())
 	}()
 
+	if err := lockDelegationWrites(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	// The rows are saved from a copy, which receives the stored defaults, seq
+	// and cycle, so that they are published as stored.
+	stored := slices.Clone(delegations)
+
 	batch := &pgx.Batch{}
-	query := `
-		INSERT INTO delegations (id, timestamp, amount, delegator, level, block_hash, operation_hash, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (operation_hash) DO UPDATE SET
-			timestamp = EXCLUDED.timestamp,
-			amount = EXCLUDED.amount,
-			block_hash = EXCLUDED.block_hash,
-			delegator = EXCLUDED.delegator,
-			level = EXCLUDED.level
-	`
 
-	for _, delegation := range delegations {
+	for i := range stored {
+		delegation := &stored[i]
 		if delegation.ID == "" {
 			delegation.ID = uuid.New().String()
 		}
@@ -105,7 +155,7 @@ func (r *Repository) SaveBatch(delegations []domain.Delegation) error {
 			delegation.CreatedAt = time.Now()
 		}
 
-		batch.Queue(query,
+		batch.Queue(insertDelegationQuery,
 			delegation.ID,
 			delegation.Timestamp,
 			delegation.Amount,
@@ -114,6 +164,8 @@ func (r *Repository) SaveBatch(delegations []domain.Delegation) error {
 			delegation.BlockHash,
 			delegation.OperationHash,
 			delegation.CreatedAt,
+			delegation.Baker,
+			delegation.PrevBaker,
 		)
 	}
 
@@ -121,8 +173,11 @@ func (r *Repository) SaveBatch(delegations []domain.Delegation) error {
 
 	successCount := 0
 	duplicateCount := 0
+	var created []domain.Delegation
 	for i := 0; i < batch.Len(); i++ {
-		if _, err := br.Exec(); err != nil {
+		var inserted bool
+		d := &stored[i]
+		if err := br.QueryRow().Scan(&d.ID, &d.CreatedAt, &d.Seq, &d.Cycle, &inserted); err != nil {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
 				duplicateCount++
@@ -130,46 +185,55 @@ func (r *Repository) SaveBatch(delegations []domain.Delegation) error {
 				continue
 			}
 			br.Close()
-			return fmt.Errorf("failed to execute batch item %d: %w", i, err)
+			return nil, fmt.Errorf("failed to execute batch item %d: %w", i, err)
 		}
 		successCount++
+		if inserted {
+			created = append(created, *d)
+		}
 	}
 
 	// Close the batch result before committing the transaction
 	if err := br.Close(); err != nil {
-		return fmt.Errorf("failed to close batch result: %w", err)
+		return nil, fmt.Errorf("failed to close batch result: %w", err)
+	}
+
+	if err := r.applyStateChanges(ctx, tx, stored); err != nil {
+		return nil, err
+	}
+
+	if err := r.enqueueWebhookDeliveries(ctx, tx, stored); err != nil {
+		return nil, err
+	}
+
+	if err := r.writeOutboxEvents(ctx, tx, created); err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	r.logger.Infow("Saved batch of delegations", "attempted", len(delegations), "saved", successCount, "duplicates", duplicateCount)
-	return nil
+	return created, nil
 }
 
-func (r *Repository) FindAll(year *int) ([]domain.Delegation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (r *Repository) FindAll(ctx context.Context, year *int) ([]domain.Delegation, error) {
+	return r.FindByFilter(ctx, domain.DelegationFilter{Year: year})
+}
+
+func (r *Repository) FindByFilter(ctx context.Context, filter domain.DelegationFilter) ([]domain.Delegation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	var query string
-	var args []interface{}
-
-	if year != nil {
-		query = `
-			SELECT id, timestamp, amount, delegator, level, block_hash, created_at
-			FROM delegations
-			WHERE EXTRACT(YEAR FROM timestamp) = $1
-			ORDER BY timestamp DESC
-		`
-		args = append(args, *year)
-	} else {
-		query = `
-			SELECT id, timestamp, amount, delegator, level, block_hash, created_at
-			FROM delegations
-			ORDER BY timestamp DESC
-		`
-	}
+	conditions, args := filterConditions(nil, nil, filter)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM delegations
+		%s
+		ORDER BY timestamp DESC
+	`, delegationColumns, whereClause(conditions))
 
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
@@ -179,18 +243,84 @@ func (r *Repository) FindAll(year *int) ([]domain.Delegation, error) {
 
 	var delegations []domain.Delegation
 	for rows.Next() {
-		var d domain.Delegation
-		err := rows.Scan(
-			&d.ID,
-			&d.Timestamp,
-			&d.Amount,
-			&d.Delegator,
-			&d.Level,
-			&d.BlockHash,
-			&d.CreatedAt,
-		)
+		d, err := scanDelegation(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan delegation: %w", err)
+			return nil, err
+		}
+		delegations = append(delegations, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return delegations, nil
+}
+
+// ExportDelegations streams matching delegations row by row. It has no
+// timeout of its own since exports may run for minutes; callers bound it
+// with ctx.
+func (r *Repository) ExportDelegations(ctx context.Context, filter domain.DelegationFilter, emit func(domain.Delegation) error) error {
+	conditions, args := filterConditions(nil, nil, filter)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM delegations
+		%s
+		ORDER BY timestamp DESC, seq DESC
+	`, delegationColumns, whereClause(conditions))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query delegations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanDelegation(rows)
+		if err != nil {
+			return err
+		}
+		if err := emit(d); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindPage(ctx context.Context, query domain.DelegationPageQuery) ([]domain.Delegation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	conditions, args := filterConditions(nil, nil, query.Filter)
+	if query.Before != nil {
+		args = append(args, query.Before.Level, query.Before.Seq)
+		conditions = append(conditions, fmt.Sprintf("(CAST(level AS BIGINT), seq) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, query.Limit)
+
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM delegations
+		%s
+		ORDER BY CAST(level AS BIGINT) DESC, seq DESC
+		LIMIT $%d
+	`, delegationColumns, whereClause(conditions), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delegations page: %w", err)
+	}
+	defer rows.Close()
+
+	var delegations []domain.Delegation
+	for rows.Next() {
+		d, err := scanDelegation(rows)
+		if err != nil {
+			return nil, err
 		}
 		delegations = append(delegations, d)
 	}
@@ -202,8 +332,53 @@ func (r *Repository) FindAll(year *int) ([]domain.Delegation, error) {
 	return delegations, nil
 }
 
-func (r *Repository) GetLastIndexedLevel() (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func filterConditions(conditions []string, args []interface{}, filter domain.DelegationFilter) ([]string, []interface{}) {
+	if filter.Year != nil {
+		args = append(args, *filter.Year)
+		conditions = append(conditions, fmt.Sprintf("EXTRACT(YEAR FROM timestamp) = $%d", len(args)))
+	}
+	if filter.Cycle != nil {
+		args = append(args, *filter.Cycle)
+		conditions = append(conditions, fmt.Sprintf("cycle = $%d", len(args)))
+	}
+	if filter.Baker != "" {
+		args = append(args, filter.Baker)
+		conditions = append(conditions, fmt.Sprintf("baker = $%d", len(args)))
+	}
+	if filter.Delegator != "" {
+		args = append(args, filter.Delegator)
+		conditions = append(conditions, fmt.Sprintf("delegator = $%d", len(args)))
+	}
+	return conditions, args
+}
+
+const delegationColumns = `id, timestamp, amount, delegator, level, block_hash, COALESCE(operation_hash, ''),
+		created_at, COALESCE(baker, ''), COALESCE(prev_baker, ''), cycle, seq`
+
+func scanDelegation(row pgx.Row) (domain.Delegation, error) {
+	var d domain.Delegation
+	err := row.Scan(
+		&d.ID,
+		&d.Timestamp,
+		&d.Amount,
+		&d.Delegator,
+		&d.Level,
+		&d.BlockHash,
+		&d.OperationHash,
+		&d.CreatedAt,
+		&d.Baker,
+		&d.PrevBaker,
+		&d.Cycle,
+		&d.Seq,
+	)
+	if err != nil {
+		return d, fmt.Errorf("failed to scan delegation: %w", err)
+	}
+	return d, nil
+}
+
+func (r *Repository) GetLastIndexedLevel(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	var lastLevel sql.NullInt64
@@ -227,8 +402,24 @@ func (r *Repository) GetLastIndexedLevel() (int64, error) {
 	return lastLevel.Int64, nil
 }
 
-func (r *Repository) Exists(delegator string, level string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// CountDelegationsInLevels counts the delegations stored from fromLevel to
+// toLevel included.
+func (r *Repository) CountDelegationsInLevels(ctx context.Context, fromLevel, toLevel int64) (int64, error) {
+	var count int64
+	query := `
+		SELECT COUNT(*)
+		FROM delegations
+		WHERE CAST(level AS BIGINT) BETWEEN $1 AND $2
+	`
+
+	if err := r.db.QueryRow(ctx, query, fromLevel, toLevel).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count delegations: %w", err)
+	}
+	return count, nil
+}
+
+func (r *Repository) Exists(ctx context.Context, delegator string, level string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	var exists bool
@@ -247,8 +438,8 @@ func (r *Repository) Exists(delegator string, level string) (bool, error) {
 	return exists, nil
 }
 
-func (r *Repository) UpdateIndexingMetadata(level int64, timestamp time.Time) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *Repository) UpdateIndexingMetadata(ctx context.Context, level int64, timestamp time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	query := `
@@ -267,8 +458,8 @@ func (r *Repository) UpdateIndexingMetadata(level int64, timestamp time.Time) er
 	return nil
 }
 
-func (r *Repository) GetIndexingMetadata() (int64, *time.Time, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *Repository) GetIndexingMetadata(ctx context.Context) (int64, *time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	var level int64
@@ -295,8 +486,41 @@ func (r *Repository) GetIndexingMetadata() (int64, *time.Time, error) {
 	return level, nil, nil
 }
 
-func (r *Repository) GetDelegationsByTimeRange(start, end time.Time) ([]domain.Delegation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (r *Repository) SetPollingPaused(ctx context.Context, paused bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE indexing_metadata
+		SET polling_paused = $1,
+		    updated_at = NOW()
+		WHERE id = 1
+	`, paused)
+	if err != nil {
+		return fmt.Errorf("failed to update polling state: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) IsPollingPaused(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var paused bool
+	err := r.db.QueryRow(ctx, "SELECT polling_paused FROM indexing_metadata WHERE id = 1").Scan(&paused)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get polling state: %w", err)
+	}
+
+	return paused, nil
+}
+
+func (r *Repository) GetDelegationsByTimeRange(ctx context.Context, start, end time.Time) ([]domain.Delegation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	query := `
@@ -333,53 +557,60 @@ func (r *Repository) GetDelegationsByTimeRange(start, end time.Time) ([]domain.D
 	return delegations, nil
 }
 
-func (r *Repository) GetStats() (map[string]interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (r *Repository) GetLastTimestamp(ctx context.Context) (*time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	stats := make(map[string]interface{})
+	var last *time.Time
+	if err := r.db.QueryRow(ctx, `SELECT MAX(timestamp) FROM delegations`).Scan(&last); err != nil {
+		return nil, fmt.Errorf("failed to get last timestamp: %w", err)
+	}
+	return last, nil
+}
 
-	var totalCount int64
-	err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM delegations").Scan(&totalCount)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total count: %w", err)
+func (r *Repository) CountDelegations(ctx context.Context, since *time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var count int64
+	if err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM delegations
+		WHERE $1::timestamptz IS NULL OR timestamp >= $1
+	`, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count delegations: %w", err)
 	}
-	stats["total_delegations"] = totalCount
+	return count, nil
+}
 
-	var totalAmount sql.NullString
-	err = r.db.QueryRow(ctx, `
-		SELECT SUM(CAST(amount AS NUMERIC))::TEXT 
+func (r *Repository) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var totalCount, uniqueDelegators int64
+	var totalAmount string
+	var oldest, latest *time.Time
+	var lastLevel *int64
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT delegator), COALESCE(SUM(CAST(amount AS NUMERIC)), 0)::TEXT,
+			MIN(timestamp), MAX(timestamp), MAX(CAST(level AS BIGINT))
 		FROM delegations
-	`).Scan(&totalAmount)
+	`).Scan(&totalCount, &uniqueDelegators, &totalAmount, &oldest, &latest, &lastLevel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total amount: %w", err)
-	}
-	if totalAmount.Valid {
-		stats["total_amount"] = totalAmount.String
-	} else {
-		stats["total_amount"] = "0"
+		return nil, fmt.Errorf("failed to get stats: %w", err)
 	}
 
-	var uniqueDelegators int64
-	err = r.db.QueryRow(ctx, "SELECT COUNT(DISTINCT delegator) FROM delegations").Scan(&uniqueDelegators)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get unique delegators: %w", err)
-	}
-	stats["unique_delegators"] = uniqueDelegators
-
-	var lastLevel sql.NullString
-	err = r.db.QueryRow(ctx, `
-		SELECT level FROM delegations 
-		ORDER BY CAST(level AS BIGINT) DESC 
-		LIMIT 1
-	`).Scan(&lastLevel)
-	if err != nil && err != pgx.ErrNoRows {
-		return nil, fmt.Errorf("failed to get last level: %w", err)
-	}
-	if lastLevel.Valid {
-		if l, err := strconv.ParseInt(lastLevel.String, 10, 64); err == nil {
-			stats["last_indexed_level"] = l
-		}
+	stats := map[string]interface{}{
+		"total_delegations": totalCount,
+		"unique_delegators": uniqueDelegators,
+		"total_amount":      totalAmount,
+	}
+	if latest != nil {
+		stats["latest_delegation"] = *latest
+		stats["oldest_delegation"] = *oldest
+	}
+	if lastLevel != nil {
+		stats["last_indexed_level"] = *lastLevel
 	}
 
 	return stats, nil
@@ -0,0 +1,268 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLevels returns the first of n levels no other test uses.
+func testLevels(n int64) int64 {
+	return 100_000_000 + rand.Int64N(1_000_000_000)/n*n
+}
+
+func testDelegation(delegator string, level int64, baker string) domain.Delegation {
+	return domain.Delegation{
+		Timestamp:     time.Now().UTC().Truncate(time.Second),
+		Amount:        "1000",
+		Delegator:     delegator,
+		Level:         strconv.FormatInt(level, 10),
+		BlockHash:     "block" + strconv.FormatInt(level, 10),
+		OperationHash: "op" + uuid.New().String(),
+		Baker:         baker,
+	}
+}
+
+func TestRepository_SaveBatchReturnsStoredDelegations(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	level := testLevels(10)
+	cycle := level / 10
+	require.NoError(t, repo.SaveCycles(ctx, []domain.Cycle{{Index: cycle, FirstLevel: level, LastLevel: level + 9}}))
+
+	delegator := "tz1" + uuid.New().String()
+	first := testDelegation(delegator, level, "tz1baker")
+	batch := []domain.Delegation{first}
+
+	created, err := repo.SaveBatch(ctx, batch)
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	stored := created[0]
+	assert.NotEmpty(t, stored.ID)
+	assert.False(t, stored.CreatedAt.IsZero())
+	assert.Positive(t, stored.Seq)
+	require.NotNil(t, stored.Cycle)
+	assert.Equal(t, cycle, *stored.Cycle)
+	assert.Empty(t, batch[0].ID, "the caller's delegations are left as passed")
+
+	// Saving again only returns the new delegation, after the first.
+	second := testDelegation(delegator, level+1, "tz1baker")
+	created, err = repo.SaveBatch(ctx, []domain.Delegation{first, second})
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	assert.Equal(t, second.OperationHash, created[0].OperationHash)
+	assert.Greater(t, created[0].Seq, stored.Seq)
+}
+
+func TestRepository_AggregatesDelegations(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	// Far in the future, so that only these delegations are counted.
+	since := time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(rand.Int64N(1_000_000)) * time.Hour)
+	level := testLevels(10)
+	delegator := "tz1" + uuid.New().String()
+	var batch []domain.Delegation
+	for i := range int64(3) {
+		d := testDelegation(delegator, level+i, "tz1baker")
+		d.Timestamp = since.Add(time.Duration(i-1) * time.Minute)
+		batch = append(batch, d)
+	}
+	_, err := repo.SaveBatch(ctx, batch)
+	require.NoError(t, err)
+
+	count, err := repo.CountDelegations(ctx, &since)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	total, err := repo.CountDelegations(ctx, nil)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, total, int64(3))
+
+	last, err := repo.GetLastTimestamp(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.False(t, last.Before(since.Add(time.Minute)))
+
+	stats, err := repo.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, total, stats["total_delegations"])
+	assert.Contains(t, stats, "latest_delegation")
+}
+
+func TestRepository_ProjectsDelegatorState(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	level := testLevels(10)
+	moved, stayed := "tz1"+uuid.New().String(), "tz1"+uuid.New().String()
+	first, second := "tz1"+uuid.New().String(), "tz1"+uuid.New().String()
+	latest := testDelegation(moved, level+1, second)
+	_, err := repo.SaveBatch(ctx, []domain.Delegation{
+		latest,
+		testDelegation(moved, level, first),
+		testDelegation(stayed, level, first),
+	})
+	require.NoError(t, err)
+
+	// Re-indexing an older level does not rewind the state.
+	_, err = repo.SaveBatch(ctx, []domain.Delegation{testDelegation(moved, level-1, first)})
+	require.NoError(t, err)
+
+	state, err := repo.GetDelegatorState(ctx, domain.DelegatorQuery{Delegator: moved})
+	require.NoError(t, err)
+	assert.Equal(t, second, state.Baker)
+	assert.Equal(t, level+1, state.SinceLevel)
+	assert.Equal(t, latest.OperationHash, state.OperationHash)
+
+	delegators, err := repo.GetBakerDelegators(ctx, domain.BakerDelegatorsQuery{Baker: first, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), delegators.DelegatorCount)
+	require.Len(t, delegators.Data, 1)
+	assert.Equal(t, stayed, delegators.Data[0].Delegator)
+
+	delegators, err = repo.GetBakerDelegators(ctx, domain.BakerDelegatorsQuery{Baker: second, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, delegators.Data, 1)
+	assert.Equal(t, moved, delegators.Data[0].Delegator)
+}
+
+// Sequence numbers follow storage order, not levels, and pages order a level
+// by them.
+func TestRepository_OrdersBySeq(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	before, err := repo.GetLatestSeq(ctx)
+	require.NoError(t, err)
+
+	level := testLevels(10)
+	delegator := "tz1" + uuid.New().String()
+	batch := []domain.Delegation{
+		testDelegation(delegator, level+1, "tz1baker"),
+		testDelegation(delegator, level, "tz1baker"),
+		testDelegation(delegator, level, "tz1baker"),
+	}
+	created, err := repo.SaveBatch(ctx, batch)
+	require.NoError(t, err)
+	require.Len(t, created, 3)
+	assert.Greater(t, created[0].Seq, before)
+	assert.Greater(t, created[1].Seq, created[0].Seq)
+	assert.Greater(t, created[2].Seq, created[1].Seq)
+
+	latest, err := repo.GetLatestSeq(ctx)
+	require.NoError(t, err)
+	stream, err := repo.FindAfterSeq(ctx, before, latest, domain.DelegationFilter{Delegator: delegator}, 10)
+	require.NoError(t, err)
+	require.Len(t, stream, 3)
+	for i, d := range stream {
+		assert.Equal(t, batch[i].OperationHash, d.OperationHash)
+	}
+
+	page, err := repo.FindPage(ctx, domain.DelegationPageQuery{Filter: domain.DelegationFilter{Delegator: delegator}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page, 3)
+	assert.Equal(t, batch[0].OperationHash, page[0].OperationHash)
+	assert.Equal(t, batch[2].OperationHash, page[1].OperationHash)
+	assert.Equal(t, batch[1].OperationHash, page[2].OperationHash)
+}
+
+// Saving a delegation enqueues it for the webhooks whose filter it matches,
+// unless it was stored before the webhook was registered.
+func TestRepository_EnqueuesWebhookDeliveries(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	level := testLevels(10)
+	baker := "tz1" + uuid.New().String()
+	earlier := testDelegation("tz1"+uuid.New().String(), level, baker)
+	_, err := repo.SaveBatch(ctx, []domain.Delegation{earlier})
+	require.NoError(t, err)
+
+	webhook := &domain.Webhook{
+		URL:    "https://example.com/hook",
+		Secret: "s3cret",
+		Filter: domain.SubscriptionFilter{Bakers: []string{baker}},
+		Active: true,
+	}
+	require.NoError(t, repo.CreateWebhook(ctx, webhook))
+	t.Cleanup(func() { _ = repo.DeleteWebhook(context.Background(), webhook.ID) })
+
+	matching := testDelegation("tz1"+uuid.New().String(), level+1, baker)
+	_, err = repo.SaveBatch(ctx, []domain.Delegation{
+		earlier,
+		matching,
+		testDelegation("tz1"+uuid.New().String(), level+1, "tz1other"),
+	})
+	require.NoError(t, err)
+	// Saving again does not enqueue twice.
+	_, err = repo.SaveBatch(ctx, []domain.Delegation{matching})
+	require.NoError(t, err)
+
+	deliveries, err := repo.ListDeliveries(ctx, domain.WebhookDeliveryQuery{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, matching.OperationHash, deliveries[0].OperationHash)
+	assert.Equal(t, domain.WebhookEventDelegation, deliveries[0].Type)
+	assert.Equal(t, domain.WebhookDeliveryPending, deliveries[0].Status)
+	assert.Contains(t, string(deliveries[0].Payload), matching.OperationHash)
+}
+
+// Rolling back levels tells outbox and webhook consumers where the removed
+// delegations start, in the transaction that removes them.
+func TestRepository_RollbackLevelsEmitsEvent(t *testing.T) {
+	repo, db := newTestRepository(t)
+	repo.EnableOutbox()
+	ctx := context.Background()
+
+	webhook := &domain.Webhook{URL: "https://example.com/hook", Secret: "s3cret", Active: true}
+	require.NoError(t, repo.CreateWebhook(ctx, webhook))
+	t.Cleanup(func() { _ = repo.DeleteWebhook(context.Background(), webhook.ID) })
+
+	lastLevel, err := repo.GetLastIndexedLevel(ctx)
+	require.NoError(t, err)
+	delegator := "tz1" + uuid.New().String()
+	_, err = repo.SaveBatch(ctx, []domain.Delegation{
+		testDelegation(delegator, lastLevel+1, "tz1baker"),
+		testDelegation(delegator, lastLevel+2, "tz1baker"),
+	})
+	require.NoError(t, err)
+
+	removed, err := repo.RollbackLevels(ctx, lastLevel+2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	deliveries, err := repo.ListDeliveries(ctx, domain.WebhookDeliveryQuery{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	rollback := deliveries[0]
+	assert.Equal(t, domain.TopicDelegationsRolledBack, rollback.Type)
+	want := fmt.Sprintf(`{"from_level": %d, "removed": 1}`, lastLevel+2)
+	assert.JSONEq(t, want, string(rollback.Payload))
+
+	var payload []byte
+	err = db.QueryRow(ctx, `
+		SELECT payload FROM outbox_events WHERE topic = $1 AND event_key = $2
+	`, domain.TopicDelegationsRolledBack, rollback.OperationHash).Scan(&payload)
+	require.NoError(t, err)
+	assert.JSONEq(t, want, string(payload))
+
+	// Rolling back levels holding no delegations emits nothing.
+	removed, err = repo.RollbackLevels(ctx, lastLevel+2)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+	deliveries, err = repo.ListDeliveries(ctx, domain.WebhookDeliveryQuery{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 3)
+}
@@ -0,0 +1,299 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+const upsertDelegationStateQuery = `
+	INSERT INTO delegation_state (delegator, baker, since_level, since_timestamp, last_amount, operation_hash, updated_at)
+	VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, NOW())
+	ON CONFLICT (delegator) DO UPDATE SET
+		baker = EXCLUDED.baker,
+		since_level = EXCLUDED.since_level,
+		since_timestamp = EXCLUDED.since_timestamp,
+		last_amount = EXCLUDED.last_amount,
+		operation_hash = EXCLUDED.operation_hash,
+		updated_at = NOW()
+	WHERE delegation_state.since_level <= EXCLUDED.since_level
+`
+
+// rebuildDelegationStateQuery projects the latest remaining delegation of
+// each of the delegators in $1 onto delegation_state.
+const rebuildDelegationStateQuery = `
+	INSERT INTO delegation_state (delegator, baker, since_level, since_timestamp, last_amount, operation_hash)
+	SELECT DISTINCT ON (delegator)
+		delegator, baker, CAST(level AS BIGINT), timestamp, amount, operation_hash
+	FROM delegations
+	WHERE delegator = ANY($1)
+	ORDER BY delegator, CAST(level AS BIGINT) DESC, timestamp DESC
+`
+
+// applyStateChanges projects delegations onto delegation_state within tx, so
+// the projection commits or rolls back together with the event log. Events
+// older than the stored state are ignored, which keeps re-indexing a past
+// level range from rewinding an account's current baker.
+func (r *Repository) applyStateChanges(ctx context.Context, tx pgx.Tx, delegations []domain.Delegation) error {
+	latest := make(map[string]domain.Delegation, len(delegations))
+	order := make([]string, 0, len(delegations))
+	levels := make(map[string]int64, len(delegations))
+
+	for _, d := range delegations {
+		level, err := strconv.ParseInt(d.Level, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid level %q for delegation %s: %w", d.Level, d.OperationHash, err)
+		}
+		current, seen := levels[d.Delegator]
+		if !seen {
+			order = append(order, d.Delegator)
+		}
+		if !seen || level >= current {
+			latest[d.Delegator] = d
+			levels[d.Delegator] = level
+		}
+	}
+
+	batch := &pgx.Batch{}
+	for _, delegator := range order {
+		d := latest[delegator]
+		batch.Queue(upsertDelegationStateQuery,
+			d.Delegator,
+			d.Baker,
+			levels[delegator],
+			d.Timestamp,
+			d.Amount,
+			d.OperationHash,
+		)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			br.Close()
+			return fmt.Errorf("failed to update delegation state for %s: %w", order[i], err)
+		}
+	}
+
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("failed to close state batch result: %w", err)
+	}
+
+	return nil
+}
+
+// historyAsOfCondition returns the predicate selecting delegations that
+// happened at or before asOf, using args[argIndex] as its parameter.
+func historyAsOfCondition(asOf *domain.AsOf, argIndex int) (string, interface{}) {
+	if asOf.Level != nil {
+		return fmt.Sprintf("CAST(level AS BIGINT) <= $%d", argIndex), *asOf.Level
+	}
+	return fmt.Sprintf("timestamp <= $%d", argIndex), *asOf.Timestamp
+}
+
+func (r *Repository) GetIndexedRange(ctx context.Context) (*domain.IndexedRange, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var firstLevel, lastLevel *int64
+	var firstTimestamp, lastTimestamp *time.Time
+	err := r.db.QueryRow(ctx, `
+		SELECT MIN(CAST(level AS BIGINT)), MAX(CAST(level AS BIGINT)), MIN(timestamp), MAX(timestamp)
+		FROM delegations
+	`).Scan(&firstLevel, &lastLevel, &firstTimestamp, &lastTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indexed range: %w", err)
+	}
+	if firstLevel == nil {
+		return nil, nil
+	}
+	return &domain.IndexedRange{
+		FirstLevel:     *firstLevel,
+		LastLevel:      *lastLevel,
+		FirstTimestamp: *firstTimestamp,
+		LastTimestamp:  *lastTimestamp,
+	}, nil
+}
+
+func (r *Repository) GetDelegatorState(ctx context.Context, query domain.DelegatorQuery) (*domain.DelegatorState, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	sqlQuery := `
+		SELECT delegator, COALESCE(baker, ''), since_level, since_timestamp, last_amount, COALESCE(operation_hash, '')
+		FROM delegation_state
+		WHERE delegator = $1
+	`
+	args := []interface{}{query.Delegator}
+
+	if query.AsOf != nil {
+		condition, arg := historyAsOfCondition(query.AsOf, 2)
+		sqlQuery = fmt.Sprintf(`
+			SELECT delegator, COALESCE(baker, ''), CAST(level AS BIGINT), timestamp, amount, COALESCE(operation_hash, '')
+			FROM delegations
+			WHERE delegator = $1 AND %s
+			ORDER BY CAST(level AS BIGINT) DESC, timestamp DESC
+			LIMIT 1
+		`, condition)
+		args = append(args, arg)
+	}
+
+	var s domain.DelegatorState
+	err := r.db.QueryRow(ctx, sqlQuery, args...).Scan(
+		&s.Delegator,
+		&s.Baker,
+		&s.SinceLevel,
+		&s.SinceTimestamp,
+		&s.LastAmount,
+		&s.OperationHash,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrDelegatorNotFound
+		}
+		return nil, fmt.Errorf("failed to get delegator state: %w", err)
+	}
+
+	return &s, nil
+}
+
+func (r *Repository) GetBakerDelegators(ctx context.Context, query domain.BakerDelegatorsQuery) (*domain.BakerDelegators, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// The source relation exposes the delegation_state columns, either
+	// directly or rebuilt from the event log as of the requested point.
+	source := "delegation_state"
+	args := []interface{}{query.Baker}
+	if query.AsOf != nil {
+		condition, arg := historyAsOfCondition(query.AsOf, 2)
+		source = fmt.Sprintf(`(
+			SELECT DISTINCT ON (delegator)
+				delegator, baker, CAST(level AS BIGINT) AS since_level, timestamp AS since_timestamp,
+				amount AS last_amount, operation_hash
+			FROM delegations
+			WHERE %s
+			ORDER BY delegator, CAST(level AS BIGINT) DESC, timestamp DESC
+		) AS state_as_of`, condition)
+		args = append(args, arg)
+	}
+
+	result := &domain.BakerDelegators{Baker: query.Baker, AsOf: query.AsOf}
+
+	err := r.db.QueryRow(ctx, fmt.Sprintf(`
+		SELECT COUNT(*), COALESCE(SUM(CAST(last_amount AS NUMERIC)), 0)::TEXT
+		FROM %s
+		WHERE baker = $1
+	`, source), args...).Scan(&result.DelegatorCount, &result.TotalAmount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate baker delegators: %w", err)
+	}
+
+	pageArgs := append(args, query.Limit, query.Offset)
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		SELECT delegator, COALESCE(baker, ''), since_level, since_timestamp, last_amount, COALESCE(operation_hash, '')
+		FROM %s
+		WHERE baker = $1
+		ORDER BY CAST(last_amount AS NUMERIC) DESC, delegator ASC
+		LIMIT $%d OFFSET $%d
+	`, source, len(pageArgs)-1, len(pageArgs)), pageArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query baker delegators: %w", err)
+	}
+	defer rows.Close()
+
+	result.Data = []domain.DelegatorState{}
+	for rows.Next() {
+		var s domain.DelegatorState
+		if err := rows.Scan(
+			&s.Delegator,
+			&s.Baker,
+			&s.SinceLevel,
+			&s.SinceTimestamp,
+			&s.LastAmount,
+			&s.OperationHash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan delegator state: %w", err)
+		}
+		result.Data = append(result.Data, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *Repository) GetDelegatorStates(ctx context.Context, delegators []string) ([]domain.DelegatorState, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT delegator, COALESCE(baker, ''), since_level, since_timestamp, last_amount, COALESCE(operation_hash, '')
+		FROM delegation_state
+		WHERE delegator = ANY($1)
+	`, delegators)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delegator states: %w", err)
+	}
+	defer rows.Close()
+
+	var states []domain.DelegatorState
+	for rows.Next() {
+		var s domain.DelegatorState
+		if err := rows.Scan(
+			&s.Delegator,
+			&s.Baker,
+			&s.SinceLevel,
+			&s.SinceTimestamp,
+			&s.LastAmount,
+			&s.OperationHash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan delegator state: %w", err)
+		}
+		states = append(states, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return states, nil
+}
+
+func (r *Repository) GetBakerSummaries(ctx context.Context, bakers []string) ([]domain.BakerSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT baker, COUNT(*), COALESCE(SUM(CAST(last_amount AS NUMERIC)), 0)::TEXT
+		FROM delegation_state
+		WHERE baker = ANY($1)
+		GROUP BY baker
+	`, bakers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query baker summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []domain.BakerSummary
+	for rows.Next() {
+		var s domain.BakerSummary
+		if err := rows.Scan(&s.Baker, &s.DelegatorCount, &s.TotalAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan baker summary: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return summaries, nil
+}
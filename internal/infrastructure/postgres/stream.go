@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// delegationWriteLock is the advisory lock held by every transaction that
+// inserts delegations. Sequence numbers are drawn at insert time but only
+// become visible at commit, so two concurrent writers could commit out of
+// order: a reader that saw the later one would then move its cursor past the
+// rows of the earlier one and never return them. Serialising the writers
+// makes rows visible in sequence order.
+const delegationWriteLock int64 = 0x64656c6567
+
+// lockDelegationWrites takes delegationWriteLock until tx ends. It must be
+// called before tx inserts any delegation.
+func lockDelegationWrites(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", delegationWriteLock); err != nil {
+		return fmt.Errorf("failed to lock delegation writes: %w", err)
+	}
+	return nil
+}
+
+// GetLatestSeq returns the sequence number of the latest committed
+// delegation. Every delegation with a lower sequence number is visible.
+func (r *Repository) GetLatestSeq(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var seq int64
+	if err := r.db.QueryRow(ctx, "SELECT COALESCE(MAX(seq), 0) FROM delegations").Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to get latest sequence: %w", err)
+	}
+
+	return seq, nil
+}
+
+func (r *Repository) FindAfterSeq(ctx context.Context, afterSeq, upToSeq int64, filter domain.DelegationFilter, limit int) ([]domain.Delegation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	args := []interface{}{afterSeq, upToSeq}
+	conditions := []string{"seq > $1", "seq <= $2"}
+	conditions, args = filterConditions(conditions, args, filter)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM delegations
+		%s
+		ORDER BY seq ASC
+		LIMIT $%d
+	`, delegationColumns, whereClause(conditions), len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delegations after sequence: %w", err)
+	}
+	defer rows.Close()
+
+	var delegations []domain.Delegation
+	for rows.Next() {
+		d, err := scanDelegation(rows)
+		if err != nil {
+			return nil, err
+		}
+		delegations = append(delegations, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return delegations, nil
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepository connects to the database of TEST_DATABASE_URL, skipping
+// the test when it is not set.
+func newTestRepository(t *testing.T) (*Repository, *pgxpool.Pool) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	log, err := logger.New("error", "test")
+	require.NoError(t, err)
+
+	db, err := pgxpool.New(context.Background(), url)
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+	require.NoError(t, RunMigrations(db, log))
+
+	return NewRepository(db, log), db
+}
+
+// A writer that draws its sequence numbers first but commits last must not
+// be skipped by a reader that saw the other writer's rows.
+func TestRepository_StreamInterleavedWriters(t *testing.T) {
+	repo, db := newTestRepository(t)
+	ctx := context.Background()
+
+	delegator := "tz1" + uuid.New().String()
+	delegation := func(level string) domain.Delegation {
+		return domain.Delegation{
+			ID:            uuid.New().String(),
+			Timestamp:     time.Now(),
+			Amount:        "1000",
+			Delegator:     delegator,
+			Level:         level,
+			BlockHash:     "block" + level,
+			OperationHash: uuid.New().String(),
+			CreatedAt:     time.Now(),
+		}
+	}
+	first, second := delegation("1"), delegation("2")
+	filter := domain.DelegationFilter{Delegator: delegator}
+
+	cursor, err := repo.GetLatestSeq(ctx)
+	require.NoError(t, err)
+
+	// The first writer inserts and stays open.
+	tx, err := db.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(context.Background())
+	require.NoError(t, lockDelegationWrites(ctx, tx))
+	var inserted bool
+	require.NoError(t, tx.QueryRow(ctx, insertDelegationQuery,
+		first.ID, first.Timestamp, first.Amount, first.Delegator, first.Level,
+		first.BlockHash, first.OperationHash, first.CreatedAt, first.Baker, first.PrevBaker,
+	).Scan(&first.ID, &first.CreatedAt, &first.Seq, &first.Cycle, &inserted))
+
+	// The second writer waits for the first to commit.
+	saved := make(chan error, 1)
+	go func() {
+		_, err := repo.SaveBatch(ctx, []domain.Delegation{second})
+		saved <- err
+	}()
+	select {
+	case err := <-saved:
+		t.Fatalf("second writer committed before the first: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	latest, err := repo.GetLatestSeq(ctx)
+	require.NoError(t, err)
+	delegations, err := repo.FindAfterSeq(ctx, cursor, latest, filter, 10)
+	require.NoError(t, err)
+	assert.Empty(t, delegations)
+	cursor = latest
+
+	require.NoError(t, tx.Commit(ctx))
+	require.NoError(t, <-saved)
+
+	latest, err = repo.GetLatestSeq(ctx)
+	require.NoError(t, err)
+	delegations, err = repo.FindAfterSeq(ctx, cursor, latest, filter, 10)
+	require.NoError(t, err)
+	require.Len(t, delegations, 2)
+	assert.Equal(t, first.OperationHash, delegations[0].OperationHash)
+	assert.Equal(t, second.OperationHash, delegations[1].OperationHash)
+}
+
+// Pages follow the chain, not the order delegations were stored in.
+func TestRepository_FindPageByLevel(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	delegator := "tz1" + uuid.New().String()
+	var stored []domain.Delegation
+	for _, level := range []string{"30", "10", "20"} {
+		stored = append(stored, domain.Delegation{
+			ID:            uuid.New().String(),
+			Timestamp:     time.Now(),
+			Amount:        "1000",
+			Delegator:     delegator,
+			Level:         level,
+			BlockHash:     "block" + level,
+			OperationHash: uuid.New().String(),
+			CreatedAt:     time.Now(),
+		})
+	}
+	_, err := repo.SaveBatch(ctx, stored)
+	require.NoError(t, err)
+
+	filter := domain.DelegationFilter{Delegator: delegator}
+	first, err := repo.FindPage(ctx, domain.DelegationPageQuery{Filter: filter, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, "30", first[0].Level)
+	assert.Equal(t, "20", first[1].Level)
+
+	cursor := domain.CursorOf(first[1])
+	rest, err := repo.FindPage(ctx, domain.DelegationPageQuery{Filter: filter, Before: &cursor, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	assert.Equal(t, "10", rest[0].Level)
+}
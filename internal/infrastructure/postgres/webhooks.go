@@ -0,0 +1,374 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// enqueueWebhookDeliveriesQuery matches freshly stored delegations against
+// active webhooks. Re-saving a delegation never enqueues it twice, and
+// delegations stored before a webhook was registered are not sent to it.
+const enqueueWebhookDeliveriesQuery = `
+	INSERT INTO webhook_deliveries (webhook_id, operation_hash, payload)
+	SELECT w.id, d.operation_hash, p.payload::jsonb
+	FROM unnest($1::text[], $2::text[]) AS p(operation_hash, payload)
+	JOIN delegations d ON d.operation_hash = p.operation_hash
+	JOIN webhooks w ON w.active
+		AND d.seq > w.start_seq
+		AND (cardinality(w.bakers) = 0 OR d.baker = ANY(w.bakers))
+		AND (cardinality(w.delegators) = 0 OR d.delegator = ANY(w.delegators))
+		AND CAST(d.amount AS NUMERIC) >= w.min_amount
+	ON CONFLICT (webhook_id, operation_hash) DO NOTHING
+`
+
+// webhookDeliveryColumns lists the columns read by scanWebhookDelivery,
+// qualified by table. The next attempt is only meaningful while pending.
+func webhookDeliveryColumns(table string) string {
+	return fmt.Sprintf(`
+		%[1]s.id, %[1]s.webhook_id, %[1]s.operation_hash, %[1]s.event_type, %[1]s.payload, %[1]s.status, %[1]s.attempts,
+		CASE WHEN %[1]s.status = 'pending' THEN %[1]s.next_attempt_at END,
+		%[1]s.last_status_code, COALESCE(%[1]s.last_error, ''), %[1]s.created_at, %[1]s.delivered_at
+	`, table)
+}
+
+// enqueueWebhookDeliveries writes the webhook outbox within tx, so a
+// delivery exists if and only if its delegation was committed.
+func (r *Repository) enqueueWebhookDeliveries(ctx context.Context, tx pgx.Tx, delegations []domain.Delegation) error {
+	hashes := make([]string, 0, len(delegations))
+	payloads := make([]string, 0, len(delegations))
+	for _, d := range delegations {
+		if d.OperationHash == "" {
+			continue
+		}
+		payload, err := json.Marshal(d.Movement())
+		if err != nil {
+			return fmt.Errorf("failed to encode webhook payload for %s: %w", d.OperationHash, err)
+		}
+		hashes = append(hashes, d.OperationHash)
+		payloads = append(payloads, string(payload))
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, enqueueWebhookDeliveriesQuery, hashes, payloads); err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// CreateWebhook registers a webhook for the delegations stored after it. The
+// delegation writes lock is held while reading the latest sequence number, so
+// that no delegation is being stored without being seen by the webhook.
+func (r *Repository) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		tx.Rollback(context.Background())
+	}()
+
+	if err := lockDelegationWrites(ctx, tx); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO webhooks (url, secret, bakers, delegators, min_amount, active, start_seq)
+		VALUES ($1, $2, $3, $4, $5, $6, (SELECT COALESCE(MAX(seq), 0) FROM delegations))
+		RETURNING id, created_at
+	`,
+		webhook.URL,
+		webhook.Secret,
+		nonNil(webhook.Filter.Bakers),
+		nonNil(webhook.Filter.Delegators),
+		webhook.Filter.MinAmount,
+		webhook.Active,
+	).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, url, secret, bakers, delegators, min_amount, active, created_at
+		FROM webhooks
+		ORDER BY created_at ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []domain.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (r *Repository) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRow(ctx, `
+		SELECT id, url, secret, bakers, delegators, min_amount, active, created_at
+		FROM webhooks
+		WHERE id::text = $1
+	`, id)
+
+	webhook, err := scanWebhook(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (r *Repository) DeleteWebhook(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhooks WHERE id::text = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *Repository) ListDeliveries(ctx context.Context, query domain.WebhookDeliveryQuery) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	args := []interface{}{query.WebhookID}
+	conditions := []string{"webhook_id::text = $1"}
+	if query.Status != "" {
+		args = append(args, string(query.Status))
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	args = append(args, query.Limit)
+
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM webhook_deliveries
+		%s
+		ORDER BY id DESC
+		LIMIT $%d
+	`, webhookDeliveryColumns("webhook_deliveries"), whereClause(conditions), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []domain.WebhookDelivery
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *Repository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.DueDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Claimed rows are pushed out by the lease, so a replica that dies while
+	// delivering leaves them to be retried rather than lost.
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries wd
+			SET attempts = wd.attempts + 1,
+				next_attempt_at = NOW() + $2::float8 * INTERVAL '1 second'
+			FROM due
+			WHERE wd.id = due.id
+			RETURNING wd.*
+		)
+		SELECT %s, w.url, w.secret
+		FROM claimed
+		JOIN webhooks w ON w.id = claimed.webhook_id
+		ORDER BY claimed.id ASC
+	`, webhookDeliveryColumns("claimed")), limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var due []domain.DueDelivery
+	for rows.Next() {
+		var d domain.DueDelivery
+		if err := scanWebhookDelivery(rows, &d.WebhookDelivery, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return due, nil
+}
+
+func (r *Repository) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', last_status_code = $2, last_error = NULL, delivered_at = NOW()
+		WHERE id = $1
+	`, id, statusCode)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook delivery %d delivered: %w", id, err)
+	}
+	return nil
+}
+
+func (r *Repository) MarkFailed(ctx context.Context, id int64, statusCode int, reason string, retryAt *time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	status := domain.WebhookDeliveryPending
+	if retryAt == nil {
+		status = domain.WebhookDeliveryDead
+	}
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, last_status_code = NULLIF($3, 0), last_error = $4, next_attempt_at = $5
+		WHERE id = $1
+	`, id, string(status), statusCode, reason, retryAt)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery %d failure: %w", id, err)
+	}
+	return nil
+}
+
+func (r *Repository) Redeliver(ctx context.Context, webhookID string, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE id = $1 AND webhook_id::text = $2
+	`, id, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to requeue webhook delivery %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWebhookDeliveryNotFound
+	}
+	return nil
+}
+
+func scanWebhook(row pgx.Row) (*domain.Webhook, error) {
+	var w domain.Webhook
+	if err := row.Scan(
+		&w.ID,
+		&w.URL,
+		&w.Secret,
+		&w.Filter.Bakers,
+		&w.Filter.Delegators,
+		&w.Filter.MinAmount,
+		&w.Active,
+		&w.CreatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan webhook: %w", err)
+	}
+	if len(w.Filter.Bakers) == 0 {
+		w.Filter.Bakers = nil
+	}
+	if len(w.Filter.Delegators) == 0 {
+		w.Filter.Delegators = nil
+	}
+	return &w, nil
+}
+
+func scanWebhookDelivery(row pgx.Row, d *domain.WebhookDelivery, extra ...interface{}) error {
+	var status string
+	var statusCode *int32
+	dest := append([]interface{}{
+		&d.ID,
+		&d.WebhookID,
+		&d.OperationHash,
+		&d.Type,
+		&d.Payload,
+		&status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&statusCode,
+		&d.LastError,
+		&d.CreatedAt,
+		&d.DeliveredAt,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return fmt.Errorf("failed to scan webhook delivery: %w", err)
+	}
+
+	d.Status = domain.WebhookDeliveryStatus(status)
+	if statusCode != nil {
+		code := int(*statusCode)
+		d.LastStatusCode = &code
+	}
+	return nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
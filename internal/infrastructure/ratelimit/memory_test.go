@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter_Allow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+	limit := domain.RateLimit{PerSecond: 2, Burst: 3}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		decision, err := limiter.Allow(context.Background(), "client", limit)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 3, decision.Limit)
+		assert.Equal(t, remaining, decision.Remaining)
+	}
+
+	decision, err := limiter.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+	assert.Equal(t, 500*time.Millisecond, decision.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, decision.Reset)
+
+	decision, _ = limiter.Allow(context.Background(), "other", limit)
+	assert.True(t, decision.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	decision, _ = limiter.Allow(context.Background(), "client", limit)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+}
+
+func TestMemoryLimiter_SweepsRefilledBuckets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+	limit := domain.RateLimit{PerSecond: 1, Burst: 2}
+
+	limiter.Allow(context.Background(), "idle", limit)
+	limiter.Allow(context.Background(), "busy", limit)
+
+	now = now.Add(sweepInterval)
+	limiter.Allow(context.Background(), "busy", limit)
+
+	assert.NotContains(t, limiter.buckets, "idle")
+	assert.Contains(t, limiter.buckets, "busy")
+}
@@ -0,0 +1,28 @@
+package ratelimit
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/postgres"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
+
+// New returns the limiter selected by cfg.Backend.
+func New(cfg *config.RateLimit, db *pgxpool.Pool, logger *logger.Logger) (domain.RateLimiter, error) {
+	switch cfg.Backend {
+	case BackendMemory:
+		return NewMemoryLimiter(), nil
+	case BackendPostgres:
+		return postgres.NewRateLimiter(db, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", cfg.Backend)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	resty "github.com/go-resty/resty/v2"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
 	"golang.org/x/time/rate"
@@ -21,6 +24,9 @@ type Client struct {
 	rateLimiter *rate.Limiter
 	maxRetries  int
 	retryDelay  time.Duration
+
+	mu           sync.Mutex
+	reachability Reachability
 }
 
 func NewClient(baseURL string, timeout time.Duration, maxRetries int, retryDelay time.Duration, log *logger.Logger) *Client {
@@ -64,13 +70,14 @@ func (c *Client) GetDelegations(ctx context.Context, params QueryParams) ([]Dele
 	duration := time.Since(start).Seconds()
 	success := err == nil && resp.StatusCode() == 200
 	metrics.RecordTzktAPIRequest(duration, success)
+	c.record(ctx, start, err, resp)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch delegations: %w", err)
+		return nil, unavailable(ctx, fmt.Errorf("failed to fetch delegations: %w", err))
 	}
 
 	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode(), string(resp.Body()))
+		return nil, statusError(resp)
 	}
 
 	var delegations []DelegationResponse
@@ -107,6 +114,43 @@ func (c *Client) GetDelegationsFromLevel(ctx context.Context, level int64, limit
 	return c.GetDelegations(ctx, params)
 }
 
+// GetDelegationsInLevelRange returns up to limit delegations from fromLevel
+// to toLevel included, in ascending ID order. A zero toLevel means no upper
+// bound. Pages after the first pass the ID of the last delegation returned
+// as afterID, so that levels with more than limit delegations are read in
+// full.
+func (c *Client) GetDelegationsInLevelRange(ctx context.Context, fromLevel, toLevel, afterID int64, limit int) ([]DelegationResponse, error) {
+	params := QueryParams{
+		Limit:   limit,
+		AfterID: afterID,
+		Level: &LevelFilter{
+			Gte: &fromLevel,
+		},
+		Sort: []string{"id.asc"},
+	}
+	if toLevel > 0 {
+		params.Level.Lte = &toLevel
+	}
+
+	return c.GetDelegations(ctx, params)
+}
+
+// CountDelegations returns the number of applied delegations from fromLevel
+// to toLevel included.
+func (c *Client) CountDelegations(ctx context.Context, fromLevel, toLevel int64) (int64, error) {
+	queryParams := map[string]string{
+		"level.ge": strconv.FormatInt(fromLevel, 10),
+		"level.le": strconv.FormatInt(toLevel, 10),
+		"status":   "applied",
+	}
+
+	var count int64
+	if err := c.getJSON(ctx, "/v1/operations/delegations/count", queryParams, &count); err != nil {
+		return 0, fmt.Errorf("failed to count delegations: %w", err)
+	}
+	return count, nil
+}
+
 func (c *Client) GetHistoricalDelegations(ctx context.Context, startDate time.Time, batchSize int) (<-chan []DelegationResponse, <-chan error) {
 	delegationsChan := make(chan []DelegationResponse, 10)
 	errorChan := make(chan error, 1)
@@ -154,6 +198,114 @@ func (c *Client) GetHistoricalDelegations(ctx context.Context, startDate time.Ti
 	return delegationsChan, errorChan
 }
 
+// GetCycles returns cycles with an index of at least fromIndex, in ascending
+// order. TzKT includes upcoming cycles with projected boundaries.
+func (c *Client) GetCycles(ctx context.Context, fromIndex int64, limit int) ([]CycleResponse, error) {
+	queryParams := map[string]string{
+		"index.ge": strconv.FormatInt(fromIndex, 10),
+		"sort.asc": "index",
+		"limit":    strconv.Itoa(limit),
+	}
+
+	var cycles []CycleResponse
+	if err := c.getJSON(ctx, "/v1/cycles", queryParams, &cycles); err != nil {
+		return nil, fmt.Errorf("failed to fetch cycles: %w", err)
+	}
+
+	c.logger.Debugw("Fetched cycles", "count", len(cycles), "fromIndex", fromIndex)
+
+	return cycles, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, queryParams map[string]string, out interface{}) error {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limiter error: %w", err)
+	}
+
+	url := c.baseURL + path
+
+	start := time.Now()
+	resp, err := c.httpClient.R().
+		SetContext(ctx).
+		SetQueryParams(queryParams).
+		SetHeader("Accept", "application/json").
+		Get(url)
+
+	duration := time.Since(start).Seconds()
+	success := err == nil && resp.StatusCode() == 200
+	metrics.RecordTzktAPIRequest(duration, success)
+	c.record(ctx, start, err, resp)
+
+	if err != nil {
+		return unavailable(ctx, err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return statusError(resp)
+	}
+
+	if err := json.Unmarshal(resp.Body(), out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return nil
+}
+
+// GetHead returns the current chain head.
+func (c *Client) GetHead(ctx context.Context) (*HeadResponse, error) {
+	var head HeadResponse
+	if err := c.getJSON(ctx, "/v1/head", nil, &head); err != nil {
+		return nil, fmt.Errorf("failed to fetch head: %w", err)
+	}
+	return &head, nil
+}
+
+// Reachability returns the outcome of the most recent request, so that
+// health checks can report on TzKT without calling it.
+func (c *Client) Reachability() Reachability {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reachability
+}
+
+func (c *Client) record(ctx context.Context, start time.Time, err error, resp *resty.Response) {
+	// Requests abandoned by the caller say nothing about TzKT.
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil && (resp.StatusCode() >= 500 || resp.StatusCode() == http.StatusTooManyRequests) {
+		err = fmt.Errorf("unexpected status code: %d", resp.StatusCode())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reachability.CheckedAt = time.Now()
+	c.reachability.Latency = time.Since(start)
+	c.reachability.Err = err
+	if err == nil {
+		c.reachability.LastSuccess = c.reachability.CheckedAt
+	}
+}
+
+// unavailable marks a failed request as an upstream outage, unless it failed
+// because the caller gave up.
+func unavailable(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	return domain.NewUpstreamUnavailableError("TzKT API unavailable", err)
+}
+
+// statusError reports a non-200 response. Server errors and rate limiting,
+// which persist after retries, mean TzKT is unavailable.
+func statusError(resp *resty.Response) error {
+	err := fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode(), string(resp.Body()))
+	if resp.StatusCode() >= 500 || resp.StatusCode() == http.StatusTooManyRequests {
+		return domain.NewUpstreamUnavailableError("TzKT API unavailable", err)
+	}
+	return err
+}
+
 func (c *Client) buildQueryParams(params QueryParams) map[string]string {
 	queryParams := make(map[string]string)
 
@@ -165,6 +317,10 @@ func (c *Client) buildQueryParams(params QueryParams) map[string]string {
 		queryParams["offset"] = strconv.Itoa(params.Offset)
 	}
 
+	if params.AfterID > 0 {
+		queryParams["id.gt"] = strconv.FormatInt(params.AfterID, 10)
+	}
+
 	if params.Level != nil {
 		if params.Level.Gte != nil {
 			queryParams["level.ge"] = strconv.FormatInt(*params.Level.Gte, 10)
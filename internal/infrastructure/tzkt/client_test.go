@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -159,6 +161,24 @@ func TestClient_RetryOnError(t *testing.T) {
 	assert.Equal(t, "tz1success", delegations[0].Sender.Address)
 }
 
+func TestClient_UnavailableAfterRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	log, _ := logger.New("debug", "test")
+	client := NewClient(server.URL, 5*time.Second, 1, 10*time.Millisecond, log)
+
+	_, err := client.GetDelegations(context.Background(), QueryParams{Limit: 10})
+	require.Error(t, err)
+	assert.True(t, domain.IsKind(err, domain.KindUpstreamUnavailable))
+
+	_, err = client.GetCycles(context.Background(), 0, 10)
+	require.Error(t, err)
+	assert.True(t, domain.IsKind(err, domain.KindUpstreamUnavailable))
+}
+
 func TestClient_ContextCancellation(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(2 * time.Second)
@@ -207,3 +227,105 @@ func TestClient_BuildQueryParams(t *testing.T) {
 	assert.Contains(t, queryParams["select"], "id")
 	assert.Equal(t, "applied", queryParams["status"])
 }
+
+func TestClient_GetDelegationsInLevelRangeAndCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1000", r.URL.Query().Get("level.ge"))
+		assert.Equal(t, "1999", r.URL.Query().Get("level.le"))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/operations/delegations":
+			assert.Equal(t, "77", r.URL.Query().Get("id.gt"))
+			assert.Equal(t, "id", r.URL.Query().Get("sort.asc"))
+			json.NewEncoder(w).Encode([]DelegationResponse{{ID: 1, Level: 1500}})
+		case "/v1/operations/delegations/count":
+			assert.Equal(t, "applied", r.URL.Query().Get("status"))
+			w.Write([]byte("42"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	log, _ := logger.New("debug", "test")
+	client := NewClient(server.URL, 5*time.Second, 3, time.Second, log)
+
+	delegations, err := client.GetDelegationsInLevelRange(context.Background(), 1000, 1999, 77, 100)
+	require.NoError(t, err)
+	assert.Len(t, delegations, 1)
+
+	count, err := client.CountDelegations(context.Background(), 1000, 1999)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), count)
+}
+
+func TestClient_GetCycles(t *testing.T) {
+	mockResponse := []CycleResponse{
+		{
+			Index:      700,
+			FirstLevel: 5300001,
+			LastLevel:  5324576,
+			StartTime:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			EndTime:    time.Date(2024, 5, 3, 20, 0, 0, 0, time.UTC),
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/cycles", r.URL.Path)
+		assert.Equal(t, "700", r.URL.Query().Get("index.ge"))
+		assert.Equal(t, "index", r.URL.Query().Get("sort.asc"))
+		assert.Equal(t, "500", r.URL.Query().Get("limit"))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mockResponse)
+	}))
+	defer server.Close()
+
+	log, _ := logger.New("debug", "test")
+	client := NewClient(server.URL, 5*time.Second, 3, time.Second, log)
+
+	cycles, err := client.GetCycles(context.Background(), 700, 500)
+
+	require.NoError(t, err)
+	require.Len(t, cycles, 1)
+	assert.Equal(t, int64(700), cycles[0].Index)
+	assert.Equal(t, int64(5300001), cycles[0].FirstLevel)
+	assert.Equal(t, int64(5324576), cycles[0].LastLevel)
+}
+
+func TestClient_GetHeadAndReachability(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/head", r.URL.Path)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HeadResponse{Level: 5000000, Timestamp: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Synced: true})
+	}))
+	defer server.Close()
+
+	log, _ := logger.New("debug", "test")
+	client := NewClient(server.URL, 5*time.Second, 0, 10*time.Millisecond, log)
+	assert.True(t, client.Reachability().CheckedAt.IsZero())
+
+	head, err := client.GetHead(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000000), head.Level)
+
+	state := client.Reachability()
+	assert.NoError(t, state.Err)
+	assert.False(t, state.CheckedAt.IsZero())
+	assert.Equal(t, state.CheckedAt, state.LastSuccess)
+
+	healthy.Store(false)
+	_, err = client.GetHead(context.Background())
+	require.Error(t, err)
+
+	failed := client.Reachability()
+	assert.Error(t, failed.Err)
+	assert.Equal(t, state.LastSuccess, failed.LastSuccess)
+}
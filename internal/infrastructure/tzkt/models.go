@@ -26,8 +26,11 @@ type Delegate struct {
 }
 
 type QueryParams struct {
-	Limit     int
-	Offset    int
+	Limit  int
+	Offset int
+	// AfterID, when set, only returns operations with a greater ID, to page
+	// through a query sorted by ID.
+	AfterID   int64
 	Level     *LevelFilter
 	Timestamp *TimestampFilter
 	Sort      []string
@@ -53,3 +56,27 @@ type TimestampFilter struct {
 	Lt  *time.Time
 	Lte *time.Time
 }
+
+type CycleResponse struct {
+	Index      int64     `json:"index"`
+	FirstLevel int64     `json:"firstLevel"`
+	StartTime  time.Time `json:"startTime"`
+	LastLevel  int64     `json:"lastLevel"`
+	EndTime    time.Time `json:"endTime"`
+}
+
+// HeadResponse is the state of the chain head as seen by TzKT.
+type HeadResponse struct {
+	Level     int64     `json:"level"`
+	Timestamp time.Time `json:"timestamp"`
+	Synced    bool      `json:"synced"`
+}
+
+// Reachability is the outcome of the most recent request to TzKT. Err is nil
+// when that request succeeded.
+type Reachability struct {
+	CheckedAt   time.Time
+	Latency     time.Duration
+	Err         error
+	LastSuccess time.Time
+}
@@ -4,18 +4,11 @@ package integration
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
-	"os"
 	"testing"
 	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
-	_ "github.com/lib/pq"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/application"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
 	postgresRepo "github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/postgres"
@@ -45,7 +38,7 @@ func setupTestDB(t *testing.T) *TestSuite {
 		postgresContainer.WithDatabase("testdb"),
 		postgresContainer.WithUsername("testuser"),
 		postgresContainer.WithPassword("testpass"),
-		testcontainers.WithWaitStrategy(postgresContainer.Wait),
+		postgresContainer.BasicWaitStrategies(),
 	)
 	require.NoError(t, err)
 
@@ -57,26 +50,25 @@ func setupTestDB(t *testing.T) *TestSuite {
 	pool, err := pgxpool.New(ctx, connStr)
 	require.NoError(t, err)
 
-	// Run migrations
-	err = runMigrations(connStr)
-	require.NoError(t, err)
-
 	// Create logger
 	log, err := logger.New("debug", "test")
 	require.NoError(t, err)
 
+	// Run migrations
+	err = postgresRepo.RunMigrations(pool, log)
+	require.NoError(t, err)
+
 	// Create repository
 	repo := postgresRepo.NewRepository(pool, log)
 
-	// Create TzKT client (using mock for integration tests)
-	mockTzkt := &MockTzktClient{}
-
-	// Create service
+	// Create service; the tests only read from the database, so TzKT is
+	// never called
 	cfg := &config.TzktAPI{
-		URL:             "https://api.tzkt.io",
+		BaseURL:         "https://api.tzkt.io",
 		PollingInterval: 30 * time.Second,
 	}
-	service := application.NewService(repo, mockTzkt, cfg, log)
+	tzktClient := tzkt.NewClient(cfg.BaseURL, 5*time.Second, 0, time.Second, log)
+	service := application.NewService(repo, tzktClient, cfg, log)
 
 	return &TestSuite{
 		container: container,
@@ -100,68 +92,6 @@ func (s *TestSuite) Cleanup(t *testing.T) {
 	}
 }
 
-func runMigrations(connStr string) error {
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
-	if err != nil {
-		return err
-	}
-
-	migrationsPath := "file://../migrations"
-	if _, err := os.Stat("../migrations"); os.IsNotExist(err) {
-		// Try alternative path
-		migrationsPath = "file://./migrations"
-	}
-
-	m, err := migrate.NewWithDatabaseInstance(
-		migrationsPath,
-		"postgres", driver)
-	if err != nil {
-		// Create tables manually if migrations not found
-		return createTablesManually(db)
-	}
-
-	return m.Up()
-}
-
-func createTablesManually(db *sql.DB) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS delegations (
-			id UUID PRIMARY KEY,
-			timestamp TIMESTAMP NOT NULL,
-			amount VARCHAR(255) NOT NULL,
-			delegator VARCHAR(255) NOT NULL,
-			level VARCHAR(255) NOT NULL,
-			block_hash VARCHAR(255) NOT NULL,
-			operation_hash VARCHAR(255) UNIQUE,
-			created_at TIMESTAMP DEFAULT NOW()
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_delegations_timestamp ON delegations(timestamp)`,
-		`CREATE INDEX IF NOT EXISTS idx_delegations_delegator ON delegations(delegator)`,
-		`CREATE INDEX IF NOT EXISTS idx_delegations_level ON delegations(level)`,
-		`CREATE TABLE IF NOT EXISTS indexing_metadata (
-			id INTEGER PRIMARY KEY,
-			last_indexed_level BIGINT DEFAULT 0,
-			last_indexed_timestamp TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT NOW()
-		)`,
-		`INSERT INTO indexing_metadata (id) VALUES (1) ON CONFLICT DO NOTHING`,
-	}
-
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query: %w", err)
-		}
-	}
-
-	return nil
-}
-
 // Integration Tests
 
 func TestIntegration_SaveAndRetrieveDelegation(t *testing.T) {
@@ -185,11 +115,11 @@ func TestIntegration_SaveAndRetrieveDelegation(t *testing.T) {
 	}
 
 	// Save delegation
-	err := suite.repo.Save(delegation)
+	err := suite.repo.Save(context.Background(), delegation)
 	require.NoError(t, err)
 
 	// Retrieve delegations
-	delegations, err := suite.repo.FindAll(nil)
+	delegations, err := suite.repo.FindAll(context.Background(), nil)
 	require.NoError(t, err)
 	assert.Len(t, delegations, 1)
 	assert.Equal(t, delegation.Delegator, delegations[0].Delegator)
@@ -236,11 +166,12 @@ func TestIntegration_SaveBatchDelegations(t *testing.T) {
 	}
 
 	// Save batch
-	err := suite.repo.SaveBatch(delegations)
+	created, err := suite.repo.SaveBatch(context.Background(), delegations)
 	require.NoError(t, err)
+	assert.Len(t, created, len(delegations))
 
 	// Retrieve all
-	retrieved, err := suite.repo.FindAll(nil)
+	retrieved, err := suite.repo.FindAll(context.Background(), nil)
 	require.NoError(t, err)
 	assert.Len(t, retrieved, 3)
 }
@@ -254,7 +185,7 @@ func TestIntegration_GetLastIndexedLevel(t *testing.T) {
 	defer suite.Cleanup(t)
 
 	// Initially should be 0
-	level, err := suite.repo.GetLastIndexedLevel()
+	level, err := suite.repo.GetLastIndexedLevel(context.Background())
 	require.NoError(t, err)
 	assert.Equal(t, int64(0), level)
 
@@ -280,11 +211,12 @@ func TestIntegration_GetLastIndexedLevel(t *testing.T) {
 		},
 	}
 
-	err = suite.repo.SaveBatch(delegations)
+	created, err := suite.repo.SaveBatch(context.Background(), delegations)
 	require.NoError(t, err)
+	assert.Len(t, created, len(delegations))
 
 	// Should return highest level
-	level, err = suite.repo.GetLastIndexedLevel()
+	level, err = suite.repo.GetLastIndexedLevel(context.Background())
 	require.NoError(t, err)
 	assert.Equal(t, int64(2338090), level)
 }
@@ -330,14 +262,15 @@ func TestIntegration_GetDelegationsByTimeRange(t *testing.T) {
 		},
 	}
 
-	err := suite.repo.SaveBatch(delegations)
+	created, err := suite.repo.SaveBatch(context.Background(), delegations)
 	require.NoError(t, err)
+	assert.Len(t, created, len(delegations))
 
 	// Query for last 36 hours
 	start := now.Add(-36 * time.Hour)
 	end := now
 	
-	retrieved, err := suite.repo.GetDelegationsByTimeRange(start, end)
+	retrieved, err := suite.repo.GetDelegationsByTimeRange(context.Background(), start, end)
 	require.NoError(t, err)
 	assert.Len(t, retrieved, 2) // Should only get the last 2 delegations
 }
@@ -381,10 +314,11 @@ func TestIntegration_GetStats(t *testing.T) {
 		},
 	}
 
-	err := suite.repo.SaveBatch(delegations)
+	created, err := suite.repo.SaveBatch(context.Background(), delegations)
 	require.NoError(t, err)
+	assert.Len(t, created, len(delegations))
 
-	stats, err := suite.repo.GetStats()
+	stats, err := suite.repo.GetStats(context.Background())
 	require.NoError(t, err)
 	
 	assert.Equal(t, int64(3), stats["total_delegations"])
@@ -423,41 +357,19 @@ func TestIntegration_ServiceGetDelegations(t *testing.T) {
 		},
 	}
 
-	err := suite.repo.SaveBatch(delegations)
+	created, err := suite.repo.SaveBatch(context.Background(), delegations)
 	require.NoError(t, err)
+	assert.Len(t, created, len(delegations))
 
 	// Test GetDelegations without year filter
-	allDelegations, err := suite.service.GetDelegations(nil)
+	allDelegations, err := suite.service.GetDelegations(context.Background(), nil)
 	require.NoError(t, err)
 	assert.Len(t, allDelegations, 2)
 
 	// Test GetDelegations with year filter
 	year := 2023
-	yearDelegations, err := suite.service.GetDelegations(&year)
+	yearDelegations, err := suite.service.GetDelegations(context.Background(), &year)
 	require.NoError(t, err)
 	assert.Len(t, yearDelegations, 1)
 	assert.Equal(t, 2023, yearDelegations[0].Timestamp.Year())
 }
-
-// Mock TzKT client for integration tests
-type MockTzktClient struct{}
-
-func (m *MockTzktClient) GetDelegations(ctx context.Context, params tzkt.QueryParams) ([]tzkt.DelegationResponse, error) {
-	return []tzkt.DelegationResponse{}, nil
-}
-
-func (m *MockTzktClient) GetDelegationsSince(ctx context.Context, timestamp time.Time, limit int) ([]tzkt.DelegationResponse, error) {
-	return []tzkt.DelegationResponse{}, nil
-}
-
-func (m *MockTzktClient) GetDelegationsFromLevel(ctx context.Context, level int64, limit int) ([]tzkt.DelegationResponse, error) {
-	return []tzkt.DelegationResponse{}, nil
-}
-
-func (m *MockTzktClient) GetHistoricalDelegations(ctx context.Context, startDate time.Time, batchSize int) (<-chan []tzkt.DelegationResponse, <-chan error) {
-	dataChan := make(chan []tzkt.DelegationResponse)
-	errChan := make(chan error)
-	close(dataChan)
-	close(errChan)
-	return dataChan, errChan
-}
\ No newline at end of file
@@ -0,0 +1,101 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// queryComplexity estimates the cost of an operation before executing it.
+// Every field costs 1, and the selections under a field taking a first
+// argument are counted once per requested item, so nested lists multiply.
+func queryComplexity(query, operationName string, variables map[string]interface{}) (int, error) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return 0, err
+	}
+
+	var op *ast.OperationDefinition
+	switch {
+	case operationName != "":
+		op = doc.Operations.ForName(operationName)
+	case len(doc.Operations) == 1:
+		op = doc.Operations[0]
+	}
+	if op == nil {
+		// Left for the executor to report.
+		return 0, nil
+	}
+
+	c := complexityWalker{doc: doc, variables: variables, visiting: make(map[string]bool)}
+	return c.selectionSet(op.SelectionSet)
+}
+
+type complexityWalker struct {
+	doc       *ast.QueryDocument
+	variables map[string]interface{}
+	visiting  map[string]bool
+}
+
+func (c *complexityWalker) selectionSet(set ast.SelectionSet) (int, error) {
+	total := 0
+	for _, selection := range set {
+		var cost int
+		var err error
+
+		switch s := selection.(type) {
+		case *ast.Field:
+			cost, err = c.selectionSet(s.SelectionSet)
+			cost = 1 + c.multiplier(s)*cost
+		case *ast.InlineFragment:
+			cost, err = c.selectionSet(s.SelectionSet)
+		case *ast.FragmentSpread:
+			fragment := c.doc.Fragments.ForName(s.Name)
+			if fragment == nil {
+				continue
+			}
+			if c.visiting[s.Name] {
+				return 0, fmt.Errorf("fragment %s spreads itself", s.Name)
+			}
+			c.visiting[s.Name] = true
+			cost, err = c.selectionSet(fragment.SelectionSet)
+			c.visiting[s.Name] = false
+		}
+
+		if err != nil {
+			return 0, err
+		}
+		total += cost
+	}
+	return total, nil
+}
+
+// multiplier is the number of items a list field may return.
+func (c *complexityWalker) multiplier(field *ast.Field) int {
+	arg := field.Arguments.ForName("first")
+	if arg == nil {
+		if hasPagination(field.Name) {
+			return defaultFirst
+		}
+		return 1
+	}
+
+	n := defaultFirst
+	switch arg.Value.Kind {
+	case ast.IntValue:
+		if v, err := strconv.Atoi(arg.Value.Raw); err == nil {
+			n = v
+		}
+	case ast.Variable:
+		if v, ok := c.variables[arg.Value.Raw].(float64); ok {
+			n = int(v)
+		}
+	}
+	return min(max(n, 1), maxFirst)
+}
+
+func hasPagination(fieldName string) bool {
+	return fieldName == "delegations" || fieldName == "delegators"
+}
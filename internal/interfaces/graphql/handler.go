@@ -0,0 +1,144 @@
+// Package graphql serves the delegation data over GraphQL.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const maxRequestBytes = 1 << 20
+
+// Service is what the GraphQL resolvers need from the application service.
+type Service interface {
+	ListDelegationsPage(ctx context.Context, query domain.DelegationPageQuery) (*domain.DelegationPage, error)
+	GetDelegatorStates(ctx context.Context, delegators []string) ([]domain.DelegatorState, error)
+	GetBakerSummaries(ctx context.Context, bakers []string) ([]domain.BakerSummary, error)
+	GetBakerDelegators(ctx context.Context, query domain.BakerDelegatorsQuery) (*domain.BakerDelegators, error)
+	GetStats(ctx context.Context) (map[string]interface{}, error)
+}
+
+// Limits bound the work a single query may cause.
+type Limits struct {
+	// MaxComplexity is the highest accepted query cost, see queryComplexity.
+	MaxComplexity int
+	MaxDepth      int
+}
+
+var DefaultLimits = Limits{
+	MaxComplexity: 5000,
+	MaxDepth:      10,
+}
+
+type Handler struct {
+	service Service
+	schema  *graphqlgo.Schema
+	limits  Limits
+	logger  *logger.Logger
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type errorResponse struct {
+	Errors []errorMessage `json:"errors"`
+}
+
+type errorMessage struct {
+	Message string `json:"message"`
+}
+
+// NewHandler returns a handler answering GraphQL queries sent as JSON POST
+// bodies or as GET query parameters. It responds 501 when service lacks the
+// queries GraphQL relies on.
+func NewHandler(service domain.DelegationService, logger *logger.Logger, limits Limits) *Handler {
+	h := &Handler{limits: limits, logger: logger}
+
+	svc, ok := service.(Service)
+	if !ok {
+		return h
+	}
+	h.service = svc
+	h.schema = graphqlgo.MustParseSchema(schema, &resolver{service: svc, logger: logger},
+		graphqlgo.UseFieldResolvers(),
+		graphqlgo.MaxDepth(limits.MaxDepth),
+		graphqlgo.MaxParallelism(50),
+	)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.schema == nil {
+		writeJSON(w, http.StatusNotImplemented, map[string]string{"error": "GraphQL not available"})
+		return
+	}
+
+	req, err := parseRequest(r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	complexity, err := queryComplexity(req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if complexity > h.limits.MaxComplexity {
+		writeErrors(w, http.StatusBadRequest, fmt.Sprintf("query complexity %d exceeds the limit of %d", complexity, h.limits.MaxComplexity))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders(r.Context(), h.service, h.logger))
+	response := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	for _, e := range response.Errors {
+		h.logger.Debugw("GraphQL query error", "error", e.Message, "path", e.Path)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func parseRequest(r *http.Request) (*request, error) {
+	var req request
+
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if variables := q.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return nil, fmt.Errorf("variables must be a JSON object")
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+			return nil, fmt.Errorf("request body must be a JSON object with a query")
+		}
+	default:
+		return nil, fmt.Errorf("method %s not allowed", r.Method)
+	}
+
+	if req.Query == "" {
+		return nil, fmt.Errorf("missing query")
+	}
+	return &req, nil
+}
+
+func writeErrors(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Errors: []errorMessage{{Message: message}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
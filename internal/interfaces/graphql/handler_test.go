@@ -0,0 +1,324 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) GetDelegations(ctx context.Context, year *int) ([]domain.Delegation, error) {
+	args := m.Called(year)
+	return args.Get(0).([]domain.Delegation), args.Error(1)
+}
+
+func (m *MockService) IndexDelegations(ctx context.Context, fromLevel int64) error {
+	return m.Called(fromLevel).Error(0)
+}
+
+func (m *MockService) StartPolling() error {
+	return m.Called().Error(0)
+}
+
+func (m *MockService) StopPolling() {
+	m.Called()
+}
+
+func (m *MockService) ListDelegationsPage(ctx context.Context, query domain.DelegationPageQuery) (*domain.DelegationPage, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DelegationPage), args.Error(1)
+}
+
+func (m *MockService) GetDelegatorStates(ctx context.Context, delegators []string) ([]domain.DelegatorState, error) {
+	args := m.Called(delegators)
+	return args.Get(0).([]domain.DelegatorState), args.Error(1)
+}
+
+func (m *MockService) GetBakerSummaries(ctx context.Context, bakers []string) ([]domain.BakerSummary, error) {
+	args := m.Called(bakers)
+	return args.Get(0).([]domain.BakerSummary), args.Error(1)
+}
+
+func (m *MockService) GetBakerDelegators(ctx context.Context, query domain.BakerDelegatorsQuery) (*domain.BakerDelegators, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BakerDelegators), args.Error(1)
+}
+
+func (m *MockService) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	args := m.Called()
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
+
+// unorderedKeys matches a batch of keys regardless of the order in which
+// concurrently resolved fields requested them.
+func unorderedKeys(want ...string) interface{} {
+	sort.Strings(want)
+	return mock.MatchedBy(func(keys []string) bool {
+		got := append([]string(nil), keys...)
+		sort.Strings(got)
+		return strings.Join(got, ",") == strings.Join(want, ",")
+	})
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func execute(t *testing.T, service domain.DelegationService, query string, variables map[string]interface{}) (int, graphqlResponse) {
+	t.Helper()
+	return executeAs(t, service, nil, query, variables)
+}
+
+// executeAs runs query as principal, as authenticated by the HTTP auth
+// middleware; a nil principal is anonymous.
+func executeAs(t *testing.T, service domain.DelegationService, principal *domain.Principal, query string, variables map[string]interface{}) (int, graphqlResponse) {
+	t.Helper()
+
+	log, _ := logger.New("debug", "test")
+	handler := NewHandler(service, log, DefaultLimits)
+
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if principal != nil {
+		req = req.WithContext(domain.ContextWithPrincipal(req.Context(), principal))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var resp graphqlResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return w.Code, resp
+}
+
+func TestHandler_DelegationsConnection(t *testing.T) {
+	service := new(MockService)
+	cycle := int64(700)
+	year := 2024
+	before := domain.DelegationCursor{Level: 5000002, Seq: 50}
+
+	service.On("ListDelegationsPage", domain.DelegationPageQuery{
+		Filter: domain.DelegationFilter{Year: &year, Baker: "tz1baker"},
+		Before: &before,
+		Limit:  2,
+	}).Return(&domain.DelegationPage{
+		Data: []domain.Delegation{
+			{ID: "d1", OperationHash: "op1", Timestamp: time.Date(2024, 5, 5, 6, 29, 14, 0, time.UTC), Amount: "100", Delegator: "tz1one", Baker: "tz1baker", Level: "5000001", Cycle: &cycle, Seq: 42},
+			{ID: "d2", OperationHash: "op2", Timestamp: time.Date(2024, 5, 5, 6, 28, 0, 0, time.UTC), Amount: "200", Delegator: "tz1two", Baker: "tz1baker", Level: "5000000", Seq: 41},
+		},
+		HasMore: true,
+	}, nil)
+
+	// Both delegators' states and the baker summary are fetched in one batch each.
+	service.On("GetDelegatorStates", unorderedKeys("tz1one", "tz1two")).Return([]domain.DelegatorState{
+		{Delegator: "tz1one", Baker: "tz1baker", SinceLevel: 5000001, LastAmount: "100"},
+		{Delegator: "tz1two", Baker: "tz1baker", SinceLevel: 5000000, LastAmount: "200"},
+	}, nil).Once()
+	service.On("GetBakerSummaries", []string{"tz1baker"}).Return([]domain.BakerSummary{
+		{Baker: "tz1baker", DelegatorCount: 2, TotalAmount: "300"},
+	}, nil).Once()
+
+	code, resp := execute(t, service, `
+		query($after: String) {
+			delegations(first: 2, after: $after, filter: {year: 2024, baker: "tz1baker"}) {
+				edges {
+					cursor
+					node {
+						operationHash
+						level
+						cycle
+						delegator { address sinceLevel baker { address delegatorCount } }
+					}
+				}
+				pageInfo { hasNextPage endCursor }
+			}
+		}
+	`, map[string]interface{}{"after": encodeCursor(before)})
+
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{
+		"delegations": {
+			"edges": [
+				{"cursor": "`+encodeCursor(domain.DelegationCursor{Level: 5000001, Seq: 42})+`", "node": {"operationHash": "op1", "level": 5000001, "cycle": 700,
+					"delegator": {"address": "tz1one", "sinceLevel": 5000001, "baker": {"address": "tz1baker", "delegatorCount": 2}}}},
+				{"cursor": "`+encodeCursor(domain.DelegationCursor{Level: 5000000, Seq: 41})+`", "node": {"operationHash": "op2", "level": 5000000, "cycle": null,
+					"delegator": {"address": "tz1two", "sinceLevel": 5000000, "baker": {"address": "tz1baker", "delegatorCount": 2}}}}
+			],
+			"pageInfo": {"hasNextPage": true, "endCursor": "`+encodeCursor(domain.DelegationCursor{Level: 5000000, Seq: 41})+`"}
+		}
+	}`, string(resp.Data))
+
+	service.AssertExpectations(t)
+}
+
+func TestHandler_BakerDelegatorsPrimeStates(t *testing.T) {
+	service := new(MockService)
+	service.On("GetBakerDelegators", domain.BakerDelegatorsQuery{Baker: "tz1baker", Limit: 5}).Return(&domain.BakerDelegators{
+		Baker: "tz1baker",
+		Data: []domain.DelegatorState{
+			{Delegator: "tz1one", Baker: "tz1baker", LastAmount: "100"},
+		},
+	}, nil)
+
+	code, resp := execute(t, service, `{ baker(address: "tz1baker") { delegators(first: 5) { address lastAmount } } }`, nil)
+
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"baker": {"delegators": [{"address": "tz1one", "lastAmount": "100"}]}}`, string(resp.Data))
+	service.AssertNotCalled(t, "GetDelegatorStates", mock.Anything)
+}
+
+func TestHandler_UnknownDelegator(t *testing.T) {
+	service := new(MockService)
+	service.On("GetDelegatorStates", []string{"tz1nobody"}).Return([]domain.DelegatorState{}, nil)
+
+	code, resp := execute(t, service, `{ delegator(address: "tz1nobody") { address } }`, nil)
+
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"delegator": null}`, string(resp.Data))
+}
+
+func TestHandler_InvalidCursor(t *testing.T) {
+	service := new(MockService)
+
+	code, resp := execute(t, service, `{ delegations(after: "bogus") { pageInfo { hasNextPage } } }`, nil)
+
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "invalid cursor")
+	service.AssertNotCalled(t, "ListDelegationsPage", mock.Anything)
+}
+
+func TestHandler_HidesStorageErrors(t *testing.T) {
+	service := new(MockService)
+	service.On("ListDelegationsPage", domain.DelegationPageQuery{Limit: defaultFirst}).
+		Return(nil, fmt.Errorf("pq: relation \"delegations\" does not exist"))
+
+	code, resp := execute(t, service, `{ delegations { pageInfo { hasNextPage } } }`, nil)
+
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "failed to retrieve delegations", resp.Errors[0].Message)
+}
+
+func TestHandler_RejectsComplexQuery(t *testing.T) {
+	service := new(MockService)
+
+	code, resp := execute(t, service, `{
+		delegations(first: 100) {
+			edges { node { delegator { delegations(first: 100) { edges { node { id amount } } } } } }
+		}
+	}`, nil)
+
+	assert.Equal(t, http.StatusBadRequest, code)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "exceeds the limit")
+	service.AssertNotCalled(t, "ListDelegationsPage", mock.Anything)
+}
+
+func TestHandler_Stats(t *testing.T) {
+	service := new(MockService)
+	latest := time.Date(2024, 5, 5, 6, 29, 14, 0, time.UTC)
+	service.On("GetStats").Return(map[string]interface{}{
+		"total_delegations": 3,
+		"unique_delegators": 2,
+		"total_amount":      "600",
+		"latest_delegation": latest,
+	}, nil)
+
+	code, resp := execute(t, service, `{ stats { totalDelegations uniqueDelegators totalAmount latestDelegation oldestDelegation } }`, nil)
+
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"stats": {"totalDelegations": 3, "uniqueDelegators": 2, "totalAmount": "600",
+		"latestDelegation": "2024-05-05T06:29:14Z", "oldestDelegation": null}}`, string(resp.Data))
+}
+
+func TestHandler_StatsRequireScope(t *testing.T) {
+	service := new(MockService)
+	service.On("GetStats").Return(map[string]interface{}{"total_delegations": 3}, nil)
+	query := `{ stats { totalDelegations } }`
+
+	reader := &domain.Principal{Subject: "api_key:reader", Scopes: []domain.Scope{domain.ScopeReadDelegations}}
+	code, resp := executeAs(t, service, reader, query, nil)
+
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, domain.ErrInsufficientScope.Message, resp.Errors[0].Message)
+	assert.Equal(t, "insufficient_scope", resp.Errors[0].Extensions["code"])
+	service.AssertNotCalled(t, "GetStats")
+
+	statsReader := &domain.Principal{Subject: "api_key:stats", Scopes: []domain.Scope{domain.ScopeReadDelegations, domain.ScopeReadStats}}
+	_, resp = executeAs(t, service, statsReader, query, nil)
+
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"stats": {"totalDelegations": 3}}`, string(resp.Data))
+}
+
+func TestHandler_NotAvailable(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+	var service domain.DelegationService = &struct{ domain.DelegationService }{}
+	handler := NewHandler(service, log, DefaultLimits)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ stats { totalAmount } }"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestQueryComplexity(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      int
+	}{
+		{"scalar fields", `{ stats { totalDelegations totalAmount } }`, nil, 3},
+		{"default page size", `{ delegations { edges { node { id } } } }`, nil, 1 + 20*3},
+		{"explicit first", `{ delegations(first: 5) { edges { node { id } } } }`, nil, 1 + 5*3},
+		{"first from variable", `query($n: Int) { delegations(first: $n) { edges { cursor } } }`, map[string]interface{}{"n": float64(10)}, 1 + 10*2},
+		{"first is capped", `{ delegations(first: 100000) { edges { cursor } } }`, nil, 1 + maxFirst*2},
+		{"fragments", `{ delegations(first: 2) { ...Edges } } fragment Edges on DelegationConnection { edges { cursor } }`, nil, 1 + 2*2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := queryComplexity(tt.query, "", tt.variables)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := queryComplexity(`{ delegations { ...A } } fragment A on DelegationConnection { ...A }`, "", nil)
+	assert.Error(t, err)
+}
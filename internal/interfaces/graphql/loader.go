@@ -0,0 +1,96 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	loaderWait     = 2 * time.Millisecond
+	loaderMaxBatch = 500
+)
+
+// batchLoader coalesces the keys requested by concurrently resolving fields
+// into a single fetch, and caches results for the lifetime of one request.
+// A key missing from the fetched map is reported as not found.
+type batchLoader[K comparable, V any] struct {
+	fetch func(keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	cache   map[K]*loaderResult[V]
+	pending []K
+	results []*loaderResult[V]
+}
+
+type loaderResult[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+func newBatchLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *batchLoader[K, V] {
+	return &batchLoader[K, V]{
+		fetch: fetch,
+		cache: make(map[K]*loaderResult[V]),
+	}
+}
+
+func (l *batchLoader[K, V]) Load(ctx context.Context, key K) (V, bool, error) {
+	l.mu.Lock()
+	result, ok := l.cache[key]
+	if !ok {
+		result = &loaderResult[V]{done: make(chan struct{})}
+		l.cache[key] = result
+		l.pending = append(l.pending, key)
+		l.results = append(l.results, result)
+
+		switch len(l.pending) {
+		case 1:
+			time.AfterFunc(loaderWait, l.dispatch)
+		case loaderMaxBatch:
+			go l.dispatch()
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-result.done:
+		return result.value, result.found, result.err
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+}
+
+// Prime caches a value obtained by other means, such as a list query.
+func (l *batchLoader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+	result := &loaderResult[V]{done: make(chan struct{}), value: value, found: true}
+	close(result.done)
+	l.cache[key] = result
+}
+
+func (l *batchLoader[K, V]) dispatch() {
+	l.mu.Lock()
+	keys, results := l.pending, l.results
+	l.pending, l.results = nil, nil
+	l.mu.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+
+	values, err := l.fetch(keys)
+	for i, key := range keys {
+		results[i].value, results[i].found = values[key]
+		results[i].err = err
+		close(results[i].done)
+	}
+}
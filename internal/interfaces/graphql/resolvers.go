@@ -0,0 +1,471 @@
+package graphql
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const (
+	defaultFirst = 20
+	maxFirst     = 100
+	cursorPrefix = "delegation:"
+)
+
+type loaders struct {
+	delegators *batchLoader[string, domain.DelegatorState]
+	bakers     *batchLoader[string, domain.BakerSummary]
+}
+
+type loadersKey struct{}
+
+// newLoaders returns the loaders of one request. Batches are fetched with
+// the request context, so they stop when the request is cancelled.
+func newLoaders(ctx context.Context, service Service, log *logger.Logger) *loaders {
+	return &loaders{
+		delegators: newBatchLoader(func(addresses []string) (map[string]domain.DelegatorState, error) {
+			states, err := service.GetDelegatorStates(ctx, addresses)
+			if err != nil {
+				return nil, queryError(log, err, "failed to retrieve delegators")
+			}
+			byAddress := make(map[string]domain.DelegatorState, len(states))
+			for _, s := range states {
+				byAddress[s.Delegator] = s
+			}
+			return byAddress, nil
+		}),
+		bakers: newBatchLoader(func(addresses []string) (map[string]domain.BakerSummary, error) {
+			summaries, err := service.GetBakerSummaries(ctx, addresses)
+			if err != nil {
+				return nil, queryError(log, err, "failed to retrieve bakers")
+			}
+			byAddress := make(map[string]domain.BakerSummary, len(summaries))
+			for _, s := range summaries {
+				byAddress[s.Baker] = s
+			}
+			return byAddress, nil
+		}),
+	}
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// queryError logs err and returns what the client may see of it: the message
+// of a domain error, or message for anything else, so storage details stay in
+// the logs.
+func queryError(log *logger.Logger, err error, message string) error {
+	if domainErr, ok := domain.AsError(err); ok {
+		message = domainErr.Message
+	}
+	log.Errorw(message, "error", err)
+	return errors.New(message)
+}
+
+// authError is a domain authentication or authorization error, reported
+// with its code in the error extensions.
+type authError struct {
+	err *domain.Error
+}
+
+func (e authError) Error() string {
+	return e.err.Message
+}
+
+func (e authError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.err.Code}
+}
+
+// requireScope rejects authenticated principals lacking scope. The route
+// scope of /graphql only grants reading delegations; anonymous requests got
+// through only where the route allows them.
+func requireScope(ctx context.Context, scope domain.Scope) error {
+	if p := domain.PrincipalFromContext(ctx); p != nil && !p.HasScope(scope) {
+		return authError{err: domain.ErrInsufficientScope}
+	}
+	return nil
+}
+
+type resolver struct {
+	service Service
+	logger  *logger.Logger
+}
+
+type pageArgs struct {
+	First *int32
+	After *string
+}
+
+type delegationFilterInput struct {
+	Year      *int32
+	Cycle     *int32
+	Baker     *string
+	Delegator *string
+}
+
+func (r *resolver) Delegations(ctx context.Context, args struct {
+	First  *int32
+	After  *string
+	Filter *delegationFilterInput
+}) (*connectionResolver, error) {
+	var filter domain.DelegationFilter
+	if f := args.Filter; f != nil {
+		if f.Year != nil {
+			year := int(*f.Year)
+			filter.Year = &year
+		}
+		if f.Cycle != nil {
+			cycle := int64(*f.Cycle)
+			filter.Cycle = &cycle
+		}
+		if f.Baker != nil {
+			filter.Baker = *f.Baker
+		}
+		if f.Delegator != nil {
+			filter.Delegator = *f.Delegator
+		}
+	}
+	return r.connection(ctx, filter, pageArgs{First: args.First, After: args.After})
+}
+
+func (r *resolver) Delegator(ctx context.Context, args struct{ Address string }) (*delegatorResolver, error) {
+	_, found, err := loadersFrom(ctx).delegators.Load(ctx, args.Address)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return &delegatorResolver{root: r, address: args.Address}, nil
+}
+
+func (r *resolver) Baker(args struct{ Address string }) *bakerResolver {
+	return &bakerResolver{root: r, address: args.Address}
+}
+
+func (r *resolver) Stats(ctx context.Context) (*statsResolver, error) {
+	if err := requireScope(ctx, domain.ScopeReadStats); err != nil {
+		return nil, err
+	}
+	stats, err := r.service.GetStats(ctx)
+	if err != nil {
+		return nil, queryError(r.logger, err, "failed to retrieve statistics")
+	}
+	return &statsResolver{stats: stats}, nil
+}
+
+func (r *resolver) connection(ctx context.Context, filter domain.DelegationFilter, args pageArgs) (*connectionResolver, error) {
+	query := domain.DelegationPageQuery{Filter: filter, Limit: defaultFirst}
+	if args.First != nil {
+		if *args.First <= 0 {
+			return nil, errors.New("first must be a positive integer")
+		}
+		query.Limit = int(*args.First)
+	}
+	if args.After != nil {
+		cursor, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		query.Before = &cursor
+	}
+
+	page, err := r.service.ListDelegationsPage(ctx, query)
+	if err != nil {
+		return nil, queryError(r.logger, err, "failed to retrieve delegations")
+	}
+	return &connectionResolver{root: r, page: page}, nil
+}
+
+func encodeCursor(cursor domain.DelegationCursor) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + cursor.String()))
+}
+
+func decodeCursor(cursor string) (domain.DelegationCursor, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(raw), cursorPrefix) {
+		if c, err := domain.ParseDelegationCursor(strings.TrimPrefix(string(raw), cursorPrefix)); err == nil {
+			return c, nil
+		}
+	}
+	return domain.DelegationCursor{}, fmt.Errorf("invalid cursor %q", cursor)
+}
+
+type connectionResolver struct {
+	root *resolver
+	page *domain.DelegationPage
+}
+
+func (c *connectionResolver) Edges() []*edgeResolver {
+	edges := make([]*edgeResolver, len(c.page.Data))
+	for i := range c.page.Data {
+		edges[i] = &edgeResolver{node: &delegationResolver{root: c.root, d: c.page.Data[i]}}
+	}
+	return edges
+}
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNextPage: c.page.HasMore}
+	if n := len(c.page.Data); n > 0 {
+		cursor := encodeCursor(domain.CursorOf(c.page.Data[n-1]))
+		info.endCursor = &cursor
+	}
+	return info
+}
+
+type edgeResolver struct {
+	node *delegationResolver
+}
+
+func (e *edgeResolver) Cursor() string {
+	return encodeCursor(domain.CursorOf(e.node.d))
+}
+
+func (e *edgeResolver) Node() *delegationResolver {
+	return e.node
+}
+
+type pageInfoResolver struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (p *pageInfoResolver) HasNextPage() bool {
+	return p.hasNextPage
+}
+
+func (p *pageInfoResolver) EndCursor() *string {
+	return p.endCursor
+}
+
+type delegationResolver struct {
+	root *resolver
+	d    domain.Delegation
+}
+
+func (d *delegationResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(d.d.ID)
+}
+
+func (d *delegationResolver) OperationHash() string {
+	return d.d.OperationHash
+}
+
+func (d *delegationResolver) Timestamp() graphqlgo.Time {
+	return graphqlgo.Time{Time: d.d.Timestamp}
+}
+
+func (d *delegationResolver) Amount() string {
+	return d.d.Amount
+}
+
+func (d *delegationResolver) Level() (int32, error) {
+	level, err := strconv.ParseInt(d.d.Level, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid level %q", d.d.Level)
+	}
+	return int32(level), nil
+}
+
+func (d *delegationResolver) BlockHash() string {
+	return d.d.BlockHash
+}
+
+func (d *delegationResolver) Cycle() *int32 {
+	if d.d.Cycle == nil {
+		return nil
+	}
+	cycle := int32(*d.d.Cycle)
+	return &cycle
+}
+
+func (d *delegationResolver) Delegator() *delegatorResolver {
+	return &delegatorResolver{root: d.root, address: d.d.Delegator}
+}
+
+func (d *delegationResolver) Baker() *bakerResolver {
+	return d.root.bakerOrNil(d.d.Baker)
+}
+
+func (d *delegationResolver) PrevBaker() *bakerResolver {
+	return d.root.bakerOrNil(d.d.PrevBaker)
+}
+
+func (r *resolver) bakerOrNil(address string) *bakerResolver {
+	if address == "" {
+		return nil
+	}
+	return &bakerResolver{root: r, address: address}
+}
+
+// delegatorResolver loads the account's current state only when one of its
+// fields is selected, batched across the whole request.
+type delegatorResolver struct {
+	root    *resolver
+	address string
+}
+
+func (d *delegatorResolver) state(ctx context.Context) (*domain.DelegatorState, error) {
+	state, found, err := loadersFrom(ctx).delegators.Load(ctx, d.address)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (d *delegatorResolver) Address() string {
+	return d.address
+}
+
+func (d *delegatorResolver) Baker(ctx context.Context) (*bakerResolver, error) {
+	state, err := d.state(ctx)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	return d.root.bakerOrNil(state.Baker), nil
+}
+
+func (d *delegatorResolver) SinceLevel(ctx context.Context) (*int32, error) {
+	state, err := d.state(ctx)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	level := int32(state.SinceLevel)
+	return &level, nil
+}
+
+func (d *delegatorResolver) SinceTimestamp(ctx context.Context) (*graphqlgo.Time, error) {
+	state, err := d.state(ctx)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	return &graphqlgo.Time{Time: state.SinceTimestamp}, nil
+}
+
+func (d *delegatorResolver) LastAmount(ctx context.Context) (*string, error) {
+	state, err := d.state(ctx)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	return &state.LastAmount, nil
+}
+
+func (d *delegatorResolver) Delegations(ctx context.Context, args pageArgs) (*connectionResolver, error) {
+	return d.root.connection(ctx, domain.DelegationFilter{Delegator: d.address}, args)
+}
+
+type bakerResolver struct {
+	root    *resolver
+	address string
+}
+
+func (b *bakerResolver) summary(ctx context.Context) (domain.BakerSummary, error) {
+	summary, found, err := loadersFrom(ctx).bakers.Load(ctx, b.address)
+	if err != nil {
+		return domain.BakerSummary{}, err
+	}
+	if !found {
+		return domain.BakerSummary{Baker: b.address, TotalAmount: "0"}, nil
+	}
+	return summary, nil
+}
+
+func (b *bakerResolver) Address() string {
+	return b.address
+}
+
+func (b *bakerResolver) DelegatorCount(ctx context.Context) (int32, error) {
+	summary, err := b.summary(ctx)
+	return int32(summary.DelegatorCount), err
+}
+
+func (b *bakerResolver) TotalAmount(ctx context.Context) (string, error) {
+	summary, err := b.summary(ctx)
+	return summary.TotalAmount, err
+}
+
+func (b *bakerResolver) Delegators(ctx context.Context, args struct {
+	First  *int32
+	Offset *int32
+}) ([]*delegatorResolver, error) {
+	query := domain.BakerDelegatorsQuery{Baker: b.address, Limit: defaultFirst}
+	if args.First != nil {
+		query.Limit = min(int(*args.First), maxFirst)
+	}
+	if args.Offset != nil {
+		query.Offset = int(*args.Offset)
+	}
+	if query.Limit <= 0 || query.Offset < 0 {
+		return nil, errors.New("first must be positive and offset non-negative")
+	}
+
+	result, err := b.root.service.GetBakerDelegators(ctx, query)
+	if err != nil {
+		return nil, queryError(b.root.logger, err, "failed to retrieve baker delegators")
+	}
+
+	delegators := loadersFrom(ctx).delegators
+	resolvers := make([]*delegatorResolver, len(result.Data))
+	for i, state := range result.Data {
+		delegators.Prime(state.Delegator, state)
+		resolvers[i] = &delegatorResolver{root: b.root, address: state.Delegator}
+	}
+	return resolvers, nil
+}
+
+func (b *bakerResolver) Delegations(ctx context.Context, args pageArgs) (*connectionResolver, error) {
+	return b.root.connection(ctx, domain.DelegationFilter{Baker: b.address}, args)
+}
+
+type statsResolver struct {
+	stats map[string]interface{}
+}
+
+func (s *statsResolver) TotalDelegations() int32 {
+	return int32(statInt(s.stats["total_delegations"]))
+}
+
+func (s *statsResolver) UniqueDelegators() int32 {
+	return int32(statInt(s.stats["unique_delegators"]))
+}
+
+func (s *statsResolver) TotalAmount() string {
+	if amount, ok := s.stats["total_amount"].(string); ok {
+		return amount
+	}
+	return "0"
+}
+
+func (s *statsResolver) LatestDelegation() *graphqlgo.Time {
+	return statTime(s.stats["latest_delegation"])
+}
+
+func (s *statsResolver) OldestDelegation() *graphqlgo.Time {
+	return statTime(s.stats["oldest_delegation"])
+}
+
+func statInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return 0
+}
+
+func statTime(v interface{}) *graphqlgo.Time {
+	if t, ok := v.(time.Time); ok {
+		return &graphqlgo.Time{Time: t}
+	}
+	return nil
+}
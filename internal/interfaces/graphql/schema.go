@@ -0,0 +1,85 @@
+package graphql
+
+// schema is the GraphQL schema served at /graphql. Amounts are mutez as
+// strings, like in the REST API, since they can exceed 2^53.
+const schema = `
+schema {
+	query: Query
+}
+
+scalar Time
+
+type Query {
+	"Delegations, newest first. first defaults to 20 and is capped at 100."
+	delegations(first: Int, after: String, filter: DelegationFilter): DelegationConnection!
+	"An account that has delegated, or null if it never did."
+	delegator(address: String!): Delegator
+	baker(address: String!): Baker!
+	stats: Stats!
+}
+
+input DelegationFilter {
+	year: Int
+	cycle: Int
+	baker: String
+	delegator: String
+}
+
+type DelegationConnection {
+	edges: [DelegationEdge!]!
+	pageInfo: PageInfo!
+}
+
+type DelegationEdge {
+	cursor: String!
+	node: Delegation!
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	endCursor: String
+}
+
+type Delegation {
+	id: ID!
+	operationHash: String!
+	timestamp: Time!
+	amount: String!
+	level: Int!
+	blockHash: String!
+	cycle: Int
+	delegator: Delegator!
+	"The new baker, or null when the delegation removed the baker."
+	baker: Baker
+	prevBaker: Baker
+}
+
+type Delegator {
+	address: String!
+	"Current baker, or null if the account is not delegating."
+	baker: Baker
+	sinceLevel: Int
+	sinceTimestamp: Time
+	lastAmount: String
+	"Delegations of this account, newest first."
+	delegations(first: Int, after: String): DelegationConnection!
+}
+
+type Baker {
+	address: String!
+	delegatorCount: Int!
+	totalAmount: String!
+	"Current delegators by amount, largest first. first defaults to 20 and is capped at 100."
+	delegators(first: Int, offset: Int): [Delegator!]!
+	"Delegations to this baker, newest first."
+	delegations(first: Int, after: String): DelegationConnection!
+}
+
+type Stats {
+	totalDelegations: Int!
+	uniqueDelegators: Int!
+	totalAmount: String!
+	latestDelegation: Time
+	oldestDelegation: Time
+}
+`
@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	delegationv1 "github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/api/delegation/v1"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyMetadata carries the API key; "authorization: Bearer <key>" is
+// accepted as well.
+const apiKeyMetadata = "x-api-key"
+
+// Authenticator resolves API keys to the principal they stand for.
+type Authenticator interface {
+	AuthenticateAPIKey(ctx context.Context, secret string) (*domain.Principal, error)
+}
+
+// requiresAuth reports whether a method serves delegations; health checks
+// and reflection are public.
+func requiresAuth(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+delegationv1.DelegationService_ServiceDesc.ServiceName+"/")
+}
+
+// authorize checks that ctx carries credentials granting read:delegations,
+// or none at all when anonymous reads are allowed.
+func (s *Server) authorize(ctx context.Context, fullMethod string) error {
+	if !requiresAuth(fullMethod) {
+		return nil
+	}
+
+	secret := credentials(ctx)
+	if secret == "" {
+		if s.auth.AnonymousReads {
+			return nil
+		}
+		return status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	authenticator, ok := s.service.(Authenticator)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	principal, err := authenticator.AuthenticateAPIKey(ctx, secret)
+	switch {
+	case domain.IsKind(err, domain.KindUnauthenticated):
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	case err != nil:
+		s.logger.Errorw("Failed to authenticate request", "error", err, "method", fullMethod)
+		return status.Error(codes.Unavailable, "failed to authenticate request")
+	case !principal.HasScope(domain.ScopeReadDelegations):
+		return status.Error(codes.PermissionDenied, "credentials do not grant access to this operation")
+	}
+	return nil
+}
+
+func credentials(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get(apiKeyMetadata); len(keys) > 0 && keys[0] != "" {
+		return keys[0]
+	}
+	for _, value := range md.Get("authorization") {
+		scheme, token, ok := strings.Cut(value, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+func (s *Server) authUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,305 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	delegationv1 "github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/api/delegation/v1"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/pubsub"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	watchBuffer     = 256
+	maxWatchFilters = 1000
+	pageTokenPrefix = "delegation:"
+)
+
+// Service is what the gRPC API needs from the application layer.
+type Service interface {
+	ListDelegationsPage(ctx context.Context, query domain.DelegationPageQuery) (*domain.DelegationPage, error)
+	GetDelegatorState(ctx context.Context, query domain.DelegatorQuery) (*domain.DelegatorState, error)
+	SubscribeDelegations(buffer int) *pubsub.Subscription
+}
+
+// Server serves the DelegationService API together with the standard gRPC
+// health and reflection services.
+type Server struct {
+	delegationv1.UnimplementedDelegationServiceServer
+
+	service  Service
+	auth     config.Auth
+	logger   *logger.Logger
+	grpc     *grpc.Server
+	health   *health.Server
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewServer builds the server. API keys are checked when service is also
+// an Authenticator; otherwise only anonymous calls, if allowed by auth, are
+// accepted.
+func NewServer(service Service, auth config.Auth, logger *logger.Logger) *Server {
+	s := &Server{
+		service: service,
+		auth:    auth,
+		logger:  logger,
+		health:  health.NewServer(),
+		done:    make(chan struct{}),
+	}
+
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.recoverUnary, s.logUnary, s.authUnary),
+		grpc.ChainStreamInterceptor(s.recoverStream, s.authStream),
+	)
+
+	delegationv1.RegisterDelegationServiceServer(s.grpc, s)
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(delegationv1.DelegationService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return s
+}
+
+// Serve accepts connections on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Stop reports NOT_SERVING to health checks, ends open watch streams and
+// waits for in-flight calls until ctx expires, then closes the remaining
+// connections.
+func (s *Server) Stop(ctx context.Context) {
+	s.stopOnce.Do(func() {
+		s.health.Shutdown()
+		close(s.done)
+
+		stopped := make(chan struct{})
+		go func() {
+			s.grpc.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpc.Stop()
+		}
+	})
+}
+
+func (s *Server) ListDelegations(ctx context.Context, req *delegationv1.ListDelegationsRequest) (*delegationv1.ListDelegationsResponse, error) {
+	if req.GetPageSize() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+
+	filter, err := toDomainFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	query := domain.DelegationPageQuery{Filter: filter, Limit: int(req.GetPageSize())}
+	if token := req.GetPageToken(); token != "" {
+		cursor, err := decodePageToken(token)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		query.Before = &cursor
+	}
+
+	page, err := s.service.ListDelegationsPage(ctx, query)
+	if err != nil {
+		s.logger.Errorw("Failed to list delegations", "error", err)
+		return nil, status.Error(codes.Internal, "failed to retrieve delegations")
+	}
+
+	resp := &delegationv1.ListDelegationsResponse{
+		Delegations: make([]*delegationv1.Delegation, 0, len(page.Data)),
+	}
+	for _, d := range page.Data {
+		resp.Delegations = append(resp.Delegations, toProtoDelegation(d))
+	}
+	if page.HasMore && len(page.Data) > 0 {
+		resp.NextPageToken = encodePageToken(domain.CursorOf(page.Data[len(page.Data)-1]))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetDelegator(ctx context.Context, req *delegationv1.GetDelegatorRequest) (*delegationv1.Delegator, error) {
+	if req.GetAddress() == "" {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+
+	state, err := s.service.GetDelegatorState(ctx, domain.DelegatorQuery{Delegator: req.GetAddress()})
+	if err != nil {
+		if errors.Is(err, domain.ErrDelegatorNotFound) {
+			return nil, status.Error(codes.NotFound, "delegator not found")
+		}
+		s.logger.Errorw("Failed to get delegator state", "error", err, "delegator", req.GetAddress())
+		return nil, status.Error(codes.Internal, "failed to retrieve delegator")
+	}
+
+	return &delegationv1.Delegator{
+		Address:        state.Delegator,
+		Baker:          state.Baker,
+		SinceLevel:     state.SinceLevel,
+		SinceTimestamp: timestamppb.New(state.SinceTimestamp),
+		LastAmount:     state.LastAmount,
+		OperationHash:  state.OperationHash,
+	}, nil
+}
+
+// WatchDelegations streams delegations from the ingestion hub as they are
+// committed. A client that falls behind is dropped by the hub and its stream
+// ends with RESOURCE_EXHAUSTED instead of slowing down ingestion.
+func (s *Server) WatchDelegations(req *delegationv1.WatchDelegationsRequest, stream delegationv1.DelegationService_WatchDelegationsServer) error {
+	if len(req.GetBakers()) > maxWatchFilters || len(req.GetDelegators()) > maxWatchFilters {
+		return status.Errorf(codes.InvalidArgument, "at most %d bakers and %d delegators per watch", maxWatchFilters, maxWatchFilters)
+	}
+	if req.GetMinAmount() < 0 {
+		return status.Error(codes.InvalidArgument, "min_amount must not be negative")
+	}
+
+	filter := domain.SubscriptionFilter{
+		Bakers:     req.GetBakers(),
+		Delegators: req.GetDelegators(),
+		MinAmount:  req.GetMinAmount(),
+	}
+
+	sub := s.service.SubscribeDelegations(watchBuffer)
+	defer sub.Close()
+	sub.SetMatch(filter.Matches)
+
+	metrics.GRPCWatchStreams.Inc()
+	defer metrics.GRPCWatchStreams.Dec()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case d, ok := <-sub.C():
+			if !ok {
+				if sub.Lagged() {
+					s.logger.Warnw("Ending slow WatchDelegations stream")
+					return status.Error(codes.ResourceExhausted, "slow consumer")
+				}
+				return nil
+			}
+			// Delegations queued before SetMatch took effect are unfiltered.
+			if !filter.Matches(d) {
+				continue
+			}
+			if err := stream.Send(toProtoDelegation(d)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toDomainFilter(f *delegationv1.DelegationFilter) (domain.DelegationFilter, error) {
+	var filter domain.DelegationFilter
+	if f == nil {
+		return filter, nil
+	}
+
+	if f.Year != nil {
+		year := int(f.GetYear())
+		if year < 2018 || year > 2100 {
+			return filter, status.Error(codes.InvalidArgument, "year must be between 2018 and 2100")
+		}
+		filter.Year = &year
+	}
+	if f.Cycle != nil {
+		cycle := f.GetCycle()
+		if cycle < 0 {
+			return filter, status.Error(codes.InvalidArgument, "cycle must not be negative")
+		}
+		filter.Cycle = &cycle
+	}
+	filter.Baker = f.GetBaker()
+	filter.Delegator = f.GetDelegator()
+
+	return filter, nil
+}
+
+func toProtoDelegation(d domain.Delegation) *delegationv1.Delegation {
+	level, _ := strconv.ParseInt(d.Level, 10, 64)
+	return &delegationv1.Delegation{
+		OperationHash: d.OperationHash,
+		Timestamp:     timestamppb.New(d.Timestamp),
+		Amount:        d.Amount,
+		Delegator:     d.Delegator,
+		Baker:         d.Baker,
+		PrevBaker:     d.PrevBaker,
+		Level:         level,
+		Cycle:         d.Cycle,
+	}
+}
+
+func encodePageToken(cursor domain.DelegationCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + cursor.String()))
+}
+
+func decodePageToken(token string) (domain.DelegationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(raw), pageTokenPrefix) {
+		return domain.DelegationCursor{}, fmt.Errorf("invalid page token %q", token)
+	}
+	return domain.ParseDelegationCursor(strings.TrimPrefix(string(raw), pageTokenPrefix))
+}
+
+func (s *Server) logUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+
+	metrics.GRPCRequestDuration.WithLabelValues(info.FullMethod, code.String()).Observe(time.Since(start).Seconds())
+	s.logger.Infow("gRPC request processed",
+		"method", info.FullMethod,
+		"code", code.String(),
+		"latency", time.Since(start),
+	)
+	return resp, err
+}
+
+func (s *Server) recoverUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Errorw("Panic recovered", "error", r, "method", info.FullMethod, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal server error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func (s *Server) recoverStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Errorw("Panic recovered", "error", r, "method", info.FullMethod, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal server error")
+		}
+	}()
+	return handler(srv, ss)
+}
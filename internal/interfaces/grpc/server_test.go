@@ -0,0 +1,253 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	delegationv1 "github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/api/delegation/v1"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/pubsub"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type MockService struct {
+	mock.Mock
+	hub        *pubsub.Hub
+	subscribed chan struct{}
+}
+
+func (m *MockService) ListDelegationsPage(ctx context.Context, query domain.DelegationPageQuery) (*domain.DelegationPage, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DelegationPage), args.Error(1)
+}
+
+func (m *MockService) GetDelegatorState(ctx context.Context, query domain.DelegatorQuery) (*domain.DelegatorState, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DelegatorState), args.Error(1)
+}
+
+func (m *MockService) SubscribeDelegations(buffer int) *pubsub.Subscription {
+	sub := m.hub.Subscribe(buffer)
+	if m.subscribed != nil {
+		m.subscribed <- struct{}{}
+	}
+	return sub
+}
+
+func (m *MockService) AuthenticateAPIKey(ctx context.Context, secret string) (*domain.Principal, error) {
+	args := m.Called(secret)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Principal), args.Error(1)
+}
+
+func startServer(t *testing.T, service *MockService) (*Server, *grpc.ClientConn) {
+	return startServerWithAuth(t, service, config.Auth{AnonymousReads: true})
+}
+
+func startServerWithAuth(t *testing.T, service *MockService, auth config.Auth) (*Server, *grpc.ClientConn) {
+	t.Helper()
+
+	log, _ := logger.New("debug", "test")
+	server := NewServer(service, auth, log)
+
+	lis := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Stop(ctx)
+	})
+	return server, conn
+}
+
+func TestServer_ListDelegations(t *testing.T) {
+	service := &MockService{hub: pubsub.NewHub()}
+	_, conn := startServer(t, service)
+	client := delegationv1.NewDelegationServiceClient(conn)
+
+	year := 2024
+	before := domain.DelegationCursor{Level: 5000002, Seq: 50}
+	cycle := int64(700)
+	service.On("ListDelegationsPage", domain.DelegationPageQuery{
+		Filter: domain.DelegationFilter{Year: &year, Baker: "tz1baker"},
+		Before: &before,
+		Limit:  2,
+	}).Return(&domain.DelegationPage{
+		Data: []domain.Delegation{
+			{OperationHash: "op1", Timestamp: time.Date(2024, 5, 5, 6, 29, 14, 0, time.UTC), Amount: "100", Delegator: "tz1one", Baker: "tz1baker", Level: "5000001", Cycle: &cycle, Seq: 42},
+			{OperationHash: "op2", Amount: "200", Delegator: "tz1two", Baker: "tz1baker", Level: "5000000", Seq: 41},
+		},
+		HasMore: true,
+	}, nil)
+
+	yearFilter := int32(2024)
+	resp, err := client.ListDelegations(context.Background(), &delegationv1.ListDelegationsRequest{
+		Filter:    &delegationv1.DelegationFilter{Year: &yearFilter, Baker: "tz1baker"},
+		PageSize:  2,
+		PageToken: encodePageToken(before),
+	})
+
+	require.NoError(t, err)
+	require.Len(t, resp.Delegations, 2)
+	assert.Equal(t, "op1", resp.Delegations[0].OperationHash)
+	assert.Equal(t, int64(5000001), resp.Delegations[0].Level)
+	assert.Equal(t, int64(700), resp.Delegations[0].GetCycle())
+	assert.Equal(t, time.Date(2024, 5, 5, 6, 29, 14, 0, time.UTC), resp.Delegations[0].Timestamp.AsTime())
+	assert.Nil(t, resp.Delegations[1].Cycle)
+	assert.Equal(t, encodePageToken(domain.DelegationCursor{Level: 5000000, Seq: 41}), resp.NextPageToken)
+	service.AssertExpectations(t)
+}
+
+func TestServer_ListDelegationsInvalidArguments(t *testing.T) {
+	service := &MockService{hub: pubsub.NewHub()}
+	_, conn := startServer(t, service)
+	client := delegationv1.NewDelegationServiceClient(conn)
+
+	year := int32(1999)
+	tests := []*delegationv1.ListDelegationsRequest{
+		{PageToken: "bogus"},
+		{PageSize: -1},
+		{Filter: &delegationv1.DelegationFilter{Year: &year}},
+	}
+
+	for _, req := range tests {
+		_, err := client.ListDelegations(context.Background(), req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+	service.AssertNotCalled(t, "ListDelegationsPage", mock.Anything)
+}
+
+func TestServer_GetDelegator(t *testing.T) {
+	service := &MockService{hub: pubsub.NewHub()}
+	_, conn := startServer(t, service)
+	client := delegationv1.NewDelegationServiceClient(conn)
+
+	service.On("GetDelegatorState", domain.DelegatorQuery{Delegator: "tz1one"}).Return(&domain.DelegatorState{
+		Delegator:  "tz1one",
+		Baker:      "tz1baker",
+		SinceLevel: 5000001,
+		LastAmount: "100",
+	}, nil)
+	service.On("GetDelegatorState", domain.DelegatorQuery{Delegator: "tz1nobody"}).Return(nil, domain.ErrDelegatorNotFound)
+
+	delegator, err := client.GetDelegator(context.Background(), &delegationv1.GetDelegatorRequest{Address: "tz1one"})
+	require.NoError(t, err)
+	assert.Equal(t, "tz1baker", delegator.Baker)
+	assert.Equal(t, int64(5000001), delegator.SinceLevel)
+
+	_, err = client.GetDelegator(context.Background(), &delegationv1.GetDelegatorRequest{Address: "tz1nobody"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_WatchDelegations(t *testing.T) {
+	hub := pubsub.NewHub()
+	service := &MockService{hub: hub, subscribed: make(chan struct{}, 1)}
+	server, conn := startServer(t, service)
+	client := delegationv1.NewDelegationServiceClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchDelegations(ctx, &delegationv1.WatchDelegationsRequest{Bakers: []string{"tz1baker"}})
+	require.NoError(t, err)
+
+	// Wait for the server side of the stream to subscribe before publishing.
+	select {
+	case <-service.subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not subscribe")
+	}
+
+	hub.Publish([]domain.Delegation{
+		{OperationHash: "other", Baker: "tz1other", Amount: "1", Level: "1"},
+		{OperationHash: "match", Baker: "tz1baker", Amount: "2", Level: "2"},
+	})
+
+	d, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "match", d.OperationHash)
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	server.Stop(stopCtx)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestServer_Health(t *testing.T) {
+	service := &MockService{hub: pubsub.NewHub()}
+	_, conn := startServer(t, service)
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: delegationv1.DelegationService_ServiceDesc.ServiceName,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestServer_Authentication(t *testing.T) {
+	service := &MockService{hub: pubsub.NewHub()}
+	_, conn := startServerWithAuth(t, service, config.Auth{})
+	client := delegationv1.NewDelegationServiceClient(conn)
+
+	service.On("AuthenticateAPIKey", "tzd_reader").Return(&domain.Principal{
+		Subject: "api_key:reader",
+		Scopes:  []domain.Scope{domain.ScopeReadDelegations},
+	}, nil)
+	service.On("AuthenticateAPIKey", "tzd_stats").Return(&domain.Principal{
+		Subject: "api_key:stats",
+		Scopes:  []domain.Scope{domain.ScopeReadStats},
+	}, nil)
+	service.On("AuthenticateAPIKey", mock.Anything).Return(nil, domain.ErrInvalidCredentials)
+	service.On("ListDelegationsPage", mock.Anything).Return(&domain.DelegationPage{}, nil)
+
+	call := func(md ...string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), md...)
+		_, err := client.ListDelegations(ctx, &delegationv1.ListDelegationsRequest{})
+		return err
+	}
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(call()))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(apiKeyMetadata, "tzd_unknown")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call(apiKeyMetadata, "tzd_stats")))
+	assert.NoError(t, call(apiKeyMetadata, "tzd_reader"))
+	assert.NoError(t, call("authorization", "Bearer tzd_reader"))
+
+	// Health checks stay public.
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+const maxAPIKeyNameLength = 100
+
+type APIKeyManager interface {
+	CreateAPIKey(ctx context.Context, name string, scopes []domain.Scope) (*domain.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]domain.APIKey, error)
+	RotateAPIKey(ctx context.Context, id string) (*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+type createAPIKeyRequest struct {
+	Name   string         `json:"name"`
+	Scopes []domain.Scope `json:"scopes"`
+}
+
+func (h *Handler) apiKeyManager(c *gin.Context) (APIKeyManager, bool) {
+	manager, ok := h.service.(APIKeyManager)
+	if !ok {
+		notImplemented(c, "API keys not available")
+	}
+	return manager, ok
+}
+
+// CreateAPIKey issues a key. The response is the only one carrying its
+// secret.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	manager, ok := h.apiKeyManager(c)
+	if !ok {
+		return
+	}
+
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidBody(c, "Invalid request body. Must be a JSON object with name and scopes")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAPIKeyNameLength {
+		invalidBody(c, "Invalid name. Must be between 1 and 100 characters")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		invalidBody(c, "Invalid scopes. At least one scope is required")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !scope.Valid() {
+			invalidBody(c, "Invalid scope "+string(scope)+". Must be one of: read:delegations, read:stats, admin")
+			return
+		}
+	}
+
+	key, err := manager.CreateAPIKey(c.Request.Context(), req.Name, req.Scopes)
+	if err != nil {
+		h.logger.Errorw("Failed to create API key", "error", err)
+		respondError(c, err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys lists every key, revoked ones included, with its usage.
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	manager, ok := h.apiKeyManager(c)
+	if !ok {
+		return
+	}
+
+	keys, err := manager.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		h.logger.Errorw("Failed to list API keys", "error", err)
+		respondError(c, err, "Failed to retrieve API keys")
+		return
+	}
+	if keys == nil {
+		keys = []domain.APIKey{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": keys,
+	})
+}
+
+// RotateAPIKey replaces the secret of a key, keeping its scopes and usage.
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	manager, ok := h.apiKeyManager(c)
+	if !ok {
+		return
+	}
+
+	key, err := manager.RotateAPIKey(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.apiKeyError(c, err, "Failed to rotate API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	manager, ok := h.apiKeyManager(c)
+	if !ok {
+		return
+	}
+
+	if err := manager.RevokeAPIKey(c.Request.Context(), c.Param("id")); err != nil {
+		h.apiKeyError(c, err, "Failed to revoke API key")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) apiKeyError(c *gin.Context, err error, message string) {
+	if !domain.IsKind(err, domain.KindNotFound) {
+		h.logger.Errorw(message, "error", err, "api_key_id", c.Param("id"))
+	}
+	respondError(c, err, message)
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+)
+
+const (
+	principalKey = "principal"
+
+	authenticateChallenge = `Bearer realm="tezos-delegation-service"`
+)
+
+// APIKeyAuthenticator resolves API keys to the principal they stand for.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, secret string) (*domain.Principal, error)
+}
+
+// routeScope returns the scope a route requires. Probes, the API
+// description and unmatched routes require none.
+func routeScope(route string) (domain.Scope, bool) {
+	switch {
+	case strings.HasPrefix(route, "/admin/"):
+		return domain.ScopeAdmin, true
+	case route == "/stats" || route == "/metrics" || strings.HasPrefix(route, "/xtz/stats/"):
+		return domain.ScopeReadStats, true
+	case route == "/graphql" || strings.HasPrefix(route, "/xtz/"):
+		return domain.ScopeReadDelegations, true
+	}
+	return "", false
+}
+
+// anonymousAllowed reports whether a route may be called without
+// credentials when anonymous reads are enabled. Metrics describe the
+// deployment rather than the chain, so they are not a public read.
+func anonymousAllowed(route string, scope domain.Scope) bool {
+	return scope != domain.ScopeAdmin && route != "/metrics"
+}
+
+// AuthMiddleware authenticates the API key sent in the X-API-Key header or
+// as a bearer token, or the JWT sent as a bearer token, and rejects
+// requests whose principal lacks the scope of the route. Requests without
+// credentials are let through to read routes when cfg.AnonymousReads is
+// set. Nil authenticators accept no credentials of their kind.
+func AuthMiddleware(apiKeys APIKeyAuthenticator, tokens domain.TokenAuthenticator, cfg config.Auth, logger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		scope, ok := routeScope(route)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		secret := credentials(c)
+		if secret == "" {
+			if cfg.AnonymousReads && anonymousAllowed(route, scope) {
+				c.Next()
+				return
+			}
+			unauthenticated(c, domain.ErrAuthenticationRequired)
+			return
+		}
+
+		var principal *domain.Principal
+		var err error
+		switch {
+		case isJWT(secret) && tokens != nil:
+			principal, err = tokens.AuthenticateToken(c.Request.Context(), secret)
+		case !isJWT(secret) && apiKeys != nil:
+			principal, err = apiKeys.AuthenticateAPIKey(c.Request.Context(), secret)
+		default:
+			err = domain.ErrInvalidCredentials
+		}
+		if err != nil {
+			if !domain.IsKind(err, domain.KindUnauthenticated) {
+				logger.Errorw("Failed to authenticate request", "error", err, "requestID", requestID(c))
+			}
+			unauthenticated(c, err)
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			respondError(c, domain.ErrInsufficientScope, "")
+			return
+		}
+
+		// Handlers outside gin, such as GraphQL, check finer scopes from the
+		// request context.
+		c.Set(principalKey, principal)
+		c.Request = c.Request.WithContext(domain.ContextWithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// credentials returns the API key of the request, if any.
+func credentials(c *gin.Context) string {
+	if key := c.GetHeader(apiKeyHeader); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// isJWT reports whether a credential has the three dot-separated parts of a
+// JWT; API keys have none.
+func isJWT(secret string) bool {
+	return strings.Count(secret, ".") == 2
+}
+
+func unauthenticated(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", authenticateChallenge)
+	respondError(c, err, "Failed to authenticate request")
+}
+
+// principal returns the client the request was authenticated as, or nil for
+// anonymous requests.
+func principal(c *gin.Context) *domain.Principal {
+	if p, ok := c.Get(principalKey); ok {
+		return p.(*domain.Principal)
+	}
+	return nil
+}
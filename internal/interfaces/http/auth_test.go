@@ -0,0 +1,163 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("AuthenticateAPIKey", "tzd_reader").Return(&domain.Principal{
+		Subject: "api_key:reader",
+		Scopes:  []domain.Scope{domain.ScopeReadDelegations},
+	}, nil)
+	mockService.On("AuthenticateAPIKey", "tzd_admin").Return(&domain.Principal{
+		Subject: "api_key:admin",
+		Scopes:  []domain.Scope{domain.ScopeAdmin},
+	}, nil)
+	mockService.On("AuthenticateAPIKey", "tzd_down").Return(nil, domain.NewStorageUnavailableError(errors.New("connection refused")))
+	mockService.On("AuthenticateAPIKey", mock.Anything).Return(nil, domain.ErrInvalidCredentials)
+	mockService.On("GetDelegations", mock.Anything).Return([]domain.Delegation{}, nil)
+	mockService.On("ListAPIKeys").Return([]domain.APIKey{}, nil)
+	mockService.On("GetStats").Return(map[string]interface{}{}, nil)
+
+	testCases := []struct {
+		name      string
+		anonymous bool
+		path      string
+		header    string
+		value     string
+		status    int
+		code      string
+	}{
+		{"Anonymous read", true, "/xtz/delegations", "", "", http.StatusOK, ""},
+		{"Anonymous read disabled", false, "/xtz/delegations", "", "", http.StatusUnauthorized, "authentication_required"},
+		{"Anonymous metrics", true, "/metrics", "", "", http.StatusUnauthorized, "authentication_required"},
+		{"Anonymous admin", true, "/admin/api-keys", "", "", http.StatusUnauthorized, "authentication_required"},
+		{"Probe without credentials", false, "/health", "", "", http.StatusOK, ""},
+		{"Invalid key", true, "/xtz/delegations", apiKeyHeader, "tzd_unknown", http.StatusUnauthorized, "invalid_credentials"},
+		{"Storage down", true, "/xtz/delegations", apiKeyHeader, "tzd_down", http.StatusServiceUnavailable, "storage_unavailable"},
+		{"Reader key", false, "/xtz/delegations", apiKeyHeader, "tzd_reader", http.StatusOK, ""},
+		{"Bearer key", false, "/xtz/delegations", "Authorization", "Bearer tzd_reader", http.StatusOK, ""},
+		{"Reader on stats", false, "/stats", apiKeyHeader, "tzd_reader", http.StatusForbidden, "insufficient_scope"},
+		{"Reader on admin", false, "/admin/api-keys", apiKeyHeader, "tzd_reader", http.StatusForbidden, "insufficient_scope"},
+		{"Admin key", false, "/admin/api-keys", apiKeyHeader, "tzd_admin", http.StatusOK, ""},
+		{"Admin key on stats", false, "/stats", "Authorization", "bearer tzd_admin", http.StatusOK, ""},
+	}
+
+	log, _ := logger.New("debug", "test")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, err := NewRouter(mockService, RouterConfig{Auth: config.Auth{AnonymousReads: tc.anonymous}}, log)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.code != "" {
+				assert.Equal(t, tc.code, decodeProblem(t, w).Code)
+			}
+			if tc.status == http.StatusUnauthorized {
+				assert.Equal(t, authenticateChallenge, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_PrincipalInRequestContext(t *testing.T) {
+	mockService := new(MockService)
+	reader := &domain.Principal{Subject: "api_key:reader", Scopes: []domain.Scope{domain.ScopeReadDelegations}}
+	mockService.On("AuthenticateAPIKey", "tzd_reader").Return(reader, nil)
+
+	log, _ := logger.New("debug", "test")
+	router := gin.New()
+	router.Use(AuthMiddleware(mockService, nil, config.Auth{}, log))
+
+	var got *domain.Principal
+	router.GET("/graphql", func(c *gin.Context) {
+		got = domain.PrincipalFromContext(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+	req.Header.Set(apiKeyHeader, "tzd_reader")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, reader, got)
+}
+
+// tokenAuthenticator accepts the JWTs in its map.
+type tokenAuthenticator map[string]*domain.Principal
+
+func (a tokenAuthenticator) AuthenticateToken(ctx context.Context, token string) (*domain.Principal, error) {
+	if p, ok := a[token]; ok {
+		return p, nil
+	}
+	return nil, domain.ErrInvalidCredentials
+}
+
+func TestAuthMiddleware_JWT(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("AuthenticateAPIKey", "tzd_reader").Return(&domain.Principal{
+		Subject: "api_key:reader",
+		Scopes:  []domain.Scope{domain.ScopeReadDelegations},
+	}, nil)
+	mockService.On("GetDelegations", mock.Anything).Return([]domain.Delegation{}, nil)
+	mockService.On("ListWebhooks").Return([]domain.Webhook{}, nil)
+
+	tokens := tokenAuthenticator{
+		"reader.token.sig": {Subject: "jwt:reader", Scopes: []domain.Scope{domain.ScopeReadDelegations, domain.ScopeReadStats}},
+		"admin.token.sig":  {Subject: "jwt:admin", Scopes: []domain.Scope{domain.ScopeAdmin}},
+	}
+
+	testCases := []struct {
+		name   string
+		tokens domain.TokenAuthenticator
+		path   string
+		token  string
+		status int
+		code   string
+	}{
+		{"Reader token", tokens, "/xtz/delegations", "reader.token.sig", http.StatusOK, ""},
+		{"Reader token on admin", tokens, "/admin/webhooks", "reader.token.sig", http.StatusForbidden, "insufficient_scope"},
+		{"Admin token", tokens, "/admin/webhooks", "admin.token.sig", http.StatusOK, ""},
+		{"Unknown token", tokens, "/xtz/delegations", "forged.token.sig", http.StatusUnauthorized, "invalid_credentials"},
+		{"API key beside tokens", tokens, "/xtz/delegations", "tzd_reader", http.StatusOK, ""},
+		{"Tokens disabled", nil, "/xtz/delegations", "admin.token.sig", http.StatusUnauthorized, "invalid_credentials"},
+	}
+
+	log, _ := logger.New("debug", "test")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, err := NewRouter(mockService, RouterConfig{TokenAuthenticator: tc.tokens}, log)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.code != "" {
+				assert.Equal(t, tc.code, decodeProblem(t, w).Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// Data completeness headers are set on every data response, so that
+// clients can tell results that may still miss delegations.
+const (
+	dataStateHeader                = "X-Data-State"
+	dataCompleteThroughHeader      = "X-Data-Complete-Through"
+	dataCompleteThroughLevelHeader = "X-Data-Complete-Through-Level"
+)
+
+var dataCompletenessHeaders = []string{dataStateHeader, dataCompleteThroughHeader, dataCompleteThroughLevelHeader}
+
+// DataCompletenessReporter tells how complete the stored delegations are.
+type DataCompletenessReporter interface {
+	DataCompleteness(ctx context.Context) domain.DataCompleteness
+}
+
+// dataRoute reports whether a route serves indexed delegations.
+func dataRoute(route string) bool {
+	return route == "/graphql" || route == "/stats" || strings.HasPrefix(route, "/xtz/")
+}
+
+// DataCompletenessMiddleware sets the data state and watermark headers on
+// the responses of data routes.
+func DataCompletenessMiddleware(reporter DataCompletenessReporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !dataRoute(c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		data := reporter.DataCompleteness(c.Request.Context())
+		c.Header(dataStateHeader, string(data.State))
+		if data.CompleteThrough != nil {
+			c.Header(dataCompleteThroughHeader, data.CompleteThrough.UTC().Format(time.RFC3339))
+			c.Header(dataCompleteThroughLevelHeader, strconv.FormatInt(data.CompleteThroughLevel, 10))
+		}
+		c.Next()
+	}
+}
+
+// GetDataCompleteness reports whether historical delegations are still
+// being indexed and the watermark through which every delegation is stored.
+func (h *Handler) GetDataCompleteness(c *gin.Context) {
+	reporter, ok := h.service.(DataCompletenessReporter)
+	if !ok {
+		notImplemented(c, "Data completeness not available")
+		return
+	}
+
+	c.JSON(http.StatusOK, reporter.DataCompleteness(c.Request.Context()))
+}
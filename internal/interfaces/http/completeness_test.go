@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// backfillingService reports a fixed data completeness.
+type backfillingService struct {
+	*MockService
+	data domain.DataCompleteness
+}
+
+func (s *backfillingService) DataCompleteness(ctx context.Context) domain.DataCompleteness {
+	return s.data
+}
+
+func TestDataCompleteness(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("GetDelegations", mock.Anything).Return([]domain.Delegation{}, nil)
+
+	through := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	service := &backfillingService{MockService: mockService, data: domain.DataCompleteness{
+		State:                domain.DataBackfilling,
+		CompleteThroughLevel: 1350000,
+		CompleteThrough:      &through,
+		HeadLevel:            5000000,
+	}}
+
+	log, _ := logger.New("debug", "test")
+	router, err := NewRouter(service, RouterConfig{Auth: config.Auth{AnonymousReads: true}}, log)
+	require.NoError(t, err)
+
+	t.Run("Headers on data routes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/xtz/delegations", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "backfilling", w.Header().Get(dataStateHeader))
+		assert.Equal(t, "2021-03-01T12:00:00Z", w.Header().Get(dataCompleteThroughHeader))
+		assert.Equal(t, "1350000", w.Header().Get(dataCompleteThroughLevelHeader))
+	})
+
+	t.Run("No headers on probes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(dataStateHeader))
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/xtz/completeness", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response domain.DataCompleteness
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, service.data.State, response.State)
+		assert.Equal(t, service.data.CompleteThroughLevel, response.CompleteThroughLevel)
+		assert.Equal(t, service.data.HeadLevel, response.HeadLevel)
+	})
+
+	t.Run("Not available", func(t *testing.T) {
+		router, err := NewRouter(mockService, RouterConfig{Auth: config.Auth{AnonymousReads: true}}, log)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/xtz/completeness", nil))
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+)
+
+// corsExposedHeaders are the response headers scripts on other origins may
+// read, besides the CORS-safelisted ones.
+var corsExposedHeaders = strings.Join(slices.Concat([]string{requestIDHeader}, rateLimitHeaders, dataCompletenessHeaders), ", ")
+
+// corsPolicy is a config.CORSPolicy prepared for matching origins.
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []corsWildcard
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+// corsWildcard matches the subdomains of a domain: "https://*.example.com"
+// becomes the prefix "https://" and the suffix ".example.com".
+type corsWildcard struct {
+	prefix, suffix string
+}
+
+func newCORSPolicy(cfg config.CORSPolicy) *corsPolicy {
+	p := &corsPolicy{
+		origins:     make(map[string]bool),
+		methods:     strings.Join(cfg.AllowedMethods, ", "),
+		headers:     strings.Join(cfg.AllowedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			prefix, domain, _ := strings.Cut(origin, "*")
+			p.wildcards = append(p.wildcards, corsWildcard{prefix: prefix, suffix: domain})
+		default:
+			p.origins[origin] = true
+		}
+	}
+	return p
+}
+
+// allows reports whether requests from origin are allowed.
+func (p *corsPolicy) allows(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.anyOrigin || p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if strings.HasPrefix(origin, w.prefix) && strings.HasSuffix(origin, w.suffix) {
+			subdomain := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+			if subdomain != "" && !strings.ContainsAny(subdomain, "/:@") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CORSMiddleware applies the admin policy to /admin routes and the public
+// policy to the others. The origin of an allowed request is echoed back,
+// never "*", so responses vary by Origin and say so. Preflight requests are
+// answered here; those from origins that are not allowed get no CORS
+// headers, which makes browsers refuse the actual request.
+func CORSMiddleware(cfg config.CORS) gin.HandlerFunc {
+	public, admin := newCORSPolicy(cfg.Public), newCORSPolicy(cfg.Admin)
+
+	return func(c *gin.Context) {
+		policy := public
+		if path := c.Request.URL.Path; path == "/admin" || strings.HasPrefix(path, "/admin/") {
+			policy = admin
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		origin := c.GetHeader("Origin")
+		if origin == "" || !policy.allows(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		header.Set("Access-Control-Allow-Origin", origin)
+		if policy.credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", policy.methods)
+			header.Set("Access-Control-Allow-Headers", policy.headers)
+			if policy.maxAge != "" {
+				header.Set("Access-Control-Max-Age", policy.maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		c.Next()
+	}
+}
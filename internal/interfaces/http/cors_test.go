@@ -0,0 +1,119 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	mockService := new(MockService)
+	mockService.On("GetDelegations", mock.Anything).Return([]domain.Delegation{}, nil)
+
+	cfg := config.CORS{
+		Public: config.CORSPolicy{
+			AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+			AllowedMethods:   []string{"GET", "POST"},
+			AllowedHeaders:   []string{"Content-Type", "X-API-Key"},
+			AllowCredentials: true,
+			MaxAge:           time.Hour,
+		},
+		Admin: config.CORSPolicy{
+			AllowedOrigins: []string{"https://ops.example.com"},
+			AllowedMethods: []string{"GET", "DELETE"},
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		method    string
+		path      string
+		origin    string
+		preflight bool
+		allowed   bool
+	}{
+		{"Exact origin", http.MethodGet, "/xtz/delegations", "https://app.example.com", false, true},
+		{"Origin case", http.MethodGet, "/xtz/delegations", "https://APP.example.com", false, true},
+		{"Wildcard subdomain", http.MethodGet, "/xtz/delegations", "https://eu.api.example.org", false, true},
+		{"Wildcard apex", http.MethodGet, "/xtz/delegations", "https://example.org", false, false},
+		{"Wildcard lookalike", http.MethodGet, "/xtz/delegations", "https://evilexample.org", false, false},
+		{"Other scheme", http.MethodGet, "/xtz/delegations", "http://app.example.com", false, false},
+		{"Unknown origin", http.MethodGet, "/xtz/delegations", "https://evil.com", false, false},
+		{"No origin", http.MethodGet, "/xtz/delegations", "", false, false},
+		{"Preflight", http.MethodOptions, "/graphql", "https://app.example.com", true, true},
+		{"Preflight unknown origin", http.MethodOptions, "/graphql", "https://evil.com", true, false},
+		{"Public origin on admin", http.MethodOptions, "/admin/webhooks", "https://app.example.com", true, false},
+		{"Admin origin", http.MethodOptions, "/admin/webhooks", "https://ops.example.com", true, true},
+	}
+
+	log, _ := logger.New("debug", "test")
+	router, err := NewRouter(mockService, RouterConfig{CORS: cfg, Auth: config.Auth{AnonymousReads: true}}, log)
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			header := w.Header()
+			assert.Contains(t, header.Values("Vary"), "Origin")
+			if tc.preflight {
+				assert.Equal(t, http.StatusNoContent, w.Code)
+				assert.Contains(t, header.Values("Vary"), "Access-Control-Request-Method")
+			} else {
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+
+			if !tc.allowed {
+				assert.Empty(t, header.Get("Access-Control-Allow-Origin"))
+				assert.Empty(t, header.Get("Access-Control-Allow-Credentials"))
+				return
+			}
+			assert.Equal(t, tc.origin, header.Get("Access-Control-Allow-Origin"))
+			if tc.preflight {
+				assert.NotEmpty(t, header.Get("Access-Control-Allow-Methods"))
+				assert.Empty(t, header.Get("Access-Control-Expose-Headers"))
+			} else {
+				assert.Contains(t, header.Get("Access-Control-Expose-Headers"), requestIDHeader)
+			}
+		})
+	}
+
+	t.Run("Policies", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/graphql", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type, X-API-Key", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+
+		req = httptest.NewRequest(http.MethodOptions, "/admin/webhooks", nil)
+		req.Header.Set("Origin", "https://ops.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "GET, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+	})
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	contentTypeCSV    = "text/csv"
+	contentTypeNDJSON = "application/x-ndjson"
+
+	// exportFlushEvery bounds how many rows are buffered before they are
+	// pushed to the client.
+	exportFlushEvery = 500
+)
+
+var delegationCSVHeader = []string{"timestamp", "amount", "delegator", "level", "operation_hash", "block_hash", "baker", "prev_baker"}
+
+// exportedDelegation is a delegation as exported: with the block and bakers,
+// so that a deployment can be seeded from an export.
+type exportedDelegation struct {
+	domain.Delegation
+	BlockHash string `json:"block_hash"`
+	Baker     string `json:"baker"`
+	PrevBaker string `json:"prev_baker"`
+}
+
+// delegationFormat picks the representation of a delegation listing from the
+// format parameter, falling back to the Accept header.
+func delegationFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+
+	accept := c.GetHeader("Accept")
+	switch {
+	case strings.Contains(accept, contentTypeCSV):
+		return formatCSV
+	case strings.Contains(accept, contentTypeNDJSON), strings.Contains(accept, "application/ndjson"):
+		return formatNDJSON
+	default:
+		return formatJSON
+	}
+}
+
+// exportDelegations streams a delegation listing as CSV or NDJSON, writing
+// rows as they are read from storage.
+func (h *Handler) exportDelegations(c *gin.Context, filter domain.DelegationFilter, format string) {
+	type DelegationExporter interface {
+		ExportDelegations(ctx context.Context, filter domain.DelegationFilter, emit func(domain.Delegation) error) error
+	}
+
+	exporter, ok := h.service.(DelegationExporter)
+	if !ok {
+		notImplemented(c, "Delegation export not available")
+		return
+	}
+
+	var (
+		rows      int
+		started   bool
+		csvWriter *csv.Writer
+	)
+
+	// Headers are written with the first row so that a failing query can
+	// still be reported with a proper status.
+	start := func() {
+		started = true
+
+		// Exports outlive the server-wide write timeout.
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+		if format == formatCSV {
+			c.Header("Content-Type", contentTypeCSV+"; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename="delegations.csv"`)
+			c.Status(http.StatusOK)
+			csvWriter = csv.NewWriter(c.Writer)
+			_ = csvWriter.Write(delegationCSVHeader)
+			return
+		}
+		c.Header("Content-Type", contentTypeNDJSON)
+		c.Status(http.StatusOK)
+	}
+
+	flush := func() error {
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	// Exports run until every row is sent, however long that takes.
+	ctx := untimedContext(c)
+	err := exporter.ExportDelegations(ctx, filter, func(d domain.Delegation) error {
+		if !started {
+			start()
+		}
+
+		if csvWriter != nil {
+			if err := csvWriter.Write([]string{d.Timestamp.UTC().Format(time.RFC3339), d.Amount, d.Delegator, d.Level, d.OperationHash, d.BlockHash, d.Baker, d.PrevBaker}); err != nil {
+				return err
+			}
+		} else {
+			data, err := json.Marshal(exportedDelegation{Delegation: d, BlockHash: d.BlockHash, Baker: d.Baker, PrevBaker: d.PrevBaker})
+			if err != nil {
+				return err
+			}
+			if _, err := c.Writer.Write(append(data, '\n')); err != nil {
+				return err
+			}
+		}
+
+		rows++
+		if rows%exportFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		h.logger.Errorw("Failed to export delegations", "error", err, "format", format, "rows", rows)
+		if !started {
+			respondError(c, err, "Failed to retrieve delegations")
+		}
+		// Once rows were sent the status cannot change; the truncated body
+		// is all the client gets.
+		return
+	}
+
+	if !started {
+		start()
+	}
+	_ = flush()
+}
@@ -1,56 +1,56 @@
 package http
 
 import (
+	"context"
+	"encoding/csv"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
 )
 
 type Handler struct {
-	service domain.DelegationService
-	logger  *logger.Logger
+	service  domain.DelegationService
+	logger   *logger.Logger
+	upgrader *websocket.Upgrader
 }
 
 func NewHandler(service domain.DelegationService, logger *logger.Logger) *Handler {
 	return &Handler{
-		service: service,
-		logger:  logger,
+		service:  service,
+		logger:   logger,
+		upgrader: newUpgrader(nil),
 	}
 }
 
-func (h *Handler) GetDelegations(c *gin.Context) {
-	yearStr := c.Query("year")
-	var yearPtr *int
-
-	if yearStr != "" {
-		year, err := strconv.Atoi(yearStr)
-		if err != nil {
-			h.logger.Errorw("Invalid year parameter", "year", yearStr, "error", err)
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid year parameter. Must be a valid YYYY format",
-			})
-			return
-		}
+// AllowWebSocketOrigins accepts WebSocket subscriptions from the origins
+// policy allows, besides the origin serving the API.
+func (h *Handler) AllowWebSocketOrigins(policy config.CORSPolicy) {
+	h.upgrader = newUpgrader(newCORSPolicy(policy))
+}
 
-		if year < 2018 || year > 2100 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Year must be between 2018 and 2100",
-			})
-			return
-		}
+func (h *Handler) GetDelegations(c *gin.Context) {
+	filter, ok := h.parseDelegationFilter(c)
+	if !ok {
+		return
+	}
 
-		yearPtr = &year
+	if format := delegationFormat(c); format != formatJSON {
+		h.exportDelegations(c, filter, format)
+		return
 	}
 
-	delegations, err := h.service.GetDelegations(yearPtr)
+	delegations, err := h.listDelegations(c.Request.Context(), filter)
 	if err != nil {
 		h.logger.Errorw("Failed to get delegations", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve delegations",
-		})
+		respondError(c, err, "Failed to retrieve delegations")
 		return
 	}
 
@@ -65,60 +65,416 @@ func (h *Handler) GetDelegations(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) GetHealth(c *gin.Context) {
-	delegations, err := h.service.GetDelegations(nil)
-	if err != nil {
-		h.logger.Errorw("Health check failed", "error", err)
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status": "unhealthy",
-			"error":  err.Error(),
-		})
-		return
+// parseDelegationFilter reads the filters shared by the delegation list and
+// stream endpoints. The year has already been checked by ValidationMiddleware.
+// It writes a 400 response and returns false on bad input.
+func (h *Handler) parseDelegationFilter(c *gin.Context) (domain.DelegationFilter, bool) {
+	var filter domain.DelegationFilter
+
+	if year, ok := queryInt(c, "year"); ok {
+		y := int(year)
+		filter.Year = &y
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":            "healthy",
-		"total_delegations": len(delegations),
-	})
+	var ok bool
+	if filter.Cycle, ok = parseCycleParam(c, "cycle"); !ok {
+		return filter, false
+	}
+
+	return filter, true
 }
 
-func (h *Handler) GetReadiness(c *gin.Context) {
-	_, err := h.service.GetDelegations(nil)
-	if err != nil {
-		h.logger.Errorw("Readiness check failed", "error", err)
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status": "not ready",
-			"error":  err.Error(),
-		})
-		return
+// listDelegations serves year-only filters through DelegationService and
+// richer filters through services that support them.
+func (h *Handler) listDelegations(ctx context.Context, filter domain.DelegationFilter) ([]domain.Delegation, error) {
+	type FilteredLister interface {
+		ListDelegations(ctx context.Context, filter domain.DelegationFilter) ([]domain.Delegation, error)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ready",
-	})
+	if filter.Cycle == nil {
+		return h.service.GetDelegations(ctx, filter.Year)
+	}
+
+	lister, ok := h.service.(FilteredLister)
+	if !ok {
+		return nil, errors.New("filtered delegation listing not supported")
+	}
+
+	return lister.ListDelegations(ctx, filter)
 }
 
 func (h *Handler) GetStats(c *gin.Context) {
 	type StatsProvider interface {
-		GetStats() (map[string]interface{}, error)
+		GetStats(ctx context.Context) (map[string]interface{}, error)
 	}
 
 	provider, ok := h.service.(StatsProvider)
 	if !ok {
-		c.JSON(http.StatusNotImplemented, gin.H{
-			"error": "Stats not available",
-		})
+		notImplemented(c, "Stats not available")
 		return
 	}
 
-	stats, err := provider.GetStats()
+	stats, err := provider.GetStats(c.Request.Context())
 	if err != nil {
 		h.logger.Errorw("Failed to get stats", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve statistics",
-		})
+		respondError(c, err, "Failed to retrieve statistics")
 		return
 	}
 
 	c.JSON(http.StatusOK, stats)
 }
+
+func (h *Handler) GetTimeSeries(c *gin.Context) {
+	type TimeSeriesProvider interface {
+		GetTimeSeries(ctx context.Context, query domain.TimeSeriesQuery) ([]domain.TimeSeriesBucket, error)
+	}
+
+	provider, ok := h.service.(TimeSeriesProvider)
+	if !ok {
+		notImplemented(c, "Time series not available")
+		return
+	}
+
+	query := domain.TimeSeriesQuery{
+		Interval: domain.TimeSeriesInterval(c.DefaultQuery("interval", string(domain.IntervalDay))),
+		Baker:    c.Query("baker"),
+	}
+
+	if !query.Interval.Valid() {
+		invalidParameter(c, "Invalid interval parameter. Must be one of day, week, month")
+		return
+	}
+
+	if query.From, query.To, ok = parseTimeRange(c); !ok {
+		return
+	}
+
+	buckets, err := provider.GetTimeSeries(c.Request.Context(), query)
+	if err != nil {
+		h.logger.Errorw("Failed to get time series", "error", err)
+		respondError(c, err, "Failed to retrieve time series")
+		return
+	}
+
+	if buckets == nil {
+		buckets = []domain.TimeSeriesBucket{}
+	}
+
+	if wantsCSV(c) {
+		writeTimeSeriesCSV(c, buckets)
+		return
+	}
+
+	c.JSON(http.StatusOK, domain.TimeSeriesResponse{
+		Interval: query.Interval,
+		Data:     buckets,
+	})
+}
+
+func (h *Handler) GetTopReport(c *gin.Context) {
+	type TopReportProvider interface {
+		GetTopReport(ctx context.Context, query domain.TopQuery) (*domain.TopReport, error)
+	}
+
+	provider, ok := h.service.(TopReportProvider)
+	if !ok {
+		notImplemented(c, "Top report not available")
+		return
+	}
+
+	var query domain.TopQuery
+	if query.Limit, ok = parseLimit(c); !ok {
+		return
+	}
+	if query.From, query.To, ok = parseTimeRange(c); !ok {
+		return
+	}
+
+	report, err := provider.GetTopReport(c.Request.Context(), query)
+	if err != nil {
+		h.logger.Errorw("Failed to get top report", "error", err)
+		respondError(c, err, "Failed to retrieve top report")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) GetLargeMovements(c *gin.Context) {
+	type MovementsProvider interface {
+		GetLargeMovements(ctx context.Context, query domain.MovementQuery) (*domain.MovementsResponse, error)
+	}
+
+	provider, ok := h.service.(MovementsProvider)
+	if !ok {
+		notImplemented(c, "Large movements not available")
+		return
+	}
+
+	var query domain.MovementQuery
+	if minAmountStr := c.Query("min_amount"); minAmountStr != "" {
+		minAmount, err := strconv.ParseInt(minAmountStr, 10, 64)
+		if err != nil || minAmount <= 0 {
+			invalidParameter(c, "Invalid min_amount parameter. Must be a positive amount in mutez")
+			return
+		}
+		query.MinAmount = minAmount
+	}
+	if query.Limit, ok = parseLimit(c); !ok {
+		return
+	}
+	if query.From, query.To, ok = parseTimeRange(c); !ok {
+		return
+	}
+
+	movements, err := provider.GetLargeMovements(c.Request.Context(), query)
+	if err != nil {
+		h.logger.Errorw("Failed to get large movements", "error", err)
+		respondError(c, err, "Failed to retrieve large movements")
+		return
+	}
+
+	c.JSON(http.StatusOK, movements)
+}
+
+func (h *Handler) GetDelegator(c *gin.Context) {
+	type DelegatorStateProvider interface {
+		GetDelegatorState(ctx context.Context, query domain.DelegatorQuery) (*domain.DelegatorState, error)
+	}
+
+	provider, ok := h.service.(DelegatorStateProvider)
+	if !ok {
+		notImplemented(c, "Delegator state not available")
+		return
+	}
+
+	query := domain.DelegatorQuery{Delegator: c.Param("address")}
+	if query.AsOf, ok = parseAsOf(c); !ok {
+		return
+	}
+
+	state, err := provider.GetDelegatorState(c.Request.Context(), query)
+	if err != nil {
+		if !domain.IsKind(err, domain.KindNotFound) && !domain.IsKind(err, domain.KindValidation) {
+			h.logger.Errorw("Failed to get delegator state", "error", err, "delegator", query.Delegator)
+		}
+		respondError(c, err, "Failed to retrieve delegator")
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+func (h *Handler) GetBakerDelegators(c *gin.Context) {
+	type BakerDelegatorsProvider interface {
+		GetBakerDelegators(ctx context.Context, query domain.BakerDelegatorsQuery) (*domain.BakerDelegators, error)
+	}
+
+	provider, ok := h.service.(BakerDelegatorsProvider)
+	if !ok {
+		notImplemented(c, "Baker delegators not available")
+		return
+	}
+
+	query := domain.BakerDelegatorsQuery{Baker: c.Param("address")}
+	if query.Limit, ok = parseLimit(c); !ok {
+		return
+	}
+	if query.Offset, ok = parseOffset(c); !ok {
+		return
+	}
+	if query.AsOf, ok = parseAsOf(c); !ok {
+		return
+	}
+
+	result, err := provider.GetBakerDelegators(c.Request.Context(), query)
+	if err != nil {
+		if !domain.IsKind(err, domain.KindValidation) {
+			h.logger.Errorw("Failed to get baker delegators", "error", err, "baker", query.Baker)
+		}
+		respondError(c, err, "Failed to retrieve baker delegators")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) GetCycleStats(c *gin.Context) {
+	type CycleStatsProvider interface {
+		GetCycleStats(ctx context.Context, query domain.CycleStatsQuery) ([]domain.CycleStats, error)
+	}
+
+	provider, ok := h.service.(CycleStatsProvider)
+	if !ok {
+		notImplemented(c, "Cycle stats not available")
+		return
+	}
+
+	query := domain.CycleStatsQuery{Baker: c.Query("baker")}
+	if query.FromCycle, ok = parseCycleParam(c, "from_cycle"); !ok {
+		return
+	}
+	if query.ToCycle, ok = parseCycleParam(c, "to_cycle"); !ok {
+		return
+	}
+	if query.FromCycle != nil && query.ToCycle != nil && *query.FromCycle > *query.ToCycle {
+		invalidParameter(c, "from_cycle must not be after to_cycle")
+		return
+	}
+	if query.Limit, ok = parseLimit(c); !ok {
+		return
+	}
+
+	stats, err := provider.GetCycleStats(c.Request.Context(), query)
+	if err != nil {
+		h.logger.Errorw("Failed to get cycle stats", "error", err)
+		respondError(c, err, "Failed to retrieve cycle stats")
+		return
+	}
+
+	if stats == nil {
+		stats = []domain.CycleStats{}
+	}
+
+	c.JSON(http.StatusOK, domain.CycleStatsResponse{Data: stats})
+}
+
+// parseLimit reads the optional limit query parameter. It writes a 400
+// response and returns false when the value is not a positive integer.
+func parseLimit(c *gin.Context) (int, bool) {
+	limitStr := c.Query("limit")
+	if limitStr == "" {
+		return 0, true
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		invalidParameter(c, "Invalid limit parameter. Must be a positive integer")
+		return 0, false
+	}
+
+	return limit, true
+}
+
+// parseCycleParam reads an optional cycle index query parameter. It writes a
+// 400 response and returns false when the value is not a non-negative integer.
+func parseCycleParam(c *gin.Context, name string) (*int64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	cycle, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || cycle < 0 {
+		invalidParameter(c, "Invalid "+name+" parameter. Must be a non-negative integer")
+		return nil, false
+	}
+
+	return &cycle, true
+}
+
+// parseOffset reads the optional offset query parameter. It writes a 400
+// response and returns false when the value is not a non-negative integer.
+func parseOffset(c *gin.Context) (int, bool) {
+	offsetStr := c.Query("offset")
+	if offsetStr == "" {
+		return 0, true
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		invalidParameter(c, "Invalid offset parameter. Must be a non-negative integer")
+		return 0, false
+	}
+
+	return offset, true
+}
+
+// parseAsOf reads the optional as_of query parameter, either a block level
+// or an RFC3339/YYYY-MM-DD timestamp. It writes a 400 response and returns
+// false when the value is neither.
+func parseAsOf(c *gin.Context) (*domain.AsOf, bool) {
+	value := c.Query("as_of")
+	if value == "" {
+		return nil, true
+	}
+
+	if level, err := strconv.ParseInt(value, 10, 64); err == nil && level > 0 {
+		return &domain.AsOf{Level: &level}, true
+	}
+
+	timestamp, err := parseTimeParam(value)
+	if err != nil {
+		invalidParameter(c, "Invalid as_of parameter. Must be a block level, RFC3339 or YYYY-MM-DD")
+		return nil, false
+	}
+
+	return &domain.AsOf{Timestamp: timestamp}, true
+}
+
+// parseTimeRange reads the optional from/to query parameters. It writes a
+// 400 response and returns false when either is malformed or the range is
+// empty.
+func parseTimeRange(c *gin.Context) (*time.Time, *time.Time, bool) {
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		invalidParameter(c, "Invalid from parameter. Must be RFC3339 or YYYY-MM-DD")
+		return nil, nil, false
+	}
+
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		invalidParameter(c, "Invalid to parameter. Must be RFC3339 or YYYY-MM-DD")
+		return nil, nil, false
+	}
+
+	if from != nil && to != nil && !from.Before(*to) {
+		invalidParameter(c, "from must be before to")
+		return nil, nil, false
+	}
+
+	return from, to, true
+}
+
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func wantsCSV(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/csv")
+}
+
+func writeTimeSeriesCSV(c *gin.Context, buckets []domain.TimeSeriesBucket) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"bucket_start", "count", "total_amount", "unique_delegators", "new_delegators", "returning_delegators"})
+	for _, b := range buckets {
+		_ = w.Write([]string{
+			b.BucketStart.Format(time.RFC3339),
+			strconv.FormatInt(b.Count, 10),
+			b.TotalAmount,
+			strconv.FormatInt(b.UniqueDelegators, 10),
+			strconv.FormatInt(b.NewDelegators, 10),
+			strconv.FormatInt(b.ReturningDelegators, 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.Error(err)
+	}
+}
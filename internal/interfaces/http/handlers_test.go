@@ -1,16 +1,21 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/pubsub"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -21,7 +26,17 @@ type MockService struct {
 	mock.Mock
 }
 
-func (m *MockService) GetDelegations(year *int) ([]domain.Delegation, error) {
+func (m *MockService) CheckReadiness(ctx context.Context) domain.HealthReport {
+	args := m.Called(ctx)
+	return args.Get(0).(domain.HealthReport)
+}
+
+func (m *MockService) IndexerHealth() domain.IndexerHealth {
+	args := m.Called()
+	return args.Get(0).(domain.IndexerHealth)
+}
+
+func (m *MockService) GetDelegations(ctx context.Context, year *int) ([]domain.Delegation, error) {
 	args := m.Called(year)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -29,7 +44,7 @@ func (m *MockService) GetDelegations(year *int) ([]domain.Delegation, error) {
 	return args.Get(0).([]domain.Delegation), args.Error(1)
 }
 
-func (m *MockService) IndexDelegations(fromLevel int64) error {
+func (m *MockService) IndexDelegations(ctx context.Context, fromLevel int64) error {
 	args := m.Called(fromLevel)
 	return args.Error(0)
 }
@@ -43,7 +58,7 @@ func (m *MockService) StopPolling() {
 	m.Called()
 }
 
-func (m *MockService) GetStats() (map[string]interface{}, error) {
+func (m *MockService) GetStats(ctx context.Context) (map[string]interface{}, error) {
 	args := m.Called()
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -51,16 +66,259 @@ func (m *MockService) GetStats() (map[string]interface{}, error) {
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
 
+func (m *MockService) GetTimeSeries(ctx context.Context, query domain.TimeSeriesQuery) ([]domain.TimeSeriesBucket, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.TimeSeriesBucket), args.Error(1)
+}
+
+func (m *MockService) GetTopReport(ctx context.Context, query domain.TopQuery) (*domain.TopReport, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TopReport), args.Error(1)
+}
+
+func (m *MockService) GetLargeMovements(ctx context.Context, query domain.MovementQuery) (*domain.MovementsResponse, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MovementsResponse), args.Error(1)
+}
+
+func (m *MockService) GetDelegatorState(ctx context.Context, query domain.DelegatorQuery) (*domain.DelegatorState, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DelegatorState), args.Error(1)
+}
+
+func (m *MockService) GetBakerDelegators(ctx context.Context, query domain.BakerDelegatorsQuery) (*domain.BakerDelegators, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BakerDelegators), args.Error(1)
+}
+
+func (m *MockService) ListDelegations(ctx context.Context, filter domain.DelegationFilter) ([]domain.Delegation, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Delegation), args.Error(1)
+}
+
+func (m *MockService) GetCycleStats(ctx context.Context, query domain.CycleStatsQuery) ([]domain.CycleStats, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.CycleStats), args.Error(1)
+}
+
+func (m *MockService) ExportDelegations(ctx context.Context, filter domain.DelegationFilter, emit func(domain.Delegation) error) error {
+	args := m.Called(filter)
+	for _, d := range args.Get(0).([]domain.Delegation) {
+		if err := emit(d); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockService) StreamDelegations(ctx context.Context, query domain.StreamQuery, emit func([]domain.Delegation) error) error {
+	args := m.Called(query)
+	for _, batch := range args.Get(0).([][]domain.Delegation) {
+		if err := emit(batch); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockService) SubscribeDelegations(buffer int) *pubsub.Subscription {
+	args := m.Called(buffer)
+	return args.Get(0).(*pubsub.Subscription)
+}
+
+func (m *MockService) CreateWebhook(ctx context.Context, url string, filter domain.SubscriptionFilter) (*domain.Webhook, error) {
+	args := m.Called(url, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Webhook), args.Error(1)
+}
+
+func (m *MockService) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Webhook), args.Error(1)
+}
+
+func (m *MockService) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Webhook), args.Error(1)
+}
+
+func (m *MockService) DeleteWebhook(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockService) ListWebhookDeliveries(ctx context.Context, query domain.WebhookDeliveryQuery) ([]domain.WebhookDelivery, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockService) RedeliverWebhookDelivery(ctx context.Context, webhookID string, id int64) error {
+	args := m.Called(webhookID, id)
+	return args.Error(0)
+}
+
+func (m *MockService) CreateAPIKey(ctx context.Context, name string, scopes []domain.Scope) (*domain.APIKey, error) {
+	args := m.Called(name, scopes)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockService) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	args := m.Called()
+	return args.Get(0).([]domain.APIKey), args.Error(1)
+}
+
+func (m *MockService) RotateAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockService) RevokeAPIKey(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockService) AuthenticateAPIKey(ctx context.Context, secret string) (*domain.Principal, error) {
+	args := m.Called(secret)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Principal), args.Error(1)
+}
+
+func (m *MockService) PausePolling(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockService) ResumePolling(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockService) IndexerStatus(ctx context.Context) (*domain.IndexerStatus, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IndexerStatus), args.Error(1)
+}
+
+func (m *MockService) StartReindex(ctx context.Context, fromLevel, toLevel int64) (*domain.Job, error) {
+	args := m.Called(fromLevel, toLevel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Job), args.Error(1)
+}
+
+func (m *MockService) StartVerification(ctx context.Context, fromLevel, toLevel int64, repair bool) (*domain.Job, error) {
+	args := m.Called(fromLevel, toLevel, repair)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Job), args.Error(1)
+}
+
+func (m *MockService) ListJobs(ctx context.Context, query domain.JobQuery) ([]domain.Job, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Job), args.Error(1)
+}
+
+func (m *MockService) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Job), args.Error(1)
+}
+
+func (m *MockService) CancelJob(ctx context.Context, id string) (*domain.Job, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Job), args.Error(1)
+}
+
 func setupRouter(service domain.DelegationService) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	log, _ := logger.New("debug", "test")
 	handler := NewHandler(service, log)
 
 	router := gin.New()
+	router.Use(ValidationMiddleware(NewOpenAPISpec()))
 	router.GET("/xtz/delegations", handler.GetDelegations)
-	router.GET("/health", handler.GetHealth)
+	router.GET("/xtz/delegations/stream", handler.StreamDelegations)
+	router.GET("/xtz/delegations/ws", handler.SubscribeDelegations)
+	router.GET("/health", handler.GetLiveness)
+	router.GET("/health/indexer", handler.GetIndexerHealth)
 	router.GET("/ready", handler.GetReadiness)
 	router.GET("/stats", handler.GetStats)
+	router.GET("/xtz/stats/timeseries", handler.GetTimeSeries)
+	router.GET("/xtz/stats/top", handler.GetTopReport)
+	router.GET("/xtz/stats/movements", handler.GetLargeMovements)
+	router.GET("/xtz/stats/cycles", handler.GetCycleStats)
+	router.GET("/xtz/delegators/:address", handler.GetDelegator)
+	router.GET("/xtz/bakers/:address/delegators", handler.GetBakerDelegators)
+	router.POST("/admin/webhooks", handler.CreateWebhook)
+	router.GET("/admin/webhooks", handler.ListWebhooks)
+	router.GET("/admin/webhooks/:id", handler.GetWebhook)
+	router.DELETE("/admin/webhooks/:id", handler.DeleteWebhook)
+	router.GET("/admin/webhooks/:id/deliveries", handler.ListWebhookDeliveries)
+	router.POST("/admin/webhooks/:id/deliveries/:delivery_id/redeliver", handler.RedeliverWebhookDelivery)
+	router.POST("/admin/api-keys", handler.CreateAPIKey)
+	router.GET("/admin/api-keys", handler.ListAPIKeys)
+	router.POST("/admin/api-keys/:id/rotate", handler.RotateAPIKey)
+	router.DELETE("/admin/api-keys/:id", handler.RevokeAPIKey)
+	router.GET("/admin/indexer", handler.GetIndexerStatus)
+	router.POST("/admin/indexer/pause", handler.PausePolling)
+	router.POST("/admin/indexer/resume", handler.ResumePolling)
+	router.POST("/admin/jobs/reindex", handler.StartReindex)
+	router.POST("/admin/jobs/verify", handler.StartVerification)
+	router.GET("/admin/jobs", handler.ListJobs)
+	router.GET("/admin/jobs/:id", handler.GetJob)
+	router.POST("/admin/jobs/:id/cancel", handler.CancelJob)
 
 	return router
 }
@@ -161,10 +419,11 @@ func TestHandler_GetDelegationsInvalidYear(t *testing.T) {
 
 			assert.Equal(t, http.StatusBadRequest, w.Code)
 
-			var response map[string]string
+			var response Problem
 			err := json.Unmarshal(w.Body.Bytes(), &response)
 			require.NoError(t, err)
-			assert.Contains(t, response["error"], tc.expected)
+			assert.Contains(t, response.Detail, tc.expected)
+			assert.Equal(t, "invalid_parameter", response.Code)
 		})
 	}
 }
@@ -191,73 +450,151 @@ func TestHandler_GetDelegationsEmptyResult(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
-func TestHandler_GetHealth(t *testing.T) {
+func TestHandler_GetDelegationsCSV(t *testing.T) {
 	mockService := new(MockService)
 	router := setupRouter(mockService)
 
-	delegations := []domain.Delegation{
-		{ID: "1"}, {ID: "2"}, {ID: "3"},
-	}
-
-	mockService.On("GetDelegations", (*int)(nil)).Return(delegations, nil)
+	year := 2024
+	mockService.On("ExportDelegations", domain.DelegationFilter{Year: &year}).Return([]domain.Delegation{
+		{Timestamp: time.Date(2024, 5, 5, 6, 29, 14, 0, time.UTC), Amount: "150000000000", Delegator: "tz1one", Level: "5000000", OperationHash: "op1", BlockHash: "b1", Baker: "tz1baker", PrevBaker: "tz1prev"},
+		{Timestamp: time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), Amount: "1", Delegator: "tz1two", Level: "4999000", OperationHash: "op2", BlockHash: "b2"},
+	}, nil)
 
-	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegations?year=2024&format=csv", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "timestamp,amount,delegator,level,operation_hash,block_hash,baker,prev_baker\n"+
+		"2024-05-05T06:29:14Z,150000000000,tz1one,5000000,op1,b1,tz1baker,tz1prev\n"+
+		"2024-05-04T00:00:00Z,1,tz1two,4999000,op2,b2,,\n", w.Body.String())
 
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	require.NoError(t, err)
+	mockService.AssertNotCalled(t, "GetDelegations", mock.Anything)
+}
+
+func TestHandler_GetDelegationsNDJSON(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
 
-	assert.Equal(t, "healthy", response["status"])
-	assert.Equal(t, float64(3), response["total_delegations"])
+	mockService.On("ExportDelegations", domain.DelegationFilter{}).Return([]domain.Delegation{
+		{Timestamp: time.Date(2024, 5, 5, 6, 29, 14, 0, time.UTC), Amount: "100", Delegator: "tz1one", Level: "5000000", OperationHash: "op1", BlockHash: "b1", Baker: "tz1baker"},
+		{Timestamp: time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), Amount: "1", Delegator: "tz1two", Level: "4999000", OperationHash: "op2"},
+	}, nil)
 
-	mockService.AssertExpectations(t)
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegations", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var first domain.Delegation
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "op1", first.OperationHash)
+	assert.Equal(t, "tz1one", first.Delegator)
+	assert.Contains(t, lines[0], `"block_hash":"b1","baker":"tz1baker","prev_baker":""`)
 }
 
-func TestHandler_GetHealthUnhealthy(t *testing.T) {
+func TestHandler_GetDelegationsExportFailure(t *testing.T) {
 	mockService := new(MockService)
 	router := setupRouter(mockService)
 
-	mockService.On("GetDelegations", (*int)(nil)).Return(nil, fmt.Errorf("database connection failed"))
+	mockService.On("ExportDelegations", domain.DelegationFilter{}).Return([]domain.Delegation{}, fmt.Errorf("database error"))
 
-	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegations?format=ndjson", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to retrieve delegations")
+}
 
-	var response map[string]string
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	require.NoError(t, err)
+func TestHandler_GetLiveness(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 
-	assert.Equal(t, "unhealthy", response["status"])
-	assert.Contains(t, response["error"], "database connection failed")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"up"}`, w.Body.String())
 
-	mockService.AssertExpectations(t)
+	// Liveness must not touch any dependency.
+	mockService.AssertNotCalled(t, "GetDelegations", mock.Anything)
+	mockService.AssertNotCalled(t, "CheckReadiness", mock.Anything)
 }
 
 func TestHandler_GetReadiness(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status domain.HealthStatus
+		code   int
+	}{
+		{"Up", domain.HealthUp, http.StatusOK},
+		{"Degraded", domain.HealthDegraded, http.StatusOK},
+		{"Down", domain.HealthDown, http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockService)
+			router := setupRouter(mockService)
+
+			mockService.On("CheckReadiness", mock.Anything).Return(domain.HealthReport{
+				Status: tc.status,
+				Checks: map[string]domain.DependencyHealth{
+					"database": {Status: domain.HealthUp, LatencyMS: 1.5},
+					"tzkt":     {Status: tc.status, Detail: "unreachable"},
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.code, w.Code)
+
+			var response domain.HealthReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.status, response.Status)
+			assert.Equal(t, 1.5, response.Checks["database"].LatencyMS)
+			mockService.AssertNotCalled(t, "GetDelegations", mock.Anything)
+		})
+	}
+}
+
+func TestHandler_GetIndexerHealth(t *testing.T) {
 	mockService := new(MockService)
 	router := setupRouter(mockService)
 
-	mockService.On("GetDelegations", (*int)(nil)).Return([]domain.Delegation{}, nil)
+	mockService.On("IndexerHealth").Return(domain.IndexerHealth{
+		Status:       domain.HealthDegraded,
+		HeadLevel:    5000100,
+		SyncedLevel:  5000000,
+		LagBlocks:    100,
+		MaxLagBlocks: 10,
+	}).Once()
 
-	req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+	req := httptest.NewRequest(http.MethodGet, "/health/indexer", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 
-	var response map[string]string
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	require.NoError(t, err)
+	var response domain.IndexerHealth
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(100), response.LagBlocks)
 
-	assert.Equal(t, "ready", response["status"])
+	mockService.On("IndexerHealth").Return(domain.IndexerHealth{Status: domain.HealthUp})
 
-	mockService.AssertExpectations(t)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/indexer", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
 }
 
 func TestHandler_GetStats(t *testing.T) {
@@ -290,3 +627,895 @@ func TestHandler_GetStats(t *testing.T) {
 
 	mockService.AssertExpectations(t)
 }
+
+func TestHandler_GetTimeSeries(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	buckets := []domain.TimeSeriesBucket{
+		{
+			BucketStart:         from,
+			Count:               12,
+			TotalAmount:         "5000000",
+			UniqueDelegators:    10,
+			NewDelegators:       4,
+			ReturningDelegators: 6,
+		},
+	}
+
+	mockService.On("GetTimeSeries", domain.TimeSeriesQuery{
+		Interval: domain.IntervalWeek,
+		From:     &from,
+		To:       &to,
+		Baker:    "tz1baker",
+	}).Return(buckets, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/stats/timeseries?interval=week&from=2024-01-01&to=2024-02-01T00:00:00Z&baker=tz1baker", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response domain.TimeSeriesResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+
+	assert.Equal(t, domain.IntervalWeek, response.Interval)
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, int64(12), response.Data[0].Count)
+	assert.Equal(t, "5000000", response.Data[0].TotalAmount)
+	assert.Equal(t, int64(6), response.Data[0].ReturningDelegators)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetTimeSeriesCSV(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	buckets := []domain.TimeSeriesBucket{
+		{
+			BucketStart:         time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			Count:               3,
+			TotalAmount:         "300",
+			UniqueDelegators:    2,
+			NewDelegators:       1,
+			ReturningDelegators: 1,
+		},
+	}
+
+	mockService.On("GetTimeSeries", domain.TimeSeriesQuery{Interval: domain.IntervalMonth}).Return(buckets, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/stats/timeseries?interval=month", nil)
+	req.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	assert.Equal(t,
+		"bucket_start,count,total_amount,unique_delegators,new_delegators,returning_delegators\n"+
+			"2024-03-01T00:00:00Z,3,300,2,1,1\n",
+		w.Body.String())
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetTimeSeriesInvalidParams(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"Invalid interval", "interval=year", "Invalid interval parameter"},
+		{"Invalid from", "from=yesterday", "Invalid from parameter"},
+		{"Invalid to", "to=2024-13-01", "Invalid to parameter"},
+		{"Inverted range", "from=2024-02-01&to=2024-01-01", "from must be before to"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/xtz/stats/timeseries?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response Problem
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			require.NoError(t, err)
+			assert.Contains(t, response.Detail, tc.expected)
+			assert.Equal(t, "invalid_parameter", response.Code)
+		})
+	}
+
+	mockService.AssertNotCalled(t, "GetTimeSeries", mock.Anything)
+}
+
+func TestHandler_GetTopReport(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := &domain.TopReport{
+		TopDelegations: []domain.Movement{
+			{Delegator: "tz1whale", Amount: "900000000000", PrevBaker: "tz1old", Baker: "tz1new", Level: "5000000"},
+		},
+		TopDelegators: []domain.TopDelegator{
+			{Delegator: "tz1whale", TotalAmount: "1800000000000", DelegationCount: 2},
+		},
+	}
+
+	mockService.On("GetTopReport", domain.TopQuery{Limit: 5, From: &from}).Return(report, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/stats/top?limit=5&from=2024-01-01", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response domain.TopReport
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+
+	require.Len(t, response.TopDelegations, 1)
+	assert.Equal(t, "tz1old", response.TopDelegations[0].PrevBaker)
+	assert.Equal(t, "tz1new", response.TopDelegations[0].Baker)
+	require.Len(t, response.TopDelegators, 1)
+	assert.Equal(t, "1800000000000", response.TopDelegators[0].TotalAmount)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetTopReportInvalidLimit(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	for _, limit := range []string{"abc", "0", "-3"} {
+		req := httptest.NewRequest(http.MethodGet, "/xtz/stats/top?limit="+limit, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "limit=%s", limit)
+	}
+
+	mockService.AssertNotCalled(t, "GetTopReport", mock.Anything)
+}
+
+func TestHandler_GetLargeMovements(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	response := &domain.MovementsResponse{
+		MinAmount: "500000000",
+		Data: []domain.Movement{
+			{Delegator: "tz1whale", Amount: "600000000", Baker: "tz1new"},
+		},
+	}
+
+	mockService.On("GetLargeMovements", domain.MovementQuery{MinAmount: 500000000}).Return(response, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/stats/movements?min_amount=500000000", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body domain.MovementsResponse
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	require.NoError(t, err)
+	assert.Equal(t, "500000000", body.MinAmount)
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, "tz1whale", body.Data[0].Delegator)
+
+	req = httptest.NewRequest(http.MethodGet, "/xtz/stats/movements?min_amount=lots", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetDelegator(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	state := &domain.DelegatorState{
+		Delegator:      "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL",
+		Baker:          "tz1baker",
+		SinceLevel:     2338084,
+		SinceTimestamp: time.Date(2022, 5, 5, 6, 29, 14, 0, time.UTC),
+		LastAmount:     "125896",
+	}
+
+	mockService.On("GetDelegatorState", domain.DelegatorQuery{Delegator: "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL"}).Return(state, nil)
+	mockService.On("GetDelegatorState", domain.DelegatorQuery{Delegator: "tz1unknown"}).Return(nil, domain.ErrDelegatorNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegators/tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response domain.DelegatorState
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, "tz1baker", response.Baker)
+	assert.Equal(t, int64(2338084), response.SinceLevel)
+
+	req = httptest.NewRequest(http.MethodGet, "/xtz/delegators/tz1unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetBakerDelegators(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	result := &domain.BakerDelegators{
+		Baker:          "tz1baker",
+		DelegatorCount: 2,
+		TotalAmount:    "3000",
+		Data: []domain.DelegatorState{
+			{Delegator: "tz1one", Baker: "tz1baker", LastAmount: "2000"},
+			{Delegator: "tz1two", Baker: "tz1baker", LastAmount: "1000"},
+		},
+	}
+
+	mockService.On("GetBakerDelegators", domain.BakerDelegatorsQuery{Baker: "tz1baker", Limit: 2, Offset: 10}).Return(result, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/bakers/tz1baker/delegators?limit=2&offset=10", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response domain.BakerDelegators
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), response.DelegatorCount)
+	assert.Equal(t, "3000", response.TotalAmount)
+	assert.Len(t, response.Data, 2)
+
+	req = httptest.NewRequest(http.MethodGet, "/xtz/bakers/tz1baker/delegators?offset=-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetDelegatorAsOf(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	level := int64(2000000)
+	quarterEnd := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+	state := &domain.DelegatorState{Delegator: "tz1one", Baker: "tz1old", SinceLevel: 1900000}
+
+	mockService.On("GetDelegatorState", domain.DelegatorQuery{
+		Delegator: "tz1one",
+		AsOf:      &domain.AsOf{Level: &level},
+	}).Return(state, nil)
+	mockService.On("GetDelegatorState", domain.DelegatorQuery{
+		Delegator: "tz1one",
+		AsOf:      &domain.AsOf{Timestamp: &quarterEnd},
+	}).Return(state, nil)
+
+	for _, asOf := range []string{"2000000", "2024-03-31T23:59:59Z"} {
+		req := httptest.NewRequest(http.MethodGet, "/xtz/delegators/tz1one?as_of="+asOf, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "as_of=%s", asOf)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegators/tz1one?as_of=last-quarter", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetBakerDelegatorsAsOfOutOfRange(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	level := int64(999999999)
+	mockService.On("GetBakerDelegators", domain.BakerDelegatorsQuery{
+		Baker: "tz1baker",
+		AsOf:  &domain.AsOf{Level: &level},
+	}).Return(nil, domain.ErrAsOfOutOfRange)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/bakers/tz1baker/delegators?as_of=999999999", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetDelegationsWithCycle(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	cycle := int64(650)
+	year := 2023
+	mockService.On("ListDelegations", domain.DelegationFilter{Year: &year, Cycle: &cycle}).Return([]domain.Delegation{
+		{Delegator: "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL", Amount: "125896", Level: "4500000", Cycle: &cycle},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegations?year=2023&cycle=650", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response domain.DelegationResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Len(t, response.Data, 1)
+	require.NotNil(t, response.Data[0].Cycle)
+	assert.Equal(t, cycle, *response.Data[0].Cycle)
+
+	req = httptest.NewRequest(http.MethodGet, "/xtz/delegations?cycle=-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetCycleStats(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	fromCycle, toCycle := int64(700), int64(710)
+	stats := []domain.CycleStats{
+		{
+			Cycle: domain.Cycle{
+				Index:      710,
+				FirstLevel: 5546001,
+				LastLevel:  5570576,
+				StartTime:  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+				EndTime:    time.Date(2024, 6, 3, 20, 0, 0, 0, time.UTC),
+			},
+			Count:            25,
+			TotalAmount:      "123456",
+			UniqueDelegators: 20,
+		},
+	}
+
+	mockService.On("GetCycleStats", domain.CycleStatsQuery{
+		FromCycle: &fromCycle,
+		ToCycle:   &toCycle,
+		Baker:     "tz1baker",
+	}).Return(stats, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/stats/cycles?from_cycle=700&to_cycle=710&baker=tz1baker", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string][]map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Len(t, response["data"], 1)
+	assert.Equal(t, float64(710), response["data"][0]["index"])
+	assert.Equal(t, float64(5546001), response["data"][0]["first_level"])
+	assert.Equal(t, float64(25), response["data"][0]["count"])
+
+	req = httptest.NewRequest(http.MethodGet, "/xtz/stats/cycles?from_cycle=710&to_cycle=700", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_StreamDelegations(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	year := 2024
+	after := int64(41)
+	batches := [][]domain.Delegation{
+		{
+			{Delegator: "tz1one", Amount: "100", Level: "5000000", Seq: 42},
+			{Delegator: "tz1two", Amount: "200", Level: "5000001", Seq: 43},
+		},
+		nil,
+	}
+
+	mockService.On("StreamDelegations", domain.StreamQuery{
+		Filter:   domain.DelegationFilter{Year: &year},
+		AfterSeq: &after,
+	}).Return(batches, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegations/stream?year=2024", nil)
+	req.Header.Set("Last-Event-ID", "41")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "id: 42\nevent: delegation\ndata: {")
+	assert.Contains(t, body, `"delegator":"tz1two"`)
+	assert.Contains(t, body, "id: 43\n")
+	assert.Contains(t, body, ": keepalive\n\n")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_StreamDelegationsInvalidLastEventID(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegations/stream?last_event_id=abc", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "StreamDelegations", mock.Anything)
+}
+
+func TestHandler_SubscribeDelegations(t *testing.T) {
+	mockService := new(MockService)
+	hub := pubsub.NewHub()
+	mockService.On("SubscribeDelegations", wsSendBuffer).Return(hub.Subscribe(wsSendBuffer))
+
+	server := httptest.NewServer(setupRouter(mockService))
+	defer server.Close()
+
+	url := "ws" + server.URL[len("http"):] + "/xtz/delegations/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var msg wsMessage
+	require.NoError(t, conn.WriteJSON(wsRequest{
+		Action: "subscribe",
+		ID:     "big",
+		Filter: domain.SubscriptionFilter{Bakers: []string{"tz1baker"}, MinAmount: 1000},
+	}))
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, wsMessage{Type: "subscribed", ID: "big"}, msg)
+
+	require.NoError(t, conn.WriteJSON(wsRequest{Action: "subscribe", ID: "mine", Filter: domain.SubscriptionFilter{Delegators: []string{"tz1mine"}}}))
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, wsMessage{Type: "subscribed", ID: "mine"}, msg)
+
+	hub.Publish([]domain.Delegation{
+		{Delegator: "tz1small", Baker: "tz1baker", Amount: "10", Level: "100"},
+		{Delegator: "tz1mine", Baker: "tz1baker", Amount: "5000", Level: "101", OperationHash: "op1"},
+	})
+
+	msg = wsMessage{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "delegation", msg.Type)
+	assert.ElementsMatch(t, []string{"big", "mine"}, msg.Subscriptions)
+	require.NotNil(t, msg.Data)
+	assert.Equal(t, "tz1mine", msg.Data.Delegator)
+	assert.Equal(t, "tz1baker", msg.Data.Baker)
+	assert.Equal(t, "op1", msg.Data.OperationHash)
+
+	require.NoError(t, conn.WriteJSON(wsRequest{Action: "unsubscribe", ID: "unknown"}))
+	msg = wsMessage{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "error", msg.Type)
+	assert.Equal(t, "unknown", msg.ID)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+	msg = wsMessage{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "error", msg.Type)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_SubscribeDelegationsOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, _ := logger.New("debug", "test")
+	mockService := new(MockService)
+	hub := pubsub.NewHub()
+	mockService.On("SubscribeDelegations", wsSendBuffer).Return(hub.Subscribe(wsSendBuffer))
+
+	handler := NewHandler(mockService, log)
+	handler.AllowWebSocketOrigins(config.CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}})
+	router := gin.New()
+	router.GET("/xtz/delegations/ws", handler.SubscribeDelegations)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	url := "ws" + server.URL[len("http"):] + "/xtz/delegations/ws"
+
+	testCases := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"No origin", "", true},
+		{"Same origin", server.URL, true},
+		{"Allowed origin", "https://app.example.com", true},
+		{"Other origin", "https://evil.example.com", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.origin != "" {
+				header.Set("Origin", tc.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if !tc.allowed {
+				require.Error(t, err)
+				assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			conn.Close()
+		})
+	}
+}
+
+func TestHandler_SubscribeDelegationsSlowConsumer(t *testing.T) {
+	mockService := new(MockService)
+	hub := pubsub.NewHub()
+	sub := hub.Subscribe(1)
+	mockService.On("SubscribeDelegations", wsSendBuffer).Return(sub)
+
+	server := httptest.NewServer(setupRouter(mockService))
+	defer server.Close()
+
+	url := "ws" + server.URL[len("http"):] + "/xtz/delegations/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var msg wsMessage
+	require.NoError(t, conn.WriteJSON(wsRequest{Action: "subscribe", ID: "all"}))
+	require.NoError(t, conn.ReadJSON(&msg))
+
+	batch := make([]domain.Delegation, 1000)
+	for i := range batch {
+		batch[i] = domain.Delegation{Delegator: fmt.Sprintf("tz1d%d", i), Amount: "1"}
+	}
+	hub.Publish(batch)
+
+	for {
+		_, _, err = conn.ReadMessage()
+		if err != nil {
+			break
+		}
+	}
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+	assert.Equal(t, "slow consumer", closeErr.Text)
+}
+
+func TestHandler_CreateWebhook(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	filter := domain.SubscriptionFilter{Bakers: []string{"tz1baker"}, MinAmount: 1000}
+	mockService.On("CreateWebhook", "https://example.com/hook", filter).Return(&domain.Webhook{
+		ID:     "wh-1",
+		URL:    "https://example.com/hook",
+		Secret: "s3cret",
+		Filter: filter,
+		Active: true,
+	}, nil)
+
+	body := `{"url":"https://example.com/hook","filter":{"bakers":["tz1baker"],"min_amount":1000}}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var webhook domain.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &webhook))
+	assert.Equal(t, "wh-1", webhook.ID)
+	assert.Equal(t, "s3cret", webhook.Secret)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_CreateWebhookInvalidRequest(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	for _, body := range []string{
+		`not json`,
+		`{"url":"ftp://example.com/hook"}`,
+		`{"url":"/relative"}`,
+		`{"url":"https://example.com/hook","filter":{"min_amount":-1}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	mockService.AssertNotCalled(t, "CreateWebhook", mock.Anything, mock.Anything)
+}
+
+func TestHandler_ListWebhookDeliveries(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	mockService.On("ListWebhookDeliveries", domain.WebhookDeliveryQuery{
+		WebhookID: "wh-1",
+		Status:    domain.WebhookDeliveryDead,
+		Limit:     20,
+	}).Return([]domain.WebhookDelivery{
+		{ID: 7, WebhookID: "wh-1", OperationHash: "op1", Payload: json.RawMessage(`{"amount":"1"}`), Status: domain.WebhookDeliveryDead, Attempts: 10, LastError: "unexpected status 500"},
+	}, nil)
+	mockService.On("ListWebhookDeliveries", domain.WebhookDeliveryQuery{WebhookID: "missing"}).Return(nil, domain.ErrWebhookNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/webhooks/wh-1/deliveries?status=dead&limit=20", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"last_error":"unexpected status 500"`)
+	assert.Contains(t, w.Body.String(), `"payload":{"amount":"1"}`)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/webhooks/missing/deliveries", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/webhooks/wh-1/deliveries?status=lost", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_RedeliverWebhookDelivery(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	mockService.On("RedeliverWebhookDelivery", "wh-1", int64(7)).Return(nil)
+	mockService.On("RedeliverWebhookDelivery", "wh-1", int64(8)).Return(domain.ErrWebhookDeliveryNotFound)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/webhooks/wh-1/deliveries/7/redeliver", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/webhooks/wh-1/deliveries/8/redeliver", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_CreateAPIKey(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	scopes := []domain.Scope{domain.ScopeReadDelegations, domain.ScopeReadStats}
+	mockService.On("CreateAPIKey", "dashboard", scopes).Return(&domain.APIKey{
+		ID:     "key-1",
+		Name:   "dashboard",
+		Prefix: "tzd_abcdefgh",
+		Secret: "tzd_abcdefghijkl",
+		Scopes: scopes,
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(`{"name":" dashboard ","scopes":["read:delegations","read:stats"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var key domain.APIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &key))
+	assert.Equal(t, "key-1", key.ID)
+	assert.Equal(t, "tzd_abcdefghijkl", key.Secret)
+
+	for _, body := range []string{
+		`not json`,
+		`{"name":"","scopes":["admin"]}`,
+		`{"name":"ops","scopes":[]}`,
+		`{"name":"ops","scopes":["write:delegations"]}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_RotateAndRevokeAPIKey(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	mockService.On("RotateAPIKey", "key-1").Return(&domain.APIKey{ID: "key-1", Secret: "tzd_new"}, nil)
+	mockService.On("RotateAPIKey", "key-2").Return(nil, domain.ErrAPIKeyNotFound)
+	mockService.On("RevokeAPIKey", "key-1").Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys/key-1/rotate", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "tzd_new")
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/api-keys/key-2/rotate", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "api_key_not_found", decodeProblem(t, w).Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/admin/api-keys/key-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_PauseAndResumePolling(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	mockService.On("PausePolling").Return(nil).Once()
+	mockService.On("IndexerStatus").Return(&domain.IndexerStatus{Paused: true, LastIndexedLevel: 5000000}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/indexer/pause", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"paused":true`)
+
+	mockService.On("ResumePolling").Return(nil).Once()
+	mockService.On("IndexerStatus").Return(&domain.IndexerStatus{Polling: true, LastIndexedLevel: 5000000}, nil).Once()
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/indexer/resume", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"polling":true`)
+
+	mockService.On("PausePolling").Return(domain.NewStorageUnavailableError(fmt.Errorf("connection refused"))).Once()
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/indexer/pause", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_StartJobs(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	mockService.On("StartReindex", int64(5000000), int64(5001000)).Return(&domain.Job{
+		ID: "job-1", Kind: domain.JobReindex, Status: domain.JobPending, FromLevel: 5000000, ToLevel: 5001000,
+	}, nil)
+	mockService.On("StartVerification", int64(1), int64(0), true).Return(&domain.Job{
+		ID: "job-2", Kind: domain.JobVerify, Status: domain.JobPending, FromLevel: 1, Repair: true,
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/jobs/reindex", strings.NewReader(`{"from_level":5000000,"to_level":5001000}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/admin/jobs/job-1", w.Header().Get("Location"))
+
+	var job domain.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, domain.JobPending, job.Status)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/jobs/verify", strings.NewReader(`{"from_level":1,"repair":true}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/admin/jobs/job-2", w.Header().Get("Location"))
+
+	for _, body := range []string{`not json`, `{}`, `{"from_level":0}`, `{"from_level":10,"to_level":5}`} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/jobs/reindex", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_GetAndCancelJob(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	running := &domain.Job{ID: "job-1", Kind: domain.JobReindex, Status: domain.JobRunning,
+		Progress: domain.JobProgress{Level: 5000500, Delegations: 42, Percent: 50}}
+	mockService.On("GetJob", "job-1").Return(running, nil)
+	mockService.On("GetJob", "missing").Return(nil, domain.ErrJobNotFound)
+	mockService.On("CancelJob", "job-1").Return(running, nil)
+	mockService.On("CancelJob", "job-2").Return(nil, domain.ErrJobFinished)
+	mockService.On("ListJobs", domain.JobQuery{}).Return([]domain.Job{*running}, nil)
+	mockService.On("ListJobs", domain.JobQuery{Kind: domain.JobBackup, Statuses: []domain.JobStatus{domain.JobFailed}, Limit: 5}).
+		Return([]domain.Job{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/jobs/job-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"percent":50`)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/jobs/missing", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "job_not_found", decodeProblem(t, w).Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/jobs/job-1/cancel", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/jobs/job-2/cancel", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "job_finished", decodeProblem(t, w).Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/jobs", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"job-1"`)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/jobs?kind=backup&status=failed&limit=5", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+
+	for _, query := range []string{"kind=vacuum", "status=done", "limit=0"} {
+		req = httptest.NewRequest(http.MethodGet, "/admin/jobs?"+query, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	mockService.AssertExpectations(t)
+}
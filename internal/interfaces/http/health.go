@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+type livenessResponse struct {
+	Status domain.HealthStatus `json:"status"`
+}
+
+// GetLiveness only reports that the process serves requests. It checks no
+// dependency, so that an outage elsewhere never gets the process restarted.
+func (h *Handler) GetLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, livenessResponse{Status: domain.HealthUp})
+}
+
+// GetReadiness reports each dependency with its status and latency. It
+// responds 503 only when the service cannot serve its API; a degraded
+// service stays in rotation.
+func (h *Handler) GetReadiness(c *gin.Context) {
+	type ReadinessChecker interface {
+		CheckReadiness(ctx context.Context) domain.HealthReport
+	}
+
+	checker, ok := h.service.(ReadinessChecker)
+	if !ok {
+		notImplemented(c, "Readiness checks not available")
+		return
+	}
+
+	report := checker.CheckReadiness(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == domain.HealthDown {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// GetIndexerHealth reports the indexing lag behind the chain head. It
+// responds 503 when the lag exceeds its thresholds, so that uptime monitors
+// alert on a stalled indexer.
+func (h *Handler) GetIndexerHealth(c *gin.Context) {
+	type IndexerHealthReporter interface {
+		IndexerHealth() domain.IndexerHealth
+	}
+
+	reporter, ok := h.service.(IndexerHealthReporter)
+	if !ok {
+		notImplemented(c, "Indexer health not available")
+		return
+	}
+
+	health := reporter.IndexerHealth()
+
+	status := http.StatusOK
+	if health.Status != domain.HealthUp {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, health)
+}
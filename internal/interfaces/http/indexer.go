@@ -0,0 +1,232 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// IndexerAdmin controls the polling loop and the background jobs.
+type IndexerAdmin interface {
+	PausePolling(ctx context.Context) error
+	ResumePolling(ctx context.Context) error
+	IndexerStatus(ctx context.Context) (*domain.IndexerStatus, error)
+	StartReindex(ctx context.Context, fromLevel, toLevel int64) (*domain.Job, error)
+	StartVerification(ctx context.Context, fromLevel, toLevel int64, repair bool) (*domain.Job, error)
+	ListJobs(ctx context.Context, query domain.JobQuery) ([]domain.Job, error)
+	GetJob(ctx context.Context, id string) (*domain.Job, error)
+	CancelJob(ctx context.Context, id string) (*domain.Job, error)
+}
+
+// levelRangeRequest selects the levels of a job. A zero ToLevel stands for
+// the chain head.
+type levelRangeRequest struct {
+	FromLevel int64 `json:"from_level"`
+	ToLevel   int64 `json:"to_level"`
+}
+
+type verifyRequest struct {
+	levelRangeRequest
+	Repair bool `json:"repair"`
+}
+
+func (h *Handler) indexerAdmin(c *gin.Context) (IndexerAdmin, bool) {
+	admin, ok := h.service.(IndexerAdmin)
+	if !ok {
+		notImplemented(c, "Indexer administration not available")
+	}
+	return admin, ok
+}
+
+func (r levelRangeRequest) valid(c *gin.Context) bool {
+	if r.FromLevel < 1 {
+		invalidBody(c, "Invalid from_level. Must be a positive integer")
+		return false
+	}
+	if r.ToLevel != 0 && r.ToLevel < r.FromLevel {
+		invalidBody(c, "Invalid to_level. Must be at least from_level, or omitted to index up to the chain head")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) GetIndexerStatus(c *gin.Context) {
+	admin, ok := h.indexerAdmin(c)
+	if !ok {
+		return
+	}
+	h.respondIndexerStatus(c, admin)
+}
+
+// PausePolling stops every replica from polling TzKT until polling is
+// resumed.
+func (h *Handler) PausePolling(c *gin.Context) {
+	admin, ok := h.indexerAdmin(c)
+	if !ok {
+		return
+	}
+	if err := admin.PausePolling(c.Request.Context()); err != nil {
+		h.logger.Errorw("Failed to pause polling", "error", err)
+		respondError(c, err, "Failed to pause polling")
+		return
+	}
+	h.respondIndexerStatus(c, admin)
+}
+
+func (h *Handler) ResumePolling(c *gin.Context) {
+	admin, ok := h.indexerAdmin(c)
+	if !ok {
+		return
+	}
+	if err := admin.ResumePolling(c.Request.Context()); err != nil {
+		h.logger.Errorw("Failed to resume polling", "error", err)
+		respondError(c, err, "Failed to resume polling")
+		return
+	}
+	h.respondIndexerStatus(c, admin)
+}
+
+func (h *Handler) respondIndexerStatus(c *gin.Context, admin IndexerAdmin) {
+	status, err := admin.IndexerStatus(c.Request.Context())
+	if err != nil {
+		h.logger.Errorw("Failed to get indexer status", "error", err)
+		respondError(c, err, "Failed to retrieve indexer status")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// StartReindex queues a job storing the delegations of a level range again.
+func (h *Handler) StartReindex(c *gin.Context) {
+	admin, ok := h.indexerAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req levelRangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidBody(c, "Invalid request body. Must be a JSON object with from_level and optionally to_level")
+		return
+	}
+	if !req.valid(c) {
+		return
+	}
+
+	job, err := admin.StartReindex(c.Request.Context(), req.FromLevel, req.ToLevel)
+	h.respondJobStarted(c, job, err)
+}
+
+// StartVerification queues a job comparing the delegations stored in a level
+// range with TzKT, and reindexing those missing when repair is set.
+func (h *Handler) StartVerification(c *gin.Context) {
+	admin, ok := h.indexerAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req verifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidBody(c, "Invalid request body. Must be a JSON object with from_level, optionally to_level and repair")
+		return
+	}
+	if !req.valid(c) {
+		return
+	}
+
+	job, err := admin.StartVerification(c.Request.Context(), req.FromLevel, req.ToLevel, req.Repair)
+	h.respondJobStarted(c, job, err)
+}
+
+func (h *Handler) respondJobStarted(c *gin.Context, job *domain.Job, err error) {
+	if err != nil {
+		if !domain.IsKind(err, domain.KindValidation) {
+			h.logger.Errorw("Failed to start job", "error", err)
+		}
+		respondError(c, err, "Failed to start job")
+		return
+	}
+	c.Header("Location", "/admin/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListJobs is the job history, newest first, optionally filtered by kind and
+// status.
+func (h *Handler) ListJobs(c *gin.Context) {
+	admin, ok := h.indexerAdmin(c)
+	if !ok {
+		return
+	}
+
+	var query domain.JobQuery
+	if kind := c.Query("kind"); kind != "" {
+		query.Kind = domain.JobKind(kind)
+		if !query.Kind.Valid() {
+			invalidParameter(c, "Invalid kind. Must be one of: reindex, verify, index_historical, verify_sync, backup")
+			return
+		}
+	}
+	if status := c.Query("status"); status != "" {
+		query.Statuses = []domain.JobStatus{domain.JobStatus(status)}
+		if !query.Statuses[0].Valid() {
+			invalidParameter(c, "Invalid status. Must be one of: pending, running, succeeded, failed, cancelled")
+			return
+		}
+	}
+	if query.Limit, ok = parseLimit(c); !ok {
+		return
+	}
+
+	jobs, err := admin.ListJobs(c.Request.Context(), query)
+	if err != nil {
+		h.logger.Errorw("Failed to list jobs", "error", err)
+		respondError(c, err, "Failed to retrieve jobs")
+		return
+	}
+	if jobs == nil {
+		jobs = []domain.Job{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": jobs,
+	})
+}
+
+func (h *Handler) GetJob(c *gin.Context) {
+	admin, ok := h.indexerAdmin(c)
+	if !ok {
+		return
+	}
+
+	job, err := admin.GetJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.jobError(c, err, "Failed to retrieve job")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob asks a job to stop; it is reported as cancelled once it has.
+func (h *Handler) CancelJob(c *gin.Context) {
+	admin, ok := h.indexerAdmin(c)
+	if !ok {
+		return
+	}
+
+	job, err := admin.CancelJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.jobError(c, err, "Failed to cancel job")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *Handler) jobError(c *gin.Context, err error, message string) {
+	if !domain.IsKind(err, domain.KindNotFound) && !domain.IsKind(err, domain.KindConflict) {
+		h.logger.Errorw(message, "error", err, "job_id", c.Param("id"))
+	}
+	respondError(c, err, message)
+}
@@ -1,6 +1,8 @@
 package http
 
 import (
+	"context"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -18,6 +20,7 @@ func LoggingMiddleware(logger *logger.Logger) gin.HandlerFunc {
 			"latency", param.Latency,
 			"clientIP", param.ClientIP,
 			"userAgent", param.Request.UserAgent(),
+			"requestID", param.Keys[requestIDKey],
 			"error", param.ErrorMessage,
 		)
 		// Record metrics
@@ -30,28 +33,59 @@ func LoggingMiddleware(logger *logger.Logger) gin.HandlerFunc {
 	})
 }
 
-func CORSMiddleware() gin.HandlerFunc {
+// untimedContextKey holds the request context as it was before
+// TimeoutMiddleware gave it a deadline.
+const untimedContextKey = "untimed_context"
+
+// untimedRoutes never get a deadline unless one is configured: they stream
+// for as long as the client listens.
+var untimedRoutes = map[string]time.Duration{
+	"/xtz/delegations/stream": 0,
+	"/xtz/delegations/ws":     0,
+}
+
+// TimeoutMiddleware bounds every request by the timeout configured for its
+// route in routes, or by timeout otherwise; zero means no deadline. The
+// deadline is set on the request context, so storage queries issued with it
+// are cancelled once it expires or the client goes away.
+func TimeoutMiddleware(timeout time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
+		timeout := timeout
+		if _, d, ok := routeSetting(routes, c.Request.Method, c.FullPath()); ok {
+			timeout = d
+		} else if d, ok := untimedRoutes[c.FullPath()]; ok {
+			timeout = d
+		}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		if timeout <= 0 {
+			c.Next()
 			return
 		}
 
+		// Leave time to write the timeout problem, also past the server-wide
+		// write timeout when the route is allowed to run longer.
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout + timeoutWriteGrace))
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Set(untimedContextKey, c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
 
-func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Request = c.Request.WithContext(c.Request.Context())
-		c.Next()
+// timeoutWriteGrace is how long a timed out request may still take to
+// write its response.
+const timeoutWriteGrace = 5 * time.Second
+
+// untimedContext returns the request context without the route deadline,
+// for responses that are streamed until the client stops reading.
+func untimedContext(c *gin.Context) context.Context {
+	if ctx, ok := c.Get(untimedContextKey); ok {
+		return ctx.(context.Context)
 	}
+	return c.Request.Context()
 }
 
 func RecoveryMiddleware(logger *logger.Logger) gin.HandlerFunc {
@@ -60,15 +94,8 @@ func RecoveryMiddleware(logger *logger.Logger) gin.HandlerFunc {
 			"error", recovered,
 			"path", c.Request.URL.Path,
 			"method", c.Request.Method,
+			"requestID", requestID(c),
 		)
-		c.AbortWithStatusJSON(500, gin.H{
-			"error": "Internal server error",
-		})
+		writeProblem(c, http.StatusInternalServerError, codeInternal, "Internal server error")
 	})
 }
-
-func RateLimitMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Next()
-	}
-}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingService holds queries until their context is done, like a
+// database that takes too long.
+type blockingService struct {
+	*MockService
+	queryErr chan error
+}
+
+func (s *blockingService) GetDelegations(ctx context.Context, year *int) ([]domain.Delegation, error) {
+	<-ctx.Done()
+	s.queryErr <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(TimeoutMiddleware(time.Minute, map[string]time.Duration{
+		"/xtz/stats/cycles":           time.Hour,
+		"GET /xtz/delegations/stream": time.Second,
+		"/graphql":                    0,
+	}))
+
+	deadlines := make(map[string]time.Duration)
+	record := func(c *gin.Context) {
+		deadline, ok := c.Request.Context().Deadline()
+		if ok {
+			deadlines[c.Request.Method+" "+c.FullPath()] = time.Until(deadline).Round(time.Minute)
+		}
+		c.Status(http.StatusOK)
+	}
+	router.GET("/xtz/delegations", record)
+	router.GET("/xtz/stats/cycles", record)
+	router.GET("/xtz/delegations/stream", record)
+	router.GET("/xtz/delegations/ws", record)
+	router.GET("/graphql", record)
+	router.POST("/graphql", record)
+
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/xtz/delegations"},
+		{http.MethodGet, "/xtz/stats/cycles"},
+		{http.MethodGet, "/xtz/delegations/stream"},
+		{http.MethodGet, "/xtz/delegations/ws"},
+		{http.MethodGet, "/graphql"},
+		{http.MethodPost, "/graphql"},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(r.method, r.path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, r.path)
+	}
+
+	assert.Equal(t, map[string]time.Duration{
+		"GET /xtz/delegations":        time.Minute,
+		"GET /xtz/stats/cycles":       time.Hour,
+		"GET /xtz/delegations/stream": 0,
+	}, deadlines)
+}
+
+func TestTimeoutMiddleware_CancelsQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, _ := logger.New("debug", "test")
+	service := &blockingService{MockService: new(MockService), queryErr: make(chan error, 1)}
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), TimeoutMiddleware(20*time.Millisecond, nil))
+	router.GET("/xtz/delegations", NewHandler(service, log).GetDelegations)
+
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/xtz/delegations", nil))
+
+		assert.Equal(t, context.DeadlineExceeded, <-service.queryErr)
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, codeTimeout, decodeProblem(t, w).Code)
+	})
+
+	t.Run("Client gone", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/xtz/delegations", nil).WithContext(ctx))
+
+		assert.Equal(t, context.Canceled, <-service.queryErr)
+		assert.Equal(t, statusClientClosedRequest, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+}
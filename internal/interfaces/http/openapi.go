@@ -0,0 +1,598 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// OpenAPISpec is the subset of an OpenAPI 3 document this service needs.
+type OpenAPISpec struct {
+	OpenAPI    string                 `json:"openapi"`
+	Info       OpenAPIInfo            `json:"info"`
+	Paths      map[string]OpenAPIPath `json:"paths"`
+	Components OpenAPIComponents      `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIPath maps lower-case HTTP methods to operations.
+type OpenAPIPath map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Security    []SecurityRequirement      `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+}
+
+// SecurityRequirement maps security scheme names to required scopes.
+type SecurityRequirement map[string][]string
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *int64             `json:"maximum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// route documents one route registered by NewRouter. Path uses gin syntax.
+type route struct {
+	method      string
+	path        string
+	operationID string
+	summary     string
+	tag         string
+	params      []OpenAPIParameter
+	body        interface{}
+	status      int
+	response    interface{}
+	contentType string
+	// alternatives are further media types of the success response.
+	alternatives []string
+	// failure is a status also returned with the success body, such as
+	// 503 from a failing health check.
+	failure int
+}
+
+var (
+	yearMin, yearMax = int64(2018), int64(2100)
+	zero, one        = int64(0), int64(1)
+)
+
+var (
+	yearParam = OpenAPIParameter{Name: "year", In: "query", Description: "Only delegations made in this year",
+		Schema: &Schema{Type: "integer", Minimum: &yearMin, Maximum: &yearMax}}
+	cycleParam = OpenAPIParameter{Name: "cycle", In: "query", Description: "Only delegations made in this Tezos cycle",
+		Schema: &Schema{Type: "integer", Format: "int64", Minimum: &zero}}
+	limitParam = OpenAPIParameter{Name: "limit", In: "query", Description: "Maximum number of items returned",
+		Schema: &Schema{Type: "integer", Minimum: &one}}
+	offsetParam = OpenAPIParameter{Name: "offset", In: "query", Description: "Number of items skipped",
+		Schema: &Schema{Type: "integer", Minimum: &zero}}
+	fromParam = OpenAPIParameter{Name: "from", In: "query", Description: "Inclusive start, RFC3339 or YYYY-MM-DD",
+		Schema: &Schema{Type: "string"}}
+	toParam = OpenAPIParameter{Name: "to", In: "query", Description: "Exclusive end, RFC3339 or YYYY-MM-DD",
+		Schema: &Schema{Type: "string"}}
+	asOfParam = OpenAPIParameter{Name: "as_of", In: "query", Description: "Block level or RFC3339/YYYY-MM-DD timestamp to replay the state at",
+		Schema: &Schema{Type: "string"}}
+	bakerParam = OpenAPIParameter{Name: "baker", In: "query", Description: "Only delegations to this baker",
+		Schema: &Schema{Type: "string"}}
+)
+
+func pathParam(name, description string) OpenAPIParameter {
+	return OpenAPIParameter{Name: name, In: "path", Description: description, Required: true, Schema: &Schema{Type: "string"}}
+}
+
+// routes lists every route of NewRouter. TestOpenAPISpecMatchesRouter fails
+// when the two disagree.
+var routes = []route{
+	{method: http.MethodGet, path: "/health", operationID: "getHealth", summary: "Liveness probe", tag: "health",
+		status: http.StatusOK, response: livenessResponse{}},
+	{method: http.MethodGet, path: "/health/live", operationID: "getLiveness", summary: "Liveness probe", tag: "health",
+		status: http.StatusOK, response: livenessResponse{}},
+	{method: http.MethodGet, path: "/health/ready", operationID: "getHealthReadiness", summary: "Readiness probe with per-dependency status and latency", tag: "health",
+		status: http.StatusOK, response: domain.HealthReport{}, failure: http.StatusServiceUnavailable},
+	{method: http.MethodGet, path: "/health/indexer", operationID: "getIndexerHealth", summary: "Indexing lag behind the chain head", tag: "health",
+		status: http.StatusOK, response: domain.IndexerHealth{}, failure: http.StatusServiceUnavailable},
+	{method: http.MethodGet, path: "/ready", operationID: "getReadiness", summary: "Readiness probe with per-dependency status and latency", tag: "health",
+		status: http.StatusOK, response: domain.HealthReport{}, failure: http.StatusServiceUnavailable},
+	{method: http.MethodGet, path: "/openapi.json", operationID: "getOpenAPISpec", summary: "This OpenAPI document", tag: "meta",
+		status: http.StatusOK, response: map[string]interface{}{}},
+	{method: http.MethodGet, path: "/xtz/completeness", operationID: "getDataCompleteness", summary: "Data state and the watermark through which every delegation is stored", tag: "delegations",
+		status: http.StatusOK, response: domain.DataCompleteness{}},
+	{method: http.MethodGet, path: "/xtz/delegations", operationID: "listDelegations", summary: "List delegations, newest first", tag: "delegations",
+		params: []OpenAPIParameter{yearParam, cycleParam,
+			{Name: "format", In: "query", Description: "Response format, also selectable with Accept: text/csv or application/x-ndjson. CSV and NDJSON are streamed",
+				Schema: &Schema{Type: "string", Enum: []string{formatJSON, formatCSV, formatNDJSON}}}},
+		status: http.StatusOK, response: domain.DelegationResponse{}, alternatives: []string{contentTypeCSV, contentTypeNDJSON}},
+	{method: http.MethodGet, path: "/xtz/delegations/stream", operationID: "streamDelegations", summary: "Server-Sent Events stream of delegations", tag: "delegations",
+		params: []OpenAPIParameter{yearParam, cycleParam,
+			{Name: "last_event_id", In: "query", Description: "Resume after this event ID, like the Last-Event-ID header",
+				Schema: &Schema{Type: "integer", Format: "int64", Minimum: &zero}}},
+		status: http.StatusOK, contentType: "text/event-stream"},
+	{method: http.MethodGet, path: "/xtz/delegations/ws", operationID: "subscribeDelegations", summary: "WebSocket subscriptions to new delegations", tag: "delegations",
+		status: http.StatusSwitchingProtocols},
+	{method: http.MethodGet, path: "/xtz/stats/timeseries", operationID: "getTimeSeries", summary: "Delegation activity per time bucket", tag: "stats",
+		params: []OpenAPIParameter{
+			{Name: "interval", In: "query", Description: "Bucket size",
+				Schema: &Schema{Type: "string", Enum: []string{string(domain.IntervalDay), string(domain.IntervalWeek), string(domain.IntervalMonth)}}},
+			bakerParam, fromParam, toParam,
+			{Name: "format", In: "query", Description: "Response format, also selectable with Accept: text/csv",
+				Schema: &Schema{Type: "string", Enum: []string{"json", "csv"}}},
+		},
+		status: http.StatusOK, response: domain.TimeSeriesResponse{}},
+	{method: http.MethodGet, path: "/xtz/stats/top", operationID: "getTopReport", summary: "Largest delegations and delegators", tag: "stats",
+		params: []OpenAPIParameter{limitParam, fromParam, toParam},
+		status: http.StatusOK, response: domain.TopReport{}},
+	{method: http.MethodGet, path: "/xtz/stats/movements", operationID: "getLargeMovements", summary: "Delegations above an amount", tag: "stats",
+		params: []OpenAPIParameter{
+			{Name: "min_amount", In: "query", Description: "Minimum amount in mutez",
+				Schema: &Schema{Type: "integer", Format: "int64", Minimum: &one}},
+			limitParam, fromParam, toParam,
+		},
+		status: http.StatusOK, response: domain.MovementsResponse{}},
+	{method: http.MethodGet, path: "/xtz/stats/cycles", operationID: "getCycleStats", summary: "Delegation activity per cycle", tag: "stats",
+		params: []OpenAPIParameter{
+			{Name: "from_cycle", In: "query", Schema: &Schema{Type: "integer", Format: "int64", Minimum: &zero}},
+			{Name: "to_cycle", In: "query", Schema: &Schema{Type: "integer", Format: "int64", Minimum: &zero}},
+			bakerParam, limitParam,
+		},
+		status: http.StatusOK, response: domain.CycleStatsResponse{}},
+	{method: http.MethodGet, path: "/xtz/delegators/:address", operationID: "getDelegator", summary: "Current baker of an account", tag: "state",
+		params: []OpenAPIParameter{pathParam("address", "Delegator address"), asOfParam},
+		status: http.StatusOK, response: domain.DelegatorState{}},
+	{method: http.MethodGet, path: "/xtz/bakers/:address/delegators", operationID: "getBakerDelegators", summary: "Accounts currently delegating to a baker", tag: "state",
+		params: []OpenAPIParameter{pathParam("address", "Baker address"), limitParam, offsetParam, asOfParam},
+		status: http.StatusOK, response: domain.BakerDelegators{}},
+	{method: http.MethodPost, path: "/admin/api-keys", operationID: "createAPIKey", summary: "Issue an API key; its secret is only returned here", tag: "admin",
+		body: createAPIKeyRequest{}, status: http.StatusCreated, response: domain.APIKey{}},
+	{method: http.MethodGet, path: "/admin/api-keys", operationID: "listAPIKeys", summary: "List API keys and their usage", tag: "admin",
+		status: http.StatusOK, response: apiKeyList{}},
+	{method: http.MethodPost, path: "/admin/api-keys/:id/rotate", operationID: "rotateAPIKey", summary: "Replace the secret of an API key", tag: "admin",
+		params: []OpenAPIParameter{pathParam("id", "API key ID")},
+		status: http.StatusOK, response: domain.APIKey{}},
+	{method: http.MethodDelete, path: "/admin/api-keys/:id", operationID: "revokeAPIKey", summary: "Revoke an API key", tag: "admin",
+		params: []OpenAPIParameter{pathParam("id", "API key ID")},
+		status: http.StatusNoContent},
+	{method: http.MethodPost, path: "/admin/webhooks", operationID: "createWebhook", summary: "Register a webhook", tag: "admin",
+		body: createWebhookRequest{}, status: http.StatusCreated, response: domain.Webhook{}},
+	{method: http.MethodGet, path: "/admin/webhooks", operationID: "listWebhooks", summary: "List webhooks", tag: "admin",
+		status: http.StatusOK, response: webhookList{}},
+	{method: http.MethodGet, path: "/admin/webhooks/:id", operationID: "getWebhook", summary: "Get a webhook", tag: "admin",
+		params: []OpenAPIParameter{pathParam("id", "Webhook ID")},
+		status: http.StatusOK, response: domain.Webhook{}},
+	{method: http.MethodDelete, path: "/admin/webhooks/:id", operationID: "deleteWebhook", summary: "Delete a webhook and its delivery log", tag: "admin",
+		params: []OpenAPIParameter{pathParam("id", "Webhook ID")},
+		status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/admin/webhooks/:id/deliveries", operationID: "listWebhookDeliveries", summary: "Delivery log of a webhook, newest first", tag: "admin",
+		params: []OpenAPIParameter{
+			pathParam("id", "Webhook ID"),
+			{Name: "status", In: "query", Schema: &Schema{Type: "string",
+				Enum: []string{string(domain.WebhookDeliveryPending), string(domain.WebhookDeliveryDelivered), string(domain.WebhookDeliveryDead)}}},
+			limitParam,
+		},
+		status: http.StatusOK, response: webhookDeliveryList{}},
+	{method: http.MethodPost, path: "/admin/webhooks/:id/deliveries/:delivery_id/redeliver", operationID: "redeliverWebhookDelivery", summary: "Queue a delivery again", tag: "admin",
+		params: []OpenAPIParameter{pathParam("id", "Webhook ID"), pathParam("delivery_id", "Delivery ID")},
+		status: http.StatusAccepted},
+	{method: http.MethodGet, path: "/admin/indexer", operationID: "getIndexerStatus", summary: "Polling state of this instance and indexing progress", tag: "admin",
+		status: http.StatusOK, response: domain.IndexerStatus{}},
+	{method: http.MethodPost, path: "/admin/indexer/pause", operationID: "pausePolling", summary: "Stop this instance from polling TzKT", tag: "admin",
+		status: http.StatusOK, response: domain.IndexerStatus{}},
+	{method: http.MethodPost, path: "/admin/indexer/resume", operationID: "resumePolling", summary: "Resume polling TzKT", tag: "admin",
+		status: http.StatusOK, response: domain.IndexerStatus{}},
+	{method: http.MethodPost, path: "/admin/jobs/reindex", operationID: "startReindex", summary: "Store the delegations of a level range again, in the background", tag: "admin",
+		body: levelRangeRequest{}, status: http.StatusAccepted, response: domain.Job{}},
+	{method: http.MethodPost, path: "/admin/jobs/verify", operationID: "startVerification", summary: "Compare stored delegations with TzKT, and optionally repair them, in the background", tag: "admin",
+		body: verifyRequest{}, status: http.StatusAccepted, response: domain.Job{}},
+	{method: http.MethodGet, path: "/admin/jobs", operationID: "listJobs", summary: "Job history, newest first", tag: "admin",
+		params: []OpenAPIParameter{
+			{Name: "kind", In: "query", Schema: &Schema{Type: "string",
+				Enum: []string{string(domain.JobReindex), string(domain.JobVerify), string(domain.JobIndexHistorical), string(domain.JobVerifySync), string(domain.JobBackup), string(domain.JobBackfillBakers)}}},
+			{Name: "status", In: "query", Schema: &Schema{Type: "string",
+				Enum: []string{string(domain.JobPending), string(domain.JobRunning), string(domain.JobSucceeded), string(domain.JobFailed), string(domain.JobCancelled)}}},
+			limitParam,
+		},
+		status: http.StatusOK, response: jobList{}},
+	{method: http.MethodGet, path: "/admin/jobs/:id", operationID: "getJob", summary: "Status and progress of a job", tag: "admin",
+		params: []OpenAPIParameter{pathParam("id", "Job ID")},
+		status: http.StatusOK, response: domain.Job{}},
+	{method: http.MethodPost, path: "/admin/jobs/:id/cancel", operationID: "cancelJob", summary: "Cancel a pending or running job", tag: "admin",
+		params: []OpenAPIParameter{pathParam("id", "Job ID")},
+		status: http.StatusAccepted, response: domain.Job{}},
+	{method: http.MethodGet, path: "/graphql", operationID: "queryGraphQLGet", summary: "GraphQL query passed as query parameters", tag: "graphql",
+		params: []OpenAPIParameter{
+			{Name: "query", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+			{Name: "operationName", In: "query", Schema: &Schema{Type: "string"}},
+			{Name: "variables", In: "query", Description: "JSON object", Schema: &Schema{Type: "string"}},
+		},
+		status: http.StatusOK, response: map[string]interface{}{}},
+	{method: http.MethodPost, path: "/graphql", operationID: "queryGraphQL", summary: "GraphQL query", tag: "graphql",
+		body: graphQLRequest{}, status: http.StatusOK, response: map[string]interface{}{}},
+	{method: http.MethodGet, path: "/stats", operationID: "getStats", summary: "Statistics about indexed delegations", tag: "stats",
+		status: http.StatusOK, response: map[string]interface{}{}},
+	{method: http.MethodGet, path: "/metrics", operationID: "getMetrics", summary: "Prometheus metrics", tag: "meta",
+		status: http.StatusOK, contentType: "text/plain"},
+}
+
+// Response envelopes built with gin.H, declared for documentation only.
+type apiKeyList struct {
+	Data []domain.APIKey `json:"data"`
+}
+
+type webhookList struct {
+	Data []domain.Webhook `json:"data"`
+}
+
+type jobList struct {
+	Data []domain.Job `json:"data"`
+}
+
+type webhookDeliveryList struct {
+	WebhookID string                   `json:"webhook_id"`
+	Data      []domain.WebhookDelivery `json:"data"`
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// NewOpenAPISpec builds the OpenAPI document of the routes registered by
+// NewRouter. Component schemas are derived from the Go types the handlers
+// serialize.
+func NewOpenAPISpec() *OpenAPISpec {
+	spec := &OpenAPISpec{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:       "Tezos Delegation Service",
+			Description: "Indexes Tezos delegations from TzKT and serves them over REST, GraphQL and gRPC.",
+			Version:     "1.0.0",
+		},
+		Paths: make(map[string]OpenAPIPath),
+		Components: OpenAPIComponents{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				"apiKey": {Type: "apiKey", Name: apiKeyHeader, In: "header",
+					Description: "API key; read routes may also be called anonymously when the deployment allows it"},
+				"bearer": {Type: "http", Scheme: "bearer", Description: "API key, or a JWT issued by the configured identity provider, sent as a bearer token"},
+			},
+		},
+	}
+
+	errorSchema := spec.schemaFor(reflect.TypeOf(Problem{}))
+
+	for _, r := range routes {
+		op := &OpenAPIOperation{
+			OperationID: r.operationID,
+			Summary:     r.summary,
+			Tags:        []string{r.tag},
+			Parameters:  r.params,
+			Responses:   make(map[string]OpenAPIResponse),
+		}
+
+		if r.body != nil {
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content: map[string]OpenAPIMediaType{
+					"application/json": {Schema: spec.schemaFor(reflect.TypeOf(r.body))},
+				},
+			}
+		}
+
+		success := OpenAPIResponse{Description: http.StatusText(r.status)}
+		switch {
+		case r.response != nil:
+			success.Content = map[string]OpenAPIMediaType{
+				"application/json": {Schema: spec.schemaFor(reflect.TypeOf(r.response))},
+			}
+		case r.contentType != "":
+			success.Content = map[string]OpenAPIMediaType{r.contentType: {Schema: &Schema{Type: "string"}}}
+		}
+		for _, contentType := range r.alternatives {
+			success.Content[contentType] = OpenAPIMediaType{Schema: &Schema{Type: "string"}}
+		}
+		op.Responses[strconv.Itoa(r.status)] = success
+
+		errorContent := map[string]OpenAPIMediaType{contentTypeProblem: {Schema: errorSchema}}
+		if len(r.params) > 0 || r.body != nil {
+			op.Responses["400"] = OpenAPIResponse{Description: "Invalid request", Content: errorContent}
+		}
+		op.Responses["500"] = OpenAPIResponse{Description: "Internal error", Content: errorContent}
+		op.Responses["503"] = OpenAPIResponse{Description: "Storage or upstream unavailable", Content: errorContent}
+		if _, untimed := untimedRoutes[r.path]; !untimed {
+			op.Responses["504"] = OpenAPIResponse{Description: "Request timed out", Content: errorContent}
+		}
+		if scope, ok := routeScope(r.path); ok {
+			op.Security = []SecurityRequirement{{"apiKey": {}}, {"bearer": {}}}
+			op.Responses["401"] = OpenAPIResponse{Description: "Missing or invalid credentials", Content: errorContent}
+			op.Responses["403"] = OpenAPIResponse{Description: "Credentials lack the " + string(scope) + " scope", Content: errorContent}
+		}
+		if !rateLimitExempt(r.path) {
+			op.Responses["429"] = OpenAPIResponse{Description: "Rate limit exceeded", Content: errorContent}
+		}
+		if r.failure != 0 {
+			op.Responses[strconv.Itoa(r.failure)] = OpenAPIResponse{Description: http.StatusText(r.failure), Content: success.Content}
+		}
+
+		path := openAPIPath(r.path)
+		if spec.Paths[path] == nil {
+			spec.Paths[path] = make(OpenAPIPath)
+		}
+		spec.Paths[path][strings.ToLower(r.method)] = op
+	}
+
+	return spec
+}
+
+// Operation returns the operation for a method and gin route path.
+func (s *OpenAPISpec) Operation(method, ginPath string) *OpenAPIOperation {
+	return s.Paths[openAPIPath(ginPath)][strings.ToLower(method)]
+}
+
+// openAPIPath converts gin's :param segments to OpenAPI's {param}.
+func openAPIPath(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns the schema of t, registering named structs as
+// components and referencing them.
+func (s *OpenAPISpec) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schemaFor(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return s.structSchema(t)
+		}
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, ok := s.Components.Schemas[name]; !ok {
+			// Register before recursing so self references terminate.
+			s.Components.Schemas[name] = &Schema{}
+			*s.Components.Schemas[name] = *s.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (s *OpenAPISpec) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.addFields(schema, t)
+	return schema
+}
+
+func (s *OpenAPISpec) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.addFields(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = s.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// OpenAPIHandler serves the spec as JSON.
+func OpenAPIHandler(spec *OpenAPISpec) gin.HandlerFunc {
+	body, err := json.Marshal(spec)
+	if err != nil {
+		panic(fmt.Sprintf("failed to marshal OpenAPI spec: %v", err))
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+const validatedQueryKey = "openapi.query"
+
+// ValidationMiddleware checks the query parameters of documented routes
+// against the spec and rejects invalid requests with 400. Parsed integer
+// parameters are made available to handlers through queryInt.
+func ValidationMiddleware(spec *OpenAPISpec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op := spec.Operation(c.Request.Method, c.FullPath())
+		if op == nil {
+			c.Next()
+			return
+		}
+
+		values := make(map[string]int64)
+		for _, param := range op.Parameters {
+			if param.In != "query" {
+				continue
+			}
+
+			raw, present := c.GetQuery(param.Name)
+			if !present || raw == "" {
+				if param.Required {
+					invalidParameter(c, fmt.Sprintf("Missing %s parameter", param.Name))
+					return
+				}
+				continue
+			}
+
+			value, problem := validateParam(param, raw)
+			if problem != "" {
+				invalidParameter(c, problem)
+				return
+			}
+			if param.Schema.Type == "integer" {
+				values[param.Name] = value
+			}
+		}
+
+		c.Set(validatedQueryKey, values)
+		c.Next()
+	}
+}
+
+// validateParam checks raw against the parameter schema. It returns the
+// integer value of integer parameters, or a description of the problem.
+func validateParam(param OpenAPIParameter, raw string) (int64, string) {
+	schema := param.Schema
+
+	switch schema.Type {
+	case "integer":
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return 0, fmt.Sprintf("Invalid %s parameter. Must be %s", param.Name, describeInteger(schema))
+		}
+		if (schema.Minimum != nil && value < *schema.Minimum) || (schema.Maximum != nil && value > *schema.Maximum) {
+			if schema.Minimum != nil && schema.Maximum != nil {
+				return 0, fmt.Sprintf("%s must be between %d and %d", strings.ToUpper(param.Name[:1])+param.Name[1:], *schema.Minimum, *schema.Maximum)
+			}
+			return 0, fmt.Sprintf("Invalid %s parameter. Must be %s", param.Name, describeInteger(schema))
+		}
+		return value, ""
+	case "string":
+		if len(schema.Enum) > 0 {
+			for _, allowed := range schema.Enum {
+				if raw == allowed {
+					return 0, ""
+				}
+			}
+			return 0, fmt.Sprintf("Invalid %s parameter. Must be one of %s", param.Name, strings.Join(schema.Enum, ", "))
+		}
+	}
+	return 0, ""
+}
+
+func describeInteger(schema *Schema) string {
+	switch {
+	case schema.Minimum != nil && schema.Maximum != nil:
+		return fmt.Sprintf("an integer between %d and %d", *schema.Minimum, *schema.Maximum)
+	case schema.Minimum != nil && *schema.Minimum == 0:
+		return "a non-negative integer"
+	case schema.Minimum != nil && *schema.Minimum == 1:
+		return "a positive integer"
+	case schema.Minimum != nil:
+		return fmt.Sprintf("an integer of at least %d", *schema.Minimum)
+	default:
+		return "an integer"
+	}
+}
+
+// queryInt returns an integer query parameter validated by
+// ValidationMiddleware and whether it was set.
+func queryInt(c *gin.Context, name string) (int64, bool) {
+	values, _ := c.Get(validatedQueryKey)
+	params, _ := values.(map[string]int64)
+	value, ok := params[name]
+	return value, ok
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+func TestOpenAPISpecMatchesRouter(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+	router, err := NewRouter(new(MockService), RouterConfig{}, log)
+	require.NoError(t, err)
+	spec := NewOpenAPISpec()
+
+	registered := make(map[string]bool)
+	for _, r := range router.Routes() {
+		key := r.Method + " " + openAPIPath(r.Path)
+		registered[key] = true
+
+		op := spec.Operation(r.Method, r.Path)
+		if !assert.NotNil(t, op, "route %s is not documented in the OpenAPI spec", key) {
+			continue
+		}
+
+		var documented []string
+		for _, param := range op.Parameters {
+			if param.In == "path" {
+				documented = append(documented, param.Name)
+			}
+		}
+		var actual []string
+		for _, match := range pathParamPattern.FindAllStringSubmatch(openAPIPath(r.Path), -1) {
+			actual = append(actual, match[1])
+		}
+		assert.ElementsMatch(t, actual, documented, "path parameters of %s", key)
+	}
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			key := strings.ToUpper(method) + " " + path
+			assert.True(t, registered[key], "documented operation %s is not registered in NewRouter", key)
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	log, _ := logger.New("debug", "test")
+	router, err := NewRouter(new(MockService), RouterConfig{}, log)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var doc struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/xtz/delegations")
+	assert.Contains(t, doc.Paths, "/admin/webhooks/{id}/deliveries/{delivery_id}/redeliver")
+
+	// Every reference resolves to a component schema.
+	for _, match := range regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(w.Body.String(), -1) {
+		assert.Contains(t, doc.Components.Schemas, match[1])
+	}
+	assert.Contains(t, doc.Components.Schemas, "Delegation")
+}
+
+func TestOpenAPISpecDelegationSchema(t *testing.T) {
+	spec := NewOpenAPISpec()
+	delegation := spec.Components.Schemas["Delegation"]
+	require.NotNil(t, delegation)
+
+	assert.ElementsMatch(t, []string{"timestamp", "amount", "delegator", "level", "operation_hash"}, delegation.Required)
+	assert.Equal(t, "date-time", delegation.Properties["timestamp"].Format)
+	assert.NotContains(t, delegation.Properties, "seq")
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	testCases := []struct {
+		name     string
+		target   string
+		expected string
+	}{
+		{"Integer type", "/xtz/stats/cycles?from_cycle=abc", "Invalid from_cycle parameter. Must be a non-negative integer"},
+		{"Integer minimum", "/xtz/bakers/tz1baker/delegators?limit=0", "Invalid limit parameter. Must be a positive integer"},
+		{"Integer range", "/xtz/delegations/stream?year=1999", "Year must be between 2018 and 2100"},
+		{"Enum", "/xtz/stats/timeseries?format=xml", "Invalid format parameter. Must be one of json, csv"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.expected, response.Detail)
+		})
+	}
+
+	mockService.AssertNotCalled(t, "GetCycleStats", mock.Anything)
+	mockService.AssertNotCalled(t, "GetBakerDelegators", mock.Anything)
+	mockService.AssertNotCalled(t, "GetTimeSeries", mock.Anything)
+}
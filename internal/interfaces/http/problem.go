@@ -0,0 +1,140 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+const (
+	contentTypeProblem = "application/problem+json"
+
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+
+	// maxRequestIDLength bounds client-supplied request IDs, which are
+	// echoed in headers and logs.
+	maxRequestIDLength = 128
+
+	codeInternal       = "internal_error"
+	codeNotImplemented = "not_implemented"
+	codeTimeout        = "request_timeout"
+
+	// statusClientClosedRequest is logged for requests abandoned by the
+	// client; nobody reads the response.
+	statusClientClosedRequest = 499
+)
+
+// Problem is an RFC 7807 problem details object. Code is a stable,
+// machine-readable identifier of the error; Detail is meant for humans and
+// may change.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// RequestIDMiddleware assigns every request an ID, reusing a well-formed
+// X-Request-ID sent by the client. The ID is returned in the X-Request-ID
+// response header and in problem responses.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// writeProblem aborts the request with a problem response.
+func writeProblem(c *gin.Context, status int, code, detail string) {
+	c.Header("Content-Type", contentTypeProblem)
+	c.AbortWithStatusJSON(status, Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Code:      code,
+		RequestID: requestID(c),
+	})
+}
+
+// respondError reports err as a problem. Domain errors keep their kind and
+// code; any other error is an internal error described by detail, so that
+// its message never reaches the client. Errors caused by the request
+// running out of time are reported as such, whatever the layer that saw
+// the deadline.
+func respondError(c *gin.Context, err error, detail string) {
+	switch c.Request.Context().Err() {
+	case context.DeadlineExceeded:
+		writeProblem(c, http.StatusGatewayTimeout, codeTimeout, "Request timed out")
+		return
+	case context.Canceled:
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+
+	domainErr, ok := domain.AsError(err)
+	if !ok {
+		writeProblem(c, http.StatusInternalServerError, codeInternal, detail)
+		return
+	}
+	writeProblem(c, statusOf(domainErr.Kind), domainErr.Code, domainErr.Message)
+}
+
+func statusOf(kind domain.ErrorKind) int {
+	switch kind {
+	case domain.KindValidation:
+		return http.StatusBadRequest
+	case domain.KindNotFound:
+		return http.StatusNotFound
+	case domain.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case domain.KindForbidden:
+		return http.StatusForbidden
+	case domain.KindConflict:
+		return http.StatusConflict
+	case domain.KindUpstreamUnavailable, domain.KindStorageUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func invalidParameter(c *gin.Context, detail string) {
+	writeProblem(c, http.StatusBadRequest, domain.CodeInvalidParameter, detail)
+}
+
+func invalidBody(c *gin.Context, detail string) {
+	writeProblem(c, http.StatusBadRequest, domain.CodeInvalidBody, detail)
+}
+
+func notImplemented(c *gin.Context, detail string) {
+	writeProblem(c, http.StatusNotImplemented, codeNotImplemented, detail)
+}
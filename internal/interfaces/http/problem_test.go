@@ -0,0 +1,127 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+
+	assert.Equal(t, contentTypeProblem, w.Header().Get("Content-Type"))
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, w.Code, problem.Status)
+	assert.Equal(t, http.StatusText(w.Code), problem.Title)
+	return problem
+}
+
+func TestRespondError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"Not found", domain.ErrDelegatorNotFound, http.StatusNotFound, "delegator_not_found", "delegator not found"},
+		{"Wrapped validation", errors.Join(errors.New("query"), domain.ErrAsOfOutOfRange), http.StatusBadRequest, "as_of_out_of_range", "as_of is outside the indexed history"},
+		{"Storage unavailable", domain.NewStorageUnavailableError(errors.New("dial tcp 10.0.0.5:5432: connection refused")), http.StatusServiceUnavailable, "storage_unavailable", "storage unavailable"},
+		{"Upstream unavailable", domain.NewUpstreamUnavailableError("TzKT API unavailable", errors.New("502")), http.StatusServiceUnavailable, "upstream_unavailable", "TzKT API unavailable"},
+		{"Unclassified", errors.New("pq: relation \"delegations\" does not exist"), http.StatusInternalServerError, "internal_error", "Failed to retrieve delegations"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(RequestIDMiddleware())
+			router.GET("/", func(c *gin.Context) {
+				respondError(c, tc.err, "Failed to retrieve delegations")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(requestIDHeader, "req-123")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			problem := decodeProblem(t, w)
+			assert.Equal(t, tc.code, problem.Code)
+			assert.Equal(t, tc.detail, problem.Detail)
+			assert.Equal(t, "req-123", problem.RequestID)
+			assert.NotContains(t, w.Body.String(), "10.0.0.5")
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, requestID(c))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "client-id")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "client-id", w.Header().Get(requestIDHeader))
+	assert.Equal(t, "client-id", w.Body.String())
+
+	// Malformed IDs are replaced rather than echoed.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "bad id\twith spaces")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	generated := w.Header().Get(requestIDHeader)
+	assert.NotEmpty(t, generated)
+	assert.NotEqual(t, "bad id\twith spaces", generated)
+	assert.Equal(t, generated, w.Body.String())
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, _ := logger.New("debug", "test")
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), RecoveryMiddleware(log))
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	problem := decodeProblem(t, w)
+	assert.Equal(t, "internal_error", problem.Code)
+	assert.Equal(t, w.Header().Get(requestIDHeader), problem.RequestID)
+	assert.NotContains(t, w.Body.String(), "boom")
+}
+
+func TestHandler_GetDelegatorNotFoundProblem(t *testing.T) {
+	mockService := new(MockService)
+	router := setupRouter(mockService)
+
+	mockService.On("GetDelegatorState", domain.DelegatorQuery{Delegator: "tz1unknown"}).Return(nil, domain.ErrDelegatorNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/xtz/delegators/tz1unknown", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "delegator_not_found", decodeProblem(t, w).Code)
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+
+	codeRateLimited = "rate_limited"
+
+	defaultRatePolicy = "default"
+	preAuthRatePolicy = "preauth"
+	unmatchedRoute    = "unmatched"
+)
+
+// rateLimitHeaders are set on every limited response, following the IETF
+// RateLimit header fields draft. Reset and Retry-After are in seconds.
+var rateLimitHeaders = []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"}
+
+// rateLimitExempt reports whether a route is never rate limited: probes and
+// scrapes come from the platform and must not be throttled.
+func rateLimitExempt(route string) bool {
+	return route == "/health" || strings.HasPrefix(route, "/health/") || route == "/ready" || route == "/metrics"
+}
+
+// RateLimitMiddleware takes a token from the client's bucket for the matched
+// route and rejects the request with 429 when none is left. Authenticated
+// clients are limited per principal, others per IP address. Routes listed in
+// cfg.Routes have their own buckets; all other routes share one. When the
+// limiter fails the request is let through.
+func RateLimitMiddleware(limiter domain.RateLimiter, cfg config.RateLimit, logger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if rateLimitExempt(route) {
+			c.Next()
+			return
+		}
+
+		policy, rule := ratePolicy(cfg, c.Request.Method, route)
+		limit, client := rule.IP, "ip:"+c.ClientIP()
+		if p := principal(c); p != nil {
+			limit, client = rule.Key, p.Subject
+		}
+		if takeToken(c, limiter, policy+"|"+client, limit, logger) {
+			c.Next()
+		}
+	}
+}
+
+// PreAuthRateLimitMiddleware takes a token from the bucket of the client IP
+// before credentials are checked, so that looking up keys and verifying
+// tokens is limited even for requests that fail authentication. Its limit
+// is meant to be well above the per-route ones, which still apply after
+// authentication.
+func PreAuthRateLimitMiddleware(limiter domain.RateLimiter, limit config.Rate, logger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rateLimitExempt(c.FullPath()) {
+			c.Next()
+			return
+		}
+		if takeToken(c, limiter, preAuthRatePolicy+"|ip:"+c.ClientIP(), limit, logger) {
+			c.Next()
+		}
+	}
+}
+
+// takeToken takes a token from a bucket and sets the rate limit headers. It
+// responds 429 and returns false when none is left. A zero rate or a failing
+// limiter lets the request through.
+func takeToken(c *gin.Context, limiter domain.RateLimiter, bucket string, limit config.Rate, logger *logger.Logger) bool {
+	if limit.PerSecond <= 0 {
+		return true
+	}
+
+	decision, err := limiter.Allow(c.Request.Context(), bucket, domain.RateLimit{PerSecond: limit.PerSecond, Burst: limit.Burst})
+	if err != nil {
+		logger.Warnw("Rate limiter unavailable, allowing request", "error", err, "requestID", requestID(c))
+		return true
+	}
+
+	header := c.Writer.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	header.Set("RateLimit-Reset", strconv.FormatInt(seconds(decision.Reset), 10))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, seconds(time.Duration(float64(limit.Burst)/limit.PerSecond*float64(time.Second)))))
+
+	if !decision.Allowed {
+		retryAfter := strconv.FormatInt(max(seconds(decision.RetryAfter), 1), 10)
+		header.Set("Retry-After", retryAfter)
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.RateLimitedRequests.WithLabelValues(route).Inc()
+		writeProblem(c, http.StatusTooManyRequests, codeRateLimited, "Rate limit exceeded, retry in "+retryAfter+" seconds")
+		return false
+	}
+	return true
+}
+
+// ratePolicy returns the rule for a route, preferring "METHOD /route" over
+// "/route" entries, and the name of the buckets it uses.
+func ratePolicy(cfg config.RateLimit, method, route string) (string, config.RateLimitRule) {
+	if name, rule, ok := routeSetting(cfg.Routes, method, route); ok {
+		return name, rule
+	}
+	return defaultRatePolicy, cfg.Default
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
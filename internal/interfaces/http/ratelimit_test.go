@@ -0,0 +1,168 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/infrastructure/ratelimit"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, domain.RateLimit) (domain.RateLimitDecision, error) {
+	return domain.RateLimitDecision{}, errors.New("connection refused")
+}
+
+// acceptingAuthenticator takes any API key for a principal of its own.
+type acceptingAuthenticator struct{}
+
+func (acceptingAuthenticator) AuthenticateAPIKey(_ context.Context, secret string) (*domain.Principal, error) {
+	return &domain.Principal{Subject: "api_key:" + secret, Scopes: []domain.Scope{domain.ScopeAdmin}}, nil
+}
+
+func setupRateLimitedRouter(limiter domain.RateLimiter, cfg config.RateLimit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	log, _ := logger.New("debug", "test")
+
+	router := gin.New()
+	router.Use(
+		RequestIDMiddleware(),
+		AuthMiddleware(acceptingAuthenticator{}, nil, config.Auth{AnonymousReads: true}, log),
+		RateLimitMiddleware(limiter, cfg, log),
+	)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/health", ok)
+	router.GET("/xtz/delegations", ok)
+	router.GET("/xtz/delegations/stream", ok)
+	router.GET("/graphql", ok)
+	router.POST("/graphql", ok)
+	return router
+}
+
+func doRequest(router *gin.Engine, method, path, remoteAddr, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	if apiKey != "" {
+		req.Header.Set(apiKeyHeader, apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	cfg := config.RateLimit{
+		Default: config.RateLimitRule{
+			IP:  config.Rate{PerSecond: 0.5, Burst: 2},
+			Key: config.Rate{PerSecond: 1, Burst: 3},
+		},
+	}
+	router := setupRateLimitedRouter(ratelimit.NewMemoryLimiter(), cfg)
+
+	w := doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1234", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=4", w.Header().Get("RateLimit-Policy"))
+
+	w = doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1234", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1234", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, codeRateLimited, decodeProblem(t, w).Code)
+
+	// Routes without their own policy share the default buckets.
+	w = doRequest(router, http.MethodGet, "/graphql", "192.0.2.1:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Other clients and API keys have their own buckets.
+	w = doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.2:1234", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1234", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+
+	// Probes are never limited.
+	w = doRequest(router, http.MethodGet, "/health", "192.0.2.1:1234", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitMiddleware_RoutePolicies(t *testing.T) {
+	cfg := config.RateLimit{
+		Default: config.RateLimitRule{IP: config.Rate{PerSecond: 1, Burst: 1}},
+		Routes: map[string]config.RateLimitRule{
+			"/xtz/delegations/stream": {IP: config.Rate{PerSecond: 1, Burst: 2}},
+			"/graphql":                {IP: config.Rate{PerSecond: 1, Burst: 2}},
+			"POST /graphql":           {},
+		},
+	}
+	router := setupRateLimitedRouter(ratelimit.NewMemoryLimiter(), cfg)
+
+	assert.Equal(t, http.StatusOK, doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1", "").Code)
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, doRequest(router, http.MethodGet, "/xtz/delegations/stream", "192.0.2.1:1", "").Code)
+		assert.Equal(t, http.StatusOK, doRequest(router, http.MethodGet, "/graphql", "192.0.2.1:1", "").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(router, http.MethodGet, "/xtz/delegations/stream", "192.0.2.1:1", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(router, http.MethodGet, "/graphql", "192.0.2.1:1", "").Code)
+
+	// A zero rate disables limiting, and method-specific entries win.
+	for i := 0; i < 5; i++ {
+		w := doRequest(router, http.MethodPost, "/graphql", "192.0.2.1:1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitMiddleware_FailsOpen(t *testing.T) {
+	cfg := config.RateLimit{Default: config.RateLimitRule{IP: config.Rate{PerSecond: 1, Burst: 1}}}
+	router := setupRateLimitedRouter(failingLimiter{}, cfg)
+
+	w := doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+// Requests with invalid credentials are limited before they are checked.
+func TestPreAuthRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, _ := logger.New("debug", "test")
+
+	authenticator := new(MockService)
+	authenticator.On("AuthenticateAPIKey", mock.Anything).Return(nil, domain.ErrInvalidCredentials)
+
+	router := gin.New()
+	router.Use(
+		RequestIDMiddleware(),
+		PreAuthRateLimitMiddleware(ratelimit.NewMemoryLimiter(), config.Rate{PerSecond: 0.5, Burst: 2}, log),
+		AuthMiddleware(authenticator, nil, config.Auth{AnonymousReads: true}, log),
+	)
+	router.GET("/xtz/delegations", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1", "guess").Code)
+	}
+	w := doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.1:1", "guess")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	authenticator.AssertNumberOfCalls(t, "AuthenticateAPIKey", 2)
+
+	assert.Equal(t, http.StatusUnauthorized, doRequest(router, http.MethodGet, "/xtz/delegations", "192.0.2.2:1", "guess").Code)
+}
@@ -1,37 +1,133 @@
 package http
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/interfaces/graphql"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/config"
 	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/logger"
 )
 
-func NewRouter(service domain.DelegationService, logger *logger.Logger) *gin.Engine {
+// RouterConfig holds the settings of the middleware NewRouter installs.
+type RouterConfig struct {
+	// TrustedProxies may set X-Forwarded-For. When empty, the client IP is
+	// the address of the connection.
+	TrustedProxies []string
+	RateLimit      config.RateLimit
+	// RateLimiter stores the token buckets; rate limiting is off when nil.
+	RateLimiter domain.RateLimiter
+	// RequestTimeout bounds every request, unless RouteTimeouts has a
+	// timeout for its route. Zero means no deadline.
+	RequestTimeout time.Duration
+	RouteTimeouts  map[string]time.Duration
+	Auth           config.Auth
+	CORS           config.CORS
+	// TokenAuthenticator verifies bearer JWTs; they are rejected when nil.
+	TokenAuthenticator domain.TokenAuthenticator
+}
+
+func NewRouter(service domain.DelegationService, cfg RouterConfig, logger *logger.Logger) (*gin.Engine, error) {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	spec := NewOpenAPISpec()
+
+	// API keys are resolved by the service; without it, only anonymous
+	// requests are accepted.
+	authenticator, _ := service.(APIKeyAuthenticator)
 
 	router.Use(
+		RequestIDMiddleware(),
 		RecoveryMiddleware(logger),
 		LoggingMiddleware(logger),
-		CORSMiddleware(),
-		RateLimitMiddleware(),
+		CORSMiddleware(cfg.CORS),
+		TimeoutMiddleware(cfg.RequestTimeout, cfg.RouteTimeouts),
 	)
+	if cfg.RateLimiter != nil {
+		router.Use(PreAuthRateLimitMiddleware(cfg.RateLimiter, cfg.RateLimit.PreAuth, logger))
+	}
+	router.Use(AuthMiddleware(authenticator, cfg.TokenAuthenticator, cfg.Auth, logger))
+	if cfg.RateLimiter != nil {
+		router.Use(RateLimitMiddleware(cfg.RateLimiter, cfg.RateLimit, logger))
+	}
+	if reporter, ok := service.(DataCompletenessReporter); ok {
+		router.Use(DataCompletenessMiddleware(reporter))
+	}
+	router.Use(ValidationMiddleware(spec))
 
 	handler := NewHandler(service, logger)
+	handler.AllowWebSocketOrigins(cfg.CORS.Public)
 
-	router.GET("/health", handler.GetHealth)
+	router.GET("/health", handler.GetLiveness)
+	router.GET("/health/live", handler.GetLiveness)
+	router.GET("/health/ready", handler.GetReadiness)
+	router.GET("/health/indexer", handler.GetIndexerHealth)
 	router.GET("/ready", handler.GetReadiness)
+	router.GET("/openapi.json", OpenAPIHandler(spec))
 
 	api := router.Group("/xtz")
 	{
+		api.GET("/completeness", handler.GetDataCompleteness)
 		api.GET("/delegations", handler.GetDelegations)
+		api.GET("/delegations/stream", handler.StreamDelegations)
+		api.GET("/delegations/ws", handler.SubscribeDelegations)
+		api.GET("/stats/timeseries", handler.GetTimeSeries)
+		api.GET("/stats/top", handler.GetTopReport)
+		api.GET("/stats/movements", handler.GetLargeMovements)
+		api.GET("/stats/cycles", handler.GetCycleStats)
+		api.GET("/delegators/:address", handler.GetDelegator)
+		api.GET("/bakers/:address/delegators", handler.GetBakerDelegators)
 	}
 
+	// Admin routes require an API key or a token with the admin scope.
+	admin := router.Group("/admin")
+	{
+		admin.POST("/api-keys", handler.CreateAPIKey)
+		admin.GET("/api-keys", handler.ListAPIKeys)
+		admin.POST("/api-keys/:id/rotate", handler.RotateAPIKey)
+		admin.DELETE("/api-keys/:id", handler.RevokeAPIKey)
+		admin.POST("/webhooks", handler.CreateWebhook)
+		admin.GET("/webhooks", handler.ListWebhooks)
+		admin.GET("/webhooks/:id", handler.GetWebhook)
+		admin.DELETE("/webhooks/:id", handler.DeleteWebhook)
+		admin.GET("/webhooks/:id/deliveries", handler.ListWebhookDeliveries)
+		admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", handler.RedeliverWebhookDelivery)
+		admin.GET("/indexer", handler.GetIndexerStatus)
+		admin.POST("/indexer/pause", handler.PausePolling)
+		admin.POST("/indexer/resume", handler.ResumePolling)
+		admin.POST("/jobs/reindex", handler.StartReindex)
+		admin.POST("/jobs/verify", handler.StartVerification)
+		admin.GET("/jobs", handler.ListJobs)
+		admin.GET("/jobs/:id", handler.GetJob)
+		admin.POST("/jobs/:id/cancel", handler.CancelJob)
+	}
+
+	graphqlHandler := gin.WrapH(graphql.NewHandler(service, logger, graphql.DefaultLimits))
+	router.GET("/graphql", graphqlHandler)
+	router.POST("/graphql", graphqlHandler)
+
 	router.GET("/stats", handler.GetStats)
 
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-	return router
+	return router, nil
+}
+
+// routeSetting looks up the setting configured for a route, preferring the
+// one keyed by method and route ("POST /graphql") over the route alone.
+func routeSetting[V any](settings map[string]V, method, route string) (string, V, bool) {
+	for _, name := range []string{method + " " + route, route} {
+		if setting, ok := settings[name]; ok {
+			return name, setting, true
+		}
+	}
+	var zero V
+	return "", zero, false
 }
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+// StreamDelegations pushes newly committed delegations as Server-Sent Events.
+// Each event carries the delegation sequence number as its ID, so a client
+// reconnecting with Last-Event-ID (or ?last_event_id=) resumes from the store.
+func (h *Handler) StreamDelegations(c *gin.Context) {
+	type DelegationStreamer interface {
+		StreamDelegations(ctx context.Context, query domain.StreamQuery, emit func([]domain.Delegation) error) error
+	}
+
+	streamer, ok := h.service.(DelegationStreamer)
+	if !ok {
+		notImplemented(c, "Delegation stream not available")
+		return
+	}
+
+	filter, ok := h.parseDelegationFilter(c)
+	if !ok {
+		return
+	}
+	query := domain.StreamQuery{Filter: filter}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	if lastEventID != "" {
+		seq, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || seq < 0 {
+			invalidParameter(c, "Invalid Last-Event-ID. Must be a non-negative integer")
+			return
+		}
+		query.AfterSeq = &seq
+	}
+
+	// Streams outlive the server-wide write timeout.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	err := streamer.StreamDelegations(c.Request.Context(), query, func(delegations []domain.Delegation) error {
+		if delegations == nil {
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		}
+
+		for _, d := range delegations {
+			data, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: delegation\ndata: %s\n\n", d.Seq, data); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil && c.Request.Context().Err() == nil {
+		h.logger.Errorw("Delegation stream failed", "error", err)
+		fmt.Fprint(c.Writer, "event: error\ndata: {\"error\":\"Delegation stream interrupted\"}\n\n")
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+)
+
+const maxWebhookFilterAddresses = 1000
+
+type WebhookManager interface {
+	CreateWebhook(ctx context.Context, url string, filter domain.SubscriptionFilter) (*domain.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	GetWebhook(ctx context.Context, id string) (*domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	ListWebhookDeliveries(ctx context.Context, query domain.WebhookDeliveryQuery) ([]domain.WebhookDelivery, error)
+	RedeliverWebhookDelivery(ctx context.Context, webhookID string, id int64) error
+}
+
+type createWebhookRequest struct {
+	URL    string                    `json:"url"`
+	Filter domain.SubscriptionFilter `json:"filter"`
+}
+
+func (h *Handler) webhookManager(c *gin.Context) (WebhookManager, bool) {
+	manager, ok := h.service.(WebhookManager)
+	if !ok {
+		notImplemented(c, "Webhooks not available")
+	}
+	return manager, ok
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	manager, ok := h.webhookManager(c)
+	if !ok {
+		return
+	}
+
+	var req createWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidBody(c, "Invalid request body. Must be a JSON object with url and filter")
+		return
+	}
+
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		invalidBody(c, "Invalid url. Must be an absolute http or https URL")
+		return
+	}
+	if len(req.Filter.Bakers) > maxWebhookFilterAddresses || len(req.Filter.Delegators) > maxWebhookFilterAddresses {
+		invalidBody(c, "Too many addresses in filter")
+		return
+	}
+	if req.Filter.MinAmount < 0 {
+		invalidBody(c, "Invalid min_amount. Must be a non-negative integer")
+		return
+	}
+
+	webhook, err := manager.CreateWebhook(c.Request.Context(), req.URL, req.Filter)
+	if err != nil {
+		h.logger.Errorw("Failed to create webhook", "error", err)
+		respondError(c, err, "Failed to create webhook")
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	manager, ok := h.webhookManager(c)
+	if !ok {
+		return
+	}
+
+	webhooks, err := manager.ListWebhooks(c.Request.Context())
+	if err != nil {
+		h.logger.Errorw("Failed to list webhooks", "error", err)
+		respondError(c, err, "Failed to retrieve webhooks")
+		return
+	}
+	if webhooks == nil {
+		webhooks = []domain.Webhook{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": webhooks,
+	})
+}
+
+func (h *Handler) GetWebhook(c *gin.Context) {
+	manager, ok := h.webhookManager(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := manager.GetWebhook(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.webhookError(c, err, "Failed to retrieve webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	manager, ok := h.webhookManager(c)
+	if !ok {
+		return
+	}
+
+	if err := manager.DeleteWebhook(c.Request.Context(), c.Param("id")); err != nil {
+		h.webhookError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries is the delivery log of one webhook, newest first.
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	manager, ok := h.webhookManager(c)
+	if !ok {
+		return
+	}
+
+	query := domain.WebhookDeliveryQuery{WebhookID: c.Param("id")}
+
+	if status := c.Query("status"); status != "" {
+		query.Status = domain.WebhookDeliveryStatus(status)
+		if !query.Status.Valid() {
+			invalidParameter(c, "Invalid status. Must be one of: pending, delivered, dead")
+			return
+		}
+	}
+
+	if query.Limit, ok = parseLimit(c); !ok {
+		return
+	}
+
+	deliveries, err := manager.ListWebhookDeliveries(c.Request.Context(), query)
+	if err != nil {
+		h.webhookError(c, err, "Failed to retrieve webhook deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []domain.WebhookDelivery{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhook_id": query.WebhookID,
+		"data":       deliveries,
+	})
+}
+
+func (h *Handler) RedeliverWebhookDelivery(c *gin.Context) {
+	manager, ok := h.webhookManager(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		respondError(c, domain.ErrWebhookDeliveryNotFound, "")
+		return
+	}
+
+	if err := manager.RedeliverWebhookDelivery(c.Request.Context(), c.Param("id"), id); err != nil {
+		h.webhookError(c, err, "Failed to requeue webhook delivery")
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func (h *Handler) webhookError(c *gin.Context, err error, message string) {
+	if !domain.IsKind(err, domain.KindNotFound) {
+		h.logger.Errorw(message, "error", err, "webhook_id", c.Param("id"))
+	}
+	respondError(c, err, message)
+}
@@ -0,0 +1,275 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/pubsub"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
+)
+
+const (
+	wsSendBuffer          = 256
+	wsMaxSubscriptions    = 32
+	wsMaxFilterAddresses  = 1000
+	wsMaxMessageSize      = 64 * 1024
+	wsWriteTimeout        = 10 * time.Second
+	wsPongTimeout         = 60 * time.Second
+	wsPingInterval        = 45 * time.Second
+	wsCloseSlowConsumer   = "slow consumer"
+	wsActionSubscribe     = "subscribe"
+	wsActionUnsubscribe   = "unsubscribe"
+	wsMessageSubscribed   = "subscribed"
+	wsMessageUnsubscribed = "unsubscribed"
+	wsMessageDelegation   = "delegation"
+	wsMessageError        = "error"
+)
+
+// newUpgrader accepts WebSocket handshakes from clients that send no Origin,
+// which are not browsers, from the origin serving the API and from the
+// origins the public CORS policy allows. Browsers do not apply CORS to
+// WebSockets, so any page could otherwise subscribe with its visitors'
+// credentials.
+func newUpgrader(origins *corsPolicy) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 4096,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+				return true
+			}
+			return origins != nil && origins.allows(origin)
+		},
+	}
+}
+
+type wsRequest struct {
+	Action string                    `json:"action"`
+	ID     string                    `json:"id"`
+	Filter domain.SubscriptionFilter `json:"filter"`
+}
+
+type wsMessage struct {
+	Type          string           `json:"type"`
+	ID            string           `json:"id,omitempty"`
+	Subscriptions []string         `json:"subscriptions,omitempty"`
+	Data          *domain.Movement `json:"data,omitempty"`
+	Error         string           `json:"error,omitempty"`
+}
+
+// wsFilters holds the subscriptions of one connection. It is read by the
+// hub on publish and written by the connection's reader.
+type wsFilters struct {
+	mu      sync.RWMutex
+	filters map[string]domain.SubscriptionFilter
+}
+
+func (f *wsFilters) set(id string, filter domain.SubscriptionFilter) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.filters[id]; !exists {
+		if len(f.filters) >= wsMaxSubscriptions {
+			return false
+		}
+		metrics.WebSocketSubscriptions.Inc()
+	}
+	f.filters[id] = filter
+	return true
+}
+
+func (f *wsFilters) remove(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.filters[id]; !exists {
+		return false
+	}
+	delete(f.filters, id)
+	metrics.WebSocketSubscriptions.Dec()
+	return true
+}
+
+func (f *wsFilters) clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	metrics.WebSocketSubscriptions.Sub(float64(len(f.filters)))
+	f.filters = make(map[string]domain.SubscriptionFilter)
+}
+
+func (f *wsFilters) matching(d domain.Delegation) []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var ids []string
+	for id, filter := range f.filters {
+		if filter.Matches(d) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// SubscribeDelegations upgrades to a WebSocket on which clients manage any
+// number of filtered subscriptions and receive matching delegations as they
+// are committed. Clients that cannot keep up are disconnected rather than
+// slowing down ingestion.
+func (h *Handler) SubscribeDelegations(c *gin.Context) {
+	type DelegationSubscriber interface {
+		SubscribeDelegations(buffer int) *pubsub.Subscription
+	}
+
+	subscriber, ok := h.service.(DelegationSubscriber)
+	if !ok {
+		notImplemented(c, "Delegation subscriptions not available")
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an HTTP error response.
+		h.logger.Debugw("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	metrics.WebSocketClients.Inc()
+	defer metrics.WebSocketClients.Dec()
+
+	filters := &wsFilters{filters: make(map[string]domain.SubscriptionFilter)}
+
+	sub := subscriber.SubscribeDelegations(wsSendBuffer)
+	defer sub.Close()
+	sub.SetMatch(func(d domain.Delegation) bool {
+		return len(filters.matching(d)) > 0
+	})
+
+	replies := make(chan wsMessage, wsMaxSubscriptions)
+	done := make(chan struct{})
+	closing := make(chan struct{})
+	go h.readSubscriptions(conn, filters, replies, done, closing)
+	defer func() {
+		// Stop the reader before dropping its subscriptions.
+		close(closing)
+		conn.Close()
+		<-done
+		filters.clear()
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case reply := <-replies:
+			if err := writeWS(conn, reply); err != nil {
+				return
+			}
+		case d, ok := <-sub.C():
+			if !ok {
+				if sub.Lagged() {
+					h.logger.Warnw("Disconnecting slow WebSocket client", "remote_addr", c.ClientIP())
+					closeWS(conn, websocket.ClosePolicyViolation, wsCloseSlowConsumer)
+				}
+				return
+			}
+			ids := filters.matching(d)
+			if len(ids) == 0 {
+				continue
+			}
+			movement := d.Movement()
+			if err := writeWS(conn, wsMessage{Type: wsMessageDelegation, Subscriptions: ids, Data: &movement}); err != nil {
+				return
+			}
+			metrics.WebSocketMessagesSent.Inc()
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readSubscriptions processes client requests until the connection fails.
+// Replies go through the writer loop since a connection supports only one
+// concurrent writer.
+func (h *Handler) readSubscriptions(conn *websocket.Conn, filters *wsFilters, replies chan<- wsMessage, done chan<- struct{}, closing <-chan struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				h.logger.Debugw("WebSocket read failed", "error", err)
+			}
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		var reply wsMessage
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			reply = wsMessage{Type: wsMessageError, Error: "Invalid message. Must be a JSON object"}
+		} else {
+			reply = handleWSRequest(filters, req)
+		}
+
+		select {
+		case replies <- reply:
+		case <-closing:
+			return
+		}
+	}
+}
+
+func handleWSRequest(filters *wsFilters, req wsRequest) wsMessage {
+	if req.ID == "" {
+		return wsMessage{Type: wsMessageError, Error: "Missing subscription id"}
+	}
+
+	switch req.Action {
+	case wsActionSubscribe:
+		if len(req.Filter.Bakers) > wsMaxFilterAddresses || len(req.Filter.Delegators) > wsMaxFilterAddresses {
+			return wsMessage{Type: wsMessageError, ID: req.ID, Error: "Too many addresses in filter"}
+		}
+		if req.Filter.MinAmount < 0 {
+			return wsMessage{Type: wsMessageError, ID: req.ID, Error: "Invalid min_amount. Must be a non-negative integer"}
+		}
+		if !filters.set(req.ID, req.Filter) {
+			return wsMessage{Type: wsMessageError, ID: req.ID, Error: "Too many subscriptions on this connection"}
+		}
+		return wsMessage{Type: wsMessageSubscribed, ID: req.ID}
+	case wsActionUnsubscribe:
+		if !filters.remove(req.ID) {
+			return wsMessage{Type: wsMessageError, ID: req.ID, Error: "Unknown subscription id"}
+		}
+		return wsMessage{Type: wsMessageUnsubscribed, ID: req.ID}
+	default:
+		return wsMessage{Type: wsMessageError, ID: req.ID, Error: "Invalid action. Must be 'subscribe' or 'unsubscribe'"}
+	}
+}
+
+func writeWS(conn *websocket.Conn, msg wsMessage) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(msg)
+}
+
+func closeWS(conn *websocket.Conn, code int, text string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsWriteTimeout))
+}
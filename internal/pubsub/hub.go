@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"sync"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/pkg/metrics"
+)
+
+// Hub fans committed delegations out to in-process subscribers. Publishing
+// never blocks ingestion: a subscriber whose buffer is full is dropped and
+// its channel closed, with Lagged reporting why.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+type Subscription struct {
+	hub    *Hub
+	ch     chan domain.Delegation
+	match  func(domain.Delegation) bool
+	lagged bool
+}
+
+// Subscribe registers a subscriber able to queue up to buffer delegations.
+func (h *Hub) Subscribe(buffer int) *Subscription {
+	sub := &Subscription{
+		hub: h,
+		ch:  make(chan domain.Delegation, buffer),
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	metrics.HubSubscribers.Inc()
+	return sub
+}
+
+func (h *Hub) Publish(delegations []domain.Delegation) {
+	if len(delegations) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		for _, d := range delegations {
+			if sub.match != nil && !sub.match(d) {
+				continue
+			}
+			select {
+			case sub.ch <- d:
+				continue
+			default:
+			}
+			sub.lagged = true
+			h.remove(sub)
+			metrics.HubDroppedSubscribers.Inc()
+			break
+		}
+	}
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.ch)
+	metrics.HubSubscribers.Dec()
+}
+
+// C delivers published delegations. It is closed when the subscription is
+// closed or dropped for lagging.
+func (s *Subscription) C() <-chan domain.Delegation {
+	return s.ch
+}
+
+// SetMatch restricts the delegations queued for this subscriber, so that
+// only what it will actually consume counts against its buffer. A nil match
+// accepts every delegation.
+func (s *Subscription) SetMatch(match func(domain.Delegation) bool) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.match = match
+}
+
+// Lagged reports whether the subscription was dropped because its buffer
+// filled up. It is only meaningful once C is closed.
+func (s *Subscription) Lagged() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagged
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
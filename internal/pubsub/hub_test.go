@@ -0,0 +1,78 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/q4ZAr/kiln-mid-back/tezos-delegation-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_Publish(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(10)
+	defer sub.Close()
+
+	hub.Publish([]domain.Delegation{
+		{Delegator: "tz1one", Amount: "100"},
+		{Delegator: "tz1two", Amount: "200"},
+	})
+
+	require.Len(t, sub.C(), 2)
+	assert.Equal(t, "tz1one", (<-sub.C()).Delegator)
+	assert.Equal(t, "tz1two", (<-sub.C()).Delegator)
+}
+
+func TestHub_PublishWithMatch(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(10)
+	defer sub.Close()
+	sub.SetMatch(func(d domain.Delegation) bool {
+		return d.Delegator == "tz1two"
+	})
+
+	hub.Publish([]domain.Delegation{
+		{Delegator: "tz1one", Amount: "100"},
+		{Delegator: "tz1two", Amount: "200"},
+	})
+
+	require.Len(t, sub.C(), 1)
+	assert.Equal(t, "tz1two", (<-sub.C()).Delegator)
+}
+
+func TestHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	slow := hub.Subscribe(1)
+	fast := hub.Subscribe(10)
+	defer fast.Close()
+
+	hub.Publish([]domain.Delegation{
+		{Delegator: "tz1one", Amount: "100"},
+		{Delegator: "tz1two", Amount: "200"},
+	})
+
+	// The slow subscriber keeps what was queued, then sees its channel closed.
+	_, ok := <-slow.C()
+	assert.True(t, ok)
+	_, ok = <-slow.C()
+	assert.False(t, ok)
+	assert.True(t, slow.Lagged())
+
+	assert.Len(t, fast.C(), 2)
+	assert.False(t, fast.Lagged())
+
+	// Closing a dropped subscription is a no-op.
+	slow.Close()
+}
+
+func TestSubscription_Close(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+	sub.Close()
+
+	hub.Publish([]domain.Delegation{{Delegator: "tz1one", Amount: "100"}})
+
+	_, ok := <-sub.C()
+	assert.False(t, ok)
+	assert.False(t, sub.Lagged())
+}
@@ -162,17 +162,18 @@ type MockDelegationRepository struct {
 	mock.Mock
 }
 
-func (m *MockDelegationRepository) Save(delegation *domain.Delegation) error {
+func (m *MockDelegationRepository) Save(ctx context.Context, delegation *domain.Delegation) error {
 	args := m.Called(delegation)
 	return args.Error(0)
 }
 
-func (m *MockDelegationRepository) SaveBatch(delegations []domain.Delegation) error {
+func (m *MockDelegationRepository) SaveBatch(ctx context.Context, delegations []domain.Delegation) ([]domain.Delegation, error) {
 	args := m.Called(delegations)
-	return args.Error(0)
+	created, _ := args.Get(0).([]domain.Delegation)
+	return created, args.Error(1)
 }
 
-func (m *MockDelegationRepository) FindAll(year *int) ([]domain.Delegation, error) {
+func (m *MockDelegationRepository) FindAll(ctx context.Context, year *int) ([]domain.Delegation, error) {
 	args := m.Called(year)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -180,12 +181,12 @@ func (m *MockDelegationRepository) FindAll(year *int) ([]domain.Delegation, erro
 	return args.Get(0).([]domain.Delegation), args.Error(1)
 }
 
-func (m *MockDelegationRepository) GetLastIndexedLevel() (int64, error) {
+func (m *MockDelegationRepository) GetLastIndexedLevel(ctx context.Context) (int64, error) {
 	args := m.Called()
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockDelegationRepository) Exists(delegator string, level string) (bool, error) {
+func (m *MockDelegationRepository) Exists(ctx context.Context, delegator string, level string) (bool, error) {
 	args := m.Called(delegator, level)
 	return args.Get(0).(bool), args.Error(1)
 }
@@ -195,7 +196,7 @@ type MockDelegationService struct {
 	mock.Mock
 }
 
-func (m *MockDelegationService) GetDelegations(year *int) ([]domain.Delegation, error) {
+func (m *MockDelegationService) GetDelegations(ctx context.Context, year *int) ([]domain.Delegation, error) {
 	args := m.Called(year)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -203,7 +204,7 @@ func (m *MockDelegationService) GetDelegations(year *int) ([]domain.Delegation,
 	return args.Get(0).([]domain.Delegation), args.Error(1)
 }
 
-func (m *MockDelegationService) IndexDelegations(fromLevel int64) error {
+func (m *MockDelegationService) IndexDelegations(ctx context.Context, fromLevel int64) error {
 	args := m.Called(fromLevel)
 	return args.Error(0)
 }
@@ -217,7 +218,7 @@ func (m *MockDelegationService) StopPolling() {
 	m.Called()
 }
 
-func (m *MockDelegationService) GetStats() (map[string]interface{}, error) {
+func (m *MockDelegationService) GetStats(ctx context.Context) (map[string]interface{}, error) {
 	args := m.Called()
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
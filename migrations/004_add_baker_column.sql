@@ -0,0 +1,6 @@
+-- Store the baker (new delegate) each delegation points to.
+-- Rows indexed before this migration keep a NULL baker until the
+-- backfill_bakers job, queued at startup, fetches them again.
+ALTER TABLE delegations ADD COLUMN IF NOT EXISTS baker TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_delegations_baker ON delegations(baker);
@@ -0,0 +1,6 @@
+-- Store the baker a delegator moved away from, so stake movements between
+-- bakers can be reported without replaying the delegator's history.
+ALTER TABLE delegations ADD COLUMN IF NOT EXISTS prev_baker TEXT;
+
+-- Amount-ordered reports (top delegations, large movements)
+CREATE INDEX IF NOT EXISTS idx_delegations_amount_numeric ON delegations((CAST(amount AS NUMERIC)) DESC);
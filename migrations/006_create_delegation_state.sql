@@ -0,0 +1,21 @@
+-- Current delegation per account, maintained alongside the delegations
+-- event log so "who delegates to baker X right now" is a single lookup.
+CREATE TABLE IF NOT EXISTS delegation_state (
+    delegator TEXT PRIMARY KEY,
+    baker TEXT,
+    since_level BIGINT NOT NULL,
+    since_timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_amount TEXT NOT NULL,
+    operation_hash TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_delegation_state_baker ON delegation_state(baker);
+
+-- Backfill from the existing event log: the latest delegation of each account wins
+INSERT INTO delegation_state (delegator, baker, since_level, since_timestamp, last_amount, operation_hash)
+SELECT DISTINCT ON (delegator)
+    delegator, baker, CAST(level AS BIGINT), timestamp, amount, operation_hash
+FROM delegations
+ORDER BY delegator, CAST(level AS BIGINT) DESC, timestamp DESC
+ON CONFLICT (delegator) DO NOTHING;
@@ -0,0 +1,4 @@
+-- Point-in-time queries look up the latest delegation per account at or
+-- before a given level
+CREATE INDEX IF NOT EXISTS idx_delegations_delegator_level_numeric
+    ON delegations(delegator, (CAST(level AS BIGINT)) DESC);
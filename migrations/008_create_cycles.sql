@@ -0,0 +1,16 @@
+-- Cycle boundaries fetched from TzKT; cycle lengths vary by protocol
+CREATE TABLE IF NOT EXISTS cycles (
+    cycle_index BIGINT PRIMARY KEY,
+    first_level BIGINT NOT NULL,
+    last_level BIGINT NOT NULL,
+    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cycles_levels ON cycles(first_level, last_level);
+
+-- Cycle of each delegation, filled on insert or once the cycle is synced
+ALTER TABLE delegations ADD COLUMN IF NOT EXISTS cycle BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_delegations_cycle ON delegations(cycle);
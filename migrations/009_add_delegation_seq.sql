@@ -0,0 +1,5 @@
+-- Monotonic insertion sequence, used as the event ID of the delegation
+-- stream so clients can resume after the last event they received
+ALTER TABLE delegations ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_delegations_seq ON delegations(seq);
@@ -0,0 +1,7 @@
+-- Schema version recorded by the service after applying its migrations and
+-- checked by the readiness probe
+CREATE TABLE IF NOT EXISTS schema_version (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
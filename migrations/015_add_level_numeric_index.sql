@@ -0,0 +1,4 @@
+-- Level ranges are verified and reindexed by admin jobs, and the last
+-- indexed level is read on every poll
+CREATE INDEX IF NOT EXISTS idx_delegations_level_numeric
+    ON delegations((CAST(level AS BIGINT)));
@@ -0,0 +1,34 @@
+-- Background jobs. A job is claimed by a worker, which saves its progress
+-- and heartbeat while it runs; failed attempts go back to pending until
+-- max_attempts have been made.
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    trigger TEXT NOT NULL,
+    from_level BIGINT NOT NULL DEFAULT 0,
+    to_level BIGINT NOT NULL DEFAULT 0,
+    repair BOOLEAN NOT NULL DEFAULT FALSE,
+    progress JSONB NOT NULL DEFAULT '{}',
+    result JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 1,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    singleton BOOLEAN NOT NULL DEFAULT FALSE,
+    scheduled_for TIMESTAMP WITH TIME ZONE,
+    worker TEXT,
+    heartbeat_at TIMESTAMP WITH TIME ZONE,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at DESC);
+
+-- At most one singleton job of each kind is pending or running, and a
+-- schedule enqueues one job per due time across replicas.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_singleton ON jobs(kind) WHERE singleton AND status IN ('pending', 'running');
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_scheduled_for ON jobs(kind, scheduled_for);
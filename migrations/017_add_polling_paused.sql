@@ -0,0 +1,2 @@
+-- Whether polling is paused, on every replica, until it is resumed.
+ALTER TABLE indexing_metadata ADD COLUMN IF NOT EXISTS polling_paused BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- At most one job of each kind runs at a time. Jobs that raced to run
+-- alongside another of their kind go back to pending; their workers stop
+-- once they find the job is no longer theirs.
+UPDATE jobs SET status = 'pending', worker = NULL, heartbeat_at = NULL
+WHERE status = 'running' AND id NOT IN (
+    SELECT DISTINCT ON (kind) id FROM jobs WHERE status = 'running' ORDER BY kind, started_at ASC
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_running ON jobs(kind) WHERE status = 'running';
@@ -0,0 +1,3 @@
+-- Pages of delegations are ordered by level, then by storage order within a
+-- level.
+CREATE INDEX IF NOT EXISTS idx_delegations_level_seq ON delegations((CAST(level AS BIGINT)) DESC, seq DESC);
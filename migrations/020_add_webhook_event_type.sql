@@ -0,0 +1,2 @@
+-- Webhook deliveries carry either a delegation or a rollback.
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS event_type TEXT NOT NULL DEFAULT 'delegation';
@@ -1,20 +1,35 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Database Database
-	Server   Server
-	TzktAPI  TzktAPI
-	Logging  Logging
-	Metrics  Metrics
+	Database  Database
+	Server    Server
+	TzktAPI   TzktAPI
+	Logging   Logging
+	Metrics   Metrics
+	GRPC      GRPC
+	Analytics Analytics
+	Webhooks  Webhooks
+	Outbox    Outbox
+	Health    Health
+	RateLimit RateLimit
+	Auth      Auth
+	CORS      CORS
+	Jobs      Jobs
+	Backup    Backup
+	Snapshot  Snapshot
 }
 
 type Database struct {
@@ -28,6 +43,12 @@ type Server struct {
 	Port            string
 	RequestTimeout  time.Duration
 	ShutdownTimeout time.Duration
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For
+	// header is believed when identifying clients.
+	TrustedProxies []string
+	// RouteTimeouts overrides RequestTimeout per gin route, optionally
+	// prefixed with its method. A zero timeout means no deadline.
+	RouteTimeouts map[string]time.Duration
 }
 
 type TzktAPI struct {
@@ -50,6 +71,216 @@ type Metrics struct {
 	Enabled bool
 }
 
+type GRPC struct {
+	Port    string
+	Enabled bool
+}
+
+type Analytics struct {
+	// LargeMovementThreshold is the default minimum amount, in mutez, for a
+	// delegation to appear in the large movements feed.
+	LargeMovementThreshold int64
+}
+
+type Webhooks struct {
+	Enabled        bool
+	PollInterval   time.Duration
+	RequestTimeout time.Duration
+	BatchSize      int
+	Concurrency    int
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// AllowPrivateTargets lets webhooks target loopback, private and
+	// link-local addresses, which are otherwise refused.
+	AllowPrivateTargets bool
+}
+
+// Jobs configures the background job runner. Each process runs up to
+// Concurrency jobs, claimed from the jobs table every PollInterval. Failed
+// attempts are retried after RetryBackoff, doubled after each failure, and
+// finished jobs are kept for Retention. Schedules maps job kinds to the cron
+// expressions enqueuing them.
+type Jobs struct {
+	Concurrency  int
+	PollInterval time.Duration
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	Retention    time.Duration
+	Schedules    map[string]string
+}
+
+// Backup configures where backup archives are stored. Target is a local
+// directory or an s3://bucket/prefix URL; Keep is how many of the latest
+// archives are kept, all of them when zero.
+type Backup struct {
+	Target string
+	Keep   int
+	S3     S3
+}
+
+// S3 configures an S3-compatible object store. Without access keys,
+// credentials are read from the AWS environment variables, the shared
+// credentials file or the instance role.
+type S3 struct {
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Insecure uses plain HTTP, for local object stores such as MinIO.
+	Insecure bool
+}
+
+// Snapshot configures the snapshot a fresh deployment is seeded from
+// instead of TzKT. Source is a path or an http(s) URL; SHA256, when set, is
+// the expected hex digest of the file.
+type Snapshot struct {
+	Source string
+	SHA256 string
+}
+
+// Outbox configures the relay of the transactional event outbox to an event
+// bus. Publisher is one of nats, stdout or file.
+type Outbox struct {
+	Enabled           bool
+	Publisher         string
+	FilePath          string
+	NATSURL           string
+	NATSSubjectPrefix string
+	NATSJetStream     bool
+	PollInterval      time.Duration
+	PublishTimeout    time.Duration
+	BatchSize         int
+	Retention         time.Duration
+}
+
+// Health configures the readiness and indexer health checks. The indexer is
+// reported as degraded when it is more than IndexerMaxLagBlocks behind the
+// chain head, or has not caught up with a head produced in IndexerMaxLag.
+type Health struct {
+	CheckTimeout        time.Duration
+	IndexerMaxLagBlocks int64
+	IndexerMaxLag       time.Duration
+}
+
+// Auth configures API authentication. When AnonymousReads is set, requests
+// without credentials may read delegations and statistics; admin endpoints
+// and /metrics always require credentials.
+type Auth struct {
+	AnonymousReads bool
+	// APIKeyUsageFlush is how often the usage counts of API keys, kept in
+	// memory, are written to the database.
+	APIKeyUsageFlush time.Duration
+	JWT              JWT
+}
+
+// JWT configures bearer tokens issued by an OIDC provider. Tokens are
+// accepted when JWKSURL or KeyFile is set; KeyFile holds a JWK set or PEM
+// public keys. RolesClaim is the claim, possibly nested ("realm_access.roles"),
+// listing the roles of the subject, and RoleScopes maps roles to scopes.
+type JWT struct {
+	JWKSURL     string
+	KeyFile     string
+	Issuer      string
+	Audience    string
+	RolesClaim  string
+	RoleScopes  map[string][]string
+	JWKSRefresh time.Duration
+	Leeway      time.Duration
+}
+
+// Enabled reports whether bearer tokens are accepted.
+func (j JWT) Enabled() bool {
+	return j.JWKSURL != "" || j.KeyFile != ""
+}
+
+// Validate requires the issuer and audience of accepted tokens: without
+// them, a token the provider issued to any other application would do.
+func (j JWT) Validate() error {
+	if !j.Enabled() {
+		return nil
+	}
+	if j.Issuer == "" {
+		return errors.New("an issuer is required")
+	}
+	if j.Audience == "" {
+		return errors.New("an audience is required")
+	}
+	return nil
+}
+
+// CORS holds the cross-origin policies of the public routes and of the
+// /admin routes, which should be open to fewer origins.
+type CORS struct {
+	Public CORSPolicy
+	Admin  CORSPolicy
+}
+
+// CORSPolicy lists what browsers on other origins may do. An allowed
+// origin is exact ("https://app.example.com"), a wildcard subdomain
+// ("https://*.example.com") or "*" for any origin; credentials cannot be
+// allowed for any origin. A zero MaxAge lets browsers use their default.
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Validate checks the allowed origins.
+func (p CORSPolicy) Validate() error {
+	for _, origin := range p.AllowedOrigins {
+		if origin == "*" {
+			if p.AllowCredentials {
+				return errors.New("credentials cannot be allowed for any origin")
+			}
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#@") {
+			return fmt.Errorf("%q: expected scheme://host[:port]", origin)
+		}
+		if wildcard := strings.TrimPrefix(host, "*."); strings.Contains(wildcard, "*") || wildcard == "" {
+			return fmt.Errorf("%q: only a leading *. subdomain wildcard is supported", origin)
+		}
+	}
+	if p.MaxAge < 0 {
+		return errors.New("max age cannot be negative")
+	}
+	return nil
+}
+
+// RateLimit configures per-client token buckets. Anonymous clients are
+// limited per IP, authenticated clients per principal. Routes maps a gin
+// route, optionally prefixed with its method ("POST /graphql"), to its own
+// limits; routes not listed share Default. Backend is memory, for limits
+// per replica, or postgres, for limits shared by all replicas.
+type RateLimit struct {
+	Enabled bool
+	Backend string
+	// PreAuth limits every client IP before credentials are checked, so
+	// that requests with invalid credentials are limited too.
+	PreAuth Rate
+	Default RateLimitRule
+	Routes  map[string]RateLimitRule
+}
+
+// RateLimitRule holds the limits of anonymous and authenticated clients. A rate
+// of 0 disables limiting.
+type RateLimitRule struct {
+	IP  Rate
+	Key Rate
+}
+
+// Rate is a token bucket refilled with PerSecond tokens up to Burst.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+const defaultCORSHeaders = "Accept,Authorization,Cache-Control,Content-Type,X-API-Key,X-Request-ID,X-Requested-With"
+
 func Load() (*Config, error) {
 	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
@@ -66,6 +297,7 @@ func Load() (*Config, error) {
 			Port:            getEnv("SERVER_PORT", "8080"),
 			RequestTimeout:  getEnvAsDuration("REQUEST_TIMEOUT", "60s"),
 			ShutdownTimeout: getEnvAsDuration("SHUTDOWN_TIMEOUT", "10s"),
+			TrustedProxies:  getEnvAsList("TRUSTED_PROXIES"),
 		},
 		TzktAPI: TzktAPI{
 			BaseURL:             getEnv("TZKT_API_URL", "https://api.tzkt.io"),
@@ -84,11 +316,291 @@ func Load() (*Config, error) {
 			Port:    getEnv("METRICS_PORT", "9090"),
 			Enabled: getEnvAsBool("METRICS_ENABLED", true),
 		},
+		GRPC: GRPC{
+			Port:    getEnv("GRPC_PORT", "50051"),
+			Enabled: getEnvAsBool("GRPC_ENABLED", true),
+		},
+		Analytics: Analytics{
+			LargeMovementThreshold: getEnvAsInt64("LARGE_MOVEMENT_THRESHOLD", 100_000_000_000),
+		},
+		Webhooks: Webhooks{
+			Enabled:             getEnvAsBool("WEBHOOKS_ENABLED", true),
+			PollInterval:        getEnvAsDuration("WEBHOOK_POLL_INTERVAL", "2s"),
+			RequestTimeout:      getEnvAsDuration("WEBHOOK_REQUEST_TIMEOUT", "10s"),
+			BatchSize:           getEnvAsInt("WEBHOOK_BATCH_SIZE", 50),
+			Concurrency:         getEnvAsInt("WEBHOOK_CONCURRENCY", 4),
+			MaxAttempts:         getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 10),
+			InitialBackoff:      getEnvAsDuration("WEBHOOK_INITIAL_BACKOFF", "30s"),
+			MaxBackoff:          getEnvAsDuration("WEBHOOK_MAX_BACKOFF", "6h"),
+			AllowPrivateTargets: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+		},
+		Outbox: Outbox{
+			Enabled:           getEnvAsBool("OUTBOX_ENABLED", false),
+			Publisher:         getEnv("OUTBOX_PUBLISHER", "nats"),
+			FilePath:          getEnv("OUTBOX_FILE_PATH", ""),
+			NATSURL:           getEnv("NATS_URL", "nats://localhost:4222"),
+			NATSSubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", "tezos."),
+			NATSJetStream:     getEnvAsBool("NATS_JETSTREAM", true),
+			PollInterval:      getEnvAsDuration("OUTBOX_POLL_INTERVAL", "1s"),
+			PublishTimeout:    getEnvAsDuration("OUTBOX_PUBLISH_TIMEOUT", "30s"),
+			BatchSize:         getEnvAsInt("OUTBOX_BATCH_SIZE", 500),
+			Retention:         getEnvAsDuration("OUTBOX_RETENTION", "168h"),
+		},
+		Health: Health{
+			CheckTimeout:        getEnvAsDuration("HEALTH_CHECK_TIMEOUT", "2s"),
+			IndexerMaxLagBlocks: getEnvAsInt64("INDEXER_MAX_LAG_BLOCKS", 10),
+			IndexerMaxLag:       getEnvAsDuration("INDEXER_MAX_LAG", "2m"),
+		},
+		Auth: Auth{
+			AnonymousReads:   getEnvAsBool("AUTH_ANONYMOUS_READS", true),
+			APIKeyUsageFlush: getEnvAsDuration("AUTH_API_KEY_USAGE_FLUSH", "30s"),
+			JWT: JWT{
+				JWKSURL:     getEnv("AUTH_JWKS_URL", ""),
+				KeyFile:     getEnv("AUTH_JWT_KEY_FILE", ""),
+				Issuer:      getEnv("AUTH_JWT_ISSUER", ""),
+				Audience:    getEnv("AUTH_JWT_AUDIENCE", ""),
+				RolesClaim:  getEnv("AUTH_JWT_ROLES_CLAIM", "roles"),
+				JWKSRefresh: getEnvAsDuration("AUTH_JWKS_REFRESH", "1h"),
+				Leeway:      getEnvAsDuration("AUTH_JWT_LEEWAY", "30s"),
+			},
+		},
+	}
+
+	roleScopes, err := ParseRoleScopes(getEnv("AUTH_JWT_ROLE_SCOPES", "admin=admin,reader=read:delegations|read:stats"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_JWT_ROLE_SCOPES: %w", err)
+	}
+	cfg.Auth.JWT.RoleScopes = roleScopes
+	if err := cfg.Auth.JWT.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid JWT authentication: %w", err)
+	}
+	if cfg.Auth.APIKeyUsageFlush <= 0 {
+		return nil, fmt.Errorf("invalid AUTH_API_KEY_USAGE_FLUSH: must be positive")
+	}
+
+	timeouts, err := ParseRouteTimeouts(getEnv("ROUTE_TIMEOUTS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ROUTE_TIMEOUTS: %w", err)
+	}
+	cfg.Server.RouteTimeouts = timeouts
+
+	cfg.CORS = CORS{
+		Public: CORSPolicy{
+			AllowedOrigins:   splitList(getEnv("CORS_ALLOWED_ORIGINS", "*")),
+			AllowedMethods:   splitList(getEnv("CORS_ALLOWED_METHODS", "GET,POST")),
+			AllowedHeaders:   splitList(getEnv("CORS_ALLOWED_HEADERS", defaultCORSHeaders)),
+			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvAsDuration("CORS_MAX_AGE", "24h"),
+		},
+		Admin: CORSPolicy{
+			AllowedOrigins:   getEnvAsList("CORS_ADMIN_ALLOWED_ORIGINS"),
+			AllowedMethods:   splitList(getEnv("CORS_ADMIN_ALLOWED_METHODS", "GET,POST,DELETE")),
+			AllowedHeaders:   splitList(getEnv("CORS_ADMIN_ALLOWED_HEADERS", defaultCORSHeaders)),
+			AllowCredentials: getEnvAsBool("CORS_ADMIN_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvAsDuration("CORS_ADMIN_MAX_AGE", "10m"),
+		},
+	}
+	if err := cfg.CORS.Public.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid CORS policy: %w", err)
+	}
+	if err := cfg.CORS.Admin.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid admin CORS policy: %w", err)
 	}
 
+	cfg.Jobs = Jobs{
+		Concurrency:  getEnvAsInt("JOBS_CONCURRENCY", 2),
+		PollInterval: getEnvAsDuration("JOBS_POLL_INTERVAL", "5s"),
+		MaxAttempts:  getEnvAsInt("JOBS_MAX_ATTEMPTS", 3),
+		RetryBackoff: getEnvAsDuration("JOBS_RETRY_BACKOFF", "1m"),
+		Retention:    getEnvAsDuration("JOBS_RETENTION", "720h"),
+	}
+	if cfg.Jobs.Concurrency < 1 || cfg.Jobs.MaxAttempts < 1 || cfg.Jobs.PollInterval <= 0 {
+		return nil, fmt.Errorf("invalid jobs configuration: JOBS_CONCURRENCY, JOBS_MAX_ATTEMPTS and JOBS_POLL_INTERVAL must be positive")
+	}
+	schedules, err := ParseJobSchedules(getEnv("JOBS_SCHEDULES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOBS_SCHEDULES: %w", err)
+	}
+	cfg.Jobs.Schedules = schedules
+
+	cfg.Backup = Backup{
+		Target: getEnv("BACKUP_TARGET", "backups"),
+		Keep:   getEnvAsInt("BACKUP_KEEP", 7),
+		S3: S3{
+			Endpoint:        getEnv("BACKUP_S3_ENDPOINT", "s3.amazonaws.com"),
+			Region:          getEnv("BACKUP_S3_REGION", ""),
+			AccessKeyID:     getEnv("BACKUP_S3_ACCESS_KEY_ID", ""),
+			SecretAccessKey: getEnv("BACKUP_S3_SECRET_ACCESS_KEY", ""),
+			Insecure:        getEnvAsBool("BACKUP_S3_INSECURE", false),
+		},
+	}
+	if cfg.Backup.Target == "" || cfg.Backup.Keep < 0 {
+		return nil, fmt.Errorf("invalid backup configuration: BACKUP_TARGET must be set and BACKUP_KEEP must not be negative")
+	}
+
+	cfg.Snapshot = Snapshot{
+		Source: getEnv("SNAPSHOT_SOURCE", ""),
+		SHA256: strings.ToLower(getEnv("SNAPSHOT_SHA256", "")),
+	}
+	if digest, err := hex.DecodeString(cfg.Snapshot.SHA256); err != nil || (len(digest) != 0 && len(digest) != sha256.Size) {
+		return nil, fmt.Errorf("invalid SNAPSHOT_SHA256: must be a hex-encoded SHA-256 digest")
+	}
+
+	cfg.RateLimit = RateLimit{
+		Enabled: getEnvAsBool("RATE_LIMIT_ENABLED", true),
+		Backend: getEnv("RATE_LIMIT_BACKEND", "memory"),
+		PreAuth: Rate{PerSecond: getEnvAsFloat("RATE_LIMIT_PREAUTH_RPS", 200), Burst: getEnvAsInt("RATE_LIMIT_PREAUTH_BURST", 400)},
+		Default: RateLimitRule{
+			IP:  Rate{PerSecond: getEnvAsFloat("RATE_LIMIT_IP_RPS", 20), Burst: getEnvAsInt("RATE_LIMIT_IP_BURST", 40)},
+			Key: Rate{PerSecond: getEnvAsFloat("RATE_LIMIT_KEY_RPS", 100), Burst: getEnvAsInt("RATE_LIMIT_KEY_BURST", 200)},
+		},
+	}
+	routes, err := ParseRateLimitRoutes(getEnv("RATE_LIMIT_ROUTES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ROUTES: %w", err)
+	}
+	cfg.RateLimit.Routes = routes
+
 	return cfg, nil
 }
 
+// ParseRoleScopes parses role mappings of the form
+// "admin=admin, analyst=read:delegations|read:stats".
+func ParseRoleScopes(value string) (map[string][]string, error) {
+	roles := make(map[string][]string)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		role, spec, ok := strings.Cut(entry, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("%q: expected role=scope[|scope]", entry)
+		}
+
+		var scopes []string
+		for _, scope := range strings.Split(spec, "|") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+		if len(scopes) == 0 {
+			return nil, fmt.Errorf("%q: no scopes", entry)
+		}
+		roles[role] = scopes
+	}
+
+	return roles, nil
+}
+
+// ParseJobSchedules parses job schedules of the form
+// "backup=0 3 * * *; verify_sync=@daily". Entries are separated by
+// semicolons, as cron expressions may contain commas.
+func ParseJobSchedules(value string) (map[string]string, error) {
+	schedules := make(map[string]string)
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kind, spec, ok := strings.Cut(entry, "=")
+		kind, spec = strings.TrimSpace(kind), strings.TrimSpace(spec)
+		if !ok || kind == "" || spec == "" {
+			return nil, fmt.Errorf("%q: expected kind=cron expression", entry)
+		}
+		schedules[kind] = spec
+	}
+
+	return schedules, nil
+}
+
+// ParseRouteTimeouts parses route timeouts of the form
+// "/xtz/stats/timeseries=10s, GET /graphql=30s".
+func ParseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, spec, ok := strings.Cut(entry, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			return nil, fmt.Errorf("%q: expected route=duration", entry)
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(spec))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("%q: invalid duration %q", entry, spec)
+		}
+		timeouts[route] = timeout
+	}
+
+	return timeouts, nil
+}
+
+// ParseRateLimitRoutes parses route limits of the form
+// "/xtz/delegations/stream=1:5, POST /graphql=5:10/20:40", where each rule
+// is rate:burst for anonymous clients, optionally followed by /rate:burst
+// for API key clients. Without the second part both get the same limit.
+func ParseRateLimitRoutes(value string) (map[string]RateLimitRule, error) {
+	routes := make(map[string]RateLimitRule)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, spec, ok := strings.Cut(entry, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			return nil, fmt.Errorf("%q: expected route=rate:burst", entry)
+		}
+
+		ipSpec, keySpec, hasKey := strings.Cut(spec, "/")
+		var rule RateLimitRule
+		var err error
+		if rule.IP, err = parseRate(ipSpec); err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		rule.Key = rule.IP
+		if hasKey {
+			if rule.Key, err = parseRate(keySpec); err != nil {
+				return nil, fmt.Errorf("%q: %w", entry, err)
+			}
+		}
+
+		routes[route] = rule
+	}
+
+	return routes, nil
+}
+
+func parseRate(spec string) (Rate, error) {
+	perSecond, burst, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		return Rate{}, fmt.Errorf("expected rate:burst, got %q", spec)
+	}
+
+	var rate Rate
+	var err error
+	if rate.PerSecond, err = strconv.ParseFloat(perSecond, 64); err != nil || rate.PerSecond < 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q", perSecond)
+	}
+	if rate.Burst, err = strconv.Atoi(burst); err != nil || rate.Burst < 1 {
+		return Rate{}, fmt.Errorf("invalid burst %q", burst)
+	}
+	return rate, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -104,6 +616,37 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsInt64(key string, defaultValue int64) int64 {
+	valueStr := os.Getenv(key)
+	if value, err := strconv.ParseInt(valueStr, 10, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsList splits a comma-separated variable, dropping empty items.
+func getEnvAsList(key string) []string {
+	return splitList(os.Getenv(key))
+}
+
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	valueStr := os.Getenv(key)
 	if value, err := strconv.ParseBool(valueStr); err == nil {
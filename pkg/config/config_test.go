@@ -0,0 +1,106 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimitRoutes(t *testing.T) {
+	routes, err := ParseRateLimitRoutes(" /xtz/delegations/stream=1:5, POST /graphql=0.5:10/20:40,/stats=0:1 ")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]RateLimitRule{
+		"/xtz/delegations/stream": {IP: Rate{PerSecond: 1, Burst: 5}, Key: Rate{PerSecond: 1, Burst: 5}},
+		"POST /graphql":           {IP: Rate{PerSecond: 0.5, Burst: 10}, Key: Rate{PerSecond: 20, Burst: 40}},
+		"/stats":                  {IP: Rate{PerSecond: 0, Burst: 1}, Key: Rate{PerSecond: 0, Burst: 1}},
+	}, routes)
+
+	routes, err = ParseRateLimitRoutes("")
+	require.NoError(t, err)
+	assert.Empty(t, routes)
+
+	for _, invalid := range []string{"/graphql", "=1:5", "/graphql=1", "/graphql=x:5", "/graphql=1:0", "/graphql=-1:5", "/graphql=1:5/2"} {
+		_, err := ParseRateLimitRoutes(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseRouteTimeouts(t *testing.T) {
+	timeouts, err := ParseRouteTimeouts(" /xtz/stats/timeseries=10s, POST /graphql=1m,/xtz/delegations/stream=0 ")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]time.Duration{
+		"/xtz/stats/timeseries":   10 * time.Second,
+		"POST /graphql":           time.Minute,
+		"/xtz/delegations/stream": 0,
+	}, timeouts)
+
+	for _, invalid := range []string{"/graphql", "=1s", "/graphql=1", "/graphql=-1s"} {
+		_, err := ParseRouteTimeouts(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseJobSchedules(t *testing.T) {
+	schedules, err := ParseJobSchedules(" backup=0 3 * * 1,4 ; verify_sync = @daily;")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"backup":      "0 3 * * 1,4",
+		"verify_sync": "@daily",
+	}, schedules)
+
+	for _, invalid := range []string{"backup", "=@daily", "backup="} {
+		_, err := ParseJobSchedules(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseRoleScopes(t *testing.T) {
+	roles, err := ParseRoleScopes(" admin=admin, analyst = read:delegations | read:stats ")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]string{
+		"admin":   {"admin"},
+		"analyst": {"read:delegations", "read:stats"},
+	}, roles)
+
+	for _, invalid := range []string{"admin", "=admin", "admin=", "admin=|"} {
+		_, err := ParseRoleScopes(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestJWT_Validate(t *testing.T) {
+	assert.NoError(t, JWT{}.Validate())
+	assert.NoError(t, JWT{JWKSURL: "https://id.example.com/jwks", Issuer: "https://id.example.com/", Audience: "api"}.Validate())
+
+	for _, invalid := range []JWT{
+		{JWKSURL: "https://id.example.com/jwks", Audience: "api"},
+		{KeyFile: "jwt.pem", Issuer: "https://id.example.com/"},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestCORSPolicy_Validate(t *testing.T) {
+	valid := CORSPolicy{AllowedOrigins: []string{"*", "https://app.example.com", "https://*.example.com", "http://localhost:3000"}}
+	assert.NoError(t, valid.Validate())
+
+	credentials := CORSPolicy{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}
+	assert.NoError(t, credentials.Validate())
+
+	for _, invalid := range []CORSPolicy{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"example.com"}},
+		{AllowedOrigins: []string{"https://example.com/app"}},
+		{AllowedOrigins: []string{"https://*"}},
+		{AllowedOrigins: []string{"https://app.*.example.com"}},
+		{MaxAge: -time.Second},
+	} {
+		assert.Error(t, invalid.Validate(), invalid.AllowedOrigins)
+	}
+}
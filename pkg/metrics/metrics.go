@@ -73,6 +73,117 @@ var (
 			Help: "Progress of historical indexing (0-100)",
 		},
 	)
+
+	HubSubscribers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tezos_hub_subscribers",
+			Help: "Number of in-process subscribers to newly indexed delegations",
+		},
+	)
+
+	HubDroppedSubscribers = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tezos_hub_dropped_subscribers_total",
+			Help: "The total number of subscribers dropped for not keeping up",
+		},
+	)
+
+	WebSocketClients = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tezos_websocket_clients",
+			Help: "Number of connected WebSocket clients",
+		},
+	)
+
+	WebSocketSubscriptions = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tezos_websocket_subscriptions",
+			Help: "Number of active WebSocket subscriptions",
+		},
+	)
+
+	WebSocketMessagesSent = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tezos_websocket_messages_sent_total",
+			Help: "The total number of delegation messages sent to WebSocket clients",
+		},
+	)
+
+	WebhookDeliveryAttempts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tezos_webhook_delivery_attempts_total",
+			Help: "The total number of webhook delivery attempts by outcome",
+		},
+		[]string{"result"},
+	)
+
+	JobRuns = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tezos_job_runs_total",
+			Help: "The total number of background job attempts by kind and outcome",
+		},
+		[]string{"kind", "result"},
+	)
+
+	OutboxEventsPublished = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tezos_outbox_events_published_total",
+			Help: "The total number of outbox events relayed to the event bus",
+		},
+	)
+
+	OutboxRelayErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tezos_outbox_relay_errors_total",
+			Help: "The total number of failed outbox relay attempts",
+		},
+	)
+
+	WebhookDeliveryDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "tezos_webhook_delivery_duration_seconds",
+			Help:    "Duration of webhook delivery requests in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	GRPCRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "tezos_grpc_request_duration_seconds",
+			Help:    "Duration of gRPC calls in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "code"},
+	)
+
+	GRPCWatchStreams = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tezos_grpc_watch_streams",
+			Help: "Number of open WatchDelegations streams",
+		},
+	)
+
+	ChainHeadLevel = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tezos_chain_head_level",
+			Help: "Chain head level last observed on TzKT",
+		},
+	)
+
+	IndexerSyncedLevel = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tezos_indexer_synced_level",
+			Help: "Chain head level the indexer last caught up with",
+		},
+	)
+
+	RateLimitedRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tezos_rate_limited_requests_total",
+			Help: "The total number of requests rejected by the rate limiter",
+		},
+		[]string{"route"},
+	)
 )
 
 func RecordAPIRequest(endpoint, method string, status int, duration float64) {
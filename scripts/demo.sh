@@ -9,6 +9,8 @@ echo "This demo script shows how to test the Tezos Delegation Service API."
 echo ""
 
 BASE_URL="${1:-http://localhost:8080}"
+# API key with the read:stats scope, needed for /metrics
+API_KEY="${2:-$API_KEY}"
 
 echo "Using API URL: $BASE_URL"
 echo ""
@@ -16,7 +18,7 @@ echo ""
 # Check if service is running
 echo "1. Checking service health..."
 echo "   GET $BASE_URL/health"
-curl -s "$BASE_URL/health" | jq . || echo "Service not running. Please start with 'docker-compose up' or 'go run cmd/server/main.go'"
+curl -s "$BASE_URL/health" | jq . || echo "Service not running. Please start with 'docker-compose up' or 'go run ./cmd/server'"
 echo ""
 
 echo "2. Getting all delegations..."
@@ -41,7 +43,7 @@ echo ""
 
 echo "6. Getting Prometheus metrics..."
 echo "   GET $BASE_URL/metrics"
-curl -s "$BASE_URL/metrics" | head -20 || echo "Failed to get metrics"
+curl -s -H "X-API-Key: $API_KEY" "$BASE_URL/metrics" | head -20 || echo "Failed to get metrics"
 echo ""
 
 echo "✅ Demo complete!"
@@ -71,12 +71,11 @@ restore_backup() {
         DELEGATION_COUNT=$(echo $DELEGATION_COUNT | tr -d ' ')
         
         if [ "$DELEGATION_COUNT" = "0" ] || [ -z "$DELEGATION_COUNT" ]; then
-            if [ -f /app/backups/latest.sql.gz ]; then
-                print_warning "Restoring database from backup..."
-                zcat /app/backups/latest.sql.gz | psql "${DATABASE_URL}"
+            print_warning "Restoring database from the latest backup..."
+            if /app/tezos-delegation-service restore; then
                 print_status "Database restored from backup"
             else
-                print_warning "No backup found, starting with empty database"
+                print_warning "No backup restored, starting with empty database"
             fi
         else
             print_status "Database already contains $DELEGATION_COUNT delegations, skipping restore"
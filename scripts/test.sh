@@ -22,7 +22,7 @@ echo -e "\n${GREEN}✅ All tests passed!${NC}"
 
 # Build binary
 echo -e "\n${YELLOW}Building binary...${NC}"
-go build -o bin/tezos-delegation-service cmd/server/main.go || {
+go build -o bin/tezos-delegation-service ./cmd/server || {
     echo -e "${RED}Build failed!${NC}"
     exit 1
 }